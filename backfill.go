@@ -0,0 +1,172 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+	"github.com/prometheus-community/stackdriver_exporter/delta"
+)
+
+// backfillHandler serves the POST requests of a one-off backfill of the metric types starting with the prefix param
+// over the window from the start param to the end one, both RFC 3339 times. Every point of the window is answered as
+// an OpenMetrics sample timestamped with its end time, e.g. to be imported by promtool tsdb
+// create-blocks-from openmetrics, as a registry only holds the latest sample of a series. The prefix must be one of the
+// configured metric type prefixes or narrower, the window must not be longer than maxWindow and the backfill is
+// cancelled after timeout.
+func backfillHandler(h *handler, maxWindow, timeout time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		prefix, start, end, err := parseBackfillParams(r, maxWindow)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !h.configuredPrefix(prefix) {
+			http.Error(w, fmt.Sprintf("invalid prefix %q, it must start with one of the configured metric type prefixes", prefix), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		var families []*dto.MetricFamily
+		for _, project := range h.projectIDs {
+			collector, err := h.backfillCollector(project, prefix)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("failed to create the backfill collector: %s", err), http.StatusInternalServerError)
+				return
+			}
+			projectFamilies, err := collector.Backfill(ctx, prefix, start, end)
+			if err != nil {
+				h.logger.Error("Error backfilling the metrics", "project_id", project, "prefix", prefix, "err", err)
+				http.Error(w, fmt.Sprintf("failed to backfill the metrics of project %s: %s", project, err), http.StatusInternalServerError)
+				return
+			}
+			families = append(families, projectFamilies...)
+		}
+
+		w.Header().Set("Content-Type", string(expfmt.NewFormat(expfmt.TypeOpenMetrics)))
+		for _, family := range mergeMetricFamilies(families) {
+			if _, err := expfmt.MetricFamilyToOpenMetrics(w, family); err != nil {
+				h.logger.Error("Error writing the backfilled metrics", "err", err)
+				return
+			}
+		}
+		if _, err := expfmt.FinalizeOpenMetrics(w); err != nil {
+			h.logger.Error("Error writing the backfilled metrics", "err", err)
+		}
+	}
+}
+
+// parseBackfillParams returns the metric type prefix and the window of a backfill request, at most maxWindow long.
+func parseBackfillParams(r *http.Request, maxWindow time.Duration) (prefix string, start, end time.Time, err error) {
+	query := r.URL.Query()
+	prefix = query.Get("prefix")
+	if prefix == "" {
+		return "", time.Time{}, time.Time{}, errors.New("the prefix param is required")
+	}
+	if start, err = time.Parse(time.RFC3339, query.Get("start")); err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid start param: %w", err)
+	}
+	if end, err = time.Parse(time.RFC3339, query.Get("end")); err != nil {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid end param: %w", err)
+	}
+	if !end.After(start) {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid window, the end %s must be after the start %s", end, start)
+	}
+	if window := end.Sub(start); window > maxWindow {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("invalid window of %s, it must not be longer than %s", window, maxWindow)
+	}
+	return prefix, start, end, nil
+}
+
+// configuredPrefix returns whether the metric types starting with prefix are all selected by a configured metric
+// type prefix, so that a backfill does not list more metrics than the scrapes.
+func (h *handler) configuredPrefix(prefix string) bool {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	for _, configured := range h.metricsPrefixes {
+		if strings.HasPrefix(prefix, configured) {
+			return true
+		}
+	}
+	return false
+}
+
+// backfillCollector returns a collector of the project dedicated to a backfill of the metric types starting with
+// prefix. It has its own delta stores, and reports the raw DELTA points with their end time.
+func (h *handler) backfillCollector(project, prefix string) (*collectors.MonitoringCollector, error) {
+	reloadable := h.reloadableOptions("", nil)
+	reloadable.MetricTypePrefixes = []string{prefix}
+	opts := h.collectorOptions(reloadable)
+	opts.AggregateDeltas = false
+	opts.OmitPointTimestamps = false
+	opts.SkipUnchangedSeries = false
+	opts.CircuitBreakerFailures = 0
+	return collectors.NewMonitoringCollector(project, h.m.forProject(project), opts, h.logger,
+		delta.NewInMemoryCounterStore(h.logger, 0), delta.NewInMemoryHistogramStore(h.logger, 0))
+}
+
+// mergeMetricFamilies merges the metric families of the same name, sorted by name, their metrics being sorted by
+// labels then timestamp as OpenMetrics expects the samples of a series together and in order.
+func mergeMetricFamilies(families []*dto.MetricFamily) []*dto.MetricFamily {
+	byName := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		merged, ok := byName[family.GetName()]
+		if !ok {
+			merged = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type, Unit: family.Unit}
+			byName[family.GetName()] = merged
+		}
+		merged.Metric = append(merged.Metric, family.Metric...)
+	}
+
+	merged := make([]*dto.MetricFamily, 0, len(byName))
+	for _, family := range byName {
+		sort.SliceStable(family.Metric, func(i, j int) bool {
+			if left, right := labelsKey(family.Metric[i]), labelsKey(family.Metric[j]); left != right {
+				return left < right
+			}
+			return family.Metric[i].GetTimestampMs() < family.Metric[j].GetTimestampMs()
+		})
+		merged = append(merged, family)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].GetName() < merged[j].GetName() })
+	return merged
+}
+
+// labelsKey returns the label pairs of a metric, sorted by name as gathered, as a string.
+func labelsKey(metric *dto.Metric) string {
+	var key strings.Builder
+	for _, pair := range metric.GetLabel() {
+		key.WriteString(pair.GetName())
+		key.WriteByte(0)
+		key.WriteString(pair.GetValue())
+		key.WriteByte(0)
+	}
+	return key.String()
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+func TestBackfillHandler(t *testing.T) {
+	metricType := "custom.googleapis.com/app/requests"
+	var mu sync.Mutex
+	var windows [][2]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var response any
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			response = &monitoring.ListMetricDescriptorsResponse{MetricDescriptors: []*monitoring.MetricDescriptor{
+				{Name: metricType, Type: metricType, MetricKind: "GAUGE", ValueType: "DOUBLE"},
+			}}
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			mu.Lock()
+			windows = append(windows, [2]string{r.URL.Query().Get("interval.startTime"), r.URL.Query().Get("interval.endTime")})
+			mu.Unlock()
+			older, newer := 1.0, 2.0
+			response = &monitoring.ListTimeSeriesResponse{TimeSeries: []*monitoring.TimeSeries{{
+				Metric:     &monitoring.Metric{Type: metricType},
+				Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "my-project"}},
+				MetricKind: "GAUGE",
+				ValueType:  "DOUBLE",
+				// The points are listed newest first
+				Points: []*monitoring.Point{
+					{Interval: &monitoring.TimeInterval{EndTime: "2026-01-01T02:00:00Z"}, Value: &monitoring.TypedValue{DoubleValue: &newer}},
+					{Interval: &monitoring.TimeInterval{EndTime: "2026-01-01T01:00:00Z"}, Value: &monitoring.TypedValue{DoubleValue: &older}},
+				},
+			}}}
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"custom.googleapis.com/app", "compute.googleapis.com/instance"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	handler := backfillHandler(h, 6*time.Hour, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/-/backfill?prefix=custom.googleapis.com/app&start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the backfill to succeed, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if len(windows) != 1 || windows[0] != [2]string{"2026-01-01T00:00:00Z", "2026-01-01T03:00:00Z"} {
+		t.Errorf("expected the time series to be listed over the requested window, got %v", windows)
+	}
+	body := recorder.Body.String()
+	for _, sample := range []string{
+		`stackdriver_gce_instance_custom_googleapis_com_app_requests{project_id="my-project",unit=""} 1.0 1.7672292e+09`,
+		`stackdriver_gce_instance_custom_googleapis_com_app_requests{project_id="my-project",unit=""} 2.0 1.7672328e+09`,
+	} {
+		if !strings.Contains(body, sample) {
+			t.Errorf("expected the backfill to contain %q, got %s", sample, body)
+		}
+	}
+	if strings.Index(body, "} 1.0 ") > strings.Index(body, "} 2.0 ") {
+		t.Errorf("expected the samples in timestamp order, got %s", body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("expected the backfill to be finalized, got %s", body)
+	}
+
+	for _, tc := range []struct {
+		method string
+		query  string
+		code   int
+	}{
+		{http.MethodGet, "prefix=custom.googleapis.com/app&start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", http.StatusMethodNotAllowed},
+		{http.MethodPost, "start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", http.StatusBadRequest},
+		{http.MethodPost, "prefix=custom.googleapis.com/app&start=yesterday&end=2026-01-01T03:00:00Z", http.StatusBadRequest},
+		{http.MethodPost, "prefix=custom.googleapis.com/app&start=2026-01-01T03:00:00Z&end=2026-01-01T00:00:00Z", http.StatusBadRequest},
+		{http.MethodPost, "prefix=custom.googleapis.com/app&start=2026-01-01T00:00:00Z&end=2026-01-01T07:00:00Z", http.StatusBadRequest},
+		{http.MethodPost, "prefix=custom.googleapis.com&start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", http.StatusBadRequest},
+		{http.MethodPost, "prefix=custom.googleapis.com/other&start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", http.StatusBadRequest},
+	} {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest(tc.method, "/-/backfill?"+tc.query, nil))
+		if recorder.Code != tc.code {
+			t.Errorf("expected a %s of %q to be answered with %d, got %d", tc.method, tc.query, tc.code, recorder.Code)
+		}
+	}
+
+	recorder = httptest.NewRecorder()
+	backfillHandler(h, 6*time.Hour, time.Nanosecond)(recorder, httptest.NewRequest(http.MethodPost, "/-/backfill?prefix=custom.googleapis.com/app&start=2026-01-01T00:00:00Z&end=2026-01-01T03:00:00Z", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected a backfill timing out to fail, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
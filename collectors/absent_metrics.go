@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/monitoring/v3"
+)
+
+// hasDeltaEntries reports whether the delta stores hold entries of a descriptor, which are reported whether or not it
+// has time series in the scrape.
+func (c *MonitoringCollector) hasDeltaEntries(metricDescriptor *monitoring.MetricDescriptor) bool {
+	return len(c.counterStore.ListMetrics(metricDescriptor.Name)) > 0 || len(c.histogramStore.ListMetrics(metricDescriptor.Name)) > 0
+}
+
+// reportAbsentMetric reports a placeholder metric, without labels, for each monitored resource type of a descriptor
+// having no time series, so that the metric names, their HELP and TYPE, stay exposed. The placeholders of the
+// counters and gauges have a NaN value, those of the distributions are histograms without observations. The
+// descriptors without monitored resource types can't be named and have none.
+func (c *MonitoringCollector) reportAbsentMetric(metricDescriptor *monitoring.MetricDescriptor, ch chan<- prometheus.Metric) {
+	if metricDescriptor.ValueType == "STRING" && !c.emitStringMetrics {
+		return
+	}
+	valueType := prometheus.GaugeValue
+	if metricDescriptor.MetricKind == "CUMULATIVE" || (metricDescriptor.MetricKind == "DELTA" && c.aggregatesDeltas(metricDescriptor.Type)) {
+		valueType = prometheus.CounterValue
+	}
+
+	help := metricHelp(metricDescriptor)
+	unitSuffix := c.unitSuffix(metricDescriptor)
+	for _, resourceType := range metricDescriptor.MonitoredResourceTypes {
+		fqName := buildFQName(c.metricPrefix, &monitoring.TimeSeries{
+			Metric:   &monitoring.Metric{Type: metricDescriptor.Type},
+			Resource: &monitoring.MonitoredResource{Type: resourceType},
+		}, unitSuffix)
+		desc := prometheus.NewDesc(fqName, help, nil, nil)
+		if metricDescriptor.ValueType == "DISTRIBUTION" {
+			ch <- prometheus.MustNewConstHistogram(desc, 0, 0, nil)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(desc, valueType, math.NaN())
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+func TestMonitoringCollector_AbsentMetrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	newAPI := func() *fakeMonitoringAPI {
+		api := newFakeAPIWithDescriptors(1)
+		api.descriptors = append(api.descriptors,
+			&monitoring.MetricDescriptor{
+				Name:                   "projects/test-project/metricDescriptors/custom.googleapis.com/requests",
+				Type:                   "custom.googleapis.com/requests",
+				Description:            "Number of requests served.",
+				MetricKind:             "CUMULATIVE",
+				ValueType:              "INT64",
+				MonitoredResourceTypes: []string{"gce_instance"},
+			},
+			&monitoring.MetricDescriptor{
+				Type:                   "custom.googleapis.com/latencies",
+				MetricKind:             "GAUGE",
+				ValueType:              "DISTRIBUTION",
+				MonitoredResourceTypes: []string{"gce_instance", "gae_app"},
+			},
+		)
+		return api
+	}
+	// gather runs a full collection of the fake API and returns the text exposition of the collected metrics
+	gather := func(t *testing.T, api *fakeMonitoringAPI, emitAbsentMetrics bool, counterStore *testCounterStore) string {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes: []string{"custom.googleapis.com"},
+			RequestInterval:    time.Minute,
+			EmitAbsentMetrics:  emitAbsentMetrics,
+		}, logger, counterStore, &testHistogramStore{})
+		require.NoError(t, err)
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(c)
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		var exposition strings.Builder
+		for _, family := range families {
+			_, err := expfmt.MetricFamilyToText(&exposition, family)
+			require.NoError(t, err)
+		}
+		return exposition.String()
+	}
+
+	text := gather(t, newAPI(), true, &testCounterStore{})
+	assert.Contains(t, text, "# HELP stackdriver_gce_instance_custom_googleapis_com_requests Number of requests served.\n"+
+		"# TYPE stackdriver_gce_instance_custom_googleapis_com_requests counter\n"+
+		"stackdriver_gce_instance_custom_googleapis_com_requests NaN\n")
+	for _, fqName := range []string{"stackdriver_gce_instance_custom_googleapis_com_latencies", "stackdriver_gae_app_custom_googleapis_com_latencies"} {
+		assert.Contains(t, text, "# TYPE "+fqName+" histogram\n", "every monitored resource type should have a placeholder")
+		assert.Contains(t, text, fqName+"_count 0\n")
+	}
+	assert.Contains(t, text, `stackdriver_gce_instance_custom_googleapis_com_metric_00{instance="a"`)
+	assert.NotContains(t, text, "stackdriver_gce_instance_custom_googleapis_com_metric_00 NaN", "a descriptor with time series should have no placeholder")
+
+	t.Run("disabled", func(t *testing.T) {
+		text := gather(t, newAPI(), false, &testCounterStore{})
+		assert.NotContains(t, text, "custom_googleapis_com_requests")
+		assert.NotContains(t, text, "custom_googleapis_com_latencies")
+	})
+
+	t.Run("failed fetch", func(t *testing.T) {
+		api := newAPI()
+		api.timeSeriesHook = func(r *http.Request) int {
+			if strings.Contains(r.URL.Query().Get("filter"), "custom.googleapis.com/requests") {
+				return http.StatusInternalServerError
+			}
+			return 0
+		}
+		text := gather(t, api, true, &testCounterStore{})
+		assert.NotContains(t, text, "custom_googleapis_com_requests", "a descriptor whose fetch failed should have no placeholder")
+		assert.Contains(t, text, "stackdriver_gae_app_custom_googleapis_com_latencies_count 0\n")
+	})
+
+	t.Run("delta entries", func(t *testing.T) {
+		counterStore := &testCounterStore{metrics: map[string][]*ConstMetric{
+			"projects/test-project/metricDescriptors/custom.googleapis.com/requests": {{
+				FqName:      "stackdriver_gce_instance_custom_googleapis_com_requests",
+				LabelKeys:   []string{"instance"},
+				LabelValues: []string{"a"},
+				ValueType:   prometheus.CounterValue,
+				Value:       3,
+				ReportTime:  time.Now(),
+			}},
+		}}
+		text := gather(t, newAPI(), true, counterStore)
+		assert.NotContains(t, text, "stackdriver_gce_instance_custom_googleapis_com_requests NaN", "a descriptor with delta entries should have no placeholder")
+	})
+}
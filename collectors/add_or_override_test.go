@@ -171,7 +171,7 @@ func TestMonitoringCollector_AddOrOverride(t *testing.T) {
 			copy(labelValues, tt.initialValues)
 
 			// Call the function under test
-			collector.addOrOverrideLabels(&labelKeys, &labelValues, tt.addKey, tt.addValue, tt.override)
+			withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addOrOverrideLabels(labels, tt.addKey, tt.addValue, tt.override) })
 
 			// Verify results
 			assert.Equal(t, tt.expectedKeys, labelKeys, tt.description+" - keys mismatch")
@@ -193,34 +193,28 @@ func TestMonitoringCollector_AddOrOverride_Integration(t *testing.T) {
 		labelValues := []string{"initial_value"}
 
 		// Add new key
-		collector.addOrOverrideLabels(&labelKeys, &labelValues, "new1", "value1", false)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "new1", "value1", false) })
 		assert.Equal(t, []string{"initial", "new1"}, labelKeys)
 		assert.Equal(t, []string{"initial_value", "value1"}, labelValues)
 
 		// Try to override without permission
-		collector.addOrOverrideLabels(&labelKeys, &labelValues, "initial", "changed", false)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "initial", "changed", false) })
 		assert.Equal(t, []string{"initial", "new1"}, labelKeys)
 		assert.Equal(t, []string{"initial_value", "value1"}, labelValues)
 
 		// Override with permission
-		collector.addOrOverrideLabels(&labelKeys, &labelValues, "initial", "changed", true)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "initial", "changed", true) })
 		assert.Equal(t, []string{"initial", "new1"}, labelKeys)
 		assert.Equal(t, []string{"changed", "value1"}, labelValues)
 
 		// Add another new key
-		collector.addOrOverrideLabels(&labelKeys, &labelValues, "new2", "value2", true)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "new2", "value2", true) })
 		assert.Equal(t, []string{"initial", "new1", "new2"}, labelKeys)
 		assert.Equal(t, []string{"changed", "value1", "value2"}, labelValues)
 	})
 }
 
 func TestMonitoringCollector_FindKeyIndex(t *testing.T) {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-
-	collector := &MonitoringCollector{
-		logger: logger,
-	}
-
 	tests := []struct {
 		name          string
 		keys          []string
@@ -288,7 +282,7 @@ func TestMonitoringCollector_FindKeyIndex(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := collector.findKeyIndex(tt.keys, tt.searchKey)
+			result := (&labelSet{keys: tt.keys}).IndexOf(tt.searchKey)
 			assert.Equal(t, tt.expectedIndex, result, tt.description)
 		})
 	}
@@ -312,7 +306,7 @@ func BenchmarkMonitoringCollector_AddOrOverride(b *testing.B) {
 			copy(keys, labelKeys)
 			copy(values, labelValues)
 
-			collector.addOrOverrideLabels(&keys, &values, "new_key", "new_value", false)
+			withLabelSet(&keys, &values, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "new_key", "new_value", false) })
 		}
 	})
 
@@ -323,7 +317,7 @@ func BenchmarkMonitoringCollector_AddOrOverride(b *testing.B) {
 			copy(keys, labelKeys)
 			copy(values, labelValues)
 
-			collector.addOrOverrideLabels(&keys, &values, "key3", "new_value", true)
+			withLabelSet(&keys, &values, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "key3", "new_value", true) })
 		}
 	})
 
@@ -334,7 +328,7 @@ func BenchmarkMonitoringCollector_AddOrOverride(b *testing.B) {
 			copy(keys, labelKeys)
 			copy(values, labelValues)
 
-			collector.addOrOverrideLabels(&keys, &values, "key3", "new_value", false)
+			withLabelSet(&keys, &values, func(labels *labelSet) { collector.addOrOverrideLabels(labels, "key3", "new_value", false) })
 		}
 	})
 }
@@ -0,0 +1,115 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/monitoring/v3"
+)
+
+const (
+	alignNone  = "ALIGN_NONE"
+	reduceNone = "REDUCE_NONE"
+	// minAlignmentPeriod is the shortest alignment period accepted by the API.
+	minAlignmentPeriod = time.Minute
+)
+
+// perSeriesAligners are the aligners accepted by the API.
+// @see https://cloud.google.com/monitoring/api/ref_v3/rest/v3/projects.alertPolicies#Aligner
+var perSeriesAligners = []string{
+	"ALIGN_NONE", "ALIGN_DELTA", "ALIGN_RATE", "ALIGN_INTERPOLATE", "ALIGN_NEXT_OLDER",
+	"ALIGN_MIN", "ALIGN_MAX", "ALIGN_MEAN", "ALIGN_COUNT", "ALIGN_SUM", "ALIGN_STDDEV",
+	"ALIGN_COUNT_TRUE", "ALIGN_COUNT_FALSE", "ALIGN_FRACTION_TRUE",
+	"ALIGN_PERCENTILE_99", "ALIGN_PERCENTILE_95", "ALIGN_PERCENTILE_50", "ALIGN_PERCENTILE_05",
+	"ALIGN_PERCENT_CHANGE",
+}
+
+// crossSeriesReducers are the reducers accepted by the API.
+// @see https://cloud.google.com/monitoring/api/ref_v3/rest/v3/projects.alertPolicies#Reducer
+var crossSeriesReducers = []string{
+	"REDUCE_NONE", "REDUCE_MEAN", "REDUCE_MIN", "REDUCE_MAX", "REDUCE_SUM", "REDUCE_STDDEV",
+	"REDUCE_COUNT", "REDUCE_COUNT_TRUE", "REDUCE_COUNT_FALSE", "REDUCE_FRACTION_TRUE",
+	"REDUCE_PERCENTILE_99", "REDUCE_PERCENTILE_95", "REDUCE_PERCENTILE_50", "REDUCE_PERCENTILE_05",
+}
+
+// Aggregation is a server-side aggregation requested for the time series of the metric types starting with
+// TargetedMetricPrefix. An empty TargetedMetricPrefix applies the aggregation to every metric type.
+// @see https://cloud.google.com/monitoring/api/ref_v3/rest/v3/projects.timeSeries/list#Aggregation
+type Aggregation struct {
+	TargetedMetricPrefix string
+	// AlignmentPeriod is the duration of the blocks of time each time series is aligned on.
+	AlignmentPeriod time.Duration
+	// PerSeriesAligner aligns the points of each time series on the alignment period, e.g. ALIGN_RATE.
+	PerSeriesAligner string
+	// CrossSeriesReducer combines the aligned time series into one time series per group, e.g. REDUCE_SUM.
+	CrossSeriesReducer string
+	// GroupByFields are the fields preserved when reducing, e.g. resource.label.zone.
+	GroupByFields []string
+}
+
+// Validate checks the aggregation is one the API accepts.
+func (a Aggregation) Validate() error {
+	if !slices.Contains(perSeriesAligners, a.PerSeriesAligner) {
+		return fmt.Errorf("invalid per-series aligner %q for prefix %q", a.PerSeriesAligner, a.TargetedMetricPrefix)
+	}
+	if a.CrossSeriesReducer != "" && !slices.Contains(crossSeriesReducers, a.CrossSeriesReducer) {
+		return fmt.Errorf("invalid cross-series reducer %q for prefix %q", a.CrossSeriesReducer, a.TargetedMetricPrefix)
+	}
+	if a.PerSeriesAligner != alignNone && a.AlignmentPeriod < minAlignmentPeriod {
+		return fmt.Errorf("aligner %s for prefix %q requires an alignment period of at least %s, got %s", a.PerSeriesAligner, a.TargetedMetricPrefix, minAlignmentPeriod, a.AlignmentPeriod)
+	}
+	if a.reduces() && a.PerSeriesAligner == alignNone {
+		return fmt.Errorf("reducer %s for prefix %q cannot be combined with aligner %s", a.CrossSeriesReducer, a.TargetedMetricPrefix, alignNone)
+	}
+	if len(a.GroupByFields) > 0 && !a.reduces() {
+		return fmt.Errorf("group by fields for prefix %q require a cross-series reducer", a.TargetedMetricPrefix)
+	}
+	return nil
+}
+
+func (a Aggregation) reduces() bool {
+	return a.CrossSeriesReducer != "" && a.CrossSeriesReducer != reduceNone
+}
+
+// apply adds the aggregation parameters to a time series list call.
+func (a Aggregation) apply(call *monitoring.ProjectsTimeSeriesListCall) *monitoring.ProjectsTimeSeriesListCall {
+	call = call.AggregationPerSeriesAligner(a.PerSeriesAligner)
+	if a.PerSeriesAligner != alignNone {
+		call = call.AggregationAlignmentPeriod(fmt.Sprintf("%ds", int64(a.AlignmentPeriod/time.Second)))
+	}
+	if a.reduces() {
+		call = call.AggregationCrossSeriesReducer(a.CrossSeriesReducer)
+		if len(a.GroupByFields) > 0 {
+			call = call.AggregationGroupByFields(a.GroupByFields...)
+		}
+	}
+	return call
+}
+
+// aggregationFor returns the aggregation with the longest prefix matching the metric type, if any.
+func aggregationFor(aggregations []Aggregation, metricType string) (Aggregation, bool) {
+	var found Aggregation
+	var ok bool
+	for _, aggregation := range aggregations {
+		if strings.HasPrefix(metricType, aggregation.TargetedMetricPrefix) &&
+			(!ok || len(aggregation.TargetedMetricPrefix) > len(found.TargetedMetricPrefix)) {
+			found, ok = aggregation, true
+		}
+	}
+	return found, ok
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+func TestAggregation_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregation Aggregation
+		expectedErr string
+	}{
+		{"aligner only", Aggregation{AlignmentPeriod: time.Minute, PerSeriesAligner: "ALIGN_RATE"}, ""},
+		{"aligner and reducer", Aggregation{AlignmentPeriod: 5 * time.Minute, PerSeriesAligner: "ALIGN_RATE", CrossSeriesReducer: "REDUCE_SUM", GroupByFields: []string{"resource.label.zone"}}, ""},
+		{"no alignment", Aggregation{PerSeriesAligner: "ALIGN_NONE"}, ""},
+		{"missing aligner", Aggregation{AlignmentPeriod: time.Minute}, `invalid per-series aligner ""`},
+		{"unknown aligner", Aggregation{AlignmentPeriod: time.Minute, PerSeriesAligner: "ALIGN_MEDIAN"}, `invalid per-series aligner "ALIGN_MEDIAN"`},
+		{"unknown reducer", Aggregation{AlignmentPeriod: time.Minute, PerSeriesAligner: "ALIGN_RATE", CrossSeriesReducer: "REDUCE_AVG"}, `invalid cross-series reducer "REDUCE_AVG"`},
+		{"short alignment period", Aggregation{AlignmentPeriod: 30 * time.Second, PerSeriesAligner: "ALIGN_RATE"}, "requires an alignment period of at least 1m0s"},
+		{"reducer without alignment", Aggregation{PerSeriesAligner: "ALIGN_NONE", CrossSeriesReducer: "REDUCE_SUM"}, "cannot be combined with aligner ALIGN_NONE"},
+		{"group by without reducer", Aggregation{AlignmentPeriod: time.Minute, PerSeriesAligner: "ALIGN_RATE", GroupByFields: []string{"resource.label.zone"}}, "require a cross-series reducer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.aggregation.Validate()
+			if tt.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.expectedErr)
+			}
+		})
+	}
+}
+
+func TestAggregationFor(t *testing.T) {
+	aggregations := []Aggregation{
+		{TargetedMetricPrefix: "", PerSeriesAligner: "ALIGN_MEAN"},
+		{TargetedMetricPrefix: "compute.googleapis.com/instance/cpu", PerSeriesAligner: "ALIGN_MAX"},
+		{TargetedMetricPrefix: "compute.googleapis.com/instance", PerSeriesAligner: "ALIGN_RATE"},
+	}
+
+	aggregation, ok := aggregationFor(aggregations, "compute.googleapis.com/instance/cpu/usage_time")
+	assert.True(t, ok)
+	assert.Equal(t, "ALIGN_MAX", aggregation.PerSeriesAligner, "the longest prefix should win")
+
+	aggregation, ok = aggregationFor(aggregations, "pubsub.googleapis.com/topic/send_request_count")
+	assert.True(t, ok)
+	assert.Equal(t, "ALIGN_MEAN", aggregation.PerSeriesAligner, "an empty prefix should apply to every metric type")
+
+	_, ok = aggregationFor(aggregations[1:], "pubsub.googleapis.com/topic/send_request_count")
+	assert.False(t, ok)
+}
+
+func TestMonitoringCollector_AggregationRequest(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{
+			{Name: "custom.googleapis.com/aggregated/requests", Type: "custom.googleapis.com/aggregated/requests"},
+			{Name: "custom.googleapis.com/raw/requests", Type: "custom.googleapis.com/raw/requests"},
+		},
+		series: map[string][]*monitoring.TimeSeries{},
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/"},
+		RequestInterval:    5 * time.Minute,
+		Aggregations: []Aggregation{{
+			TargetedMetricPrefix: "custom.googleapis.com/aggregated",
+			AlignmentPeriod:      2 * time.Minute,
+			PerSeriesAligner:     "ALIGN_RATE",
+			CrossSeriesReducer:   "REDUCE_SUM",
+			GroupByFields:        []string{"resource.label.zone", "metric.label.method"},
+		}},
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+	collectSeries(t, c)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	require.Len(t, api.timeSeriesRequests, 2)
+	for _, r := range api.timeSeriesRequests {
+		query := r.URL.Query()
+		if strings.Contains(query.Get("filter"), "raw") {
+			assert.Empty(t, query.Get("aggregation.perSeriesAligner"), "untargeted metric types should not be aggregated")
+			continue
+		}
+		assert.Equal(t, "120s", query.Get("aggregation.alignmentPeriod"))
+		assert.Equal(t, "ALIGN_RATE", query.Get("aggregation.perSeriesAligner"))
+		assert.Equal(t, "REDUCE_SUM", query.Get("aggregation.crossSeriesReducer"))
+		assert.Equal(t, []string{"resource.label.zone", "metric.label.method"}, query["aggregation.groupByFields"])
+	}
+}
+
+func TestNewMonitoringCollector_InvalidAggregation(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		Aggregations: []Aggregation{{PerSeriesAligner: "ALIGN_NONE", CrossSeriesReducer: "REDUCE_SUM"}},
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	assert.ErrorContains(t, err, "cannot be combined with aligner ALIGN_NONE")
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/utils"
+)
+
+// collectedMetrics is an unchecked collector of already collected metrics.
+type collectedMetrics []prometheus.Metric
+
+// Describe implements prometheus.Collector interface.
+func (m collectedMetrics) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector interface.
+func (m collectedMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, metric := range m {
+		ch <- metric
+	}
+}
+
+// Backfill lists the time series of the metric types starting with prefix over the window from start to end, and
+// converts every point of them, not only the selected one, through the regular pipeline, each metric being
+// timestamped with the end time of its point. The points are reported oldest first, a metric family appearing once
+// per point of its series.
+//
+// The deduplicator is reset between the points, so the collector must be dedicated to the backfill rather than
+// scraped, and built without delta aggregation nor point timestamps omission for the points to keep their values and
+// times.
+func (c *MonitoringCollector) Backfill(ctx context.Context, prefix string, start, end time.Time) ([]*dto.MetricFamily, error) {
+	var descriptors []*monitoring.MetricDescriptor
+	filter := fmt.Sprintf("metric.type = starts_with(\"%s\")", prefix)
+	if err := c.listMetricDescriptors(ctx, filter, func(r *monitoring.ListMetricDescriptorsResponse) error {
+		descriptors = append(descriptors, r.MetricDescriptors...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	uniqueDescriptors := make(map[string]*monitoring.MetricDescriptor)
+	for _, descriptor := range descriptors {
+		if c.valueTypeAllowlist == nil || c.valueTypeAllowlist[descriptor.ValueType] {
+			uniqueDescriptors[descriptor.Type] = descriptor
+		}
+	}
+	descriptorTypes := make([]string, 0, len(uniqueDescriptors))
+	for descriptorType := range uniqueDescriptors {
+		descriptorTypes = append(descriptorTypes, descriptorType)
+	}
+	sort.Strings(descriptorTypes)
+
+	var families []*dto.MetricFamily
+	for _, descriptorType := range descriptorTypes {
+		descriptor := uniqueDescriptors[descriptorType]
+		call := c.monitoringService.Projects.TimeSeries.List(utils.ProjectResource(c.timeSeriesProject())).
+			Filter(c.timeSeriesFilter(descriptor)).
+			IntervalStartTime(start.Format(time.RFC3339Nano)).
+			IntervalEndTime(end.Format(time.RFC3339Nano))
+		if aggregation, ok := aggregationFor(c.aggregations, descriptor.Type); ok {
+			call = aggregation.apply(call)
+		}
+		var pageErr error
+		if err := c.listTimeSeriesPages(ctx, call, func(page *monitoring.ListTimeSeriesResponse) error {
+			var pageFamilies []*dto.MetricFamily
+			if pageFamilies, pageErr = c.backfillPage(page, descriptor); pageErr != nil {
+				return pageErr
+			}
+			families = append(families, pageFamilies...)
+			return nil
+		}); err != nil {
+			if pageErr != nil {
+				return nil, pageErr
+			}
+			return nil, fmt.Errorf("error backfilling the time series of %s: %w", descriptor.Type, err)
+		}
+	}
+	return families, nil
+}
+
+// backfillPage reports the points of a page of time series one round at a time, each round holding a single point
+// of every series, and gathers the metric families of each round.
+func (c *MonitoringCollector) backfillPage(page *monitoring.ListTimeSeriesResponse, descriptor *monitoring.MetricDescriptor) ([]*dto.MetricFamily, error) {
+	var families []*dto.MetricFamily
+	for round := 0; ; round++ {
+		roundPage := &monitoring.ListTimeSeriesResponse{}
+		for _, timeSeries := range page.TimeSeries {
+			// The points are listed newest first
+			if index := len(timeSeries.Points) - 1 - round; index >= 0 {
+				single := *timeSeries
+				single.Points = []*monitoring.Point{timeSeries.Points[index]}
+				roundPage.TimeSeries = append(roundPage.TimeSeries, &single)
+			}
+		}
+		if len(roundPage.TimeSeries) == 0 {
+			return families, nil
+		}
+
+		c.deduplicator.Reset()
+		ch := make(chan prometheus.Metric)
+		collected := make(chan collectedMetrics)
+		go func() {
+			var metrics collectedMetrics
+			for metric := range ch {
+				metrics = append(metrics, metric)
+			}
+			collected <- metrics
+		}()
+		err := c.reportTimeSeriesMetrics(roundPage, descriptor, ch, time.Now())
+		close(ch)
+		metrics := <-collected
+		if err != nil {
+			return nil, fmt.Errorf("error backfilling the time series of %s: %w", descriptor.Type, err)
+		}
+
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(metrics); err != nil {
+			return nil, err
+		}
+		roundFamilies, err := registry.Gather()
+		if err != nil {
+			return nil, fmt.Errorf("error backfilling the time series of %s: %w", descriptor.Type, err)
+		}
+		families = append(families, roundFamilies...)
+	}
+}
@@ -24,6 +24,9 @@ type DescriptorCache interface {
 	// Lookup searches the cache for an entry. If the cache has no entry or the entry has expired nil is returned.
 	Lookup(prefix string) []*monitoring.MetricDescriptor
 
+	// LookupStale searches the cache for an entry, even expired. If the cache has no entry nil is returned.
+	LookupStale(prefix string) []*monitoring.MetricDescriptor
+
 	// Store stores an entry in the cache
 	Store(prefix string, data []*monitoring.MetricDescriptor)
 }
@@ -34,6 +37,10 @@ func (d *noopDescriptorCache) Lookup(prefix string) []*monitoring.MetricDescript
 	return nil
 }
 
+func (d *noopDescriptorCache) LookupStale(prefix string) []*monitoring.MetricDescriptor {
+	return nil
+}
+
 func (d *noopDescriptorCache) Store(prefix string, data []*monitoring.MetricDescriptor) {}
 
 // descriptorCache is a MetricTypePrefix -> MetricDescriptor cache
@@ -65,6 +72,19 @@ func (d *descriptorCache) Lookup(prefix string) []*monitoring.MetricDescriptor {
 	return v.data
 }
 
+// LookupStale returns a list of MetricDescriptors if the prefix is found, even expired, nil if not found
+func (d *descriptorCache) LookupStale(prefix string) []*monitoring.MetricDescriptor {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	v, ok := d.cache[prefix]
+	if !ok {
+		return nil
+	}
+
+	return v.data
+}
+
 // Store overrides a cache entry
 func (d *descriptorCache) Store(prefix string, data []*monitoring.MetricDescriptor) {
 	entry := descriptorCacheEntry{data: data, expiry: time.Now().Add(d.ttl)}
@@ -78,6 +98,8 @@ type CollectorCache struct {
 	cache map[string]*collectorCacheEntry
 	lock  sync.RWMutex
 	ttl   time.Duration
+	// onEvict is called with the key of each expired collector removed from the cache, if set
+	onEvict func(key string)
 }
 
 // collectorCacheEntry is a cache entry for a MonitoringCollector
@@ -97,9 +119,32 @@ func NewCollectorCache(ttl time.Duration) *CollectorCache {
 	return c
 }
 
+// OnEvict sets the function called with the key of each expired collector once removed from the cache, e.g. to
+// release the resources kept for it. It must be set before the cache is used.
+func (c *CollectorCache) OnEvict(onEvict func(key string)) {
+	c.onEvict = onEvict
+}
+
+// evicted calls the eviction function, if any, with the keys of the collectors removed from the cache.
+func (c *CollectorCache) evicted(keys ...string) {
+	if c.onEvict == nil {
+		return
+	}
+	for _, key := range keys {
+		c.onEvict(key)
+	}
+}
+
 // Get returns a MonitoringCollector if the key is found and not expired
 // If key is found it resets the TTL for the collector
 func (c *CollectorCache) Get(key string) (*MonitoringCollector, bool) {
+	var expired bool
+	// Deferred first to run once the lock is released
+	defer func() {
+		if expired {
+			c.evicted(key)
+		}
+	}()
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
@@ -111,6 +156,7 @@ func (c *CollectorCache) Get(key string) (*MonitoringCollector, bool) {
 
 	if time.Now().After(entry.expiry) {
 		delete(c.cache, key)
+		expired = true
 		return nil, false
 	}
 
@@ -129,6 +175,16 @@ func (c *CollectorCache) Store(key string, collector *MonitoringCollector) {
 	c.cache[key] = entry
 }
 
+// RefreshDescriptorCaches forces every cached MonitoringCollector to list the metric descriptors again on its next
+// scrape, see MonitoringCollector.RefreshDescriptorCache.
+func (c *CollectorCache) RefreshDescriptorCaches() {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, entry := range c.cache {
+		entry.collector.RefreshDescriptorCache()
+	}
+}
+
 func (c *CollectorCache) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
@@ -139,12 +195,15 @@ func (c *CollectorCache) cleanup() {
 
 func (c *CollectorCache) removeExpired() {
 	c.lock.Lock()
-	defer c.lock.Unlock()
-
+	var expired []string
 	now := time.Now()
 	for key, entry := range c.cache {
 		if now.After(entry.expiry) {
 			delete(c.cache, key)
+			expired = append(expired, key)
 		}
 	}
+	c.lock.Unlock()
+
+	c.evicted(expired...)
 }
@@ -15,6 +15,7 @@ package collectors
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -126,4 +127,26 @@ func TestCollectorCache(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("eviction", func(t *testing.T) {
+		ttl := 10 * time.Millisecond
+		cache := NewCollectorCache(ttl)
+		var evicted []string
+		cache.OnEvict(func(key string) {
+			evicted = append(evicted, key)
+		})
+
+		cache.Store("test-key-1", createCollector("test-project-1"))
+		cache.Store("test-key-2", createCollector("test-project-2"))
+		time.Sleep(2 * ttl)
+		cache.Store("test-key-3", createCollector("test-project-3"))
+
+		if _, found := cache.Get("test-key-1"); found {
+			t.Error("Collector should have expired")
+		}
+		cache.removeExpired()
+		if !reflect.DeepEqual(evicted, []string{"test-key-1", "test-key-2"}) {
+			t.Errorf("Expected the expired collectors to be evicted, got %v", evicted)
+		}
+	})
 }
@@ -0,0 +1,158 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// circuitState is the state of a circuit breaker, reported as the value of its state gauge.
+type circuitState int
+
+const (
+	// circuitClosed lets the scrapes call the API.
+	circuitClosed circuitState = iota
+	// circuitOpen skips the API calls of the scrapes until the cooldown elapses.
+	circuitOpen
+	// circuitHalfOpen lets a single probing scrape call the API, closing the circuit if it succeeds.
+	circuitHalfOpen
+)
+
+// CircuitBreakers holds the circuit breaker of every project, shared by the collectors of a project whatever their
+// metric type prefixes, so that a project failing consistently is skipped by all its scrapes.
+type CircuitBreakers struct {
+	mu       sync.Mutex // Protects breakers
+	breakers map[string]*circuitBreaker
+}
+
+// NewCircuitBreakers creates an empty CircuitBreakers, the breaker of a project being created by its first collector.
+func NewCircuitBreakers() *CircuitBreakers {
+	return &CircuitBreakers{breakers: map[string]*circuitBreaker{}}
+}
+
+// get returns the circuit breaker of the project, created with the threshold and cooldown if none.
+// This method is thread-safe.
+func (b *CircuitBreakers) get(projectID string, threshold int, cooldown time.Duration) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.breakers[projectID]
+	if !ok {
+		breaker = newCircuitBreaker(projectID, threshold, cooldown)
+		b.breakers[projectID] = breaker
+	}
+	return breaker
+}
+
+// circuitBreaker stops calling the API of a project failing consistently, so that its scrapes do not use up the
+// shared quota and retry budget. It opens after threshold consecutive failed scrapes, skips the scrapes for the
+// cooldown, then half-opens to probe the API again with the next scrape, the other scrapes being skipped until the
+// probe ends.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex // Protects all fields below
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// probing is whether a scrape is probing the API while half-open
+	probing bool
+
+	stateMetric prometheus.Gauge
+}
+
+func newCircuitBreaker(projectID string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		stateMetric: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "monitoring",
+			Name:        "circuit_breaker_state",
+			Help:        "State of the circuit breaker of the project's scrapes (0 for closed, 1 for open, 2 for half-open).",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		}),
+	}
+}
+
+// allow returns whether a scrape starting at now may call the API, half-opening the circuit once the cooldown
+// elapsed. Half-open, only the scrape starting while no other one probes the API may call it. This method is
+// thread-safe.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+	default:
+		return true
+	}
+	b.probing = true
+	return true
+}
+
+// release lets the next scrape probe the API once the probing one ended without an outcome, e.g. cancelled by its
+// caller. This method is thread-safe.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// record records the outcome of a scrape ending at now. A success closes the circuit, a failure opens it once the
+// threshold is reached or when probing. This method is thread-safe.
+func (b *circuitBreaker) record(now time.Time, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		b.setState(circuitClosed)
+		return
+	}
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.openedAt = now
+		b.setState(circuitOpen)
+	}
+}
+
+// setState must be called with mu held.
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+	b.stateMetric.Set(float64(state))
+}
+
+// Describe implements prometheus.Collector interface.
+func (b *circuitBreaker) Describe(ch chan<- *prometheus.Desc) {
+	b.stateMetric.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (b *circuitBreaker) Collect(ch chan<- prometheus.Metric) {
+	b.stateMetric.Collect(ch)
+}
@@ -0,0 +1,42 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ContextCollector is a prometheus.Collector whose collection can be bounded by a context, such as the context of
+// the scrape request, the Monitoring API calls being cancelled once it is done.
+type ContextCollector interface {
+	prometheus.Collector
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+// WithContext returns a prometheus.Collector collecting the collector with the context.
+func WithContext(ctx context.Context, collector ContextCollector) prometheus.Collector {
+	return &contextCollector{ContextCollector: collector, ctx: ctx}
+}
+
+type contextCollector struct {
+	ContextCollector
+	ctx context.Context
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(c.ctx, ch)
+}
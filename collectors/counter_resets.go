@@ -0,0 +1,71 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+// counterResetsRetention is how long the last value of a counter is remembered for after it was last reported.
+const counterResetsRetention = time.Hour
+
+// counterResets remembers the last value reported for every counter, by its deduplication signature regardless of
+// the timestamp, so that a counter going backwards without a reset is reported at its previous value.
+type counterResets struct {
+	mu   sync.Mutex
+	last map[hash.Signature]counterPoint
+}
+
+// counterPoint is the last value reported for a counter.
+type counterPoint struct {
+	value float64
+	// startTime is the start of the interval of the point, a later one marking a reset of the counter
+	startTime  time.Time
+	reportedAt time.Time
+}
+
+func newCounterResets() *counterResets {
+	return &counterResets{last: map[hash.Signature]counterPoint{}}
+}
+
+// Reset forgets the counters not reported within the counterResetsRetention.
+func (r *counterResets) Reset(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for signature, point := range r.last {
+		if now.Sub(point.reportedAt) >= counterResetsRetention {
+			delete(r.last, signature)
+		}
+	}
+}
+
+// Clamp returns the value a counter is reported at, and true when it was clamped: the previous value when the new
+// one is lower but its interval starts no later than the previous one's, i.e. without a reset. A lower value with a
+// later start time is a legitimate reset.
+func (r *counterResets) Clamp(signature hash.Signature, value float64, startTime, now time.Time) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, ok := r.last[signature]
+	if ok && value < last.value && !startTime.After(last.startTime) {
+		last.reportedAt = now
+		r.last[signature] = last
+		return last.value, true
+	}
+	r.last[signature] = counterPoint{value: value, startTime: startTime, reportedAt: now}
+	return value, false
+}
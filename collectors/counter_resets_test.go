@@ -0,0 +1,74 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+func TestMonitoringCollector_ClampCounterResets(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "CUMULATIVE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	startTime := time.Now().Truncate(time.Minute).Add(-time.Hour)
+	newCumulative := func(value float64, startTime, endTime time.Time) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, value, endTime, nil)
+		series.MetricKind = "CUMULATIVE"
+		series.Points[0].Interval.StartTime = startTime.Format(time.RFC3339Nano)
+		return series
+	}
+	// report reports a series as a new scrape and returns the value of its counter
+	report := func(t *testing.T, c *MonitoringCollector, series *monitoring.TimeSeries) float64 {
+		c.deduplicator.Reset()
+		metrics := reportPage(t, c, descriptor, series)[fqName]
+		require.Len(t, metrics, 1)
+		return metrics[0].GetCounter().GetValue()
+	}
+
+	c := newTestCollector(t, MonitoringCollectorOptions{ClampCounterResets: true})
+	assert.Equal(t, 10.0, report(t, c, newCumulative(10, startTime, startTime.Add(time.Minute))))
+	assert.Equal(t, 10.0, report(t, c, newCumulative(8, startTime, startTime.Add(2*time.Minute))), "a spurious decrease should be reported at the previous value")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.counterResetsClampedTotal.WithLabelValues(descriptor.Type)))
+	assert.Equal(t, 12.0, report(t, c, newCumulative(12, startTime, startTime.Add(3*time.Minute))), "an increase should be reported as is")
+
+	restart := startTime.Add(3 * time.Minute)
+	assert.Equal(t, 2.0, report(t, c, newCumulative(2, restart, restart.Add(time.Minute))), "a reset with a later start time should be reported as is")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.counterResetsClampedTotal.WithLabelValues(descriptor.Type)))
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		report(t, c, newCumulative(10, startTime, startTime.Add(time.Minute)))
+		assert.Equal(t, 8.0, report(t, c, newCumulative(8, startTime, startTime.Add(2*time.Minute))))
+	})
+}
+
+func TestCounterResets_Retention(t *testing.T) {
+	signature := hash.Signature{42}
+	startTime := time.Now()
+	r := newCounterResets()
+
+	_, clamped := r.Clamp(signature, 10, startTime, startTime)
+	assert.False(t, clamped)
+	r.Reset(startTime.Add(counterResetsRetention))
+	value, clamped := r.Clamp(signature, 8, startTime, startTime.Add(counterResetsRetention))
+	assert.False(t, clamped, "a counter not reported within the retention should be forgotten")
+	assert.Equal(t, 8.0, value)
+}
@@ -14,8 +14,11 @@
 package collectors
 
 import (
+	"fmt"
 	"log/slog"
+	"regexp"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,48 +30,178 @@ import (
 // It tracks signatures of metrics that have already been sent.
 type MetricDeduplicator struct {
 	mu             sync.Mutex // Protects all fields below
-	sentSignatures map[uint64]struct{}
-	logger         *slog.Logger
+	sentSignatures map[hash.Signature]struct{}
+	// history holds the signatures of the previous iterations, most recent first
+	history       []map[hash.Signature]struct{}
+	historyDepth  int
+	maxSignatures int
+	// dedupByTimestamp includes the point timestamp in the signatures
+	dedupByTimestamp bool
+	// hashSeed diversifies the signatures, 0 being the unseeded hash
+	hashSeed uint64
+	// hasher computes the signatures, reused under mu
+	hasher hash.Hasher
+	// includeResourceType includes the monitored resource type in the signatures
+	includeResourceType bool
+	// dryRun counts the duplicates without dropping them, but those in exactSignatures
+	dryRun bool
+	// exactSignatures holds, in dry run, the signatures of the current iteration by name and every label, nil
+	// otherwise
+	exactSignatures map[hash.Signature]struct{}
+	// emittedSignatures holds, when tracking the emitted series, the signatures of the series emitted during the
+	// current iteration by name and every label, nil otherwise
+	emittedSignatures map[hash.Signature]struct{}
+	// ignoredLabels matches the label keys left out of the signatures
+	ignoredLabels *labelKeyMatcher
+	// signatureInputs holds, when debugging collisions, the distinct series hashed to each signature of the current
+	// iteration, nil otherwise
+	signatureInputs map[hash.Signature][]string
+	logger          *slog.Logger
 
 	// Prometheus metrics
 	duplicatesTotal    prometheus.Counter
 	checksTotal        prometheus.Counter
 	uniqueMetricsGauge prometheus.Gauge
+	overflowTotal      prometheus.Counter
+	// policyActionsTotal counts the decisions of the deduplication policies by action
+	policyActionsTotal *prometheus.CounterVec
+}
+
+// Deduplicator policy actions.
+const (
+	// dedupActionKeptFirst is counted when a duplicate is dropped in favour of the first occurrence.
+	dedupActionKeptFirst = "kept_first"
+	// dedupActionReverted is counted when a signature is unmarked because its metric could not be emitted.
+	dedupActionReverted = "reverted"
+	// dedupActionDryRun is counted when a duplicate is kept because the deduplicator only counts them.
+	dedupActionDryRun = "dry_run"
+)
+
+// MetricDeduplicatorOptions are the options of a MetricDeduplicator, the zero value deduplicating the series by name
+// and labels within an iteration.
+type MetricDeduplicatorOptions struct {
+	// MaxSignatures caps the number of signatures tracked per iteration, zero means unlimited. Once the cap is
+	// reached, new signatures are no longer tracked and are always treated as non-duplicates, trading dedup accuracy
+	// for bounded memory usage. The series with the same name and labels as a series of the iteration, which the
+	// registry rejects, are still detected past the cap in dry run and when tracking the emitted series.
+	MaxSignatures int
+	// DedupByTimestamp, if true, makes the metrics with the same labels but different timestamps not duplicates.
+	DedupByTimestamp bool
+	// IgnoreLabels are the patterns of the label keys left out of the signatures, a pattern being either an exact key
+	// or a key with * wildcards, e.g. tmp_*.
+	IgnoreLabels []string
+	// HistoryDepth is the number of iterations a signature is retained for, the current one included. With a depth
+	// greater than 1 a metric already sent in one of the previous iterations is a duplicate, which combined with
+	// DedupByTimestamp suppresses the points reported again by consecutive scrapes. Lower depths retain the
+	// signatures of the current iteration only.
+	HistoryDepth int
+	// DebugCollisions, if true, retains the series hashed to each signature for DumpSignatures and logs the distinct
+	// series colliding on a signature, at the cost of keeping them in memory.
+	DebugCollisions bool
+	// HashSeed seeds the hash of the signatures, letting instances aggregated together have distinct signatures for a
+	// same series. The zero seed keeps the unseeded hash.
+	HashSeed uint64
+	// IncludeResourceType, if true, makes the metrics of distinct monitored resource types not duplicates, even when
+	// their resource types are normalized to the same metric name.
+	IncludeResourceType bool
+	// Hasher computes the signatures, a nil hasher being the 64-bit FNV one.
+	Hasher hash.Hasher
+	// DryRun, if true, counts the duplicates of the options above without dropping them, to measure the impact of a
+	// deduplication policy before enforcing it. The series with the same name and labels within an iteration are
+	// still dropped, the registry rejecting them otherwise.
+	DryRun bool
+	// TrackEmitted, if true, tracks the series emitted during an iteration by name and every label for
+	// CheckAndMarkEmitted, e.g. when the labels emitted are not those deduplicated.
+	TrackEmitted bool
 }
 
 // NewMetricDeduplicator creates a new MetricDeduplicator.
-func NewMetricDeduplicator(logger *slog.Logger, projectID string) *MetricDeduplicator {
+func NewMetricDeduplicator(logger *slog.Logger, projectID string, opts MetricDeduplicatorOptions) *MetricDeduplicator {
 	if logger == nil {
 		logger = slog.Default()
 	}
+	hasher := opts.Hasher
+	if hasher == nil {
+		hasher, _ = hash.NewHasher(hash.FNV)
+	}
+
+	duplicatesTotal := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   "stackdriver",
+		Subsystem:   "deduplicator",
+		Name:        "duplicates_total",
+		Help:        "Total number of duplicate metrics detected and dropped.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	})
 
-	duplicatesTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "stackdriver",
-		Subsystem: "deduplicator",
-		Name:      "duplicates_total",
-		Help:      "Total number of duplicate metrics detected and dropped.",
-	}, []string{"project_id"}).WithLabelValues(projectID)
+	checksTotal := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   "stackdriver",
+		Subsystem:   "deduplicator",
+		Name:        "checks_total",
+		Help:        "Total number of deduplication checks performed.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	})
 
-	checksTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
-		Namespace: "stackdriver",
-		Subsystem: "deduplicator",
-		Name:      "checks_total",
-		Help:      "Total number of deduplication checks performed.",
-	}, []string{"project_id"}).WithLabelValues(projectID)
+	uniqueMetricsGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   "stackdriver",
+		Subsystem:   "deduplicator",
+		Name:        "unique_metrics",
+		Help:        "Current number of unique metrics being tracked.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	})
 
-	uniqueMetricsGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Namespace: "stackdriver",
-		Subsystem: "deduplicator",
-		Name:      "unique_metrics",
-		Help:      "Current number of unique metrics being tracked.",
-	}, []string{"project_id"}).WithLabelValues(projectID)
+	overflowTotal := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   "stackdriver",
+		Subsystem:   "deduplicator",
+		Name:        "overflow_total",
+		Help:        "Total number of metrics not tracked for deduplication because the signature limit was reached.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	})
+
+	policyActionsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   "stackdriver",
+		Subsystem:   "deduplicator",
+		Name:        "policy_actions_total",
+		Help:        "Total number of actions taken by the deduplication policies.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	}, []string{"action"})
+	for _, action := range []string{dedupActionKeptFirst, dedupActionReverted} {
+		policyActionsTotal.WithLabelValues(action)
+	}
+	var exactSignatures map[hash.Signature]struct{}
+	if opts.DryRun {
+		policyActionsTotal.WithLabelValues(dedupActionDryRun)
+		exactSignatures = make(map[hash.Signature]struct{})
+	}
+
+	var emittedSignatures map[hash.Signature]struct{}
+	if opts.TrackEmitted {
+		emittedSignatures = make(map[hash.Signature]struct{})
+	}
+
+	var signatureInputs map[hash.Signature][]string
+	if opts.DebugCollisions {
+		signatureInputs = make(map[hash.Signature][]string)
+	}
 
 	return &MetricDeduplicator{
-		sentSignatures:     make(map[uint64]struct{}),
-		logger:             logger.With("component", "deduplicator"),
-		duplicatesTotal:    duplicatesTotal,
-		checksTotal:        checksTotal,
-		uniqueMetricsGauge: uniqueMetricsGauge,
+		sentSignatures:      make(map[hash.Signature]struct{}),
+		exactSignatures:     exactSignatures,
+		emittedSignatures:   emittedSignatures,
+		signatureInputs:     signatureInputs,
+		maxSignatures:       opts.MaxSignatures,
+		historyDepth:        opts.HistoryDepth,
+		dedupByTimestamp:    opts.DedupByTimestamp,
+		hashSeed:            opts.HashSeed,
+		hasher:              hasher,
+		includeResourceType: opts.IncludeResourceType,
+		dryRun:              opts.DryRun,
+		ignoredLabels:       newLabelKeyMatcher(opts.IgnoreLabels),
+		logger:              logger.With("component", "deduplicator"),
+		duplicatesTotal:     duplicatesTotal,
+		checksTotal:         checksTotal,
+		uniqueMetricsGauge:  uniqueMetricsGauge,
+		overflowTotal:       overflowTotal,
+		policyActionsTotal:  policyActionsTotal,
 	}
 }
 
@@ -76,65 +209,289 @@ func NewMetricDeduplicator(logger *slog.Logger, projectID string) *MetricDedupli
 // If not seen, it marks it as seen and returns false (not a duplicate).
 // If seen before, returns true (duplicate detected).
 // We keep the first occurrence and drop all subsequent ones.
+// When the signature limit is reached, unseen signatures are not tracked and
+// the metric is reported as not a duplicate.
+// The second result reports whether the signature was marked by this call, to be passed to RevertMark.
+// In dry run, a duplicate is counted but reported as not a duplicate, unless it has the same name and labels as a
+// metric of the current iteration.
+// The resourceType is only part of the signature when the deduplicator includes the resource type.
 // This method is thread-safe.
-func (d *MetricDeduplicator) CheckAndMark(name string, labelKeys, labelValues []string, ts time.Time) bool {
+func (d *MetricDeduplicator) CheckAndMark(resourceType, name string, labelKeys, labelValues []string, ts time.Time) (bool, bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
 	d.checksTotal.Inc()
 
-	signature := d.hashLabels(name, labelKeys, labelValues)
+	if d.dryRun {
+		// The registry rejects the series collected twice, they are dropped whatever the policy
+		exact := d.exactSignature(name, labelKeys, labelValues)
+		if _, exists := d.exactSignatures[exact]; exists {
+			d.duplicatesTotal.Inc()
+			d.logger.Debug("dropping duplicate metric with the same labels in dry run", "fqName", name, "signature", exact)
+			d.policyActionsTotal.WithLabelValues(dedupActionKeptFirst).Inc()
+			return true, false
+		}
+		// Tracked regardless of the signature limit, the registry rejecting any series collected twice
+		d.exactSignatures[exact] = struct{}{}
+	}
+
+	signature := d.hashLabels(resourceType, name, labelKeys, labelValues, ts)
+	if d.signatureInputs != nil {
+		d.recordSignatureInput(signature, resourceType, name, labelKeys, labelValues, ts)
+	}
 
-	if _, exists := d.sentSignatures[signature]; exists {
+	if d.seen(signature) {
 		d.duplicatesTotal.Inc()
-		return true // Duplicate detected - drop it
+		if d.dryRun {
+			d.logger.Debug("keeping duplicate metric in dry run", "fqName", name, "signature", signature)
+			d.policyActionsTotal.WithLabelValues(dedupActionDryRun).Inc()
+			return false, false // Duplicate detected - counted only
+		}
+		d.logger.Debug("dropping duplicate metric", "fqName", name, "signature", signature)
+		d.policyActionsTotal.WithLabelValues(dedupActionKeptFirst).Inc()
+		return true, false // Duplicate detected - drop it
+	}
+
+	if d.maxSignatures > 0 && len(d.sentSignatures) >= d.maxSignatures {
+		d.overflowTotal.Inc()
+		return false, false // Limit reached - emit without tracking
 	}
 
 	d.sentSignatures[signature] = struct{}{} // Mark as seen
 	d.uniqueMetricsGauge.Set(float64(len(d.sentSignatures)))
 
-	return false // Not a duplicate
+	return false, true // Not a duplicate
+}
+
+// CheckAndMarkEmitted checks if a series with the same name and labels was emitted during the current iteration, the
+// registry rejecting the series collected twice. If not, it marks it as emitted and returns false. It always returns
+// false unless the deduplicator tracks the emitted series.
+// This method is thread-safe.
+func (d *MetricDeduplicator) CheckAndMarkEmitted(fqName string, labelKeys, labelValues []string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.emittedSignatures == nil {
+		return false
+	}
+	exact := d.exactSignature(fqName, labelKeys, labelValues)
+	if _, exists := d.emittedSignatures[exact]; exists {
+		return true
+	}
+	// Tracked regardless of the signature limit, the registry rejecting any series collected twice
+	d.emittedSignatures[exact] = struct{}{}
+	return false
+}
+
+// seen reports whether the signature was marked in the current iteration or in the retained history.
+func (d *MetricDeduplicator) seen(signature hash.Signature) bool {
+	if _, exists := d.sentSignatures[signature]; exists {
+		return true
+	}
+	for _, signatures := range d.history {
+		if _, exists := signatures[signature]; exists {
+			return true
+		}
+	}
+	return false
 }
 
-func (d *MetricDeduplicator) RevertMark(fqName string, labelKeys, labelValues []string, ts time.Time) {
-	signature := d.hashLabels(fqName, labelKeys, labelValues)
+// RevertMark forgets the series of a CheckAndMark call which was not dropped but could not be emitted, marked being
+// the second result of the call. The signature is only removed, and the revert counted, when the call marked it, a
+// signature marked by an earlier series being kept.
+// This method is thread-safe.
+func (d *MetricDeduplicator) RevertMark(resourceType, fqName string, labelKeys, labelValues []string, ts time.Time, marked bool) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	delete(d.sentSignatures, signature)
+	// A series not dropped in dry run always marked its exact signature
+	if d.exactSignatures != nil {
+		delete(d.exactSignatures, d.exactSignature(fqName, labelKeys, labelValues))
+	}
+	if !marked {
+		return
+	}
+	delete(d.sentSignatures, d.hashLabels(resourceType, fqName, labelKeys, labelValues, ts))
+	d.policyActionsTotal.WithLabelValues(dedupActionReverted).Inc()
 	d.uniqueMetricsGauge.Set(float64(len(d.sentSignatures)))
 }
 
-// hashLabels calculates a hash based on FQName and sorted labels.
-func (d *MetricDeduplicator) hashLabels(fqName string, labelKeys, labelValues []string) uint64 {
-	h := hash.New()
-	h = hash.Add(h, fqName)
-	h = hash.AddByte(h, hash.SeparatorByte)
+// recordSignatureInput retains the series hashed to a signature, logging it when a distinct series was already
+// hashed to the same signature.
+func (d *MetricDeduplicator) recordSignatureInput(signature hash.Signature, resourceType, fqName string, labelKeys, labelValues []string, ts time.Time) {
+	input := d.signatureInput(resourceType, fqName, labelKeys, labelValues, ts)
+	inputs := d.signatureInputs[signature]
+	for _, recorded := range inputs {
+		if recorded == input {
+			return
+		}
+	}
+	if len(inputs) > 0 {
+		d.logger.Debug("distinct series share a signature", "signature", signature, "series", input, "colliding_series", inputs)
+	}
+	d.signatureInputs[signature] = append(inputs, input)
+}
 
-	if len(labelKeys) > 0 {
-		// Create indices [0, 1, 2, ...]
-		indices := make([]int, len(labelKeys))
-		for i := range indices {
-			indices[i] = i
+// signatureInput renders the series hashed by hashLabels in the series notation, e.g. name{a="1",b="2"}, the
+// timestamp being appended after an @ when deduplicating by timestamp. The resource type is prepended when included,
+// e.g. gce_instance/name{a="1"}. The ignored labels are rendered too, telling apart the series deduplicated
+// together on purpose.
+func (d *MetricDeduplicator) signatureInput(resourceType, fqName string, labelKeys, labelValues []string, ts time.Time) string {
+	var b strings.Builder
+	if d.includeResourceType {
+		b.WriteString(resourceType)
+		b.WriteByte('/')
+	}
+	b.WriteString(fqName)
+	b.WriteByte('{')
+	first := true
+	for _, idx := range sortedLabelIndices(labelKeys) {
+		if !first {
+			b.WriteByte(',')
 		}
+		first = false
+		var value string
+		if idx < len(labelValues) {
+			value = labelValues[idx]
+		}
+		fmt.Fprintf(&b, "%s=%q", labelKeys[idx], value)
+	}
+	b.WriteByte('}')
+	if d.dedupByTimestamp {
+		fmt.Fprintf(&b, " @%d", ts.UnixNano())
+	}
+	return b.String()
+}
+
+// DumpSignatures returns the distinct series hashed to each signature of the current iteration, more than one
+// series under a signature being either a hash collision or series differing by ignored labels only. It returns nil
+// unless the deduplicator debugs collisions.
+func (d *MetricDeduplicator) DumpSignatures() map[hash.Signature][]string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-		// Sort indices by their label keys
-		sort.Slice(indices, func(i, j int) bool {
-			return labelKeys[indices[i]] < labelKeys[indices[j]]
-		})
+	if d.signatureInputs == nil {
+		return nil
+	}
+	dump := make(map[hash.Signature][]string, len(d.signatureInputs))
+	for signature, inputs := range d.signatureInputs {
+		dump[signature] = append([]string{}, inputs...)
+	}
+	return dump
+}
 
+// SeriesSignature returns the signature of a series regardless of its timestamp, hashed like the duplicates are.
+// This method is thread-safe.
+func (d *MetricDeduplicator) SeriesSignature(resourceType, fqName string, labelKeys, labelValues []string) hash.Signature {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.addSeries(resourceType, fqName, labelKeys, labelValues)
+	return d.hasher.Sum()
+}
+
+// hashLabels calculates a hash based on FQName, sorted labels and, when enabled, the resource type and timestamp.
+// It must be called holding mu, the hasher being shared.
+func (d *MetricDeduplicator) hashLabels(resourceType, fqName string, labelKeys, labelValues []string, ts time.Time) hash.Signature {
+	d.addSeries(resourceType, fqName, labelKeys, labelValues)
+	if d.dedupByTimestamp {
+		d.hasher.AddUint64(uint64(ts.UnixNano()))
+	}
+	return d.hasher.Sum()
+}
+
+// exactSignature calculates a hash based on FQName and every sorted label, regardless of the options, telling apart
+// the series the registry does. It must be called holding mu.
+func (d *MetricDeduplicator) exactSignature(fqName string, labelKeys, labelValues []string) hash.Signature {
+	d.hasher.Reset(d.hashSeed)
+	d.addLabels(fqName, labelKeys, labelValues, nil)
+	return d.hasher.Sum()
+}
+
+// addSeries resets the hasher and adds the series to it: the resource type when enabled, FQName and the sorted labels
+// not ignored. It must be called holding mu.
+func (d *MetricDeduplicator) addSeries(resourceType, fqName string, labelKeys, labelValues []string) {
+	h := d.hasher
+	h.Reset(d.hashSeed)
+	if d.includeResourceType {
+		h.AddString(resourceType)
+		h.AddByte(hash.SeparatorByte)
+	}
+	d.addLabels(fqName, labelKeys, labelValues, d.ignoredLabels)
+}
+
+// addLabels adds FQName and the sorted labels not matched by ignored to the hasher. It must be called holding mu.
+func (d *MetricDeduplicator) addLabels(fqName string, labelKeys, labelValues []string, ignored *labelKeyMatcher) {
+	h := d.hasher
+	h.AddString(fqName)
+	h.AddByte(hash.SeparatorByte)
+
+	if len(labelKeys) > 0 {
 		// Hash labels in sorted order
-		for _, idx := range indices {
-			h = hash.Add(h, labelKeys[idx])
-			h = hash.AddByte(h, hash.SeparatorByte)
+		for _, idx := range sortedLabelIndices(labelKeys) {
+			if ignored.matches(labelKeys[idx]) {
+				continue
+			}
+			h.AddString(labelKeys[idx])
+			h.AddByte(hash.SeparatorByte)
 			if idx < len(labelValues) {
-				h = hash.Add(h, labelValues[idx])
+				h.AddString(labelValues[idx])
 			}
-			h = hash.AddByte(h, hash.SeparatorByte)
+			h.AddByte(hash.SeparatorByte)
+		}
+	}
+}
+
+// sortedLabelIndices returns the indices of the label keys, sorted by key.
+func sortedLabelIndices(labelKeys []string) []int {
+	// Create indices [0, 1, 2, ...]
+	indices := make([]int, len(labelKeys))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	// Sort indices by their label keys
+	sort.Slice(indices, func(i, j int) bool {
+		return labelKeys[indices[i]] < labelKeys[indices[j]]
+	})
+	return indices
+}
+
+// labelKeyMatcher matches label keys against exact keys and * wildcard patterns, compiled once.
+type labelKeyMatcher struct {
+	exact    map[string]struct{}
+	wildcard *regexp.Regexp
+}
+
+// newLabelKeyMatcher returns a matcher of the given patterns, or nil when there are none.
+func newLabelKeyMatcher(patterns []string) *labelKeyMatcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	m := &labelKeyMatcher{exact: make(map[string]struct{})}
+	var wildcards []string
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "*") {
+			m.exact[pattern] = struct{}{}
+			continue
 		}
+		wildcards = append(wildcards, strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*"))
+	}
+	if len(wildcards) > 0 {
+		m.wildcard = regexp.MustCompile("^(?:" + strings.Join(wildcards, "|") + ")$")
 	}
+	return m
+}
 
-	return h
+// matches reports whether the label key matches one of the patterns. A nil matcher matches nothing.
+func (m *labelKeyMatcher) matches(key string) bool {
+	if m == nil {
+		return false
+	}
+	if _, ok := m.exact[key]; ok {
+		return true
+	}
+	return m.wildcard != nil && m.wildcard.MatchString(key)
 }
 
 // Describe implements prometheus.Collector interface.
@@ -142,6 +499,8 @@ func (d *MetricDeduplicator) Describe(ch chan<- *prometheus.Desc) {
 	d.duplicatesTotal.Describe(ch)
 	d.checksTotal.Describe(ch)
 	d.uniqueMetricsGauge.Describe(ch)
+	d.overflowTotal.Describe(ch)
+	d.policyActionsTotal.Describe(ch)
 }
 
 // Collect implements prometheus.Collector interface.
@@ -149,12 +508,31 @@ func (d *MetricDeduplicator) Collect(ch chan<- prometheus.Metric) {
 	d.duplicatesTotal.Collect(ch)
 	d.checksTotal.Collect(ch)
 	d.uniqueMetricsGauge.Collect(ch)
+	d.overflowTotal.Collect(ch)
+	d.policyActionsTotal.Collect(ch)
 }
 
+// Reset starts a new iteration. The signatures of the current iteration are wiped, or rotated into the history
+// when it retains more than one iteration.
 func (d *MetricDeduplicator) Reset() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	d.sentSignatures = make(map[uint64]struct{})
+	if d.historyDepth > 1 {
+		d.history = append([]map[hash.Signature]struct{}{d.sentSignatures}, d.history...)
+		if len(d.history) > d.historyDepth-1 {
+			d.history = d.history[:d.historyDepth-1]
+		}
+	}
+	d.sentSignatures = make(map[hash.Signature]struct{})
+	if d.exactSignatures != nil {
+		d.exactSignatures = make(map[hash.Signature]struct{})
+	}
+	if d.emittedSignatures != nil {
+		d.emittedSignatures = make(map[hash.Signature]struct{})
+	}
+	if d.signatureInputs != nil {
+		d.signatureInputs = make(map[hash.Signature][]string)
+	}
 	d.uniqueMetricsGauge.Set(0)
 }
@@ -17,17 +17,20 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 )
 
 func BenchmarkHashLabels(b *testing.B) {
 	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 	fqName := "benchmark_metric"
 	keys := []string{"region", "zone", "instance", "project", "service", "method", "version"}
 	vals := []string{"us-central1", "us-central1-a", "instance-1", "my-project", "api-service", "get", "v1"}
 
+	ts := time.Now()
+
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		dedup.hashLabels(fqName, keys, vals)
+		dedup.hashLabels("", fqName, keys, vals, ts)
 	}
 }
@@ -14,8 +14,10 @@
 package collectors
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -24,11 +26,18 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
 )
 
+// duplicate returns whether a CheckAndMark call detected a duplicate, leaving out whether it marked the signature.
+func duplicate(isDuplicate, _ bool) bool {
+	return isDuplicate
+}
+
 func TestMetricDeduplicator_CheckAndMark(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	labelKeys := []string{"label1", "label2"}
@@ -36,32 +45,62 @@ func TestMetricDeduplicator_CheckAndMark(t *testing.T) {
 	ts := time.Now()
 
 	// First call should not be a duplicate
-	isDuplicate := dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "First call should not be a duplicate")
 
 	// Second call with same parameters should be a duplicate
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.True(t, isDuplicate, "Second call with same parameters should be a duplicate")
 
 	// Call with different timestamp should not be a duplicate
 	ts2 := ts.Add(time.Second)
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 	assert.True(t, isDuplicate, "Call with different timestamp should be a duplicate")
 
 	// Call with different label values should not be a duplicate
 	labelValues2 := []string{"value1", "different_value"}
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues2, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues2, ts)
 	assert.False(t, isDuplicate, "Call with different label values should not be a duplicate")
 
 	// Call with different metric name should not be a duplicate
 	fqName2 := "different_metric"
-	isDuplicate = dedup.CheckAndMark(fqName2, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName2, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "Call with different metric name should not be a duplicate")
 }
 
+func TestMetricDeduplicator_CheckAndMarkByTimestamp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DedupByTimestamp: true})
+
+	fqName := "test_metric"
+	labelKeys := []string{"label1", "label2"}
+	labelValues := []string{"value1", "value2"}
+	ts := time.Now()
+
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
+	assert.False(t, isDuplicate, "First call should not be a duplicate")
+
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
+	assert.True(t, isDuplicate, "Second call with same parameters should be a duplicate")
+
+	// Call with different timestamp should not be a duplicate
+	ts2 := ts.Add(time.Second)
+	isDuplicate, marked := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
+	assert.False(t, isDuplicate, "Call with different timestamp should not be a duplicate")
+
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
+	assert.True(t, isDuplicate, "Second call with the different timestamp should be a duplicate")
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.uniqueMetricsGauge))
+
+	// Reverting a mark only forgets the signature of its timestamp
+	dedup.RevertMark("", fqName, labelKeys, labelValues, ts2, marked)
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)), "Reverted signature should not be a duplicate")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)), "Other timestamps should still be tracked")
+}
+
 func TestMetricDeduplicator_LabelOrdering(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	ts := time.Now()
@@ -74,36 +113,36 @@ func TestMetricDeduplicator_LabelOrdering(t *testing.T) {
 	labelValues2 := []string{"val_a", "val_b", "val_c"}
 
 	// First call
-	isDuplicate := dedup.CheckAndMark(fqName, labelKeys1, labelValues1, ts)
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys1, labelValues1, ts)
 	assert.False(t, isDuplicate, "First call should not be a duplicate")
 
 	// Second call with same labels but different order should be a duplicate
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys2, labelValues2, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys2, labelValues2, ts)
 	assert.True(t, isDuplicate, "Same labels in different order should be detected as duplicate")
 }
 
 func TestMetricDeduplicator_EmptyLabels(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	ts := time.Now()
 
 	// Test with empty labels
-	isDuplicate := dedup.CheckAndMark(fqName, []string{}, []string{}, ts)
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, []string{}, []string{}, ts)
 	assert.False(t, isDuplicate, "First call with empty labels should not be a duplicate")
 
-	isDuplicate = dedup.CheckAndMark(fqName, []string{}, []string{}, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, []string{}, []string{}, ts)
 	assert.True(t, isDuplicate, "Second call with empty labels should be a duplicate")
 
 	// Test with nil labels
-	isDuplicate = dedup.CheckAndMark(fqName, nil, nil, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, nil, nil, ts)
 	assert.True(t, isDuplicate, "Call with nil labels should be same as empty labels")
 }
 
 func TestMetricDeduplicator_Metrics(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	// Register metrics with a test registry
 	registry := prometheus.NewRegistry()
@@ -124,7 +163,7 @@ func TestMetricDeduplicator_Metrics(t *testing.T) {
 	assert.Equal(t, float64(0), uniqueCount, "Initial unique count should be 0")
 
 	// First call - should increment checks and unique metrics
-	dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 
 	checksCount = testutil.ToFloat64(dedup.checksTotal)
 	duplicatesCount = testutil.ToFloat64(dedup.duplicatesTotal)
@@ -135,7 +174,7 @@ func TestMetricDeduplicator_Metrics(t *testing.T) {
 	assert.Equal(t, float64(1), uniqueCount, "Unique count should be 1")
 
 	// Second call - should increment checks and duplicates
-	dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 
 	checksCount = testutil.ToFloat64(dedup.checksTotal)
 	duplicatesCount = testutil.ToFloat64(dedup.duplicatesTotal)
@@ -147,7 +186,7 @@ func TestMetricDeduplicator_Metrics(t *testing.T) {
 
 	// Third call with different timestamp - should increment checks and unique metrics
 	ts2 := ts.Add(time.Second)
-	dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 
 	checksCount = testutil.ToFloat64(dedup.checksTotal)
 	duplicatesCount = testutil.ToFloat64(dedup.duplicatesTotal)
@@ -160,7 +199,7 @@ func TestMetricDeduplicator_Metrics(t *testing.T) {
 
 func TestMetricDeduplicator_ConcurrentAccess(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	const numGoroutines = 10
 	const numCallsPerGoroutine = 100
@@ -181,7 +220,7 @@ func TestMetricDeduplicator_ConcurrentAccess(t *testing.T) {
 			for j := 0; j < numCallsPerGoroutine; j++ {
 				// Each goroutine calls with the same parameters multiple times
 				// First call should not be duplicate, subsequent calls should be
-				isDuplicate := dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+				isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 
 				if j == 0 {
 					assert.False(t, isDuplicate, "First call from goroutine %d should not be duplicate", goroutineID)
@@ -210,7 +249,7 @@ func TestMetricDeduplicator_ConcurrentAccess(t *testing.T) {
 
 func TestMetricDeduplicator_PrometheusIntegration(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	// Test Describe method
 	ch := make(chan *prometheus.Desc, 10)
@@ -222,7 +261,7 @@ func TestMetricDeduplicator_PrometheusIntegration(t *testing.T) {
 		descriptions = append(descriptions, desc)
 	}
 
-	require.Len(t, descriptions, 3, "Should have exactly 3 metric descriptions")
+	require.Len(t, descriptions, 5, "Should have exactly 5 metric descriptions")
 
 	// Test Collect method
 	metricCh := make(chan prometheus.Metric, 10)
@@ -234,12 +273,12 @@ func TestMetricDeduplicator_PrometheusIntegration(t *testing.T) {
 		metrics = append(metrics, metric)
 	}
 
-	require.Len(t, metrics, 3, "Should have exactly 3 metrics")
+	require.Len(t, metrics, 6, "Should have exactly 6 metrics, one per policy action")
 }
 
 func TestMetricDeduplicator_SliceReuse(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	ts := time.Now()
@@ -275,11 +314,11 @@ func TestMetricDeduplicator_SliceReuse(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			// First call should not be duplicate
-			isDuplicate := dedup.CheckAndMark(fqName+"_"+tc.name, tc.labelKeys, tc.labelValues, ts)
+			isDuplicate, _ := dedup.CheckAndMark("", fqName+"_"+tc.name, tc.labelKeys, tc.labelValues, ts)
 			assert.False(t, isDuplicate, "First call should not be duplicate")
 
 			// Second call should be duplicate
-			isDuplicate = dedup.CheckAndMark(fqName+"_"+tc.name, tc.labelKeys, tc.labelValues, ts)
+			isDuplicate, _ = dedup.CheckAndMark("", fqName+"_"+tc.name, tc.labelKeys, tc.labelValues, ts)
 			assert.True(t, isDuplicate, "Second call should be duplicate")
 		})
 	}
@@ -287,7 +326,7 @@ func TestMetricDeduplicator_SliceReuse(t *testing.T) {
 
 func TestMetricDeduplicator_Reset(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	labelKeys := []string{"label1", "label2"}
@@ -295,22 +334,22 @@ func TestMetricDeduplicator_Reset(t *testing.T) {
 	ts := time.Now()
 
 	// First iteration: Add some metrics
-	isDuplicate := dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "First call should not be a duplicate")
 
 	// Verify it's now marked as seen
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.True(t, isDuplicate, "Second call should be a duplicate")
 
 	// Add another metric with different timestamp - should still be a duplicate
 	// because we ignore timestamps now
 	ts2 := ts.Add(time.Second)
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 	assert.True(t, isDuplicate, "Different timestamp with same labels should still be a duplicate")
 
 	// Add a metric with different labels
 	differentLabelValues := []string{"value3", "value4"}
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, differentLabelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, differentLabelValues, ts)
 	assert.False(t, isDuplicate, "Different labels should not be a duplicate")
 
 	// Verify the unique metrics gauge shows we have 2 unique signatures
@@ -325,17 +364,17 @@ func TestMetricDeduplicator_Reset(t *testing.T) {
 	assert.Equal(t, float64(0), uniqueCount, "Should have 0 unique metrics after reset")
 
 	// After reset, the same metrics should not be considered duplicates
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "After reset, previously seen metric should not be a duplicate")
 
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 	assert.True(t, isDuplicate, "Same labels with different timestamp should still be a duplicate")
 
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, differentLabelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, differentLabelValues, ts)
 	assert.False(t, isDuplicate, "After reset, previously seen metric with different labels should not be a duplicate")
 
 	// But within the same iteration (after reset), duplicates should still be detected
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.True(t, isDuplicate, "Within same iteration after reset, duplicate should be detected")
 
 	// Verify unique count is updated correctly after reset
@@ -345,7 +384,7 @@ func TestMetricDeduplicator_Reset(t *testing.T) {
 
 func TestMetricDeduplicator_ResetBetweenIterations(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	// Simulate multiple scrape iterations with the same metrics
 	fqName := "test_metric"
@@ -356,11 +395,11 @@ func TestMetricDeduplicator_ResetBetweenIterations(t *testing.T) {
 	ts1 := time.Now()
 
 	// First metric in iteration 1
-	isDuplicate := dedup.CheckAndMark(fqName, labelKeys, labelValues, ts1)
+	isDuplicate, _ := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts1)
 	assert.False(t, isDuplicate, "Iteration 1: First occurrence should not be duplicate")
 
 	// Same metric again in iteration 1 - should be duplicate
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts1)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts1)
 	assert.True(t, isDuplicate, "Iteration 1: Same metric should be duplicate")
 
 	// Check metrics before reset
@@ -379,11 +418,11 @@ func TestMetricDeduplicator_ResetBetweenIterations(t *testing.T) {
 	ts2 := ts1.Add(5 * time.Minute) // New scrape interval
 
 	// Same metric in iteration 2 - should NOT be duplicate (clean state)
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 	assert.False(t, isDuplicate, "Iteration 2: Same metric with different timestamp should not be duplicate after reset")
 
 	// Same metric again in iteration 2 - should be duplicate within this iteration
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts2)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts2)
 	assert.True(t, isDuplicate, "Iteration 2: Same metric should be duplicate within iteration")
 
 	// Check final metrics
@@ -412,11 +451,11 @@ func TestMetricDeduplicator_ResetBetweenIterations(t *testing.T) {
 	}
 
 	for _, metric := range metrics {
-		isDuplicate = dedup.CheckAndMark(metric.name, metric.keys, metric.values, ts3)
+		isDuplicate, _ = dedup.CheckAndMark("", metric.name, metric.keys, metric.values, ts3)
 		assert.False(t, isDuplicate, "Iteration 3: New metric %s should not be duplicate", metric.name)
 
 		// Same metric again - should be duplicate
-		isDuplicate = dedup.CheckAndMark(metric.name, metric.keys, metric.values, ts3)
+		isDuplicate, _ = dedup.CheckAndMark("", metric.name, metric.keys, metric.values, ts3)
 		assert.True(t, isDuplicate, "Iteration 3: Repeated metric %s should be duplicate", metric.name)
 	}
 
@@ -427,7 +466,7 @@ func TestMetricDeduplicator_ResetBetweenIterations(t *testing.T) {
 
 func TestMetricDeduplicator_RevertMark(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "test_metric"
 	labelKeys := []string{"label1", "label2"}
@@ -435,11 +474,12 @@ func TestMetricDeduplicator_RevertMark(t *testing.T) {
 	ts := time.Now()
 
 	// Initially, metric should not be marked as duplicate
-	isDuplicate := dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, marked := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "First check should not be duplicate")
+	assert.True(t, marked, "First check should mark the signature")
 
 	// Verify it's now marked (second call should be duplicate)
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.True(t, isDuplicate, "Second check should be duplicate")
 
 	// Get initial gauge value
@@ -447,14 +487,14 @@ func TestMetricDeduplicator_RevertMark(t *testing.T) {
 	assert.Equal(t, 1.0, gaugeValue, "Gauge should show 1 unique metric")
 
 	// Revert the mark
-	dedup.RevertMark(fqName, labelKeys, labelValues, ts)
+	dedup.RevertMark("", fqName, labelKeys, labelValues, ts, marked)
 
 	// Verify gauge was updated
 	gaugeValue = testutil.ToFloat64(dedup.uniqueMetricsGauge)
 	assert.Equal(t, 0.0, gaugeValue, "Gauge should show 0 unique metrics after revert")
 
 	// Verify the metric is no longer marked as duplicate
-	isDuplicate = dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	isDuplicate, _ = dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 	assert.False(t, isDuplicate, "After revert, check should not be duplicate")
 
 	// Verify gauge is back to 1
@@ -464,7 +504,7 @@ func TestMetricDeduplicator_RevertMark(t *testing.T) {
 
 func TestMetricDeduplicator_RevertMarkNonExistent(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "nonexistent_metric"
 	labelKeys := []string{"label1"}
@@ -472,16 +512,17 @@ func TestMetricDeduplicator_RevertMarkNonExistent(t *testing.T) {
 	ts := time.Now()
 
 	// Reverting a non-existent mark should not panic or cause issues
-	dedup.RevertMark(fqName, labelKeys, labelValues, ts)
+	dedup.RevertMark("", fqName, labelKeys, labelValues, ts, false)
 
 	// Gauge should remain 0
 	gaugeValue := testutil.ToFloat64(dedup.uniqueMetricsGauge)
 	assert.Equal(t, 0.0, gaugeValue, "Gauge should remain 0 when reverting non-existent mark")
+	assert.Equal(t, 0.0, testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionReverted)), "Reverting a non-existent mark should not be counted")
 }
 
 func TestMetricDeduplicator_RevertMarkConcurrency(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-	dedup := NewMetricDeduplicator(logger, "test_project")
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
 
 	fqName := "concurrent_metric"
 	labelKeys := []string{"label1"}
@@ -489,7 +530,7 @@ func TestMetricDeduplicator_RevertMarkConcurrency(t *testing.T) {
 	ts := time.Now()
 
 	// Mark a metric first
-	dedup.CheckAndMark(fqName, labelKeys, labelValues, ts)
+	_, marked := dedup.CheckAndMark("", fqName, labelKeys, labelValues, ts)
 
 	// Test concurrent reverts (should be safe due to mutex)
 	var wg sync.WaitGroup
@@ -497,7 +538,7 @@ func TestMetricDeduplicator_RevertMarkConcurrency(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			dedup.RevertMark(fqName, labelKeys, labelValues, ts)
+			dedup.RevertMark("", fqName, labelKeys, labelValues, ts, marked)
 		}()
 	}
 
@@ -507,3 +548,377 @@ func TestMetricDeduplicator_RevertMarkConcurrency(t *testing.T) {
 	gaugeValue := testutil.ToFloat64(dedup.uniqueMetricsGauge)
 	assert.Equal(t, 0.0, gaugeValue, "Gauge should be 0 after concurrent reverts")
 }
+
+func TestMetricDeduplicator_RevertMarkUnmarked(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ts := time.Now()
+	labelKeys := []string{"zone", "pod"}
+
+	t.Run("overflow", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{MaxSignatures: 1})
+		_, marked := dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-1"}, ts)
+		assert.True(t, marked)
+		isDuplicate, marked := dedup.CheckAndMark("", "test_metric", labelKeys, []string{"b", "pod-1"}, ts)
+		assert.False(t, isDuplicate)
+		assert.False(t, marked, "an overflowing signature should not be marked")
+
+		dedup.RevertMark("", "test_metric", labelKeys, []string{"b", "pod-1"}, ts, marked)
+		assert.Zero(t, testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionReverted)), "an unmarked signature should not be reverted")
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-1"}, ts)), "the marked signature should be kept")
+	})
+
+	t.Run("dry run duplicate", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{IgnoreLabels: []string{"pod"}, DryRun: true})
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-1"}, ts)))
+		isDuplicate, marked := dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-2"}, ts)
+		assert.False(t, isDuplicate)
+		assert.False(t, marked, "a duplicate kept in dry run should not mark the signature of the first series")
+
+		dedup.RevertMark("", "test_metric", labelKeys, []string{"a", "pod-2"}, ts, marked)
+		assert.Zero(t, testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionReverted)))
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-2"}, ts)), "the reverted series should be emitted again")
+		assert.Equal(t, float64(2), testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionDryRun)), "the signature of the first series should still be tracked")
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "pod-1"}, ts)))
+	})
+}
+
+func TestMetricDeduplicator_MaxSignatures(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{MaxSignatures: 2})
+
+	fqName := "test_metric"
+	labelKeys := []string{"label1"}
+	ts := time.Now()
+
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)), "First signature should be tracked")
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"b"}, ts)), "Second signature should be tracked")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)), "Tracked signature should still be deduplicated")
+
+	// The cap is reached, new signatures are emitted but not tracked
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"c"}, ts)), "Overflowing signature should not be a duplicate")
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"c"}, ts)), "Untracked signature can not be detected as duplicate")
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.overflowTotal), "Overflow counter should count untracked signatures")
+	assert.Equal(t, float64(1), testutil.ToFloat64(dedup.duplicatesTotal), "Only tracked signatures should be counted as duplicates")
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.uniqueMetricsGauge), "Unique gauge should not grow past the cap")
+	assert.Len(t, dedup.sentSignatures, 2, "Tracked signatures should not grow past the cap")
+
+	// Reset frees room for a new scrape
+	dedup.Reset()
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"c"}, ts)), "After reset, signature should be tracked again")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"c"}, ts)), "After reset, tracked signature should be deduplicated")
+}
+
+func TestMetricDeduplicator_UnlimitedSignatures(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+
+	for i := 0; i < 1000; i++ {
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"id"}, []string{fmt.Sprint(i)}, time.Now())))
+	}
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(dedup.overflowTotal), "Overflow counter should stay at 0 when unlimited")
+	assert.Len(t, dedup.sentSignatures, 1000, "All signatures should be tracked when unlimited")
+}
+
+func TestMetricDeduplicator_PolicyActions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+
+	fqName := "test_metric"
+	labelKeys := []string{"label1"}
+	ts := time.Now()
+
+	// keep first: the first occurrence is marked, every later one is dropped
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)))
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)))
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)))
+
+	// revert: the mark of a metric that could not be emitted is removed
+	isDuplicate, marked := dedup.CheckAndMark("", fqName, labelKeys, []string{"b"}, ts)
+	assert.False(t, isDuplicate)
+	dedup.RevertMark("", fqName, labelKeys, []string{"b"}, ts, marked)
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"b"}, ts)))
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionKeptFirst)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionReverted)))
+
+	expected := `
+# HELP stackdriver_deduplicator_policy_actions_total Total number of actions taken by the deduplication policies.
+# TYPE stackdriver_deduplicator_policy_actions_total counter
+stackdriver_deduplicator_policy_actions_total{action="kept_first",project_id="test_project"} 2
+stackdriver_deduplicator_policy_actions_total{action="reverted",project_id="test_project"} 1
+`
+	require.NoError(t, testutil.CollectAndCompare(dedup, strings.NewReader(expected), "stackdriver_deduplicator_policy_actions_total"))
+}
+
+func TestMetricDeduplicator_DryRun(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{IgnoreLabels: []string{"pod"}, DryRun: true})
+
+	fqName := "test_metric"
+	labelKeys := []string{"zone", "pod"}
+	ts := time.Now()
+
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-1"}, ts)))
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-2"}, ts)), "the duplicates of the policy should not be dropped in dry run")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-1"}, ts)), "the series with the same labels should still be dropped")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-2"}, ts)), "the series with the same labels should still be dropped")
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"b", "pod-1"}, ts)))
+
+	assert.Equal(t, float64(5), testutil.ToFloat64(dedup.checksTotal))
+	assert.Equal(t, float64(3), testutil.ToFloat64(dedup.duplicatesTotal))
+	assert.Equal(t, float64(1), testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionDryRun)), "only the duplicates dropped by the policy alone should be counted as dry run")
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.policyActionsTotal.WithLabelValues(dedupActionKeptFirst)))
+
+	dedup.Reset()
+	isDuplicate, marked := dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-1"}, ts)
+	assert.False(t, isDuplicate, "the series of the previous iteration should not be dropped")
+	dedup.RevertMark("", fqName, labelKeys, []string{"a", "pod-1"}, ts, marked)
+	assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a", "pod-1"}, ts)), "a reverted series should not be dropped")
+}
+
+func TestMetricDeduplicator_DryRunMaxSignatures(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{MaxSignatures: 2, DryRun: true})
+
+	fqName := "test_metric"
+	labelKeys := []string{"zone"}
+	ts := time.Now()
+
+	for _, zone := range []string{"a", "b", "c", "d"} {
+		assert.False(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{zone}, ts)))
+	}
+	assert.Equal(t, float64(2), testutil.ToFloat64(dedup.overflowTotal))
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"d"}, ts)), "the series with the same labels should still be dropped past the cap")
+	assert.True(t, duplicate(dedup.CheckAndMark("", fqName, labelKeys, []string{"a"}, ts)))
+}
+
+func TestMetricDeduplicator_MultipleProjects(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	first := NewMetricDeduplicator(logger, "first_project", MetricDeduplicatorOptions{})
+	second := NewMetricDeduplicator(logger, "second_project", MetricDeduplicatorOptions{})
+
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(first))
+	require.NoError(t, registry.Register(second), "deduplicators of different projects should share one exposition")
+
+	first.CheckAndMark("", "test_metric", []string{"id"}, []string{"a"}, time.Now())
+	for _, id := range []string{"a", "b", "c"} {
+		second.CheckAndMark("", "test_metric", []string{"id"}, []string{id}, time.Now())
+	}
+
+	expected := `
+# HELP stackdriver_deduplicator_unique_metrics Current number of unique metrics being tracked.
+# TYPE stackdriver_deduplicator_unique_metrics gauge
+stackdriver_deduplicator_unique_metrics{project_id="first_project"} 1
+stackdriver_deduplicator_unique_metrics{project_id="second_project"} 3
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "stackdriver_deduplicator_unique_metrics"))
+}
+
+func TestMetricDeduplicator_IgnoreLabels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{IgnoreLabels: []string{"tmp_*", "pod", "*.id"}})
+	ts := time.Now()
+	labelKeys := []string{"zone", "tmp_run", "tmp_", "pod", "request.id", "podname"}
+
+	assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "1", "1", "x", "1", "p"}, ts)))
+	assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "2", "2", "y", "2", "p"}, ts)),
+		"series differing only by ignored labels should be duplicates")
+	assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"b", "1", "1", "x", "1", "p"}, ts)),
+		"series differing by a non ignored label should not be duplicates")
+	assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "1", "1", "x", "1", "q"}, ts)),
+		"an exact key should not match longer keys")
+}
+
+func TestMetricDeduplicator_CheckAndMarkEmitted(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	labelKeys := []string{"zone"}
+
+	untracked := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+	assert.False(t, untracked.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}))
+	assert.False(t, untracked.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}), "the emitted series should not be tracked by default")
+
+	dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{TrackEmitted: true, IgnoreLabels: []string{"zone"}})
+	assert.False(t, dedup.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}))
+	assert.True(t, dedup.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}), "a series emitted twice should be reported")
+	assert.False(t, dedup.CheckAndMarkEmitted("test_metric", labelKeys, []string{"b"}), "the ignored labels should tell the emitted series apart")
+	assert.Zero(t, testutil.ToFloat64(dedup.duplicatesTotal))
+
+	dedup.Reset()
+	assert.False(t, dedup.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}), "a new iteration should not have emitted series")
+
+	capped := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{TrackEmitted: true, MaxSignatures: 1})
+	assert.False(t, capped.CheckAndMarkEmitted("test_metric", labelKeys, []string{"a"}))
+	assert.False(t, capped.CheckAndMarkEmitted("test_metric", labelKeys, []string{"b"}))
+	assert.True(t, capped.CheckAndMarkEmitted("test_metric", labelKeys, []string{"b"}), "a series emitted twice should be reported past the cap")
+}
+
+func TestLabelKeyMatcher(t *testing.T) {
+	m := newLabelKeyMatcher([]string{"tmp_*", "exact", "a*b*c", "dot.*"})
+	for key, expected := range map[string]bool{
+		"tmp_":      true,
+		"tmp_x":     true,
+		"xtmp_":     false,
+		"exact":     true,
+		"exactly":   false,
+		"abc":       true,
+		"a_b_c":     true,
+		"a_b_cd":    false,
+		"dot.x":     true,
+		"dotx":      false,
+		"unrelated": false,
+	} {
+		assert.Equal(t, expected, m.matches(key), key)
+	}
+	assert.False(t, newLabelKeyMatcher(nil).matches("tmp_x"), "no patterns should match nothing")
+}
+
+func TestMetricDeduplicator_HistoryDepth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	labelKeys := []string{"label1"}
+	labelValues := []string{"value1"}
+	ts := time.Now()
+
+	t.Run("depth 1", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DedupByTimestamp: true})
+		for i := 0; i < 3; i++ {
+			assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)), "iteration %d should not remember the previous ones", i)
+			assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)), "iteration %d should deduplicate within itself", i)
+			dedup.Reset()
+		}
+	})
+
+	t.Run("depth 3", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DedupByTimestamp: true, HistoryDepth: 3})
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)))
+		dedup.Reset()
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)), "the point should be retained for the second iteration")
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts.Add(time.Second))), "a new point should not be a duplicate")
+		assert.Equal(t, float64(1), testutil.ToFloat64(dedup.uniqueMetricsGauge), "only the current iteration should be counted")
+		dedup.Reset()
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)), "the point should be retained for the third iteration")
+		dedup.Reset()
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)), "the point should be forgotten after three iterations")
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, labelValues, ts.Add(time.Second))), "the newer point should still be retained")
+	})
+}
+
+func TestMetricDeduplicator_DumpSignatures(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ts := time.Now()
+
+	t.Run("disabled", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+		dedup.CheckAndMark("", "test_metric", []string{"a"}, []string{"1"}, ts)
+		assert.Nil(t, dedup.DumpSignatures(), "signatures should not be retained unless debugging collisions")
+	})
+
+	t.Run("colliding series", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{IgnoreLabels: []string{"pod"}, DebugCollisions: true})
+
+		// Series only differing by an ignored label share their signature
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"zone", "pod"}, []string{"a", "pod-1"}, ts)))
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"pod", "zone"}, []string{"pod-2", "a"}, ts)))
+		assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"zone", "pod"}, []string{"a", "pod-1"}, ts)))
+		assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"zone", "pod"}, []string{"b", "pod-1"}, ts)))
+
+		signature := dedup.hashLabels("", "test_metric", []string{"zone"}, []string{"a"}, ts)
+		dump := dedup.DumpSignatures()
+		assert.Len(t, dump, 2)
+		assert.Equal(t, []string{`test_metric{pod="pod-1",zone="a"}`, `test_metric{pod="pod-2",zone="a"}`}, dump[signature],
+			"both series should be recorded once under their shared signature")
+
+		dedup.Reset()
+		assert.Empty(t, dedup.DumpSignatures(), "reset should forget the signatures of the previous iteration")
+	})
+
+	t.Run("hash collision", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DedupByTimestamp: true, DebugCollisions: true})
+
+		// Genuine 64-bit hash collisions can't be found in a test, record two series under a same signature instead
+		dedup.recordSignatureInput(hash.Signature{42}, "", "first_metric", []string{"a"}, []string{"1"}, ts)
+		dedup.recordSignatureInput(hash.Signature{42}, "", "second_metric", nil, nil, ts)
+
+		assert.Equal(t, map[hash.Signature][]string{{42}: {
+			fmt.Sprintf(`first_metric{a="1"} @%d`, ts.UnixNano()),
+			fmt.Sprintf(`second_metric{} @%d`, ts.UnixNano()),
+		}}, dedup.DumpSignatures())
+	})
+}
+
+func TestMetricDeduplicator_HashSeed(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ts := time.Now()
+	labelKeys, labelValues := []string{"zone"}, []string{"a"}
+
+	unseeded := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+	seeded := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{HashSeed: 42})
+
+	assert.NotEqual(t, unseeded.hashLabels("", "test_metric", labelKeys, labelValues, ts), seeded.hashLabels("", "test_metric", labelKeys, labelValues, ts))
+	assert.Equal(t, seeded.hashLabels("", "test_metric", labelKeys, labelValues, ts), seeded.hashLabels("", "test_metric", labelKeys, labelValues, ts))
+
+	// Seeding changes the signatures, not the deduplication
+	assert.False(t, duplicate(seeded.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)))
+	assert.True(t, duplicate(seeded.CheckAndMark("", "test_metric", labelKeys, labelValues, ts)))
+}
+
+func TestMetricDeduplicator_IncludeResourceType(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ts := time.Now()
+	labelKeys, labelValues := []string{"zone"}, []string{"a"}
+
+	t.Run("disabled", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{})
+		assert.False(t, duplicate(dedup.CheckAndMark("k8s_container", "test_metric", labelKeys, labelValues, ts)))
+		assert.True(t, duplicate(dedup.CheckAndMark("k8s.container", "test_metric", labelKeys, labelValues, ts)), "the resource type should be ignored by default")
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DebugCollisions: true, IncludeResourceType: true})
+		assert.False(t, duplicate(dedup.CheckAndMark("k8s_container", "test_metric", labelKeys, labelValues, ts)))
+		isDuplicate, marked := dedup.CheckAndMark("k8s.container", "test_metric", labelKeys, labelValues, ts)
+		assert.False(t, isDuplicate, "series of distinct resource types should not be duplicates")
+		assert.True(t, duplicate(dedup.CheckAndMark("k8s.container", "test_metric", labelKeys, labelValues, ts)), "series of a same resource type should still be duplicates")
+
+		dedup.RevertMark("k8s.container", "test_metric", labelKeys, labelValues, ts, marked)
+		assert.False(t, duplicate(dedup.CheckAndMark("k8s.container", "test_metric", labelKeys, labelValues, ts)), "the reverted signature should include the resource type")
+		assert.True(t, duplicate(dedup.CheckAndMark("k8s_container", "test_metric", labelKeys, labelValues, ts)))
+
+		inputs := map[string]bool{}
+		for _, series := range dedup.DumpSignatures() {
+			for _, input := range series {
+				inputs[input] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{`k8s_container/test_metric{zone="a"}`: true, `k8s.container/test_metric{zone="a"}`: true}, inputs)
+	})
+}
+
+func TestMetricDeduplicator_HashAlgorithms(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	ts := time.Now()
+	labelKeys := []string{"zone", "instance"}
+
+	for _, algorithm := range hash.Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			hasher, err := hash.NewHasher(algorithm)
+			require.NoError(t, err)
+			dedup := NewMetricDeduplicator(logger, "test_project", MetricDeduplicatorOptions{DedupByTimestamp: true, Hasher: hasher})
+
+			assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "1"}, ts)))
+			assert.True(t, duplicate(dedup.CheckAndMark("", "test_metric", []string{"instance", "zone"}, []string{"1", "a"}, ts)), "label order should not matter")
+			isDuplicate, marked := dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "2"}, ts)
+			assert.False(t, isDuplicate)
+			assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "1"}, ts.Add(time.Second))))
+			assert.False(t, duplicate(dedup.CheckAndMark("", "other_metric", labelKeys, []string{"a", "1"}, ts)))
+
+			dedup.RevertMark("", "test_metric", labelKeys, []string{"a", "2"}, ts, marked)
+			assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "2"}, ts)), "reverted signature should not be a duplicate")
+
+			dedup.Reset()
+			assert.False(t, duplicate(dedup.CheckAndMark("", "test_metric", labelKeys, []string{"a", "1"}, ts)), "reset should forget the signatures")
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/monitoring/v3"
+)
+
+const (
+	// spanContextType is the type of the exemplar attachments holding the span of a trace.
+	spanContextType = "type.googleapis.com/google.monitoring.v3.SpanContext"
+
+	traceIDLabel = "trace_id"
+	spanIDLabel  = "span_id"
+)
+
+// spanNameRE matches the span names of the span contexts, projects/[PROJECT_ID]/traces/[TRACE_ID]/spans/[SPAN_ID].
+var spanNameRE = regexp.MustCompile(`^projects/[^/]+/traces/([^/]+)/spans/([^/]+)$`)
+
+// spanContext is an exemplar attachment, a span context when of the spanContextType type.
+type spanContext struct {
+	Type     string `json:"@type"`
+	SpanName string `json:"spanName"`
+}
+
+// distributionExemplars returns the exemplars of a distribution attached to a span, labelled with its trace_id and
+// span_id, for the histogram of the buckets. An exemplar belongs to the first bucket whose le bound is greater than
+// or equal to its value, the latest exemplar of each bucket being kept.
+func distributionExemplars(dist *monitoring.Distribution, buckets map[float64]uint64) []prometheus.Exemplar {
+	if len(dist.Exemplars) == 0 {
+		return nil
+	}
+	bounds := make([]float64, 0, len(buckets))
+	for bound := range buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	// The latest exemplar by bucket index, len(bounds) being the implicit +Inf bucket
+	latest := map[int]prometheus.Exemplar{}
+	for _, exemplar := range dist.Exemplars {
+		traceID, spanID, ok := exemplarSpan(exemplar)
+		if !ok {
+			continue
+		}
+		timestamp, err := time.Parse(time.RFC3339Nano, exemplar.Timestamp)
+		if err != nil {
+			continue
+		}
+		bucket := sort.SearchFloat64s(bounds, exemplar.Value)
+		if kept, ok := latest[bucket]; ok && !timestamp.After(kept.Timestamp) {
+			continue
+		}
+		latest[bucket] = prometheus.Exemplar{
+			Value:     exemplar.Value,
+			Labels:    prometheus.Labels{traceIDLabel: traceID, spanIDLabel: spanID},
+			Timestamp: timestamp,
+		}
+	}
+
+	indexes := make([]int, 0, len(latest))
+	for bucket := range latest {
+		indexes = append(indexes, bucket)
+	}
+	sort.Ints(indexes)
+	exemplars := make([]prometheus.Exemplar, len(indexes))
+	for i, bucket := range indexes {
+		exemplars[i] = latest[bucket]
+	}
+	return exemplars
+}
+
+// exemplarSpan returns the trace and span IDs of the span context attached to an exemplar, and false when it has
+// none.
+func exemplarSpan(exemplar *monitoring.Exemplar) (string, string, bool) {
+	for _, attachment := range exemplar.Attachments {
+		var span spanContext
+		if err := json.Unmarshal(attachment, &span); err != nil || span.Type != spanContextType {
+			continue
+		}
+		if m := spanNameRE.FindStringSubmatch(span.SpanName); m != nil {
+			return m[1], m[2], true
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/monitoring/v3"
+)
+
+func newSpanExemplar(value float64, timestamp time.Time, traceID, spanID string) *monitoring.Exemplar {
+	attachment := fmt.Sprintf(`{"@type":%q,"spanName":"projects/test-project/traces/%s/spans/%s"}`, spanContextType, traceID, spanID)
+	return &monitoring.Exemplar{
+		Value:       value,
+		Timestamp:   timestamp.Format(time.RFC3339Nano),
+		Attachments: []googleapi.RawMessage{googleapi.RawMessage(attachment)},
+	}
+}
+
+func TestMonitoringCollector_Exemplars(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/latencies"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_latencies"
+	endTime := time.Now().Truncate(time.Minute)
+	newSeries := func() *monitoring.TimeSeries {
+		series := newDistributionPointTimeSeries(descriptor.Type, []float64{1, 10}, []int64{1, 2, 1}, endTime)
+		series.Points[0].Value.DistributionValue.Exemplars = []*monitoring.Exemplar{
+			newSpanExemplar(0.5, endTime.Add(-time.Second), "trace-a", "span-a"),
+			newSpanExemplar(5, endTime.Add(-2*time.Second), "trace-b", "span-b"),
+			newSpanExemplar(7, endTime.Add(-time.Second), "trace-c", "span-c"),
+			newSpanExemplar(8, endTime.Add(-3*time.Second), "trace-d", "span-d"),
+			newSpanExemplar(20, endTime.Add(-time.Second), "trace-e", "span-e"),
+			{Value: 1, Timestamp: endTime.Format(time.RFC3339Nano)},
+		}
+		return series
+	}
+
+	c := newTestCollector(t, MonitoringCollectorOptions{Exemplars: true})
+	metrics := reportPage(t, c, descriptor, newSeries())[fqName]
+	require.Len(t, metrics, 1)
+
+	exemplars := map[float64][3]string{}
+	for _, bucket := range metrics[0].GetHistogram().GetBucket() {
+		if exemplar := bucket.GetExemplar(); exemplar != nil {
+			labels := map[string]string{}
+			for _, label := range exemplar.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			exemplars[bucket.GetUpperBound()] = [3]string{fmt.Sprint(exemplar.GetValue()), labels[traceIDLabel], labels[spanIDLabel]}
+		}
+	}
+	assert.Equal(t, map[float64][3]string{
+		1:           {"0.5", "trace-a", "span-a"},
+		10:          {"7", "trace-c", "span-c"},
+		math.Inf(1): {"20", "trace-e", "span-e"},
+	}, exemplars, "the latest exemplar of each bucket should be attached to it, the exemplars without span left out")
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, newSeries())[fqName]
+		require.Len(t, metrics, 1)
+		for _, bucket := range metrics[0].GetHistogram().GetBucket() {
+			assert.Nil(t, bucket.GetExemplar(), "no exemplar should be attached to the bucket %v", bucket.GetUpperBound())
+		}
+	})
+}
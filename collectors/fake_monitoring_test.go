@@ -0,0 +1,172 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+var (
+	fakeMetricTypeRE   = regexp.MustCompile(`metric\.type\s*=\s*"([^"]+)"`)
+	fakeMetricPrefixRE = regexp.MustCompile(`metric\.type\s*=\s*starts_with\("([^"]+)"\)`)
+)
+
+// fakeMonitoringAPI is a minimal in-memory implementation of the Monitoring API endpoints used by the
+// collectors.
+type fakeMonitoringAPI struct {
+	mu sync.Mutex
+
+	descriptors        []*monitoring.MetricDescriptor
+	uptimeCheckConfigs []*monitoring.UptimeCheckConfig
+	// series are the time series returned for a metric type
+	series map[string][]*monitoring.TimeSeries
+	// mqlTables are the tables returned for an MQL query
+	mqlTables map[string]*monitoring.QueryTimeSeriesResponse
+	// latency is added to every time series request
+	latency time.Duration
+	// pageSize, if set, splits the time series into pages of pageSize series, the page token being the index of the
+	// first series of the page
+	pageSize int
+	// descriptorHook, if set, can fail a metric descriptors request by returning a non-zero status code
+	descriptorHook func(r *http.Request) int
+	// timeSeriesHook, if set, can fail a time series request by returning a non-zero status code
+	timeSeriesHook func(r *http.Request) int
+
+	descriptorRequests []*http.Request
+	timeSeriesRequests []*http.Request
+	queryRequests      []*monitoring.QueryTimeSeriesRequest
+}
+
+func (f *fakeMonitoringAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+		f.mu.Lock()
+		f.descriptorRequests = append(f.descriptorRequests, r)
+		hook := f.descriptorHook
+		f.mu.Unlock()
+
+		if hook != nil {
+			if code := hook(r); code != 0 {
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+		}
+
+		var descriptors []*monitoring.MetricDescriptor
+		filter := r.URL.Query().Get("filter")
+		for _, d := range f.descriptors {
+			if m := fakeMetricPrefixRE.FindStringSubmatch(filter); m == nil || strings.HasPrefix(d.Type, m[1]) {
+				descriptors = append(descriptors, d)
+			}
+		}
+		writeJSON(w, &monitoring.ListMetricDescriptorsResponse{MetricDescriptors: descriptors})
+	case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+		f.mu.Lock()
+		f.timeSeriesRequests = append(f.timeSeriesRequests, r)
+		hook := f.timeSeriesHook
+		f.mu.Unlock()
+
+		if f.latency > 0 {
+			time.Sleep(f.latency)
+		}
+		if hook != nil {
+			if code := hook(r); code != 0 {
+				http.Error(w, http.StatusText(code), code)
+				return
+			}
+		}
+
+		// Like the real API, the time series are only listed for a single metric type
+		m := fakeMetricTypeRE.FindStringSubmatch(r.URL.Query().Get("filter"))
+		if m == nil {
+			http.Error(w, "the filter must name a single metric type", http.StatusBadRequest)
+			return
+		}
+		series := f.series[m[1]]
+		response := &monitoring.ListTimeSeriesResponse{TimeSeries: series}
+		if f.pageSize > 0 {
+			start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+			end := min(start+f.pageSize, len(series))
+			response.TimeSeries = series[start:end]
+			if end < len(series) {
+				response.NextPageToken = strconv.Itoa(end)
+			}
+		}
+		writeJSON(w, response)
+	case strings.HasSuffix(r.URL.Path, "/timeSeries:query"):
+		var request monitoring.QueryTimeSeriesRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.queryRequests = append(f.queryRequests, &request)
+		f.mu.Unlock()
+
+		table, ok := f.mqlTables[request.Query]
+		if !ok {
+			http.Error(w, "invalid query", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, table)
+	case strings.HasSuffix(r.URL.Path, "/uptimeCheckConfigs"):
+		writeJSON(w, &monitoring.ListUptimeCheckConfigsResponse{UptimeCheckConfigs: f.uptimeCheckConfigs})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeMonitoringAPI) timeSeriesRequestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timeSeriesRequests)
+}
+
+func (f *fakeMonitoringAPI) descriptorRequestCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.descriptorRequests)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// newFakeMonitoringService starts the fake API and returns a monitoring.Service targeting it.
+func newFakeMonitoringService(t testing.TB, api *fakeMonitoringAPI) *monitoring.Service {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	service, err := monitoring.NewService(context.Background(),
+		option.WithEndpoint(server.URL+"/"),
+		option.WithHTTPClient(server.Client()),
+	)
+	require.NoError(t, err)
+	return service
+}
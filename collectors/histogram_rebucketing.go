@@ -0,0 +1,97 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// HistogramRebucketMode decides how the count of a source bucket is split across the target buckets it overlaps
+// when re-bucketing distributions.
+type HistogramRebucketMode string
+
+const (
+	// HistogramRebucketProportional splits the count of a source bucket proportionally to the width of its part
+	// below each target bound, assuming evenly spread values, the default.
+	HistogramRebucketProportional HistogramRebucketMode = "proportional"
+	// HistogramRebucketConservative counts a source bucket in a target bucket only once the target bound is above
+	// the whole source bucket, never counting a value below a bound it may exceed.
+	HistogramRebucketConservative HistogramRebucketMode = "conservative"
+)
+
+// parseHistogramBuckets returns the target bucket bounds sorted, and the re-bucketing mode, an empty mode being
+// the default proportional one.
+func parseHistogramBuckets(bounds []float64, mode HistogramRebucketMode) ([]float64, HistogramRebucketMode, error) {
+	switch mode {
+	case "":
+		mode = HistogramRebucketProportional
+	case HistogramRebucketProportional, HistogramRebucketConservative:
+	default:
+		return nil, "", fmt.Errorf("invalid histogram re-bucketing mode %q, it must be %s or %s", mode, HistogramRebucketProportional, HistogramRebucketConservative)
+	}
+
+	sorted := make([]float64, len(bounds))
+	copy(sorted, bounds)
+	sort.Float64s(sorted)
+	for i, bound := range sorted {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return nil, "", fmt.Errorf("invalid histogram bucket bound %v, it must be finite", bound)
+		}
+		if i > 0 && bound == sorted[i-1] {
+			return nil, "", fmt.Errorf("duplicate histogram bucket bound %v", bound)
+		}
+	}
+	return sorted, mode, nil
+}
+
+// rebucketHistogram returns the cumulative buckets of a histogram re-bucketed into the target bounds, along with the
+// +Inf bucket holding the total count. The count of a target bucket is exact when its bound is a source bound, and
+// estimated from the source bucket containing it by the mode otherwise. Source buckets with an infinite bound, the
+// underflow and overflow buckets, are always split conservatively.
+func rebucketHistogram(buckets map[float64]uint64, bounds []float64, mode HistogramRebucketMode) map[float64]uint64 {
+	sourceBounds := make([]float64, 0, len(buckets))
+	for bound := range buckets {
+		sourceBounds = append(sourceBounds, bound)
+	}
+	sort.Float64s(sourceBounds)
+
+	rebucketed := make(map[float64]uint64, len(bounds)+1)
+	for _, bound := range bounds {
+		// The source bucket containing the target bound, its upper bound being the first one not below it
+		i := sort.SearchFloat64s(sourceBounds, bound)
+		switch {
+		case i == len(sourceBounds):
+			// Above the highest source bound, which can only happen without an +Inf source bucket
+			rebucketed[bound] = buckets[sourceBounds[len(sourceBounds)-1]]
+		case sourceBounds[i] == bound:
+			rebucketed[bound] = buckets[bound]
+		case i == 0:
+			// The lower bound of the underflow bucket is unknown
+			rebucketed[bound] = 0
+		default:
+			lower, upper := sourceBounds[i-1], sourceBounds[i]
+			below := buckets[lower]
+			if mode == HistogramRebucketProportional && !math.IsInf(upper, 1) {
+				below += uint64(math.Floor((bound - lower) / (upper - lower) * float64(buckets[upper]-buckets[lower])))
+			}
+			rebucketed[bound] = below
+		}
+	}
+	if len(sourceBounds) > 0 {
+		rebucketed[math.Inf(1)] = buckets[sourceBounds[len(sourceBounds)-1]]
+	}
+	return rebucketed
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+func TestRebucketHistogram(t *testing.T) {
+	inf := math.Inf(1)
+	// 2 values below 1, 10 in (1, 5], 4 in (5, 10] and 3 above 10
+	source := map[float64]uint64{1: 2, 5: 12, 10: 16, inf: 19}
+
+	for _, tc := range []struct {
+		name   string
+		bounds []float64
+		mode   HistogramRebucketMode
+		want   map[float64]uint64
+	}{
+		{
+			name:   "exact bounds",
+			bounds: []float64{1, 10},
+			mode:   HistogramRebucketProportional,
+			want:   map[float64]uint64{1: 2, 10: 16, inf: 19},
+		},
+		{
+			name:   "proportional",
+			bounds: []float64{3, 7.5},
+			mode:   HistogramRebucketProportional,
+			want:   map[float64]uint64{3: 7, 7.5: 14, inf: 19},
+		},
+		{
+			name:   "conservative",
+			bounds: []float64{3, 7.5},
+			mode:   HistogramRebucketConservative,
+			want:   map[float64]uint64{3: 2, 7.5: 12, inf: 19},
+		},
+		{
+			name:   "underflow and overflow",
+			bounds: []float64{0.5, 20},
+			mode:   HistogramRebucketProportional,
+			want:   map[float64]uint64{0.5: 0, 20: 16, inf: 19},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rebucketHistogram(source, tc.bounds, tc.mode)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, source[inf], got[inf], "the total count should be preserved")
+		})
+	}
+}
+
+func TestParseHistogramBuckets(t *testing.T) {
+	bounds, mode, err := parseHistogramBuckets([]float64{10, 1, 5}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1, 5, 10}, bounds)
+	assert.Equal(t, HistogramRebucketProportional, mode)
+
+	for _, tc := range []struct {
+		name   string
+		bounds []float64
+		mode   HistogramRebucketMode
+	}{
+		{name: "infinite bound", bounds: []float64{1, math.Inf(1)}},
+		{name: "NaN bound", bounds: []float64{math.NaN()}},
+		{name: "duplicate bound", bounds: []float64{1, 1}},
+		{name: "unknown mode", bounds: []float64{1}, mode: "linear"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := parseHistogramBuckets(tc.bounds, tc.mode)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMonitoringCollector_HistogramBuckets(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/latencies"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_latencies"
+	c := newTestCollector(t, MonitoringCollectorOptions{HistogramBuckets: []float64{10, 2.5}})
+
+	metrics := reportPage(t, c, descriptor, newDistributionPointTimeSeries(descriptor.Type, []float64{1, 5, 10}, []int64{2, 10, 4, 3}, time.Now()))
+
+	require.Len(t, metrics[fqName], 1)
+	histogram := metrics[fqName][0].GetHistogram()
+	assert.Equal(t, uint64(19), histogram.GetSampleCount())
+	got := map[float64]uint64{}
+	for _, bucket := range histogram.GetBucket() {
+		got[bucket.GetUpperBound()] = bucket.GetCumulativeCount()
+	}
+	assert.Equal(t, map[float64]uint64{2.5: 5, 10: 16, math.Inf(1): 19}, got)
+}
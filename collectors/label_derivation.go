@@ -0,0 +1,81 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// LabelDerivation derives the Target label from the value of the Source label when the value fully matches Regex,
+// e.g. the region us-central1 from the zone us-central1-a with the regex (.+)-[a-z] and the replacement $1.
+type LabelDerivation struct {
+	// Source is the name of the label whose value is matched, as reported, i.e. once renamed or prefixed.
+	Source string
+	// Target is the name of the derived label, its value being overridden if the series already has it.
+	Target string
+	// Regex is matched against the whole value of the Source label.
+	Regex string
+	// Replace is the value of the Target label, where $1 refers to the first capture group of Regex and so on.
+	Replace string
+}
+
+// labelDerivation is a LabelDerivation with its regex compiled.
+type labelDerivation struct {
+	source  string
+	target  string
+	regex   *regexp.Regexp
+	replace string
+}
+
+// compileLabelDerivations checks the names and compiles the regexes of the derivations.
+func compileLabelDerivations(derivations []LabelDerivation) ([]labelDerivation, error) {
+	var compiled []labelDerivation
+	for _, derivation := range derivations {
+		if !labelNameRE.MatchString(derivation.Target) {
+			return nil, fmt.Errorf("invalid target label name %q of derivation from %q, it must match %s", derivation.Target, derivation.Source, labelNameRE)
+		}
+		if derivation.Source == "" {
+			return nil, fmt.Errorf("empty source label of derivation to %q", derivation.Target)
+		}
+		regex, err := regexp.Compile("^(?:" + derivation.Regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex of derivation from %q to %q: %w", derivation.Source, derivation.Target, err)
+		}
+		compiled = append(compiled, labelDerivation{
+			source:  derivation.Source,
+			target:  derivation.Target,
+			regex:   regex,
+			replace: derivation.Replace,
+		})
+	}
+	return compiled, nil
+}
+
+// deriveLabels applies the label derivations in order, a derivation seeing the labels derived by the previous ones.
+// The labels whose value does not match are left untouched.
+func (c *MonitoringCollector) deriveLabels(labels *labelSet) {
+	for _, derivation := range c.labelDerivations {
+		i := labels.IndexOf(derivation.source)
+		if i == -1 {
+			continue
+		}
+		value := labels.values[i]
+		match := derivation.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			continue
+		}
+		labels.Override(derivation.target, string(derivation.regex.ExpandString(nil, derivation.replace, value, match)))
+	}
+}
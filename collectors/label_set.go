@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/api/googleapi"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+// labelValueHashSuffixLength is the length of the suffix replacing the end of the truncated label values, a dash
+// followed by 8 hexadecimal digits of the hash of the original value.
+const labelValueHashSuffixLength = 9
+
+// labelSet is an ordered set of labels kept as the parallel slices of keys and values the metrics are built from.
+// The first value added for a key wins unless overridden, keys being case-sensitive.
+type labelSet struct {
+	keys   []string
+	values []string
+	// dropEmptyValues skips the labels added or overridden with an empty value
+	dropEmptyValues bool
+	// maxValueLength truncates the values added or overridden longer than it, 0 meaning unlimited
+	maxValueLength int
+}
+
+// IndexOf returns the index of the first label with the key, or -1 if there is none.
+func (l *labelSet) IndexOf(key string) int {
+	for i, k := range l.keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Has reports whether the set has a label with the key.
+func (l *labelSet) Has(key string) bool {
+	return l.IndexOf(key) != -1
+}
+
+// Add adds the label unless the set already has the key, and reports whether it was added.
+func (l *labelSet) Add(key, value string) bool {
+	if l.Has(key) || l.skips(value) {
+		return false
+	}
+	value = truncateLabelValue(value, l.maxValueLength)
+	l.keys = append(l.keys, key)
+	l.values = append(l.values, value)
+	return true
+}
+
+// Override sets the value of the label with the key, adding the label if the set does not have it.
+func (l *labelSet) Override(key, value string) {
+	if l.skips(value) {
+		return
+	}
+	value = truncateLabelValue(value, l.maxValueLength)
+	if i := l.IndexOf(key); i != -1 {
+		l.values[i] = value
+		return
+	}
+	l.keys = append(l.keys, key)
+	l.values = append(l.values, value)
+}
+
+// Drop removes the labels whose key matches, keeping the order of the others.
+func (l *labelSet) Drop(match func(key string) bool) {
+	kept := 0
+	for i, key := range l.keys {
+		if match(key) {
+			continue
+		}
+		l.keys[kept] = key
+		l.values[kept] = l.values[i]
+		kept++
+	}
+	l.keys = l.keys[:kept]
+	l.values = l.values[:kept]
+}
+
+// skips reports whether a label with the value is left out of the set.
+func (l *labelSet) skips(value string) bool {
+	return l.dropEmptyValues && value == ""
+}
+
+// Merge adds the fields of a JSON object as labels, in their order in the object. name returns the label name of a
+// field key, or false to skip the field. Anything but a JSON object is ignored.
+func (l *labelSet) Merge(raw googleapi.RawMessage, name func(key string) (string, bool)) {
+	// Early exit for empty, null, or invalid JSON
+	if len(raw) == 0 {
+		return
+	}
+
+	result := gjson.ParseBytes(raw)
+
+	// Early exit if the result is not a valid object or is null/empty
+	if !result.Exists() || !result.IsObject() {
+		return
+	}
+
+	result.ForEach(func(key, value gjson.Result) bool {
+		if labelName, ok := name(key.String()); ok {
+			l.Add(labelName, value.String())
+		}
+		return true // continue iteration
+	})
+}
+
+// truncateLabelValue returns the value truncated to maxLength bytes when longer, its end being replaced by a hash of
+// the whole value so that values sharing a prefix stay distinct. The value is cut on a rune boundary, and returned
+// as is for a maxLength of 0.
+func truncateLabelValue(value string, maxLength int) string {
+	if maxLength <= 0 || len(value) <= maxLength {
+		return value
+	}
+	suffix := fmt.Sprintf("-%08x", uint32(hash.Add(hash.New(), value)))
+	cut := max(maxLength-len(suffix), 0)
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut] + suffix
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+// withLabelSet runs f over a label set of the label slices and writes the resulting labels back to them.
+func withLabelSet(labelKeys, labelValues *[]string, f func(labels *labelSet)) {
+	labels := &labelSet{keys: *labelKeys, values: *labelValues}
+	f(labels)
+	*labelKeys, *labelValues = labels.keys, labels.values
+}
+
+func TestLabelSet(t *testing.T) {
+	labels := &labelSet{}
+	assert.True(t, labels.Add("zone", "us-east1-b"))
+	assert.True(t, labels.Add("Zone", "us-west1-a"), "keys should be case-sensitive")
+	assert.False(t, labels.Add("zone", "europe-west1-b"), "the first value should win")
+	assert.True(t, labels.Has("Zone"))
+	assert.False(t, labels.Has("ZONE"))
+	assert.Equal(t, 1, labels.IndexOf("Zone"))
+	assert.Equal(t, -1, labels.IndexOf("region"))
+
+	labels.Override("zone", "europe-west1-b")
+	labels.Override("region", "europe-west1")
+	assert.Equal(t, []string{"zone", "Zone", "region"}, labels.keys)
+	assert.Equal(t, []string{"europe-west1-b", "us-west1-a", "europe-west1"}, labels.values)
+}
+
+func TestLabelSet_Merge(t *testing.T) {
+	labels := &labelSet{keys: []string{"zone"}, values: []string{"us-east1-b"}}
+	labels.Merge(googleapi.RawMessage(`{"zone": "europe-west1-b", "Instance-Name": "vm", "skipped": "x", "count": 3}`), func(key string) (string, bool) {
+		return strings.ToLower(key), key != "skipped"
+	})
+	assert.Equal(t, []string{"zone", "instance-name", "count"}, labels.keys)
+	assert.Equal(t, []string{"us-east1-b", "vm", "3"}, labels.values)
+
+	for _, raw := range []string{"", "null", `["zone"]`, `"zone"`, "{invalid"} {
+		labels.Merge(googleapi.RawMessage(raw), func(key string) (string, bool) { return key, true })
+	}
+	assert.Len(t, labels.keys, 3, "anything but a JSON object should be ignored")
+}
+
+func TestLabelSet_DropEmptyValues(t *testing.T) {
+	labels := &labelSet{dropEmptyValues: true}
+	assert.False(t, labels.Add("empty", ""))
+	assert.True(t, labels.Add("whitespace", " "))
+	labels.Override("overridden", "")
+	labels.Override("whitespace", "")
+	labels.Merge(googleapi.RawMessage(`{"merged": "", "kept": "value"}`), func(key string) (string, bool) { return key, true })
+
+	assert.Equal(t, []string{"whitespace", "kept"}, labels.keys)
+	assert.Equal(t, []string{" ", "value"}, labels.values)
+}
+
+func TestLabelSet_Drop(t *testing.T) {
+	labels := &labelSet{keys: []string{"unit", "instance_id", "zone", "pod_uid"}, values: []string{"By", "1", "us-central1-a", "uid"}}
+	labels.Drop(newLabelKeyMatcher([]string{"instance_id", "pod_*"}).matches)
+
+	assert.Equal(t, []string{"unit", "zone"}, labels.keys)
+	assert.Equal(t, []string{"By", "us-central1-a"}, labels.values)
+}
+
+func TestLabelSet_MaxValueLength(t *testing.T) {
+	long := strings.Repeat("a", 1000)
+	labels := &labelSet{maxValueLength: 64}
+	labels.Add("short", "us-east1-b")
+	labels.Add("long", long)
+	labels.Add("other", long+"b")
+	labels.Override("overridden", long)
+	labels.Merge(googleapi.RawMessage(`{"merged": "`+long+`"}`), func(key string) (string, bool) { return key, true })
+
+	assert.Equal(t, "us-east1-b", labels.values[0], "short values should pass through")
+	for _, value := range labels.values[1:] {
+		assert.Len(t, value, 64)
+		assert.True(t, strings.HasPrefix(value, strings.Repeat("a", 64-labelValueHashSuffixLength)))
+	}
+	assert.Equal(t, labels.values[1], labels.values[3], "the hash suffix of a value should be deterministic")
+	assert.Equal(t, labels.values[1], labels.values[4])
+	assert.NotEqual(t, labels.values[1], labels.values[2], "values sharing a prefix should stay distinct")
+
+	assert.Equal(t, long, truncateLabelValue(long, 0))
+	assert.Equal(t, "abc", truncateLabelValue("abc", 3))
+	// The value is cut on a rune boundary
+	truncated := truncateLabelValue(strings.Repeat("é", 20), 16)
+	assert.True(t, utf8.ValidString(truncated))
+	assert.LessOrEqual(t, len(truncated), 16)
+}
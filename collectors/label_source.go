@@ -0,0 +1,57 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// LabelSource is where the labels of a time series come from, the key of the LabelSourcePrefixes.
+type LabelSource string
+
+const (
+	LabelSourceMetric   LabelSource = "metric"
+	LabelSourceResource LabelSource = "resource"
+	LabelSourceSystem   LabelSource = "system"
+	LabelSourceUser     LabelSource = "user"
+)
+
+// LabelSources are the label sources, in the order their labels are merged.
+var LabelSources = []LabelSource{LabelSourceMetric, LabelSourceResource, LabelSourceSystem, LabelSourceUser}
+
+// labelPrefixRE matches the prefixes keeping a valid Prometheus label name valid.
+var labelPrefixRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// parseLabelSourcePrefixes returns the label name prefixes by label source of a source to prefix map.
+func parseLabelSourcePrefixes(prefixes map[string]string) (map[LabelSource]string, error) {
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[LabelSource]string, len(prefixes))
+	for source, prefix := range prefixes {
+		known := false
+		for _, labelSource := range LabelSources {
+			known = known || LabelSource(source) == labelSource
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown label source %q, it must be one of %v", source, LabelSources)
+		}
+		if !labelPrefixRE.MatchString(prefix) {
+			return nil, fmt.Errorf("invalid prefix %q of the %s labels, it must match %s", prefix, source, labelPrefixRE)
+		}
+		parsed[LabelSource(source)] = prefix
+	}
+	return parsed, nil
+}
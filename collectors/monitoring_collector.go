@@ -18,21 +18,79 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/tidwall/gjson"
 	"golang.org/x/net/context"
 	"google.golang.org/api/googleapi"
 	"google.golang.org/api/monitoring/v3"
 
+	"github.com/prometheus-community/stackdriver_exporter/hash"
 	"github.com/prometheus-community/stackdriver_exporter/utils"
 )
 
 const namespace = "stackdriver"
 
+// metricPrefixRE matches the prefixes valid as the first part of a Prometheus metric name.
+var metricPrefixRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// labelNameRE matches the valid Prometheus label names.
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValueTypes are the value types of the metric descriptors, the values of the ValueTypeAllowlist.
+var ValueTypes = []string{"BOOL", "INT64", "DOUBLE", "STRING", "DISTRIBUTION", "MONEY"}
+
+// defaultSystemLabelsSchemaKey is the system label holding the schema version of the metadata payload.
+const defaultSystemLabelsSchemaKey = "__schema__"
+
+// systemLabelsSchemaLabel is the label reporting the system labels schema version. It can't keep the
+// name of the system label, label names starting with __ being reserved by Prometheus.
+const systemLabelsSchemaLabel = "system_labels_schema"
+
+// rawMetricTypeLabel is the label reporting the metric type a series was normalized from.
+const rawMetricTypeLabel = "stackdriver_metric_type"
+
+// scopedProjectIDLabel is the label reporting the metrics scope project the time series were listed from.
+const scopedProjectIDLabel = "scoped_project_id"
+
+// stringValueLabel is the label reporting the value of the STRING metrics.
+const stringValueLabel = "string_value"
+
+// metricKindLabel and valueTypeLabel are the labels reporting the metric kind and value type of a descriptor.
+const (
+	metricKindLabel = "metric_kind"
+	valueTypeLabel  = "value_type"
+)
+
+// launchStageLabel is the label reporting the launch stage of a descriptor, unknownLaunchStage when it has none.
+const (
+	launchStageLabel   = "launch_stage"
+	unknownLaunchStage = "unknown"
+)
+
+// Reasons of the API calls avoided by the collector.
+const (
+	apiCallSavedDescriptorCache = "descriptor_cache"
+	apiCallSavedCoalesced       = "coalesced"
+	apiCallSavedStaleServed     = "stale_served"
+)
+
+// Methods of the API requests made by the collector.
+const (
+	apiMethodListMetricDescriptors = "ListMetricDescriptors"
+	apiMethodListTimeSeries        = "ListTimeSeries"
+)
+
+// MetricFilter is an extra filter added to the time series requests of the metric types starting with
+// TargetedMetricPrefix. An empty TargetedMetricPrefix applies the filter to every metric type.
 type MetricFilter struct {
 	TargetedMetricPrefix string
 	FilterQuery          string
@@ -47,24 +105,117 @@ type MonitoringCollector struct {
 	metricsIngestDelay              bool
 	monitoringService               *monitoring.Service
 	apiCallsTotalMetric             prometheus.Counter
+	apiErrorsTotalMetric            prometheus.Counter
+	scrapeSuccessMetric             *prometheus.GaugeVec
 	scrapesTotalMetric              prometheus.Counter
 	scrapeErrorsTotalMetric         prometheus.Counter
 	lastScrapeErrorMetric           prometheus.Gauge
 	lastScrapeTimestampMetric       prometheus.Gauge
 	lastScrapeDurationSecondsMetric prometheus.Gauge
+	scrapeDurationSecondsMetric     prometheus.Histogram
 	collectorFillMissingLabels      bool
 	monitoringDropDelegatedProjects bool
 	logger                          *slog.Logger
 	counterStore                    DeltaCounterStore
 	histogramStore                  DeltaHistogramStore
 	aggregateDeltas                 bool
+	rawDeltaPrefixes                []string
+	ephemeralDeltaPrefixes          []string
 	descriptorCache                 DescriptorCache
+	descriptorCacheRefresh          atomic.Bool
 	enableSystemLabels              bool
+	emitSystemLabelsSchema          bool
+	systemLabelsSchemaKey           string
+	promotedSystemLabels            []string
 	userLabelsOverride              bool
+	emitDistributionRange           bool
+	retryPolicy                     *retryPolicy
+	circuitBreaker                  *circuitBreaker
+	sanitizeLabelNames              bool
+	metricPrefix                    string
+	splitLargeHistogramCounts       bool
+	histogramToSummaryThreshold     int
+	emitMetricLastPointAge          bool
+	emitAbsentMetrics               bool
+	descriptorPhaseTimeout          time.Duration
+	timeSeriesPhaseTimeout          time.Duration
+	aggregations                    []Aggregation
+	emitRawMetricTypeLabel          bool
+	maxLookback                     time.Duration
+	initialLookback                 time.Duration
+	addMetricKindLabel              bool
+	addResourceTypeLabel            bool
+	addLaunchStageLabel             bool
+	inferMissingDescriptors         bool
+	deltaAggregationTTL             time.Duration
+	dropEmptyLabelValues            bool
+	metricsScopeProject             string
+	projectIDLabelSource            ProjectIDLabelSource
+	normalizeUnits                  bool
+	appendUnitSuffix                bool
+	labelRenames                    map[string]string
+	labelDerivations                []labelDerivation
+	resourceTypeAllowlist           map[string]bool
+	projectIDAllowlist              map[string]bool
+	valueTypeAllowlist              map[string]bool
+	projectIDDenylist               map[string]bool
+	perRequestTimeout               time.Duration
+	emitStringMetrics               bool
+	readiness                       *Readiness
+	dropLabels                      *labelKeyMatcher
+	dedupOnFullLabels               bool
+	nativeHistograms                bool
+	resourceInfos                   *resourceInfos
+	unchangedSeries                 *unchangedSeries
+	counterResets                   *counterResets
+	exemplars                       bool
+	omitPointTimestamps             bool
+	deltaCreatedTimestamps          bool
+	pointSelection                  PointSelection
+	maxLabelValueLength             int
+	maxSeriesPerMetricType          int
+	histogramBuckets                []float64
+	histogramRebucketMode           HistogramRebucketMode
+	labelSourcePrefixes             map[LabelSource]string
+	systemLabelsMode                SystemLabelsMode
+	reloadMu                        sync.RWMutex
+	requestSemaphore                chan struct{}
 	deduplicator                    *MetricDeduplicator
 
+	// requestedTypes are the metric types whose interval was already requested, when initialLookback is set
+	requestedTypes   map[string]bool
+	requestedTypesMu sync.Mutex
+
 	// Metrics for tracking dropped data
 	droppedMetricsTotal *prometheus.CounterVec
+	unitMismatchTotal   *prometheus.CounterVec
+	// malformedSeriesTotal counts the series skipped for having more label keys than values or vice versa
+	malformedSeriesTotal prometheus.Counter
+	// seriesLimitHitTotal counts the time series requests stopped by the max series per metric type
+	seriesLimitHitTotal *prometheus.CounterVec
+	// Metrics for tracking histograms losing precision
+	histogramPrecisionLossTotal *prometheus.CounterVec
+	// counterResetsClampedTotal counts the counters reported at their previous value instead of going backwards
+	counterResetsClampedTotal *prometheus.CounterVec
+
+	// Metrics for tracking API calls avoided by caching and coalescing
+	apiCallsSavedTotal *prometheus.CounterVec
+
+	// Metrics for tracking the API requests
+	apiRequestsTotal          *prometheus.CounterVec
+	apiRequestDurationSeconds *prometheus.HistogramVec
+	// descriptorInferredTotal counts the time series reported with a metric descriptor inferred from the series
+	descriptorInferredTotal *prometheus.CounterVec
+	// descriptorDiscoveryErrorsTotal counts the failed discoveries of the metric types missing from the cached
+	// descriptors
+	descriptorDiscoveryErrorsTotal *prometheus.CounterVec
+	// deltaEntriesMetric and deltaEvictionsTotal track the size and the evictions of the evicting delta stores
+	deltaEntriesMetric  *prometheus.GaugeVec
+	deltaEvictionsTotal *prometheus.CounterVec
+	// maxConcurrencyMetric reports the effective limit of time series requests in flight
+	maxConcurrencyMetric prometheus.Gauge
+
+	metricLastPointAgeMetric *prometheus.GaugeVec
 }
 
 type MonitoringCollectorOptions struct {
@@ -80,7 +231,7 @@ type MonitoringCollectorOptions struct {
 	// RequestOffset is used to offset the requested interval into the past.
 	RequestOffset time.Duration
 	// IngestDelay decides if the ingestion delay specified in the metrics metadata is used when calculating the
-	// request time interval.
+	// request time interval, in place of RequestOffset for the descriptors having one.
 	IngestDelay bool
 	// FillMissingLabels decides if metric labels should be added with empty string to prevent failures due to label inconsistency on metrics.
 	FillMissingLabels bool
@@ -88,14 +239,256 @@ type MonitoringCollectorOptions struct {
 	DropDelegatedProjects bool
 	// AggregateDeltas decides if DELTA metrics should be treated as a counter using the provided counterStore/distributionStore or a gauge
 	AggregateDeltas bool
+	// RawDeltaPrefixes are the metric type prefixes whose DELTA metrics are reported as gauges of their raw per
+	// interval value, bypassing the delta stores, even when AggregateDeltas is set.
+	RawDeltaPrefixes []string
+	// EphemeralDeltaPrefixes are the metric type prefixes whose DELTA metrics are accumulated as short-lived counters
+	// when AggregateDeltas is set: a series new to the counter store starts from 0, reported as a reset, its later
+	// deltas accumulating on top. Suits the series keyed by ephemeral resources, e.g. pods. The distributions are
+	// accumulated as usual.
+	EphemeralDeltaPrefixes []string
+	// ClampCounterResets, if true, will report a CUMULATIVE counter going backwards at its previous value, unless the
+	// interval of its point starts later than the previous one's, a legitimate reset. Realignment and backfill may
+	// otherwise make the counters decrease, Prometheus seeing a spurious reset. The counters not reported for an hour
+	// are forgotten. The histograms are not clamped.
+	ClampCounterResets bool
 	// DescriptorCacheTTL is the TTL on the items in the descriptorCache which caches the MetricDescriptors for a MetricTypePrefix
 	DescriptorCacheTTL time.Duration
 	// DescriptorCacheOnlyGoogle decides whether only google specific descriptors should be cached or all
 	DescriptorCacheOnlyGoogle bool
 	// EnableSystemLabels decides if system labels from metadata should be added to metrics
 	EnableSystemLabels bool
+	// SystemLabelsMode decides how the enabled system labels are added, each as its own label by default, folded
+	// into a single system_labels JSON label, or not at all.
+	SystemLabelsMode SystemLabelsMode
+	// EmitSystemLabelsSchema decides if the schema version found in the system labels under SystemLabelsSchemaKey
+	// should be reported as the system_labels_schema label, instead of as a regular system label.
+	EmitSystemLabelsSchema bool
+	// SystemLabelsSchemaKey is the system label holding the schema version, defaults to __schema__.
+	SystemLabelsSchemaKey string
+	// PromotedSystemLabels are the key prefixes of the system labels added as labels of their own, overriding the
+	// labels of the same name, whatever EnableSystemLabels and SystemLabelsMode. The other system labels are added as
+	// SystemLabelsMode decides.
+	PromotedSystemLabels []string
+	// MetricPrefix is the prefix of the exported Stackdriver metric names, defaults to stackdriver.
+	// The metrics about the exporter itself keep the stackdriver prefix.
+	MetricPrefix string
 	// UserLabelsOverride decides if user labels should override any conflicting labels
 	UserLabelsOverride bool
+	// EmitDistributionRange decides if the range of distributions, when available, should be reported as
+	// <metric>_min and <metric>_max gauges.
+	EmitDistributionRange bool
+	// RetryMaxAttempts is the max number of attempts of a Monitoring API call failing with a transient error
+	// (429 or 503). Values lower than 2 disable retries.
+	RetryMaxAttempts int
+	// RetryBaseDelay is the base delay of the exponential backoff between retries, unless the API asks for a
+	// specific delay through the Retry-After header.
+	RetryBaseDelay time.Duration
+	// RetryBudget, if set, caps the retries across all the collectors sharing it during a scrape.
+	RetryBudget *RetryBudget
+	// CircuitBreakerFailures is the number of consecutive failed scrapes after which the scrapes skip the API calls
+	// for CircuitBreakerCooldown, reporting a failure, before probing the API again. 0 disables the circuit breaker.
+	CircuitBreakerFailures int
+	// CircuitBreakerCooldown is the time the scrapes skip the API calls once the circuit breaker opens.
+	CircuitBreakerCooldown time.Duration
+	// CircuitBreakers, if set, shares the circuit breaker of the project with the other collectors sharing it, the
+	// breaker being created with the CircuitBreakerFailures and CircuitBreakerCooldown of the first collector.
+	CircuitBreakers *CircuitBreakers
+	// Readiness, if set, is marked ready for the project once its metric descriptors are listed successfully.
+	Readiness *Readiness
+	// DropLabels are the label keys left out of the emitted metrics, matched exactly or with * wildcards, e.g.
+	// instance_id or pod_*. The time series only differing by dropped labels are then duplicates, the first one
+	// winning.
+	DropLabels []string
+	// DedupOnFullLabels, if true, will compute the deduplication signatures before dropping the DropLabels, the
+	// series only differing by dropped labels not being duplicates, e.g. for the history of DedupByTimestamp. As their
+	// metrics would collide once emitted, only the first one of a scrape is emitted, the others being dropped.
+	DedupOnFullLabels bool
+	// NativeHistograms, if true, will report the distributions as native histograms when their buckets are
+	// representable, i.e. exponential buckets with a power of two growth factor and scale. The other distributions
+	// and the aggregated DELTA ones are still reported as classic histograms.
+	NativeHistograms bool
+	// Exemplars, if true, will attach the exemplars of the distributions that carry a trace span context to the
+	// buckets of their histograms, labelled with trace_id and span_id, the latest exemplar of each bucket being kept.
+	// The native histograms, the summaries and the aggregated DELTA histograms have none. Exemplars need the
+	// OpenMetrics or protobuf exposition format to be scraped.
+	Exemplars bool
+	// OmitPointTimestamps, if true, will report the metrics without the end time of their point as timestamp,
+	// Prometheus stamping them with the scrape time instead. With the point timestamps, which lag behind the scrape,
+	// Prometheus rejects a point older than the last one ingested for its series as out of order, and does not mark
+	// the series stale once no longer reported. Without them, a point reported by several scrapes is ingested as
+	// several samples.
+	OmitPointTimestamps bool
+	// DeltaCreatedTimestamps, if true, will report the counters accumulated from DELTA metrics with their created
+	// timestamp, the start time of the interval of the first point the counter store accumulated, kept across the
+	// scrapes until the counter is evicted. Exposed by the protobuf format and as _created samples by OpenMetrics.
+	DeltaCreatedTimestamps bool
+	// ResourceInfoMetric, if true, will report the resource labels and the system and user labels of the resource
+	// metadata once per resource as a <prefix>_resource_info gauge of 1. The time series then only keep the
+	// project_id resource label and the resource_id label the info metric is joined on.
+	ResourceInfoMetric bool
+	// PointSelection decides which point of a GAUGE time series is reported when the request interval holds several,
+	// latest by default. Sum and mean aggregate the points of the INT64 and DOUBLE series.
+	PointSelection PointSelection
+	// MaxLabelValueLength, if positive, truncates the label values longer than it, their end being replaced by a
+	// hash of the whole value so that they stay distinct. It must leave room for the 9 bytes of the hash suffix.
+	MaxLabelValueLength int
+	// MaxSeriesPerMetricType, if positive, caps the series retrieved for a metric type per scrape to protect the
+	// memory. Once exceeded, the pagination stops and the series retrieved within the limit are reported. 0 means
+	// unlimited.
+	MaxSeriesPerMetricType int
+	// HistogramBuckets, if set, are the upper bounds the distributions are re-bucketed into, instead of their own
+	// bucket bounds, the +Inf bucket being added. The native histograms are not re-bucketed.
+	HistogramBuckets []float64
+	// HistogramRebucketMode decides how the count of a distribution bucket is split across the HistogramBuckets it
+	// overlaps, proportional by default.
+	HistogramRebucketMode HistogramRebucketMode
+	// LabelSourcePrefixes maps the label sources, metric, resource, system and user, to the prefix of the names of
+	// their labels, e.g. resource to resource_ reporting the region resource label as resource_region, so that labels
+	// of different sources sharing a key no longer collide. Renamed labels are reported under their rename.
+	LabelSourcePrefixes map[string]string
+	// MaxConcurrentRequests caps the number of time series requests in flight for the collector, 0 means unlimited.
+	MaxConcurrentRequests int
+	// SanitizeLabelNames decides if label keys should be converted into valid Prometheus label names, replacing
+	// invalid characters with underscores. On collisions the first label merged wins, labels of a same source
+	// being merged in sorted key order.
+	SanitizeLabelNames bool
+	// DedupMaxSignatures caps the number of metric signatures tracked by the deduplicator per scrape, 0 means unlimited.
+	DedupMaxSignatures int
+	// DedupByTimestamp decides if series with the same labels but points at different timestamps should be kept.
+	DedupByTimestamp bool
+	// DedupIgnoreLabels are the label keys left out when looking for duplicate series, either exact keys or keys with
+	// * wildcards, e.g. tmp_*. Series differing only by these labels are deduplicated.
+	DedupIgnoreLabels []string
+	// DedupHistoryDepth is the number of scrapes the deduplicator retains the signatures of, the current one
+	// included. It requires DedupByTimestamp, a point already reported by one of the previous scrapes then not being
+	// reported again, the series of the next scrapes having the same labels otherwise. 0 and 1 only deduplicate
+	// within a scrape.
+	DedupHistoryDepth int
+	// DedupDebugCollisions, if true, will retain the inputs of the deduplication signatures to log the distinct
+	// series colliding on a signature at debug level, at the cost of memory.
+	DedupDebugCollisions bool
+	// DedupHashSeed seeds the hash of the deduplication signatures so that instances aggregated together have
+	// distinct signatures, 0 keeping the unseeded hash.
+	DedupHashSeed uint64
+	// DedupByResourceType, if true, will include the monitored resource type in the deduplication signatures, so
+	// that a metric and labels reported for two resource types normalized to the same metric name are both kept.
+	// It requires AddResourceTypeLabel, the kept series colliding in the registry otherwise.
+	DedupByResourceType bool
+	// DedupDryRun, if true, will count the duplicates of the deduplication options in the deduplicator metrics without
+	// dropping them, to measure their impact before enforcing them. The metrics reported twice with the same labels
+	// within a scrape are still dropped, as with the deduplication disabled they would fail it.
+	DedupDryRun bool
+	// DedupHashAlgorithm is the hash algorithm of the deduplication signatures, fnv by default or sha256 to make
+	// collisions negligible at some CPU cost.
+	DedupHashAlgorithm string
+	// SkipUnchangedSeries, if true, will not emit a series again while its point has the same timestamp and value as
+	// the one emitted by a previous scrape, reducing the churn of the slowly changing metrics. Prometheus marks a
+	// skipped series stale until it is emitted again, once changed or once UnchangedSeriesMaxAge is elapsed. The
+	// histograms and the aggregated DELTA metrics are always emitted.
+	SkipUnchangedSeries bool
+	// UnchangedSeriesMaxAge is how long an unchanged series is skipped for before being emitted again, bounding how
+	// long it stays stale. It must be positive when SkipUnchangedSeries is enabled.
+	UnchangedSeriesMaxAge time.Duration
+	// CaseInsensitiveMetricNames decides if the metric prefix should be lower-cased, the rest of the exported
+	// names always being lower case. Metric types differing only by case are deduplicated together.
+	CaseInsensitiveMetricNames bool
+	// SplitLargeHistogramCounts decides if distribution counts above 2^53, which lose precision as floats, should
+	// also be reported exactly as <metric>_count_high and <metric>_count_low gauges.
+	SplitLargeHistogramCounts bool
+	// HistogramToSummaryThreshold is the number of buckets above which distributions are reported as summaries with
+	// estimated quantiles instead of histograms, 0 means distributions are always reported as histograms.
+	HistogramToSummaryThreshold int
+	// EmitMetricLastPointAge decides if the age of the newest point of each metric type should be reported.
+	EmitMetricLastPointAge bool
+	// EmitAbsentMetrics, if true, will report a placeholder metric without labels for the metric descriptors having no
+	// time series in a scrape, so that their metric names and HELP and TYPE metadata stay exposed. The placeholders
+	// of the counters and gauges are NaN, those of the distributions empty histograms. The descriptors whose time
+	// series failed to be fetched, or having entries in the delta stores, get none.
+	EmitAbsentMetrics bool
+	// DescriptorPhaseTimeout bounds the time spent listing metric descriptors during a scrape, 0 means unbounded.
+	DescriptorPhaseTimeout time.Duration
+	// TimeSeriesPhaseTimeout bounds the time spent fetching the time series of the metric descriptors of each prefix,
+	// starting once they are all listed so that it is independent of the descriptor listing, 0 means unbounded.
+	TimeSeriesPhaseTimeout time.Duration
+	// Aggregations are the server-side aggregations requested for the time series of the metric types they target.
+	// When several target a metric type, the one with the longest prefix is used.
+	Aggregations []Aggregation
+	// EmitRawMetricTypeLabel decides if the metric type a series was normalized from should be reported as the
+	// stackdriver_metric_type label. The label takes part in deduplication.
+	EmitRawMetricTypeLabel bool
+	// MaxLookback is the oldest the requested interval can start, relative to the scrape time, to avoid requesting
+	// data beyond the retention. 0 means no limit.
+	MaxLookback time.Duration
+	// InitialLookback, if positive, is the length of the interval requested by the first collection of every metric
+	// type, instead of the RequestInterval, so that the points written shortly before startup are not missed. The
+	// later collections request the RequestInterval.
+	InitialLookback time.Duration
+	// AddMetricKindLabel, if true, will add the metric_kind and value_type labels of the metric descriptor to each
+	// emitted metric, unless a label of the same name already exists.
+	AddMetricKindLabel bool
+	// AddResourceTypeLabel, if true, will add the monitored resource type of each series as the resource_type label,
+	// unless a label of the same name already exists. The label takes part in deduplication.
+	AddResourceTypeLabel bool
+	// AddLaunchStageLabel, if true, will add the launch stage of the metric descriptor, e.g. GA or BETA, to each
+	// emitted metric as the launch_stage label, unknown for the descriptors without one, unless a label of the same
+	// name already exists.
+	AddLaunchStageLabel bool
+	// InferMissingDescriptors, if true, will report the time series of the metric types of a prefix missing from its
+	// cached descriptors, e.g. created after the descriptors were cached, with a descriptor inferred from the metric
+	// kind, value type and unit of the series. The metric types are discovered by listing the descriptors of the
+	// prefix again on each scrape served from the cache, a failed discovery not failing the scrape.
+	InferMissingDescriptors bool
+	// DeltaAggregationTTL is how long the delta stores keep the entries of a series no longer collected. The entries
+	// not collected within the TTL are evicted on the next scrape. 0 disables the eviction.
+	DeltaAggregationTTL time.Duration
+	// DropEmptyLabelValues, if true, will leave out the metric, resource, system and user labels with an empty value.
+	// Whitespace-only values are kept.
+	DropEmptyLabelValues bool
+	// MetricsScopeProject is the scoping project of a metrics scope the time series are listed from instead of the
+	// collector project, the project_id resource label still reporting the project each series comes from. The
+	// scoping project is reported as the scoped_project_id label. A single collector must use it, the collectors of
+	// other projects listing the same series from it otherwise.
+	MetricsScopeProject string
+	// ProjectIDLabelSource decides which project populates the project_id label, the project of the resource along
+	// with the scoped_project_id label by default, the project of the resource only, or the scoping project. The
+	// deduplicator sees the label's value, and reports its metrics under the scoping project with the scope source.
+	ProjectIDLabelSource ProjectIDLabelSource
+	// NormalizeUnits, if true, will report the known UCUM units of the unit label by their Prometheus names, e.g. bytes
+	// for By and seconds for s, the dimensionless unit 1 being reported as an empty unit. Unknown units are kept as is.
+	NormalizeUnits bool
+	// AppendUnitSuffix, if true, will suffix the metric names with the Prometheus name of their descriptor unit, e.g.
+	// _bytes, unless the name already ends with it. Dimensionless and unknown units add no suffix.
+	AppendUnitSuffix bool
+	// LabelRenames maps the keys of the metric, resource, system and user labels to the label names they are reported
+	// as, e.g. project_id to gcp_project. A label renamed to the name of another label collides with it like any
+	// other label, the first one added winning unless user labels override. Keys not in the map are left untouched.
+	LabelRenames map[string]string
+	// DeriveLabels derive labels from the values of others, e.g. the region from the zone, applied in order before
+	// the deduplication, so the derived labels take part in it. Combined with DropLabels, they reduce the
+	// cardinality of a label, e.g. collapsing the zones of a region.
+	DeriveLabels []LabelDerivation
+	// ResourceTypeAllowlist are the monitored resource types reported, e.g. gce_instance. The time series of other
+	// resource types are dropped before the deduplication. An empty allowlist reports every resource type.
+	ResourceTypeAllowlist []string
+	// ProjectIDAllowlist are the projects reported by their resource project_id label, e.g. the projects of interest
+	// of a metrics scope. The time series of other projects are dropped before the deduplication. An empty allowlist
+	// reports every project.
+	ProjectIDAllowlist []string
+	// ProjectIDDenylist are the projects dropped by their resource project_id label, even when allowlisted.
+	ProjectIDDenylist []string
+	// ValueTypeAllowlist are the value types of the metric descriptors collected, e.g. DOUBLE. The descriptors of
+	// other value types are skipped once listed, their time series never being requested. An empty allowlist collects
+	// every value type.
+	ValueTypeAllowlist []string
+	// PerRequestTimeout bounds each ListMetricDescriptors and ListTimeSeries request, each retry included, 0 means
+	// unbounded. A request timing out fails its metric type prefix or descriptor only, the others still being
+	// collected.
+	PerRequestTimeout time.Duration
+	// EmitStringMetrics, if true, will report the STRING metrics as gauges of constant 1 with their value as the
+	// string_value label, in the style of info metrics. They are dropped otherwise, every distinct value being a new
+	// series.
+	EmitStringMetrics bool
 }
 
 func isGoogleMetric(name string) bool {
@@ -114,6 +507,13 @@ func (d *googleDescriptorCache) Lookup(prefix string) []*monitoring.MetricDescri
 	return d.inner.Lookup(prefix)
 }
 
+func (d *googleDescriptorCache) LookupStale(prefix string) []*monitoring.MetricDescriptor {
+	if !isGoogleMetric(prefix) {
+		return nil
+	}
+	return d.inner.LookupStale(prefix)
+}
+
 func (d *googleDescriptorCache) Store(prefix string, data []*monitoring.MetricDescriptor) {
 	if !isGoogleMetric(prefix) {
 		return
@@ -131,6 +531,19 @@ type DeltaHistogramStore interface {
 	ListMetrics(metricDescriptorName string) []*HistogramMetric
 }
 
+// EvictingDeltaStore is implemented by the delta stores able to evict their entries for every metric descriptor.
+type EvictingDeltaStore interface {
+	// EvictBefore removes the entries last collected before the given time and returns how many were removed.
+	EvictBefore(t time.Time) int
+	// Len returns the number of entries in the store.
+	Len() int
+}
+
+const (
+	deltaStoreCounter   = "counter"
+	deltaStoreHistogram = "histogram"
+)
+
 func NewMonitoringCollector(projectID string, monitoringService *monitoring.Service, opts MonitoringCollectorOptions, logger *slog.Logger, counterStore DeltaCounterStore, histogramStore DeltaHistogramStore) (*MonitoringCollector, error) {
 	const subsystem = "monitoring"
 
@@ -186,6 +599,27 @@ func NewMonitoringCollector(projectID string, monitoringService *monitoring.Serv
 		},
 	)
 
+	apiErrorsTotalMetric := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "api_errors_total",
+			Help:        "Total number of Google Stackdriver Monitoring API calls that failed.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+	)
+
+	scrapeSuccessMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "scrape_success",
+			Help:        "Whether the last scrape of a metric type prefix from Google Stackdriver Monitoring succeeded (1 for success, 0 for failure).",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type_prefix"},
+	)
+
 	lastScrapeDurationSecondsMetric := prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace:   namespace,
@@ -196,6 +630,17 @@ func NewMonitoringCollector(projectID string, monitoringService *monitoring.Serv
 		},
 	)
 
+	scrapeDurationSecondsMetric := prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "scrape_duration_seconds",
+			Help:        "Duration of the metrics scrapes from Google Stackdriver Monitoring, including the emission of the metrics.",
+			Buckets:     []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+	)
+
 	droppedMetricsTotal := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace:   namespace,
@@ -207,6 +652,274 @@ func NewMonitoringCollector(projectID string, monitoringService *monitoring.Serv
 		[]string{"reason", "metric_type", "resource_type", "metric_kind", "value_type"},
 	)
 
+	malformedSeriesTotal := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "malformed_series_total",
+			Help:        "Total number of time series skipped for having a different number of label keys and label values.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+	)
+
+	seriesLimitHitTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "series_limit_hit_total",
+			Help:        "Total number of time series requests stopped for retrieving more series of a metric type than the max series per metric type.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
+	unitMismatchTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "unit_mismatch_total",
+			Help:        "Total number of time series reported with a unit differing from their metric descriptor unit.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
+	histogramPrecisionLossTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "histogram_precision_loss_total",
+			Help:        "Total number of distributions reported with a count or bucket count too large to be exactly represented as a float.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
+	counterResetsClampedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "counter_resets_clamped_total",
+			Help:        "Total number of counters reported at their previous value instead of going backwards without a reset.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
+	apiCallsSavedTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "api_calls_saved_total",
+			Help:        "Total number of Google Stackdriver Monitoring API calls avoided, by reason.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"reason"},
+	)
+	for _, reason := range []string{apiCallSavedDescriptorCache, apiCallSavedCoalesced, apiCallSavedStaleServed} {
+		apiCallsSavedTotal.WithLabelValues(reason)
+	}
+
+	apiRequestsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "api_requests_total",
+			Help:        "Total number of Google Stackdriver Monitoring API requests, by method and HTTP status code.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"method", "code"},
+	)
+
+	apiRequestDurationSeconds := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "api_request_duration_seconds",
+			Help:        "Duration of the Google Stackdriver Monitoring API requests, by method.",
+			Buckets:     []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"method"},
+	)
+
+	deltaEntriesMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "delta_entries",
+			Help:        "Number of series accumulated in the DELTA metrics stores.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"store"},
+	)
+
+	deltaEvictionsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   subsystem,
+			Name:        "delta_evictions_total",
+			Help:        "Total number of series evicted from the DELTA metrics stores after not being collected within the aggregation TTL.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"store"},
+	)
+
+	maxConcurrencyMetric := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "max_concurrency",
+			Help:        "Max number of time series requests in flight for the project, 0 means unlimited.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+	)
+
+	descriptorInferredTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "descriptor_inferred_total",
+			Help:        "Total number of time series reported with a metric descriptor inferred from the series themselves.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
+	descriptorDiscoveryErrorsTotal := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "descriptor_discovery_errors_total",
+			Help:        "Total number of failed discoveries of the metric types missing from the cached metric descriptors of a prefix.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type_prefix"},
+	)
+
+	metricPrefix := opts.MetricPrefix
+	if metricPrefix == "" {
+		metricPrefix = namespace
+	}
+	if !metricPrefixRE.MatchString(metricPrefix) {
+		return nil, fmt.Errorf("invalid metric prefix %q, it must match %s", metricPrefix, metricPrefixRE)
+	}
+	if opts.CaseInsensitiveMetricNames {
+		metricPrefix = strings.ToLower(metricPrefix)
+	}
+	pointSelection, err := parsePointSelection(opts.PointSelection)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxLabelValueLength < 0 || (opts.MaxLabelValueLength > 0 && opts.MaxLabelValueLength <= labelValueHashSuffixLength) {
+		return nil, fmt.Errorf("invalid max label value length %d, it must be 0 or more than %d", opts.MaxLabelValueLength, labelValueHashSuffixLength)
+	}
+	histogramBuckets, histogramRebucketMode, err := parseHistogramBuckets(opts.HistogramBuckets, opts.HistogramRebucketMode)
+	if err != nil {
+		return nil, err
+	}
+	labelSourcePrefixes, err := parseLabelSourcePrefixes(opts.LabelSourcePrefixes)
+	if err != nil {
+		return nil, err
+	}
+	dedupHasher, err := hash.NewHasher(opts.DedupHashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	systemLabelsMode, err := parseSystemLabelsMode(opts.SystemLabelsMode)
+	if err != nil {
+		return nil, err
+	}
+	projectIDLabelSource, err := parseProjectIDLabelSource(opts.ProjectIDLabelSource)
+	if err != nil {
+		return nil, err
+	}
+	// The deduplicator metrics are attributed to the project reported as project_id
+	dedupProjectID := projectID
+	if projectIDLabelSource == ProjectIDLabelScope && opts.MetricsScopeProject != "" {
+		dedupProjectID = opts.MetricsScopeProject
+	}
+	deduplicator := NewMetricDeduplicator(logger, dedupProjectID, MetricDeduplicatorOptions{
+		MaxSignatures:       opts.DedupMaxSignatures,
+		DedupByTimestamp:    opts.DedupByTimestamp,
+		IgnoreLabels:        opts.DedupIgnoreLabels,
+		HistoryDepth:        opts.DedupHistoryDepth,
+		DebugCollisions:     opts.DedupDebugCollisions,
+		HashSeed:            opts.DedupHashSeed,
+		IncludeResourceType: opts.DedupByResourceType,
+		Hasher:              dedupHasher,
+		DryRun:              opts.DedupDryRun,
+		TrackEmitted:        opts.DedupOnFullLabels && len(opts.DropLabels) > 0,
+	})
+	for key, name := range opts.LabelRenames {
+		if !labelNameRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid label name %q to rename %q to, it must match %s", name, key, labelNameRE)
+		}
+	}
+	for _, aggregation := range opts.Aggregations {
+		if err := aggregation.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	labelDerivations, err := compileLabelDerivations(opts.DeriveLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, valueType := range opts.ValueTypeAllowlist {
+		if !slices.Contains(ValueTypes, valueType) {
+			return nil, fmt.Errorf("invalid value type %q, it must be one of %v", valueType, ValueTypes)
+		}
+	}
+	for _, prefix := range opts.PromotedSystemLabels {
+		if prefix == "" {
+			return nil, errors.New("invalid empty promoted system label prefix")
+		}
+	}
+
+	if opts.DedupHistoryDepth > 1 && !opts.DedupByTimestamp {
+		return nil, fmt.Errorf("invalid dedup history depth %d without dedup by timestamp, the series of every scrape after the first would be dropped", opts.DedupHistoryDepth)
+	}
+
+	if opts.SkipUnchangedSeries && opts.UnchangedSeriesMaxAge <= 0 {
+		return nil, fmt.Errorf("invalid unchanged series max age %s, it must be positive to skip the unchanged series", opts.UnchangedSeriesMaxAge)
+	}
+
+	if opts.DedupByResourceType && !opts.AddResourceTypeLabel {
+		return nil, fmt.Errorf("invalid dedup by resource type without the resource type label, the series of resource types normalized to the same metric name would collide")
+	}
+
+	var breaker *circuitBreaker
+	if opts.CircuitBreakerFailures < 0 {
+		return nil, fmt.Errorf("invalid circuit breaker failures %d, it must not be negative", opts.CircuitBreakerFailures)
+	}
+	if opts.CircuitBreakerFailures > 0 {
+		if opts.CircuitBreakerCooldown <= 0 {
+			return nil, fmt.Errorf("invalid circuit breaker cooldown %s, it must be positive", opts.CircuitBreakerCooldown)
+		}
+		if opts.CircuitBreakers != nil {
+			breaker = opts.CircuitBreakers.get(projectID, opts.CircuitBreakerFailures, opts.CircuitBreakerCooldown)
+		} else {
+			breaker = newCircuitBreaker(projectID, opts.CircuitBreakerFailures, opts.CircuitBreakerCooldown)
+		}
+	}
+
+	systemLabelsSchemaKey := opts.SystemLabelsSchemaKey
+	if systemLabelsSchemaKey == "" {
+		systemLabelsSchemaKey = defaultSystemLabelsSchemaKey
+	}
+
+	metricLastPointAgeMetric := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "collector",
+			Name:        "metric_last_point_age_seconds",
+			Help:        "Age of the newest point of a metric type at the time of the last scrape.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		},
+		[]string{"metric_type"},
+	)
+
 	var descriptorCache DescriptorCache
 	if opts.DescriptorCacheTTL == 0 {
 		descriptorCache = &noopDescriptorCache{}
@@ -226,69 +939,319 @@ func NewMonitoringCollector(projectID string, monitoringService *monitoring.Serv
 		metricsIngestDelay:              opts.IngestDelay,
 		monitoringService:               monitoringService,
 		apiCallsTotalMetric:             apiCallsTotalMetric,
+		apiErrorsTotalMetric:            apiErrorsTotalMetric,
+		scrapeSuccessMetric:             scrapeSuccessMetric,
 		scrapesTotalMetric:              scrapesTotalMetric,
 		scrapeErrorsTotalMetric:         scrapeErrorsTotalMetric,
 		lastScrapeErrorMetric:           lastScrapeErrorMetric,
 		lastScrapeTimestampMetric:       lastScrapeTimestampMetric,
 		lastScrapeDurationSecondsMetric: lastScrapeDurationSecondsMetric,
+		scrapeDurationSecondsMetric:     scrapeDurationSecondsMetric,
 		collectorFillMissingLabels:      opts.FillMissingLabels,
 		monitoringDropDelegatedProjects: opts.DropDelegatedProjects,
 		logger:                          logger,
 		counterStore:                    counterStore,
 		histogramStore:                  histogramStore,
 		aggregateDeltas:                 opts.AggregateDeltas,
+		rawDeltaPrefixes:                opts.RawDeltaPrefixes,
+		ephemeralDeltaPrefixes:          opts.EphemeralDeltaPrefixes,
 		descriptorCache:                 descriptorCache,
 		enableSystemLabels:              opts.EnableSystemLabels,
+		emitSystemLabelsSchema:          opts.EmitSystemLabelsSchema,
+		systemLabelsSchemaKey:           systemLabelsSchemaKey,
+		promotedSystemLabels:            opts.PromotedSystemLabels,
 		userLabelsOverride:              opts.UserLabelsOverride,
-		deduplicator:                    NewMetricDeduplicator(logger, projectID),
+		emitDistributionRange:           opts.EmitDistributionRange,
+		sanitizeLabelNames:              opts.SanitizeLabelNames,
+		metricPrefix:                    metricPrefix,
+		splitLargeHistogramCounts:       opts.SplitLargeHistogramCounts,
+		histogramToSummaryThreshold:     opts.HistogramToSummaryThreshold,
+		emitMetricLastPointAge:          opts.EmitMetricLastPointAge,
+		emitAbsentMetrics:               opts.EmitAbsentMetrics,
+		descriptorPhaseTimeout:          opts.DescriptorPhaseTimeout,
+		timeSeriesPhaseTimeout:          opts.TimeSeriesPhaseTimeout,
+		aggregations:                    opts.Aggregations,
+		emitRawMetricTypeLabel:          opts.EmitRawMetricTypeLabel,
+		maxLookback:                     opts.MaxLookback,
+		initialLookback:                 opts.InitialLookback,
+		requestedTypes:                  map[string]bool{},
+		addMetricKindLabel:              opts.AddMetricKindLabel,
+		addResourceTypeLabel:            opts.AddResourceTypeLabel,
+		addLaunchStageLabel:             opts.AddLaunchStageLabel,
+		inferMissingDescriptors:         opts.InferMissingDescriptors,
+		deltaAggregationTTL:             opts.DeltaAggregationTTL,
+		dropEmptyLabelValues:            opts.DropEmptyLabelValues,
+		metricsScopeProject:             opts.MetricsScopeProject,
+		projectIDLabelSource:            projectIDLabelSource,
+		normalizeUnits:                  opts.NormalizeUnits,
+		appendUnitSuffix:                opts.AppendUnitSuffix,
+		labelRenames:                    opts.LabelRenames,
+		labelDerivations:                labelDerivations,
+		resourceTypeAllowlist:           stringSet(opts.ResourceTypeAllowlist),
+		projectIDAllowlist:              stringSet(opts.ProjectIDAllowlist),
+		valueTypeAllowlist:              stringSet(opts.ValueTypeAllowlist),
+		projectIDDenylist:               stringSet(opts.ProjectIDDenylist),
+		perRequestTimeout:               opts.PerRequestTimeout,
+		emitStringMetrics:               opts.EmitStringMetrics,
+		readiness:                       opts.Readiness,
+		dropLabels:                      newLabelKeyMatcher(opts.DropLabels),
+		dedupOnFullLabels:               opts.DedupOnFullLabels,
+		nativeHistograms:                opts.NativeHistograms,
+		exemplars:                       opts.Exemplars,
+		omitPointTimestamps:             opts.OmitPointTimestamps,
+		deltaCreatedTimestamps:          opts.DeltaCreatedTimestamps,
+		pointSelection:                  pointSelection,
+		maxLabelValueLength:             opts.MaxLabelValueLength,
+		maxSeriesPerMetricType:          opts.MaxSeriesPerMetricType,
+		histogramBuckets:                histogramBuckets,
+		histogramRebucketMode:           histogramRebucketMode,
+		labelSourcePrefixes:             labelSourcePrefixes,
+		systemLabelsMode:                systemLabelsMode,
+		circuitBreaker:                  breaker,
+		retryPolicy:                     newRetryPolicy(opts.RetryMaxAttempts, opts.RetryBaseDelay, opts.RetryBudget),
+		deduplicator:                    deduplicator,
 		droppedMetricsTotal:             droppedMetricsTotal,
+		malformedSeriesTotal:            malformedSeriesTotal,
+		seriesLimitHitTotal:             seriesLimitHitTotal,
+		unitMismatchTotal:               unitMismatchTotal,
+		histogramPrecisionLossTotal:     histogramPrecisionLossTotal,
+		counterResetsClampedTotal:       counterResetsClampedTotal,
+		apiCallsSavedTotal:              apiCallsSavedTotal,
+		apiRequestsTotal:                apiRequestsTotal,
+		apiRequestDurationSeconds:       apiRequestDurationSeconds,
+		descriptorInferredTotal:         descriptorInferredTotal,
+		descriptorDiscoveryErrorsTotal:  descriptorDiscoveryErrorsTotal,
+		deltaEntriesMetric:              deltaEntriesMetric,
+		deltaEvictionsTotal:             deltaEvictionsTotal,
+		maxConcurrencyMetric:            maxConcurrencyMetric,
+		metricLastPointAgeMetric:        metricLastPointAgeMetric,
+	}
+
+	if opts.ResourceInfoMetric {
+		monitoringCollector.resourceInfos = newResourceInfos()
+	}
+
+	if opts.SkipUnchangedSeries {
+		monitoringCollector.unchangedSeries = newUnchangedSeries(opts.UnchangedSeriesMaxAge)
+	}
+
+	if opts.ClampCounterResets {
+		monitoringCollector.counterResets = newCounterResets()
+	}
+
+	if opts.MaxConcurrentRequests > 0 {
+		monitoringCollector.requestSemaphore = make(chan struct{}, opts.MaxConcurrentRequests)
+		maxConcurrencyMetric.Set(float64(opts.MaxConcurrentRequests))
 	}
 
 	return monitoringCollector, nil
 }
 
+// ServedStale counts a scrape served with the metrics collected by a previous one, e.g. from a scrape cache, as
+// saving its API calls.
+func (c *MonitoringCollector) ServedStale() {
+	c.apiCallsSavedTotal.WithLabelValues(apiCallSavedStaleServed).Inc()
+}
+
+// RefreshDescriptorCache forces the metric descriptors to be listed again from the API on the next scrape,
+// regardless of the DescriptorCacheTTL. The cache is then updated with the new descriptors.
+func (c *MonitoringCollector) RefreshDescriptorCache() {
+	c.descriptorCacheRefresh.Store(true)
+}
+
 func (c *MonitoringCollector) Describe(ch chan<- *prometheus.Desc) {
 	c.apiCallsTotalMetric.Describe(ch)
+	c.apiErrorsTotalMetric.Describe(ch)
+	c.scrapeSuccessMetric.Describe(ch)
 	c.scrapesTotalMetric.Describe(ch)
 	c.scrapeErrorsTotalMetric.Describe(ch)
 	c.lastScrapeErrorMetric.Describe(ch)
 	c.lastScrapeTimestampMetric.Describe(ch)
 	c.lastScrapeDurationSecondsMetric.Describe(ch)
 	c.droppedMetricsTotal.Describe(ch)
+	c.malformedSeriesTotal.Describe(ch)
+	c.seriesLimitHitTotal.Describe(ch)
+	if c.circuitBreaker != nil {
+		c.circuitBreaker.Describe(ch)
+	}
+	c.unitMismatchTotal.Describe(ch)
+	c.histogramPrecisionLossTotal.Describe(ch)
+	c.counterResetsClampedTotal.Describe(ch)
+	c.apiCallsSavedTotal.Describe(ch)
+	c.apiRequestsTotal.Describe(ch)
+	c.apiRequestDurationSeconds.Describe(ch)
+	c.descriptorInferredTotal.Describe(ch)
+	c.descriptorDiscoveryErrorsTotal.Describe(ch)
+	c.deltaEntriesMetric.Describe(ch)
+	c.deltaEvictionsTotal.Describe(ch)
+	c.maxConcurrencyMetric.Describe(ch)
+	c.metricLastPointAgeMetric.Describe(ch)
 	c.deduplicator.Describe(ch)
+	c.scrapeDurationSecondsMetric.Describe(ch)
 }
 
 func (c *MonitoringCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(context.Background(), ch)
+}
+
+// CollectContext collects the metrics like Collect, the Monitoring API calls in flight being cancelled and no more
+// calls being made once ctx is done. The metrics of the calls completed by then are still reported, the scrape
+// counting as an error.
+func (c *MonitoringCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	// The reloadable options stay the same for the whole collection
+	c.reloadMu.RLock()
+	defer c.reloadMu.RUnlock()
+
 	var begun = time.Now()
 
+	c.evictDeltaEntries(begun)
+	if c.resourceInfos != nil {
+		c.resourceInfos.Reset()
+	}
+	if c.unchangedSeries != nil {
+		c.unchangedSeries.Reset()
+	}
+	if c.counterResets != nil {
+		c.counterResets.Reset(time.Now())
+	}
+	// A single deduplication iteration covers every prefix of the scrape, so the history retains whole scrapes
+	c.deduplicator.Reset()
+
 	errorMetric := float64(0)
-	if err := c.reportMonitoringMetrics(ch, begun); err != nil {
+	if c.circuitBreaker != nil && !c.circuitBreaker.allow(begun) {
+		// The API is not called, the scrape failing right away
+		errorMetric = float64(1)
+		c.scrapeErrorsTotalMetric.Inc()
+		for _, metricsTypePrefix := range c.metricsTypePrefixes {
+			c.scrapeSuccessMetric.WithLabelValues(metricsTypePrefix).Set(0)
+		}
+		c.logger.Warn("skipping the Google Stackdriver Monitoring API calls, the circuit breaker is open or probing the API")
+	} else if err := c.reportMonitoringMetrics(ctx, ch, begun); err != nil {
 		errorMetric = float64(1)
 		c.scrapeErrorsTotalMetric.Inc()
 		c.logger.Error("Error while getting Google Stackdriver Monitoring metrics", "err", err)
+		// A scrape cancelled by its caller says nothing about the API
+		if c.circuitBreaker != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				c.circuitBreaker.release()
+			} else {
+				c.circuitBreaker.record(time.Now(), true)
+			}
+		}
+	} else if c.circuitBreaker != nil {
+		c.circuitBreaker.record(time.Now(), false)
 	}
-	c.scrapeErrorsTotalMetric.Collect(ch)
-
-	c.apiCallsTotalMetric.Collect(ch)
+	c.updateDeltaEntries()
 
 	c.scrapesTotalMetric.Inc()
-	c.scrapesTotalMetric.Collect(ch)
-
 	c.lastScrapeErrorMetric.Set(errorMetric)
-	c.lastScrapeErrorMetric.Collect(ch)
-
 	c.lastScrapeTimestampMetric.Set(float64(time.Now().Unix()))
-	c.lastScrapeTimestampMetric.Collect(ch)
-
 	c.lastScrapeDurationSecondsMetric.Set(time.Since(begun).Seconds())
-	c.lastScrapeDurationSecondsMetric.Collect(ch)
+	c.collectSelfMetrics(ch)
 
+	// Observed last to cover the emission of every other metric
+	c.scrapeDurationSecondsMetric.Observe(time.Since(begun).Seconds())
+	c.scrapeDurationSecondsMetric.Collect(ch)
+}
+
+// collectSelfMetrics collects the metrics of the collector itself, but the scrape duration histogram.
+func (c *MonitoringCollector) collectSelfMetrics(ch chan<- prometheus.Metric) {
+	c.scrapeErrorsTotalMetric.Collect(ch)
+	c.apiCallsTotalMetric.Collect(ch)
+	c.apiErrorsTotalMetric.Collect(ch)
+	c.scrapeSuccessMetric.Collect(ch)
+	c.scrapesTotalMetric.Collect(ch)
+	c.lastScrapeErrorMetric.Collect(ch)
+	c.lastScrapeTimestampMetric.Collect(ch)
+	c.lastScrapeDurationSecondsMetric.Collect(ch)
 	c.droppedMetricsTotal.Collect(ch)
+	c.malformedSeriesTotal.Collect(ch)
+	c.seriesLimitHitTotal.Collect(ch)
+	if c.circuitBreaker != nil {
+		c.circuitBreaker.Collect(ch)
+	}
+	c.unitMismatchTotal.Collect(ch)
+	c.histogramPrecisionLossTotal.Collect(ch)
+	c.counterResetsClampedTotal.Collect(ch)
+	c.apiCallsSavedTotal.Collect(ch)
+	c.apiRequestsTotal.Collect(ch)
+	c.apiRequestDurationSeconds.Collect(ch)
+	c.descriptorInferredTotal.Collect(ch)
+	c.descriptorDiscoveryErrorsTotal.Collect(ch)
+	c.deltaEntriesMetric.Collect(ch)
+	c.deltaEvictionsTotal.Collect(ch)
+	c.maxConcurrencyMetric.Collect(ch)
+	c.metricLastPointAgeMetric.Collect(ch)
 	c.deduplicator.Collect(ch)
 }
 
-func (c *MonitoringCollector) reportMonitoringMetrics(ch chan<- prometheus.Metric, begun time.Time) error {
-	metricDescriptorsFunction := func(descriptors []*monitoring.MetricDescriptor) error {
+// SelfMetrics returns a collector of the metrics of the collector itself, such as its API calls, deduplication and
+// scrape durations, as of the last scrape. Collecting it does not call the Monitoring API.
+func (c *MonitoringCollector) SelfMetrics() prometheus.Collector {
+	return &selfMetricsCollector{c: c}
+}
+
+// selfMetricsCollector collects the metrics of a MonitoringCollector itself.
+type selfMetricsCollector struct {
+	c *MonitoringCollector
+}
+
+// Describe implements prometheus.Collector interface.
+func (s *selfMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	s.c.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (s *selfMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	s.c.collectSelfMetrics(ch)
+	s.c.scrapeDurationSecondsMetric.Collect(ch)
+}
+
+// evictingDeltaStores returns the delta stores of the collector able to evict their entries, by store name.
+func (c *MonitoringCollector) evictingDeltaStores() map[string]EvictingDeltaStore {
+	stores := map[string]EvictingDeltaStore{}
+	if store, ok := c.counterStore.(EvictingDeltaStore); ok {
+		stores[deltaStoreCounter] = store
+	}
+	if store, ok := c.histogramStore.(EvictingDeltaStore); ok {
+		stores[deltaStoreHistogram] = store
+	}
+	return stores
+}
+
+// evictDeltaEntries evicts the delta store entries not collected within the delta aggregation TTL before now, so
+// the series which stopped being reported, e.g. restarted or relabeled ones, do not accumulate forever.
+func (c *MonitoringCollector) evictDeltaEntries(now time.Time) {
+	if c.deltaAggregationTTL <= 0 {
+		return
+	}
+	for name, store := range c.evictingDeltaStores() {
+		if evicted := store.EvictBefore(now.Add(c.deltaAggregationTTL * -1)); evicted > 0 {
+			c.logger.Debug("evicted delta store entries", "store", name, "evicted", evicted, "ttl", c.deltaAggregationTTL)
+			c.deltaEvictionsTotal.WithLabelValues(name).Add(float64(evicted))
+		}
+	}
+}
+
+// updateDeltaEntries reports the number of entries of the delta stores.
+func (c *MonitoringCollector) updateDeltaEntries() {
+	for name, store := range c.evictingDeltaStores() {
+		c.deltaEntriesMetric.WithLabelValues(name).Set(float64(store.Len()))
+		c.deltaEvictionsTotal.WithLabelValues(name)
+	}
+}
+
+// withPhaseTimeout returns a child context of the scrape context bounding a phase of the scrape or a single request,
+// a zero timeout leaving it unbounded.
+func withPhaseTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (c *MonitoringCollector) reportMonitoringMetrics(ctx context.Context, ch chan<- prometheus.Metric, begun time.Time) error {
+	metricDescriptorsFunction := func(descriptors []*monitoring.MetricDescriptor, missingTypes []string) error {
 		var wg = &sync.WaitGroup{}
 
 		// It has been noticed that the same metric descriptor can be obtained from different GCP
@@ -301,80 +1264,80 @@ func (c *MonitoringCollector) reportMonitoringMetrics(ch chan<- prometheus.Metri
 		//
 		// The following makes sure metric descriptors are unique to avoid fetching more than once
 		uniqueDescriptors := make(map[string]*monitoring.MetricDescriptor)
+		allowed := 0
 		for _, descriptor := range descriptors {
+			if c.valueTypeAllowlist != nil && !c.valueTypeAllowlist[descriptor.ValueType] {
+				c.logger.Debug("skipping metric descriptor of a value type not allowed", "metric", descriptor.Type, "value_type", descriptor.ValueType)
+				continue
+			}
+			allowed++
 			uniqueDescriptors[descriptor.Type] = descriptor
 		}
+		c.apiCallsSavedTotal.WithLabelValues(apiCallSavedCoalesced).Add(float64(allowed - len(uniqueDescriptors)))
 
-		c.deduplicator.Reset()
+		now := time.Now().UTC()
 
-		errChannel := make(chan error, len(uniqueDescriptors))
+		timeSeriesCtx, cancel := withPhaseTimeout(ctx, c.timeSeriesPhaseTimeout)
+		defer cancel()
 
-		endTime := time.Now().UTC().Add(c.metricsOffset * -1)
-		startTime := endTime.Add(c.metricsInterval * -1)
+		// The series of the metric types missing from the descriptors are fetched along with the others, their
+		// descriptor being inferred from their pages
+		inferred := map[string]bool{}
+		for _, metricType := range missingTypes {
+			if _, ok := uniqueDescriptors[metricType]; !ok {
+				uniqueDescriptors[metricType] = &monitoring.MetricDescriptor{Name: metricType, Type: metricType}
+				inferred[metricType] = true
+			}
+		}
+
+		errChannel := make(chan error, len(uniqueDescriptors))
 
 		for _, metricDescriptor := range uniqueDescriptors {
 			wg.Add(1)
-			go func(metricDescriptor *monitoring.MetricDescriptor, ch chan<- prometheus.Metric, startTime, endTime time.Time) {
+			go func(metricDescriptor *monitoring.MetricDescriptor) {
 				defer wg.Done()
-				c.logger.Debug("retrieving Google Stackdriver Monitoring metrics for descriptor", "descriptor", metricDescriptor.Type)
-				filter := fmt.Sprintf("metric.type=\"%s\"", metricDescriptor.Type)
-				if c.monitoringDropDelegatedProjects {
-					filter = fmt.Sprintf(
-						"project=\"%s\" AND metric.type=\"%s\"",
-						c.projectID,
-						metricDescriptor.Type)
-				}
-
-				if c.metricsIngestDelay &&
-					metricDescriptor.Metadata != nil &&
-					metricDescriptor.Metadata.IngestDelay != "" {
-					ingestDelay := metricDescriptor.Metadata.IngestDelay
-					ingestDelayDuration, err := time.ParseDuration(ingestDelay)
-					if err != nil {
-						c.logger.Error("error parsing ingest delay from metric metadata", "descriptor", metricDescriptor.Type, "err", err, "delay", ingestDelay)
-						errChannel <- err
-						return
-					}
-					c.logger.Debug("adding ingest delay", "descriptor", metricDescriptor.Type, "delay", ingestDelay)
-					endTime = endTime.Add(ingestDelayDuration * -1)
-					startTime = startTime.Add(ingestDelayDuration * -1)
-				}
-
-				for _, ef := range c.metricsFilters {
-					if strings.HasPrefix(metricDescriptor.Type, ef.TargetedMetricPrefix) {
-						filter = fmt.Sprintf("%s AND (%s)", filter, ef.FilterQuery)
-					}
+				if err := c.acquireRequestSlot(timeSeriesCtx); err != nil {
+					errChannel <- err
+					return
 				}
-
-				c.logger.Debug("retrieving Google Stackdriver Monitoring metrics with filter", "filter", filter)
-
-				timeSeriesListCall := c.monitoringService.Projects.TimeSeries.List(utils.ProjectResource(c.projectID)).
-					Filter(filter).
-					IntervalStartTime(startTime.Format(time.RFC3339Nano)).
-					IntervalEndTime(endTime.Format(time.RFC3339Nano))
-
-				for {
-					c.apiCallsTotalMetric.Inc()
-					page, err := timeSeriesListCall.Do()
-					if err != nil {
-						c.logger.Error("error retrieving Time Series metrics for descriptor", "descriptor", metricDescriptor.Type, "err", err)
-						errChannel <- err
-						break
+				defer c.releaseRequestSlot()
+
+				// Each page is reported as soon as it is retrieved, so only a page of series is held at a time
+				var newest time.Time
+				var hasSeries bool
+				err := c.fetchTimeSeriesPages(timeSeriesCtx, metricDescriptor, now, func(page *monitoring.ListTimeSeriesResponse) error {
+					hasSeries = hasSeries || len(page.TimeSeries) > 0
+					reportedDescriptor := metricDescriptor
+					if inferred[metricDescriptor.Type] {
+						if len(page.TimeSeries) == 0 {
+							return nil
+						}
+						reportedDescriptor = inferDescriptor(page.TimeSeries[0])
+						c.descriptorInferredTotal.WithLabelValues(metricDescriptor.Type).Add(float64(len(page.TimeSeries)))
 					}
-					if page == nil {
-						break
+					if c.emitMetricLastPointAge {
+						if pageNewest, ok := newestPointTime(page); ok && pageNewest.After(newest) {
+							newest = pageNewest
+						}
 					}
-					if err := c.reportTimeSeriesMetrics(page, metricDescriptor, ch, begun); err != nil {
+					if err := c.reportTimeSeriesMetrics(page, reportedDescriptor, ch, begun); err != nil {
 						c.logger.Error("error reporting Time Series metrics for descriptor", "descriptor", metricDescriptor.Type, "err", err)
-						errChannel <- err
-						break
-					}
-					if page.NextPageToken == "" {
-						break
+						return err
 					}
-					timeSeriesListCall.PageToken(page.NextPageToken)
+					return nil
+				})
+				if err != nil {
+					errChannel <- err
+				}
+				if !newest.IsZero() {
+					c.metricLastPointAgeMetric.WithLabelValues(metricDescriptor.Type).Set(begun.Sub(newest).Seconds())
+				}
+				// A failed fetch says nothing about the series of the descriptor, and the counters aggregated from its
+				// deltas are still reported without new series. An inferred descriptor has no kind to report it with.
+				if c.emitAbsentMetrics && err == nil && !hasSeries && !inferred[metricDescriptor.Type] && !c.hasDeltaEntries(metricDescriptor) {
+					c.reportAbsentMetric(metricDescriptor, ch)
 				}
-			}(metricDescriptor, ch, startTime, endTime)
+			}(metricDescriptor)
 		}
 
 		wg.Wait()
@@ -386,12 +1349,27 @@ func (c *MonitoringCollector) reportMonitoringMetrics(ch chan<- prometheus.Metri
 	var wg = &sync.WaitGroup{}
 
 	errChannel := make(chan error, len(c.metricsTypePrefixes))
+	descriptorCtx, cancel := withPhaseTimeout(ctx, c.descriptorPhaseTimeout)
+	defer cancel()
+	refreshDescriptors := c.descriptorCacheRefresh.Swap(false)
+	// Only report the age of the metric types having points in this scrape
+	c.metricLastPointAgeMetric.Reset()
 
 	for _, metricsTypePrefix := range c.metricsTypePrefixes {
 		wg.Add(1)
 		go func(metricsTypePrefix string) {
 			defer wg.Done()
-			ctx := context.Background()
+
+			var prefixErr error
+			defer func() {
+				success := float64(1)
+				if prefixErr != nil {
+					success = 0
+					errChannel <- prefixErr
+				}
+				c.scrapeSuccessMetric.WithLabelValues(metricsTypePrefix).Set(success)
+			}()
+
 			filter := fmt.Sprintf("metric.type = starts_with(\"%s\")", metricsTypePrefix)
 			if c.monitoringDropDelegatedProjects {
 				filter = fmt.Sprintf(
@@ -400,28 +1378,41 @@ func (c *MonitoringCollector) reportMonitoringMetrics(ch chan<- prometheus.Metri
 					metricsTypePrefix)
 			}
 
-			if cached := c.descriptorCache.Lookup(metricsTypePrefix); cached != nil {
+			var missingTypes []string
+			descriptors := c.descriptorCache.Lookup(metricsTypePrefix)
+			if descriptors != nil && !refreshDescriptors {
 				c.logger.Debug("using cached Google Stackdriver Monitoring metric descriptors starting with", "prefix", metricsTypePrefix)
-				if err := metricDescriptorsFunction(cached); err != nil {
-					errChannel <- err
+				c.apiCallsSavedTotal.WithLabelValues(apiCallSavedDescriptorCache).Inc()
+				c.markReady()
+				if c.inferMissingDescriptors {
+					missingTypes = c.discoverMissingMetricTypes(descriptorCtx, metricsTypePrefix, filter, descriptors)
 				}
 			} else {
-				var cache []*monitoring.MetricDescriptor
-
-				callback := func(r *monitoring.ListMetricDescriptorsResponse) error {
-					c.apiCallsTotalMetric.Inc()
-					cache = append(cache, r.MetricDescriptors...)
-					return metricDescriptorsFunction(r.MetricDescriptors)
-				}
-
+				descriptors = nil
 				c.logger.Debug("listing Google Stackdriver Monitoring metric descriptors starting with", "prefix", metricsTypePrefix)
-				if err := c.monitoringService.Projects.MetricDescriptors.List(utils.ProjectResource(c.projectID)).
-					Filter(filter).
-					Pages(ctx, callback); err != nil {
-					errChannel <- err
+				if err := c.listMetricDescriptors(descriptorCtx, filter, func(r *monitoring.ListMetricDescriptorsResponse) error {
+					c.markReady()
+					descriptors = append(descriptors, r.MetricDescriptors...)
+					return nil
+				}); err != nil {
+					// Do not cache a partial list of descriptors, it would hide metrics until the entry expires, but
+					// still report the time series of the descriptors listed before the error, or of the expired
+					// entry if any
+					prefixErr = err
+					if stale := c.descriptorCache.LookupStale(metricsTypePrefix); stale != nil {
+						c.logger.Warn("serving the expired cached metric descriptors after a listing error", "prefix", metricsTypePrefix, "err", err)
+						c.apiCallsSavedTotal.WithLabelValues(apiCallSavedStaleServed).Inc()
+						descriptors = stale
+					}
+				} else {
+					c.descriptorCache.Store(metricsTypePrefix, descriptors)
 				}
+			}
 
-				c.descriptorCache.Store(metricsTypePrefix, cache)
+			// The time series are fetched once every page of descriptors is listed, so that the descriptor phase
+			// deadline does not run while they are
+			if err := metricDescriptorsFunction(descriptors, missingTypes); err != nil && prefixErr == nil {
+				prefixErr = err
 			}
 		}(metricsTypePrefix)
 	}
@@ -433,6 +1424,325 @@ func (c *MonitoringCollector) reportMonitoringMetrics(ch chan<- prometheus.Metri
 	return <-errChannel
 }
 
+// aggregatesDeltas reports whether the DELTA metrics of the metric type are accumulated into counters, rather than
+// reported as raw gauges.
+func (c *MonitoringCollector) aggregatesDeltas(metricType string) bool {
+	if !c.aggregateDeltas {
+		return false
+	}
+	for _, prefix := range c.rawDeltaPrefixes {
+		if strings.HasPrefix(metricType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// launchStage returns the launch stage of the descriptor, falling back to the deprecated one of its metadata.
+func launchStage(descriptor *monitoring.MetricDescriptor) string {
+	if descriptor.LaunchStage != "" && descriptor.LaunchStage != "LAUNCH_STAGE_UNSPECIFIED" {
+		return descriptor.LaunchStage
+	}
+	if descriptor.Metadata != nil && descriptor.Metadata.LaunchStage != "" && descriptor.Metadata.LaunchStage != "LAUNCH_STAGE_UNSPECIFIED" {
+		return descriptor.Metadata.LaunchStage
+	}
+	return unknownLaunchStage
+}
+
+// ephemeralDeltas reports whether the new series of the DELTA metrics of the metric type start their counter from 0.
+func (c *MonitoringCollector) ephemeralDeltas(metricType string) bool {
+	if !c.aggregatesDeltas(metricType) {
+		return false
+	}
+	for _, prefix := range c.ephemeralDeltaPrefixes {
+		if strings.HasPrefix(metricType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropLabelsFrom removes the DropLabels from the labels of a time series.
+func (c *MonitoringCollector) dropLabelsFrom(labels *labelSet) {
+	if c.dropLabels != nil {
+		labels.Drop(c.dropLabels.matches)
+	}
+}
+
+// markReady marks the project ready once a page of its metric descriptors is listed, whether or not the time series
+// of the descriptors are fetched successfully.
+func (c *MonitoringCollector) markReady() {
+	if c.readiness != nil {
+		c.readiness.MarkReady(c.projectID)
+	}
+}
+
+// timeSeriesProject returns the project the time series are listed from, the metrics scope project if any.
+func (c *MonitoringCollector) timeSeriesProject() string {
+	if c.metricsScopeProject != "" {
+		return c.metricsScopeProject
+	}
+	return c.projectID
+}
+
+// timeSeriesFilter builds the filter listing the time series of a metric descriptor. The extra filters targeting
+// the metric type, or every metric type when their targeted prefix is empty, are AND-combined with it.
+func (c *MonitoringCollector) timeSeriesFilter(metricDescriptor *monitoring.MetricDescriptor) string {
+	filter := fmt.Sprintf("metric.type=\"%s\"", metricDescriptor.Type)
+	if c.monitoringDropDelegatedProjects {
+		filter = fmt.Sprintf(
+			"project=\"%s\" AND metric.type=\"%s\"",
+			c.projectID,
+			metricDescriptor.Type)
+	}
+
+	for _, ef := range c.metricsFilters {
+		if ef.FilterQuery != "" && strings.HasPrefix(metricDescriptor.Type, ef.TargetedMetricPrefix) {
+			filter = fmt.Sprintf("%s AND (%s)", filter, ef.FilterQuery)
+		}
+	}
+	return filter
+}
+
+// newestPointTime returns the end time of the newest point found in a page of time series.
+func newestPointTime(page *monitoring.ListTimeSeriesResponse) (time.Time, bool) {
+	var newest time.Time
+	for _, timeSeries := range page.TimeSeries {
+		if point := newestPoint(timeSeries.Points); point != nil {
+			if endTime, _ := time.Parse(time.RFC3339Nano, point.Interval.EndTime); endTime.After(newest) {
+				newest = endTime
+			}
+		}
+	}
+	return newest, !newest.IsZero()
+}
+
+// requestWindow returns the interval requested for the time series of a metric descriptor at the given time. The
+// interval ends the ingest delay of the descriptor metadata before now when ingest delays are used and the descriptor
+// has one, and the request offset before now otherwise. It starts the request interval before its end, or the initial
+// lookback for the first interval of the metric type, and never more than the max lookback before now.
+func (c *MonitoringCollector) requestWindow(metricDescriptor *monitoring.MetricDescriptor, now time.Time) (startTime, endTime time.Time, err error) {
+	offset := c.metricsOffset
+	if c.metricsIngestDelay &&
+		metricDescriptor.Metadata != nil &&
+		metricDescriptor.Metadata.IngestDelay != "" {
+		ingestDelay := metricDescriptor.Metadata.IngestDelay
+		if offset, err = time.ParseDuration(ingestDelay); err != nil {
+			c.logger.Error("error parsing ingest delay from metric metadata", "descriptor", metricDescriptor.Type, "err", err, "delay", ingestDelay)
+			return time.Time{}, time.Time{}, err
+		}
+		c.logger.Debug("using ingest delay", "descriptor", metricDescriptor.Type, "delay", ingestDelay)
+	}
+
+	endTime = now.Add(offset * -1)
+	startTime = endTime.Add(c.lookback(metricDescriptor.Type) * -1)
+	if c.maxLookback > 0 {
+		if oldest := now.Add(c.maxLookback * -1); startTime.Before(oldest) {
+			c.logger.Warn("clamping the request interval start to the max lookback", "descriptor", metricDescriptor.Type, "start", startTime, "clamped_start", oldest, "max_lookback", c.maxLookback)
+			startTime = oldest
+			if startTime.After(endTime) {
+				startTime = endTime
+			}
+		}
+	}
+	return startTime, endTime, nil
+}
+
+// lookback returns the length of the interval requested for a metric type, the initial lookback the first time it is
+// requested if set and the request interval otherwise.
+func (c *MonitoringCollector) lookback(metricType string) time.Duration {
+	if c.initialLookback <= 0 {
+		return c.metricsInterval
+	}
+	c.requestedTypesMu.Lock()
+	defer c.requestedTypesMu.Unlock()
+	if c.requestedTypes[metricType] {
+		return c.metricsInterval
+	}
+	c.requestedTypes[metricType] = true
+	return c.initialLookback
+}
+
+// fetchTimeSeriesPages lists the time series of a metric descriptor over its request window at the given time,
+// calling pageFunc with each page as it is retrieved. The pagination stops at the first error, either of the request
+// or of pageFunc, the pages retrieved before it having been passed to pageFunc.
+func (c *MonitoringCollector) fetchTimeSeriesPages(ctx context.Context, metricDescriptor *monitoring.MetricDescriptor, now time.Time, pageFunc func(*monitoring.ListTimeSeriesResponse) error) error {
+	c.logger.Debug("retrieving Google Stackdriver Monitoring metrics for descriptor", "descriptor", metricDescriptor.Type)
+	filter := c.timeSeriesFilter(metricDescriptor)
+
+	startTime, endTime, err := c.requestWindow(metricDescriptor, now)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug("retrieving Google Stackdriver Monitoring metrics with filter", "filter", filter)
+
+	timeSeriesListCall := c.monitoringService.Projects.TimeSeries.List(utils.ProjectResource(c.timeSeriesProject())).
+		Filter(filter).
+		IntervalStartTime(startTime.Format(time.RFC3339Nano)).
+		IntervalEndTime(endTime.Format(time.RFC3339Nano))
+	if aggregation, ok := aggregationFor(c.aggregations, metricDescriptor.Type); ok {
+		timeSeriesListCall = aggregation.apply(timeSeriesListCall)
+	}
+
+	retrieved := 0
+	var pageErr error
+	err = c.listTimeSeriesPages(ctx, timeSeriesListCall, func(page *monitoring.ListTimeSeriesResponse) error {
+		retrieved++
+		pageErr = pageFunc(page)
+		return pageErr
+	})
+	if err != nil && pageErr == nil {
+		// The series of the pages retrieved before the error were still reported
+		c.logger.Error("error retrieving Time Series metrics for descriptor", "descriptor", metricDescriptor.Type, "retrieved_pages", retrieved, "err", err)
+	}
+	return err
+}
+
+// listTimeSeriesPages runs a time series list call through every page, calling pageFunc with each page. It stops at
+// the first error, either of the request or of pageFunc. Once a metric type has more series than the max series per
+// metric type, its extra series are left out and the call stops paginating.
+func (c *MonitoringCollector) listTimeSeriesPages(ctx context.Context, timeSeriesListCall *monitoring.ProjectsTimeSeriesListCall, pageFunc func(*monitoring.ListTimeSeriesResponse) error) error {
+	seriesPerMetricType := map[string]int{}
+	for {
+		var page *monitoring.ListTimeSeriesResponse
+		err := c.retryPolicy.do(ctx, func() (err error) {
+			c.apiCallsTotalMetric.Inc()
+			requestCtx, cancel := withPhaseTimeout(ctx, c.perRequestTimeout)
+			defer cancel()
+			requested := time.Now()
+			page, err = timeSeriesListCall.Context(requestCtx).Do()
+			c.observeAPIRequest(apiMethodListTimeSeries, requested, err)
+			if err != nil {
+				c.apiErrorsTotalMetric.Inc()
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if page == nil {
+			return nil
+		}
+		limited := c.limitSeriesPerMetricType(page, seriesPerMetricType)
+		if err := pageFunc(page); err != nil {
+			return err
+		}
+		if limited || page.NextPageToken == "" {
+			return nil
+		}
+		timeSeriesListCall.PageToken(page.NextPageToken)
+	}
+}
+
+// limitSeriesPerMetricType leaves the series of the page beyond the max series per metric type out, counting the
+// series retrieved so far by metric type, and returns whether a metric type hit the limit.
+func (c *MonitoringCollector) limitSeriesPerMetricType(page *monitoring.ListTimeSeriesResponse, seriesPerMetricType map[string]int) bool {
+	if c.maxSeriesPerMetricType <= 0 {
+		return false
+	}
+	limited := map[string]bool{}
+	kept := page.TimeSeries[:0:0]
+	for _, timeSeries := range page.TimeSeries {
+		metricType := timeSeries.Metric.Type
+		if seriesPerMetricType[metricType] >= c.maxSeriesPerMetricType {
+			limited[metricType] = true
+			continue
+		}
+		seriesPerMetricType[metricType]++
+		kept = append(kept, timeSeries)
+	}
+	page.TimeSeries = kept
+	for metricType := range limited {
+		c.seriesLimitHitTotal.WithLabelValues(metricType).Inc()
+		c.logger.Warn("metric type has more series than the max series per metric type, the extra series are left out",
+			"metric", metricType, "max_series_per_metric_type", c.maxSeriesPerMetricType)
+	}
+	return len(limited) > 0
+}
+
+// observeAPIRequest records an API request of the method made at the given time, ended with err.
+func (c *MonitoringCollector) observeAPIRequest(method string, requested time.Time, err error) {
+	c.apiRequestsTotal.WithLabelValues(method, apiRequestCode(err)).Inc()
+	c.apiRequestDurationSeconds.WithLabelValues(method).Observe(time.Since(requested).Seconds())
+}
+
+// apiRequestCode returns the code label of an API request ended with err: the HTTP status code of the response, or
+// the reason it got none.
+func apiRequestCode(err error) string {
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		return strconv.Itoa(http.StatusOK)
+	case errors.As(err, &apiErr):
+		return strconv.Itoa(apiErr.Code)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// acquireRequestSlot blocks until less than MaxConcurrentRequests time series requests are in flight, and returns
+// the error of the context if it is done first, no request having to be made then.
+func (c *MonitoringCollector) acquireRequestSlot(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.requestSemaphore == nil {
+		return nil
+	}
+	select {
+	case c.requestSemaphore <- struct{}{}:
+		// The slot may be acquired along with the context being done
+		if err := ctx.Err(); err != nil {
+			c.releaseRequestSlot()
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *MonitoringCollector) releaseRequestSlot() {
+	if c.requestSemaphore != nil {
+		<-c.requestSemaphore
+	}
+}
+
+// listMetricDescriptors calls callback for each page of metric descriptors matching filter, retrying
+// each page on transient errors.
+func (c *MonitoringCollector) listMetricDescriptors(ctx context.Context, filter string, callback func(*monitoring.ListMetricDescriptorsResponse) error) error {
+	call := c.monitoringService.Projects.MetricDescriptors.List(utils.ProjectResource(c.projectID)).Filter(filter)
+	for {
+		var page *monitoring.ListMetricDescriptorsResponse
+		if err := c.retryPolicy.do(ctx, func() (err error) {
+			c.apiCallsTotalMetric.Inc()
+			requestCtx, cancel := withPhaseTimeout(ctx, c.perRequestTimeout)
+			defer cancel()
+			requested := time.Now()
+			page, err = call.Context(requestCtx).Do()
+			c.observeAPIRequest(apiMethodListMetricDescriptors, requested, err)
+			if err != nil {
+				c.apiErrorsTotalMetric.Inc()
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+		if err := callback(page); err != nil {
+			return err
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		call.PageToken(page.NextPageToken)
+	}
+}
+
 func (c *MonitoringCollector) reportTimeSeriesMetrics(
 	page *monitoring.ListTimeSeriesResponse,
 	metricDescriptor *monitoring.MetricDescriptor,
@@ -441,76 +1751,95 @@ func (c *MonitoringCollector) reportTimeSeriesMetrics(
 ) error {
 	var metricValue float64
 	var metricValueType prometheus.ValueType
-	var newestTSPoint *monitoring.Point
-
-	timeSeriesMetrics, err := newTimeSeriesMetrics(metricDescriptor,
-		ch,
-		c.collectorFillMissingLabels,
-		c.counterStore,
-		c.histogramStore,
-		c.aggregateDeltas,
-	)
-	if err != nil {
-		return fmt.Errorf("error creating the TimeSeriesMetrics %v", err)
-	}
+	aggregateDeltas := c.aggregatesDeltas(metricDescriptor.Type)
+
+	timeSeriesMetrics := newTimeSeriesMetrics(metricDescriptor, ch, timeSeriesMetricsOptions{
+		metricPrefix:                c.metricPrefix,
+		fillMissingLabels:           c.collectorFillMissingLabels,
+		counterStore:                c.counterStore,
+		histogramStore:              c.histogramStore,
+		aggregateDeltas:             aggregateDeltas,
+		ephemeralDeltas:             c.ephemeralDeltas(metricDescriptor.Type),
+		emitDistributionRange:       c.emitDistributionRange,
+		splitLargeCounts:            c.splitLargeHistogramCounts,
+		histogramToSummaryThreshold: c.histogramToSummaryThreshold,
+		nativeHistograms:            c.nativeHistograms,
+		exemplars:                   c.exemplars,
+		omitPointTimestamps:         c.omitPointTimestamps,
+		createdTimestamps:           c.deltaCreatedTimestamps,
+		unitSuffix:                  c.unitSuffix(metricDescriptor),
+		malformedSeriesTotal:        c.malformedSeriesTotal,
+	})
 	for _, timeSeries := range page.TimeSeries {
-		newestEndTime := time.Unix(0, 0)
-		for _, point := range timeSeries.Points {
-			endTime, err := time.Parse(time.RFC3339Nano, point.Interval.EndTime)
-			if err != nil {
-				return fmt.Errorf("Error parsing TimeSeries Point interval end time `%s`: %s", point.Interval.EndTime, err)
-			}
-			if endTime.After(newestEndTime) {
-				newestEndTime = endTime
-				newestTSPoint = point
-			}
+		if c.resourceTypeAllowlist != nil && !c.resourceTypeAllowlist[timeSeries.Resource.Type] {
+			c.droppedMetricsTotal.WithLabelValues(
+				"resource_type_not_allowed",
+				timeSeries.Metric.Type,
+				timeSeries.Resource.Type,
+				timeSeries.MetricKind,
+				timeSeries.ValueType,
+			).Inc()
+			continue
+		}
+		if reason, ok := c.projectIDAllowed(timeSeries.Resource); !ok {
+			c.droppedMetricsTotal.WithLabelValues(
+				reason,
+				timeSeries.Metric.Type,
+				timeSeries.Resource.Type,
+				timeSeries.MetricKind,
+				timeSeries.ValueType,
+			).Inc()
+			continue
 		}
-		labelKeys := []string{"unit"}
-		labelValues := []string{metricDescriptor.Unit}
+
+		tsPoint, pointEndTime, err := c.selectPoint(timeSeries)
+		if err != nil {
+			return err
+		}
+		if tsPoint == nil {
+			continue
+		}
+		labels := &labelSet{keys: []string{"unit"}, values: []string{c.unitLabel(metricDescriptor, timeSeries)}, dropEmptyValues: c.dropEmptyLabelValues, maxValueLength: c.maxLabelValueLength}
 
 		// Add the metric labels
 		// @see https://cloud.google.com/monitoring/api/metrics
-		for key, value := range timeSeries.Metric.Labels {
-			if !c.keyExists(labelKeys, key) {
-				labelKeys = append(labelKeys, key)
-				labelValues = append(labelValues, value)
-			}
+		for _, key := range c.labelsOrder(timeSeries.Metric.Labels) {
+			labels.Add(c.labelName(labels, LabelSourceMetric, key), timeSeries.Metric.Labels[key])
 		}
 
-		// Add the monitored resource labels
-		// @see https://cloud.google.com/monitoring/api/resources
-		for key, value := range timeSeries.Resource.Labels {
-			if !c.keyExists(labelKeys, key) {
-				labelKeys = append(labelKeys, key)
-				labelValues = append(labelValues, value)
+		if c.resourceInfos != nil {
+			// The resource labels and metadata are reported by the resource info metric, only the project and the
+			// join key are kept
+			if projectID, ok := timeSeries.Resource.Labels["project_id"]; ok {
+				labels.Add(c.labelName(labels, LabelSourceResource, "project_id"), projectID)
 			}
+			labels.Add(resourceIDLabel, resourceID(timeSeries.Resource))
+		} else {
+			c.addResourceLabels(timeSeries, labels)
 		}
 
-		// Add system labels first, then user labels (system labels take precedence)
-		if c.enableSystemLabels && timeSeries.Metadata != nil && timeSeries.Metadata.SystemLabels != nil {
-			c.addSystemLabels(timeSeries.Metadata.SystemLabels, &labelKeys, &labelValues)
+		if c.emitRawMetricTypeLabel {
+			labels.Add(rawMetricTypeLabel, timeSeries.Metric.Type)
 		}
 
-		// Add user labels
-		if timeSeries.Metadata != nil && timeSeries.Metadata.UserLabels != nil {
-			for key, value := range timeSeries.Metadata.UserLabels {
-				c.addOrOverrideLabels(&labelKeys, &labelValues, key, value, c.userLabelsOverride)
-			}
+		c.addScopedProjectID(labels)
+
+		if c.addMetricKindLabel {
+			labels.Add(metricKindLabel, metricDescriptor.MetricKind)
+			labels.Add(valueTypeLabel, metricDescriptor.ValueType)
 		}
 
-		if c.monitoringDropDelegatedProjects {
-			dropDelegatedProject := false
-			var delegatedProjectID string
-
-			for idx, val := range labelKeys {
-				if val == "project_id" && labelValues[idx] != c.projectID {
-					dropDelegatedProject = true
-					delegatedProjectID = labelValues[idx]
-					break
-				}
-			}
+		if c.addResourceTypeLabel {
+			labels.Add(resourceTypeLabel, timeSeries.Resource.Type)
+		}
 
-			if dropDelegatedProject {
+		if c.addLaunchStageLabel {
+			labels.Add(launchStageLabel, launchStage(metricDescriptor))
+		}
+
+		if c.monitoringDropDelegatedProjects {
+			// The project of the resource, as a metric label of the same name may take the project_id label
+			if delegatedProjectID, ok := timeSeries.Resource.Labels["project_id"]; ok && delegatedProjectID != c.projectID {
 				c.droppedMetricsTotal.WithLabelValues(
 					"delegated_project",
 					timeSeries.Metric.Type,
@@ -527,11 +1856,15 @@ func (c *MonitoringCollector) reportTimeSeriesMetrics(
 			}
 		}
 
+		// Overridden after the delegated projects check, which compares the project of the resource
+		c.overrideProjectID(labels)
+		c.deriveLabels(labels)
+
 		switch timeSeries.MetricKind {
 		case "GAUGE":
 			metricValueType = prometheus.GaugeValue
 		case "DELTA":
-			if c.aggregateDeltas {
+			if aggregateDeltas {
 				metricValueType = prometheus.CounterValue
 			} else {
 				metricValueType = prometheus.GaugeValue
@@ -554,29 +1887,44 @@ func (c *MonitoringCollector) reportTimeSeriesMetrics(
 			continue
 		}
 
+		if c.resourceInfos != nil {
+			c.reportResourceInfo(timeSeries, ch)
+		}
+
+		if !c.dedupOnFullLabels {
+			c.dropLabelsFrom(labels)
+		}
+
 		// Check for duplicate metrics using deduplicator
-		if c.deduplicator.CheckAndMark(timeSeries.Metric.Type, labelKeys, labelValues, newestEndTime) {
+		fqName := buildFQName(c.metricPrefix, timeSeries, timeSeriesMetrics.unitSuffix)
+		duplicate, marked := c.deduplicator.CheckAndMark(timeSeries.Resource.Type, fqName, labels.keys, labels.values, pointEndTime)
+		if duplicate {
 			continue // Duplicate detected and logged by deduplicator
 		}
 
 		switch timeSeries.ValueType {
 		case "BOOL":
 			metricValue = 0
-			if *newestTSPoint.Value.BoolValue {
+			if *tsPoint.Value.BoolValue {
 				metricValue = 1
 			}
 		case "INT64":
-			metricValue = float64(*newestTSPoint.Value.Int64Value)
+			metricValue = float64(*tsPoint.Value.Int64Value)
 		case "DOUBLE":
-			metricValue = *newestTSPoint.Value.DoubleValue
+			metricValue = *tsPoint.Value.DoubleValue
 		case "DISTRIBUTION":
-			dist := newestTSPoint.Value.DistributionValue
+			dist := tsPoint.Value.DistributionValue
 			buckets, err := c.generateHistogramBuckets(dist)
 
 			if err == nil {
-				timeSeriesMetrics.CollectNewConstHistogram(timeSeries, newestEndTime, labelKeys, dist, buckets, labelValues, timeSeries.MetricKind)
+				c.checkHistogramPrecision(timeSeries, dist, buckets)
+				c.dropLabelsFrom(labels)
+				if c.droppedLabelsCollision(timeSeries, fqName, labels) {
+					continue
+				}
+				timeSeriesMetrics.CollectNewConstHistogram(timeSeries, pointEndTime, pointStartTime(tsPoint, pointEndTime), labels.keys, dist, buckets, labels.values, timeSeries.MetricKind)
 			} else {
-				c.deduplicator.RevertMark(timeSeries.Metric.Type, labelKeys, labelValues, newestEndTime)
+				c.deduplicator.RevertMark(timeSeries.Resource.Type, fqName, labels.keys, labels.values, pointEndTime, marked)
 				c.droppedMetricsTotal.WithLabelValues(
 					"distribution_bucket_error",
 					timeSeries.Metric.Type,
@@ -591,8 +1939,16 @@ func (c *MonitoringCollector) reportTimeSeriesMetrics(
 					"err", err)
 			}
 			continue
+		case "STRING":
+			if c.emitStringMetrics && tsPoint.Value.StringValue != nil {
+				labels.Add(stringValueLabel, *tsPoint.Value.StringValue)
+				metricValueType = prometheus.GaugeValue
+				metricValue = 1
+				break
+			}
+			fallthrough
 		default:
-			c.deduplicator.RevertMark(timeSeries.Metric.Type, labelKeys, labelValues, newestEndTime)
+			c.deduplicator.RevertMark(timeSeries.Resource.Type, fqName, labels.keys, labels.values, pointEndTime, marked)
 			c.droppedMetricsTotal.WithLabelValues(
 				"unknown_value_type",
 				timeSeries.Metric.Type,
@@ -608,12 +1964,282 @@ func (c *MonitoringCollector) reportTimeSeriesMetrics(
 			continue
 		}
 
-		timeSeriesMetrics.CollectNewConstMetric(timeSeries, newestEndTime, labelKeys, metricValueType, metricValue, labelValues, timeSeries.MetricKind)
+		if value, ok := c.aggregatePoints(timeSeries); ok {
+			metricValue = value
+		}
+
+		c.dropLabelsFrom(labels)
+		metricValue = c.clampCounterReset(timeSeries, tsPoint, fqName, labels, metricValue)
+		if c.skipUnchanged(timeSeries, fqName, labels, metricValue, pointEndTime, aggregateDeltas) {
+			continue
+		}
+		if c.droppedLabelsCollision(timeSeries, fqName, labels) {
+			continue
+		}
+		timeSeriesMetrics.CollectNewConstMetric(timeSeries, pointEndTime, pointStartTime(tsPoint, pointEndTime), labels.keys, metricValueType, metricValue, labels.values, timeSeries.MetricKind)
 	}
 	timeSeriesMetrics.Complete(begun)
 	return nil
 }
 
+// clampCounterReset returns the value a series is reported at, its previous value when a CUMULATIVE counter goes
+// backwards without a reset.
+func (c *MonitoringCollector) clampCounterReset(timeSeries *monitoring.TimeSeries, point *monitoring.Point, fqName string, labels *labelSet, value float64) float64 {
+	if c.counterResets == nil || timeSeries.MetricKind != "CUMULATIVE" {
+		return value
+	}
+	// A point without a parsable start time has no reset marker
+	startTime, _ := time.Parse(time.RFC3339Nano, point.Interval.StartTime)
+	signature := c.deduplicator.SeriesSignature(timeSeries.Resource.Type, fqName, labels.keys, labels.values)
+	clamped, ok := c.counterResets.Clamp(signature, value, startTime, time.Now())
+	if !ok {
+		return value
+	}
+	c.counterResetsClampedTotal.WithLabelValues(timeSeries.Metric.Type).Inc()
+	c.logger.Debug("clamping counter going backwards without a reset",
+		"metric", timeSeries.Metric.Type,
+		"resource_type", timeSeries.Resource.Type,
+		"value", value,
+		"clamped_value", clamped)
+	return clamped
+}
+
+// skipUnchanged reports whether a series is not emitted, its point being unchanged since the previous scrapes. The
+// aggregated DELTA metrics are always emitted, their counters being reported from the delta store anyway.
+func (c *MonitoringCollector) skipUnchanged(timeSeries *monitoring.TimeSeries, fqName string, labels *labelSet, value float64, pointEndTime time.Time, aggregateDeltas bool) bool {
+	if c.unchangedSeries == nil || (timeSeries.MetricKind == "DELTA" && aggregateDeltas) {
+		return false
+	}
+	signature := c.deduplicator.SeriesSignature(timeSeries.Resource.Type, fqName, labels.keys, labels.values)
+	if !c.unchangedSeries.Skip(signature, value, pointEndTime, time.Now()) {
+		return false
+	}
+	c.droppedMetricsTotal.WithLabelValues(
+		"unchanged",
+		timeSeries.Metric.Type,
+		timeSeries.Resource.Type,
+		timeSeries.MetricKind,
+		timeSeries.ValueType,
+	).Inc()
+	return true
+}
+
+// droppedLabelsCollision reports whether a series is not emitted, a series of the scrape only differing by dropped
+// labels having been emitted already. It only happens with DedupOnFullLabels, the deduplication telling them apart.
+func (c *MonitoringCollector) droppedLabelsCollision(timeSeries *monitoring.TimeSeries, fqName string, labels *labelSet) bool {
+	if !c.deduplicator.CheckAndMarkEmitted(fqName, labels.keys, labels.values) {
+		return false
+	}
+	c.droppedMetricsTotal.WithLabelValues(
+		"dropped_labels_collision",
+		timeSeries.Metric.Type,
+		timeSeries.Resource.Type,
+		timeSeries.MetricKind,
+		timeSeries.ValueType,
+	).Inc()
+	c.logger.Debug("dropping metric colliding with another once its labels dropped",
+		"metric", timeSeries.Metric.Type,
+		"resource_type", timeSeries.Resource.Type,
+		"fqName", fqName)
+	return true
+}
+
+// addResourceLabels adds the monitored resource labels and the system and user labels of the resource metadata.
+func (c *MonitoringCollector) addResourceLabels(timeSeries *monitoring.TimeSeries, labels *labelSet) {
+	// Add the monitored resource labels
+	// @see https://cloud.google.com/monitoring/api/resources
+	for _, key := range c.labelsOrder(timeSeries.Resource.Labels) {
+		labels.Add(c.labelName(labels, LabelSourceResource, key), timeSeries.Resource.Labels[key])
+	}
+
+	// Add system labels first, then user labels (system labels take precedence)
+	if timeSeries.Metadata != nil && timeSeries.Metadata.SystemLabels != nil {
+		if c.emitSystemLabelsSchema {
+			c.addSystemLabelsSchema(timeSeries.Metadata.SystemLabels, labels)
+		}
+		c.promoteSystemLabels(timeSeries.Metadata.SystemLabels, labels)
+		if c.enableSystemLabels {
+			c.addSystemLabels(timeSeries.Metadata.SystemLabels, labels)
+		}
+	}
+
+	// Add user labels
+	if timeSeries.Metadata != nil && timeSeries.Metadata.UserLabels != nil {
+		for _, key := range c.labelsOrder(timeSeries.Metadata.UserLabels) {
+			c.addOrOverrideLabels(labels, c.labelName(labels, LabelSourceUser, key), timeSeries.Metadata.UserLabels[key], c.userLabelsOverride)
+		}
+	}
+}
+
+// reportResourceInfo reports the <prefix>_resource_info metric of the resource of a time series, unless already
+// reported during the scrape. Its labels are the join key, the resource type and the labels left out of the data
+// metrics.
+func (c *MonitoringCollector) reportResourceInfo(timeSeries *monitoring.TimeSeries, ch chan<- prometheus.Metric) {
+	id := resourceID(timeSeries.Resource)
+	if !c.resourceInfos.MarkReported(id) {
+		return
+	}
+
+	labels := &labelSet{
+		keys:            []string{resourceIDLabel, resourceTypeLabel},
+		values:          []string{id, timeSeries.Resource.Type},
+		dropEmptyValues: c.dropEmptyLabelValues,
+		maxValueLength:  c.maxLabelValueLength,
+	}
+	c.addResourceLabels(timeSeries, labels)
+	c.dropLabelsFrom(labels)
+
+	metric, err := prometheus.NewConstMetric(
+		prometheus.NewDesc(
+			prometheus.BuildFQName(c.metricPrefix, "resource", "info"),
+			"Labels and metadata of the monitored resources, joined to their time series on resource_id.",
+			labels.keys,
+			nil,
+		),
+		prometheus.GaugeValue,
+		1,
+		labels.values...,
+	)
+	if err != nil {
+		// e.g. a label value which is not valid UTF-8, the data metrics of the resource still being reported
+		c.droppedMetricsTotal.WithLabelValues(
+			"invalid_resource_info",
+			timeSeries.Metric.Type,
+			timeSeries.Resource.Type,
+			timeSeries.MetricKind,
+			timeSeries.ValueType,
+		).Inc()
+		c.logger.Warn("dropping invalid resource info metric",
+			"resource_type", timeSeries.Resource.Type,
+			"resource_id", id,
+			"err", err)
+		return
+	}
+	ch <- metric
+}
+
+// discoverMissingMetricTypes returns the metric types of a prefix missing from its cached descriptors, such as the
+// metric types created after the descriptors were cached, by listing the descriptors of the prefix again. A failed
+// listing is logged and counted rather than failing the scrape of the prefix, the metric types discovered before
+// the error being returned.
+func (c *MonitoringCollector) discoverMissingMetricTypes(
+	ctx context.Context,
+	metricsTypePrefix string,
+	filter string,
+	cached []*monitoring.MetricDescriptor,
+) []string {
+	described := make(map[string]bool, len(cached))
+	for _, descriptor := range cached {
+		described[descriptor.Type] = true
+	}
+
+	var missingTypes []string
+	if err := c.listMetricDescriptors(ctx, filter, func(r *monitoring.ListMetricDescriptorsResponse) error {
+		for _, descriptor := range r.MetricDescriptors {
+			if described[descriptor.Type] {
+				continue
+			}
+			described[descriptor.Type] = true
+			if c.valueTypeAllowlist != nil && !c.valueTypeAllowlist[descriptor.ValueType] {
+				c.logger.Debug("skipping metric missing from the cached descriptors of a value type not allowed", "metric", descriptor.Type, "value_type", descriptor.ValueType)
+				continue
+			}
+			c.logger.Debug("inferring the descriptor of metric missing from the cached descriptors", "metric", descriptor.Type)
+			missingTypes = append(missingTypes, descriptor.Type)
+		}
+		return nil
+	}); err != nil {
+		c.logger.Warn("error discovering the metric types missing from the cached descriptors", "prefix", metricsTypePrefix, "err", err)
+		c.descriptorDiscoveryErrorsTotal.WithLabelValues(metricsTypePrefix).Inc()
+	}
+	return missingTypes
+}
+
+// projectIDAllowed returns whether the time series of a resource are reported by its project_id label, along with the
+// reason they are dropped otherwise. Resources without a project_id label are always reported.
+func (c *MonitoringCollector) projectIDAllowed(resource *monitoring.MonitoredResource) (string, bool) {
+	projectID, ok := resource.Labels["project_id"]
+	switch {
+	case !ok:
+		return "", true
+	case c.projectIDDenylist[projectID]:
+		return "project_id_denied", false
+	case c.projectIDAllowlist != nil && !c.projectIDAllowlist[projectID]:
+		return "project_id_not_allowed", false
+	default:
+		return "", true
+	}
+}
+
+// stringSet returns the set of the values, nil when empty.
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// inferDescriptor returns a metric descriptor built from the fields of a time series.
+func inferDescriptor(timeSeries *monitoring.TimeSeries) *monitoring.MetricDescriptor {
+	return &monitoring.MetricDescriptor{
+		Name:       timeSeries.Metric.Type,
+		Type:       timeSeries.Metric.Type,
+		MetricKind: timeSeries.MetricKind,
+		ValueType:  timeSeries.ValueType,
+		Unit:       timeSeries.Unit,
+	}
+}
+
+// resolveUnit returns the unit of a time series, preferring the metric descriptor unit over the one
+// reported by the series itself when they differ.
+func (c *MonitoringCollector) resolveUnit(metricDescriptor *monitoring.MetricDescriptor, timeSeries *monitoring.TimeSeries) string {
+	if metricDescriptor.Unit == "" {
+		return timeSeries.Unit
+	}
+	if timeSeries.Unit != "" && timeSeries.Unit != metricDescriptor.Unit {
+		c.unitMismatchTotal.WithLabelValues(metricDescriptor.Type).Inc()
+		c.logger.Debug("time series unit differs from metric descriptor unit, using descriptor unit",
+			"metric", metricDescriptor.Type,
+			"descriptor_unit", metricDescriptor.Unit,
+			"series_unit", timeSeries.Unit)
+	}
+	return metricDescriptor.Unit
+}
+
+// unitLabel returns the value of the unit label of a time series, by its Prometheus name if units are normalized.
+func (c *MonitoringCollector) unitLabel(metricDescriptor *monitoring.MetricDescriptor, timeSeries *monitoring.TimeSeries) string {
+	unit := c.resolveUnit(metricDescriptor, timeSeries)
+	if !c.normalizeUnits {
+		return unit
+	}
+	if name, ok := normalizeUnit(unit); ok {
+		return name
+	}
+	return unit
+}
+
+// unitSuffix returns the metric name suffix of a metric descriptor, empty unless unit suffixes are appended.
+func (c *MonitoringCollector) unitSuffix(metricDescriptor *monitoring.MetricDescriptor) string {
+	if !c.appendUnitSuffix {
+		return ""
+	}
+	name, _ := normalizeUnit(metricDescriptor.Unit)
+	return name
+}
+
+// pointStartTime returns the start of the interval of a point, endTime when it can't be parsed.
+func pointStartTime(point *monitoring.Point, endTime time.Time) time.Time {
+	startTime, err := time.Parse(time.RFC3339Nano, point.Interval.StartTime)
+	if err != nil {
+		return endTime
+	}
+	return startTime
+}
+
 func (c *MonitoringCollector) generateHistogramBuckets(
 	dist *monitoring.Distribution,
 ) (map[float64]uint64, error) {
@@ -662,61 +2288,97 @@ func (c *MonitoringCollector) generateHistogramBuckets(
 			buckets[b] = last
 		}
 	}
-	return buckets, nil
-}
-
-func (c *MonitoringCollector) keyExists(labelKeys []string, key string) bool {
-	for _, item := range labelKeys {
-		if item == key {
-			c.logger.Debug("Found duplicate label key", "key", key)
-			return true
-		}
+	if len(c.histogramBuckets) > 0 {
+		return rebucketHistogram(buckets, c.histogramBuckets, c.histogramRebucketMode), nil
 	}
-	return false
+	return buckets, nil
 }
 
-func (c *MonitoringCollector) addSystemLabels(raw googleapi.RawMessage, labelKeys *[]string, labelValues *[]string) {
-	// Early exit for empty, null, or invalid JSON
-	if len(raw) == 0 {
+// addSystemLabels adds the system labels of a time series as the system labels mode decides, the schema version
+// excepted when reported by addSystemLabelsSchema and the promoted ones.
+func (c *MonitoringCollector) addSystemLabels(raw googleapi.RawMessage, labels *labelSet) {
+	switch c.systemLabelsMode {
+	case SystemLabelsOff:
+		return
+	case SystemLabelsJSON:
+		c.addSystemLabelsJSON(raw, labels)
 		return
 	}
+	labels.Merge(raw, func(key string) (string, bool) {
+		if c.emitSystemLabelsSchema && key == c.systemLabelsSchemaKey {
+			return "", false // reported by addSystemLabelsSchema
+		}
+		if c.isPromotedSystemLabel(key) {
+			return "", false // reported by promoteSystemLabels
+		}
+		return c.labelName(labels, LabelSourceSystem, key), true
+	})
+}
 
-	result := gjson.ParseBytes(raw)
+// addSystemLabelsSchema adds the schema version found in the system labels as the system_labels_schema label.
+func (c *MonitoringCollector) addSystemLabelsSchema(raw googleapi.RawMessage, labels *labelSet) {
+	labels.Merge(raw, func(key string) (string, bool) {
+		return systemLabelsSchemaLabel, key == c.systemLabelsSchemaKey
+	})
+}
 
-	// Early exit if the result is not a valid object or is null/empty
-	if !result.Exists() || !result.IsObject() {
+// checkHistogramPrecision warns when the count or a bucket of a distribution exceeds 2^53, precision being lost once
+// converted to the float values of Prometheus samples.
+func (c *MonitoringCollector) checkHistogramPrecision(timeSeries *monitoring.TimeSeries, dist *monitoring.Distribution, buckets map[float64]uint64) {
+	exceeds := exceedsSafeInteger(uint64(dist.Count))
+	for _, count := range buckets {
+		exceeds = exceeds || exceedsSafeInteger(count)
+	}
+	if !exceeds {
 		return
 	}
 
-	result.ForEach(func(key, value gjson.Result) bool {
-		if !c.keyExists(*labelKeys, key.String()) {
-			*labelKeys = append(*labelKeys, key.String())
-			*labelValues = append(*labelValues, value.String())
-		}
-		return true // continue iteration
-	})
+	c.histogramPrecisionLossTotal.WithLabelValues(timeSeries.Metric.Type).Inc()
+	c.logger.Warn("distribution count exceeds the float64 safe integer range, precision will be lost",
+		"metric", timeSeries.Metric.Type,
+		"resource_type", timeSeries.Resource.Type,
+		"count", dist.Count)
 }
 
-func (c *MonitoringCollector) addOrOverrideLabels(labelKeys *[]string, labelValues *[]string, key string, value string, override bool) {
-	if !c.keyExists(*labelKeys, key) {
-		*labelKeys = append(*labelKeys, key)
-		*labelValues = append(*labelValues, value)
-		return
+// labelsOrder returns the keys of a label source in the order they should be merged. Keys are sorted when
+// label names are sanitized so collisions between sanitized names are resolved deterministically.
+func (c *MonitoringCollector) labelsOrder(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
 	}
+	if c.sanitizeLabelNames {
+		sort.Strings(keys)
+	}
+	return keys
+}
 
-	if !override {
-		return
+// labelName returns the label name to use for a Stackdriver label key of a source: its rename if any, else the key
+// sanitized if enabled and prefixed with the prefix of its source if any.
+func (c *MonitoringCollector) labelName(labels *labelSet, source LabelSource, key string) string {
+	if name, ok := c.labelRenames[key]; ok {
+		if labels.Has(name) {
+			c.logger.Debug("renamed label name collides with an existing label", "key", key, "label", name)
+		}
+		return name
+	}
+	prefix := c.labelSourcePrefixes[source]
+	if !c.sanitizeLabelNames {
+		return prefix + key
 	}
 
-	// Override the value
-	(*labelValues)[c.findKeyIndex(*labelKeys, key)] = value
+	name := prefix + utils.SanitizeLabelName(key)
+	if name != prefix+key && labels.Has(name) {
+		c.logger.Debug("sanitized label name collides with an existing label", "key", key, "label", name)
+	}
+	return name
 }
 
-func (c *MonitoringCollector) findKeyIndex(labelKeys []string, key string) int {
-	for i, k := range labelKeys {
-		if k == key {
-			return i
-		}
+// addOrOverrideLabels adds the label, overriding the value of an existing label of the same key if override is set.
+func (c *MonitoringCollector) addOrOverrideLabels(labels *labelSet, key string, value string, override bool) {
+	if override {
+		labels.Override(key, value)
+		return
 	}
-	return -1
+	labels.Add(key, value)
 }
@@ -160,51 +160,39 @@ func TestMonitoringCollector_LabelProcessingOrder(t *testing.T) {
 			}
 
 			// Simulate the label processing logic from reportTimeSeriesMetrics
-			labelKeys := []string{"unit"}
-			labelValues := []string{tt.unitLabel}
+			labels := &labelSet{keys: []string{"unit"}, values: []string{tt.unitLabel}}
 
 			// Add metric labels
 			for key, value := range tt.metricLabels {
-				if !collector.keyExists(labelKeys, key) {
-					labelKeys = append(labelKeys, key)
-					labelValues = append(labelValues, value)
-				}
+				labels.Add(key, value)
 			}
 
 			// Add resource labels
 			for key, value := range tt.resourceLabels {
-				if !collector.keyExists(labelKeys, key) {
-					labelKeys = append(labelKeys, key)
-					labelValues = append(labelValues, value)
-				}
+				labels.Add(key, value)
 			}
 
 			// Add user labels and system labels based on configuration
 			if collector.userLabelsOverride {
 				// Add user labels first, then system labels (user labels take precedence)
 				for key, value := range tt.userLabels {
-					if !collector.keyExists(labelKeys, key) {
-						labelKeys = append(labelKeys, key)
-						labelValues = append(labelValues, value)
-					}
+					labels.Add(key, value)
 				}
 				if collector.enableSystemLabels {
 					rawMessage := googleapi.RawMessage(tt.systemLabelsJSON)
-					collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+					collector.addSystemLabels(rawMessage, labels)
 				}
 			} else {
 				// Add system labels first, then user labels (system labels take precedence)
 				if collector.enableSystemLabels {
 					rawMessage := googleapi.RawMessage(tt.systemLabelsJSON)
-					collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+					collector.addSystemLabels(rawMessage, labels)
 				}
 				for key, value := range tt.userLabels {
-					if !collector.keyExists(labelKeys, key) {
-						labelKeys = append(labelKeys, key)
-						labelValues = append(labelValues, value)
-					}
+					labels.Add(key, value)
 				}
 			}
+			labelKeys, labelValues := labels.keys, labels.values
 
 			// Verify results
 			assert.Equal(t, tt.expectedKeys, labelKeys, tt.description+" - keys mismatch")
@@ -13,7 +13,28 @@
 
 package collectors
 
-import "testing"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/monitoring/v3"
+)
 
 func TestIsGoogleMetric(t *testing.T) {
 	good := []string{
@@ -37,3 +58,2391 @@ func TestIsGoogleMetric(t *testing.T) {
 		}
 	}
 }
+
+// newTestCollector returns a MonitoringCollector without a monitoring service, suitable for calling
+// reportTimeSeriesMetrics directly.
+func newTestCollector(t *testing.T, opts MonitoringCollectorOptions) *MonitoringCollector {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	collector, err := NewMonitoringCollector("test-project", nil, opts, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+	return collector
+}
+
+// newDoubleTimeSeries returns a GAUGE DOUBLE time series with a single point.
+func newDoubleTimeSeries(metricType string, value float64, endTime time.Time, metricLabels map[string]string) *monitoring.TimeSeries {
+	return &monitoring.TimeSeries{
+		Metric:     &monitoring.Metric{Type: metricType, Labels: metricLabels},
+		Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "test-project"}},
+		MetricKind: "GAUGE",
+		ValueType:  "DOUBLE",
+		Points: []*monitoring.Point{{
+			Interval: &monitoring.TimeInterval{EndTime: endTime.Format(time.RFC3339Nano)},
+			Value:    &monitoring.TypedValue{DoubleValue: &value},
+		}},
+	}
+}
+
+// reportPage runs reportTimeSeriesMetrics over the given series and returns the emitted metrics.
+func reportPage(t *testing.T, c *MonitoringCollector, descriptor *monitoring.MetricDescriptor, series ...*monitoring.TimeSeries) map[string][]*dto.Metric {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	require.NoError(t, c.reportTimeSeriesMetrics(&monitoring.ListTimeSeriesResponse{TimeSeries: series}, descriptor, ch, time.Now()))
+	return readMetrics(t, ch)
+}
+
+func TestMonitoringCollector_UnitMismatch(t *testing.T) {
+	c := newTestCollector(t, MonitoringCollectorOptions{})
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "compute.googleapis.com/instance/disk/read_bytes_count", Unit: "By"}
+
+	matching := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"device": "a"})
+	matching.Unit = "By"
+	mismatching := newDoubleTimeSeries(descriptor.Type, 2, time.Now(), map[string]string{"device": "b"})
+	mismatching.Unit = "kBy"
+
+	metrics := reportPage(t, c, descriptor, matching, mismatching)
+
+	fqName := "stackdriver_gce_instance_compute_googleapis_com_instance_disk_read_bytes_count"
+	require.Len(t, metrics[fqName], 2)
+	for _, m := range metrics[fqName] {
+		assert.Equal(t, "By", labelsOf(m)["unit"], "descriptor unit should be preferred")
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.unitMismatchTotal.WithLabelValues(descriptor.Type)))
+}
+
+func TestMonitoringCollector_UnitFallbackToSeries(t *testing.T) {
+	c := newTestCollector(t, MonitoringCollectorOptions{})
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/my_metric"}
+
+	series := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil)
+	series.Unit = "s"
+
+	metrics := reportPage(t, c, descriptor, series)
+
+	require.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"], 1)
+	assert.Equal(t, "s", labelsOf(metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"][0])["unit"])
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.unitMismatchTotal.WithLabelValues(descriptor.Type)))
+}
+
+// newFakeAPIWithDescriptors returns a fake API serving n gauge descriptors, each with two series sharing
+// the same labels so only the first one survives deduplication.
+func newFakeAPIWithDescriptors(n int) *fakeMonitoringAPI {
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}}
+	for i := 0; i < n; i++ {
+		metricType := fmt.Sprintf("custom.googleapis.com/metric_%02d", i)
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{
+			Name: "projects/test-project/metricDescriptors/" + metricType,
+			Type: metricType,
+		})
+		api.series[metricType] = []*monitoring.TimeSeries{
+			newDoubleTimeSeries(metricType, float64(i), time.Now(), map[string]string{"instance": "a"}),
+			newDoubleTimeSeries(metricType, float64(-i), time.Now(), map[string]string{"instance": "a"}),
+			newDoubleTimeSeries(metricType, float64(i), time.Now(), map[string]string{"instance": "b"}),
+		}
+	}
+	return api
+}
+
+// collectSeries runs a full collection and returns the value of each reported Stackdriver series
+// keyed by name and labels.
+func collectSeries(t *testing.T, c *MonitoringCollector) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 1000)
+	c.Collect(ch)
+
+	series := map[string]float64{}
+	for name, metrics := range readMetrics(t, ch) {
+		if !strings.HasPrefix(name, "stackdriver_gce_instance_") {
+			continue
+		}
+		for _, m := range metrics {
+			series[fmt.Sprintf("%s%v", name, labelsOf(m))] = m.GetGauge().GetValue()
+		}
+	}
+	return series
+}
+
+func TestMonitoringCollector_MaxConcurrentRequests(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	collect := func(maxConcurrentRequests int) (map[string]float64, int64) {
+		api := newFakeAPIWithDescriptors(20)
+		var inFlight, maxInFlight atomic.Int64
+		api.latency = 5 * time.Millisecond
+		api.timeSeriesHook = func(*http.Request) int {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				observed := maxInFlight.Load()
+				if current <= observed || maxInFlight.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return 0
+		}
+
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes:    []string{"custom.googleapis.com"},
+			RequestInterval:       time.Minute,
+			MaxConcurrentRequests: maxConcurrentRequests,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+
+		return collectSeries(t, c), maxInFlight.Load()
+	}
+
+	sequential, sequentialInFlight := collect(1)
+	concurrent, concurrentInFlight := collect(4)
+
+	assert.Len(t, sequential, 40, "each descriptor should report two unique series")
+	assert.Equal(t, sequential, concurrent, "concurrency should not change the reported series nor which duplicate wins")
+	assert.Equal(t, int64(1), sequentialInFlight)
+	assert.LessOrEqual(t, concurrentInFlight, int64(4))
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("stackdriver_gce_instance_custom_googleapis_com_metric_%02d%v", i, map[string]string{"instance": "a", "project_id": "test-project", "unit": ""})
+		assert.Equal(t, float64(i), sequential[key], "the first duplicate should win")
+	}
+}
+
+func benchmarkCollectConcurrency(b *testing.B, maxConcurrentRequests int) {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError}))
+	api := newFakeAPIWithDescriptors(20)
+	api.latency = 5 * time.Millisecond
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(b, api), MonitoringCollectorOptions{
+		MetricTypePrefixes:    []string{"custom.googleapis.com"},
+		RequestInterval:       time.Minute,
+		MaxConcurrentRequests: maxConcurrentRequests,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ch := make(chan prometheus.Metric, 1000)
+		c.Collect(ch)
+	}
+}
+
+func BenchmarkCollect_Sequential(b *testing.B) { benchmarkCollectConcurrency(b, 1) }
+
+func BenchmarkCollect_Concurrent(b *testing.B) { benchmarkCollectConcurrency(b, 8) }
+
+func TestMonitoringCollector_SanitizeLabelNames(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/my_metric"}
+	newSeries := func() *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"a.b": "1", "a-b": "2", "k8s.pod.name": "pod"})
+		series.Resource.Labels["cost.center"] = "eng"
+		series.Metadata = &monitoring.MonitoredResourceMetadata{
+			SystemLabels: googleapi.RawMessage(`{"version.tag": "v1"}`),
+			UserLabels:   map[string]string{"team.name": "infra"},
+		}
+		return series
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			c := newTestCollector(t, MonitoringCollectorOptions{SanitizeLabelNames: true, EnableSystemLabels: true})
+			metrics := reportPage(t, c, descriptor, newSeries())
+
+			require.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"], 1)
+			assert.Equal(t, map[string]string{
+				"unit":         "",
+				"a_b":          "2", // "a-b" sorts before "a.b" so it deterministically wins the collision
+				"k8s_pod_name": "pod",
+				"project_id":   "test-project",
+				"cost_center":  "eng",
+				"version_tag":  "v1",
+				"team_name":    "infra",
+			}, labelsOf(metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"][0]))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true})
+		series := newSeries()
+		series.Metric.Labels = map[string]string{"k8s.pod.name": "pod"}
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"], 1)
+		labels := labelsOf(metrics["stackdriver_gce_instance_custom_googleapis_com_my_metric"][0])
+		assert.Contains(t, labels, "k8s.pod.name", "label names should be passed through")
+		assert.Contains(t, labels, "version.tag", "label names should be passed through")
+	})
+}
+
+func TestMonitoringCollector_CaseInsensitiveMetricNames(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests"}
+	now := time.Now()
+	upper := newDoubleTimeSeries("custom.googleapis.com/REQUESTS", 1, now, map[string]string{"code": "200"})
+	lower := newDoubleTimeSeries("custom.googleapis.com/requests", 2, now, map[string]string{"code": "200"})
+
+	t.Run("enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{CaseInsensitiveMetricNames: true, MetricPrefix: "MyExporter"})
+		metrics := reportPage(t, c, descriptor, upper, lower)
+
+		require.Len(t, metrics["myexporter_gce_instance_custom_googleapis_com_requests"], 1)
+		assert.Equal(t, float64(1), metrics["myexporter_gce_instance_custom_googleapis_com_requests"][0].GetGauge().GetValue(), "the first series should be kept")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{MetricPrefix: "MyExporter"})
+		metrics := reportPage(t, c, descriptor, upper, lower)
+
+		require.Len(t, metrics["MyExporter_gce_instance_custom_googleapis_com_requests"], 1, "metric types are normalized to the same lower case name")
+	})
+}
+
+func TestMonitoringCollector_RawMetricTypeLabel(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/http/requests"}
+	now := time.Now()
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_http_requests"
+	dotted := newDoubleTimeSeries("custom.googleapis.com/http.requests", 1, now, map[string]string{"code": "200"})
+	slashed := newDoubleTimeSeries("custom.googleapis.com/http/requests", 2, now, map[string]string{"code": "200"})
+
+	t.Run("enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EmitRawMetricTypeLabel: true})
+		metrics := reportPage(t, c, descriptor, dotted, slashed)
+
+		require.Len(t, metrics[fqName], 2, "series normalized to the same name should be told apart by their raw type")
+		rawTypes := map[string]float64{}
+		for _, m := range metrics[fqName] {
+			rawTypes[labelsOf(m)[rawMetricTypeLabel]] = m.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{
+			"custom.googleapis.com/http.requests": 1,
+			"custom.googleapis.com/http/requests": 2,
+		}, rawTypes)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, dotted, slashed)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.NotContains(t, labelsOf(metrics[fqName][0]), rawMetricTypeLabel)
+	})
+}
+
+func TestMonitoringCollector_MetricKindLabel(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"code": "200"})
+
+	t.Run("enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{AddMetricKindLabel: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		assert.Equal(t, "GAUGE", labels[metricKindLabel])
+		assert.Equal(t, "DOUBLE", labels[valueTypeLabel])
+		assert.Equal(t, "200", labels["code"])
+	})
+
+	t.Run("existing label", func(t *testing.T) {
+		labelled := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"metric_kind": "user"})
+		c := newTestCollector(t, MonitoringCollectorOptions{AddMetricKindLabel: true})
+		metrics := reportPage(t, c, descriptor, labelled)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, "user", labelsOf(metrics[fqName][0])[metricKindLabel], "an existing label should not be overridden")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		assert.NotContains(t, labels, metricKindLabel)
+		assert.NotContains(t, labels, valueTypeLabel)
+	})
+}
+
+func TestMonitoringCollector_LaunchStageLabel(t *testing.T) {
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	for _, tc := range []struct {
+		name       string
+		descriptor *monitoring.MetricDescriptor
+		expected   string
+	}{
+		{"launch stage", &monitoring.MetricDescriptor{LaunchStage: "BETA"}, "BETA"},
+		{"metadata launch stage", &monitoring.MetricDescriptor{Metadata: &monitoring.MetricDescriptorMetadata{LaunchStage: "ALPHA"}}, "ALPHA"},
+		{"unspecified", &monitoring.MetricDescriptor{LaunchStage: "LAUNCH_STAGE_UNSPECIFIED"}, unknownLaunchStage},
+		{"no metadata", &monitoring.MetricDescriptor{}, unknownLaunchStage},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			descriptor := tc.descriptor
+			descriptor.Name, descriptor.Type, descriptor.MetricKind, descriptor.ValueType = "descriptor", "custom.googleapis.com/requests", "GAUGE", "DOUBLE"
+			c := newTestCollector(t, MonitoringCollectorOptions{AddLaunchStageLabel: true})
+			metrics := reportPage(t, c, descriptor, newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil))
+
+			require.Len(t, metrics[fqName], 1)
+			assert.Equal(t, tc.expected, labelsOf(metrics[fqName][0])[launchStageLabel])
+		})
+	}
+
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE", LaunchStage: "GA"}
+	metrics := reportPage(t, newTestCollector(t, MonitoringCollectorOptions{}), descriptor, newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil))
+	require.Len(t, metrics[fqName], 1)
+	assert.NotContains(t, labelsOf(metrics[fqName][0]), launchStageLabel, "the label should only be added when enabled")
+}
+
+func TestMonitoringCollector_ResourceTypeLabel(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+
+	t.Run("enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{AddResourceTypeLabel: true})
+		metrics := reportPage(t, c, descriptor,
+			newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"code": "200"}),
+			newDoubleTimeSeries(descriptor.Type, 2, time.Now(), map[string]string{"code": "200"}))
+
+		require.Len(t, metrics[fqName], 1, "the duplicate series should still be deduplicated")
+		labels := labelsOf(metrics[fqName][0])
+		assert.Equal(t, "gce_instance", labels[resourceTypeLabel])
+		assert.Equal(t, "200", labels["code"])
+	})
+
+	t.Run("existing label", func(t *testing.T) {
+		labelled := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"resource_type": "user"})
+		c := newTestCollector(t, MonitoringCollectorOptions{AddResourceTypeLabel: true})
+		metrics := reportPage(t, c, descriptor, labelled)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, "user", labelsOf(metrics[fqName][0])[resourceTypeLabel], "an existing label should not be overridden")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil))
+
+		require.Len(t, metrics[fqName], 1)
+		assert.NotContains(t, labelsOf(metrics[fqName][0]), resourceTypeLabel)
+	})
+
+	t.Run("dedup by resource type", func(t *testing.T) {
+		dotted := newDoubleTimeSeries(descriptor.Type, 2, time.Now(), nil)
+		dotted.Resource.Type = "gce.instance"
+		c := newTestCollector(t, MonitoringCollectorOptions{AddResourceTypeLabel: true, DedupByResourceType: true})
+		metrics := reportPage(t, c, descriptor, newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil), dotted)
+
+		require.Len(t, metrics[fqName], 2, "the series of distinct resource types should both be kept")
+		assert.ElementsMatch(t, []string{"gce_instance", "gce.instance"}, []string{
+			labelsOf(metrics[fqName][0])[resourceTypeLabel],
+			labelsOf(metrics[fqName][1])[resourceTypeLabel],
+		})
+
+		logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{DedupByResourceType: true}, logger, &testCounterStore{}, &testHistogramStore{})
+		assert.ErrorContains(t, err, "invalid dedup by resource type without the resource type label")
+	})
+}
+
+func TestMonitoringCollector_NormalizeUnits(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/received", MetricKind: "GAUGE", ValueType: "DOUBLE", Unit: "By"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_received"
+	series := newDoubleTimeSeries("custom.googleapis.com/received", 1, time.Now(), nil)
+
+	t.Run("normalized", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{NormalizeUnits: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, "bytes", labelsOf(metrics[fqName][0])["unit"])
+	})
+
+	t.Run("unknown unit", func(t *testing.T) {
+		unknown := *descriptor
+		unknown.Unit = "furlong"
+		c := newTestCollector(t, MonitoringCollectorOptions{NormalizeUnits: true, AppendUnitSuffix: true})
+		metrics := reportPage(t, c, &unknown, series)
+
+		require.Len(t, metrics[fqName], 1, "an unknown unit should add no suffix")
+		assert.Equal(t, "furlong", labelsOf(metrics[fqName][0])["unit"], "an unknown unit should be kept as is")
+	})
+
+	t.Run("suffix", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{AppendUnitSuffix: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName+"_bytes"], 1)
+		assert.Equal(t, "By", labelsOf(metrics[fqName+"_bytes"][0])["unit"], "the unit label should not be normalized")
+	})
+
+	t.Run("existing suffix", func(t *testing.T) {
+		suffixed := *descriptor
+		suffixed.Type = "custom.googleapis.com/received_bytes"
+		c := newTestCollector(t, MonitoringCollectorOptions{AppendUnitSuffix: true})
+		metrics := reportPage(t, c, &suffixed, newDoubleTimeSeries("custom.googleapis.com/received_bytes", 1, time.Now(), nil))
+
+		assert.Len(t, metrics[fqName+"_bytes"], 1, "the suffix should not be repeated")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, "By", labelsOf(metrics[fqName][0])["unit"])
+	})
+}
+
+func TestMonitoringCollector_InferMissingDescriptors(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+	requests := newDoubleTimeSeries("custom.googleapis.com/requests", 1, now, map[string]string{"code": "200"})
+	requests.Unit = "1"
+	latency := newDoubleTimeSeries("custom.googleapis.com/latency", 2, now, nil)
+	latency.MetricKind = "CUMULATIVE"
+	described := newDoubleTimeSeries("custom.googleapis.com/described", 3, now, nil)
+
+	// newAPI lists the descriptor of the described metric type only, until the others are created
+	newAPI := func() *fakeMonitoringAPI {
+		return &fakeMonitoringAPI{
+			descriptors: []*monitoring.MetricDescriptor{{Name: described.Metric.Type, Type: described.Metric.Type, MetricKind: "GAUGE", ValueType: "DOUBLE"}},
+			series: map[string][]*monitoring.TimeSeries{
+				requests.Metric.Type:  {requests},
+				latency.Metric.Type:   {latency},
+				described.Metric.Type: {described},
+			},
+		}
+	}
+	newCollector := func(api *fakeMonitoringAPI, infer bool) *MonitoringCollector {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes:      []string{"custom.googleapis.com"},
+			RequestInterval:         time.Minute,
+			DescriptorCacheTTL:      time.Hour,
+			InferMissingDescriptors: infer,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		return c
+	}
+	collect := func(c *MonitoringCollector) map[string][]*dto.Metric {
+		ch := make(chan prometheus.Metric, 100)
+		c.Collect(ch)
+		return readMetrics(t, ch)
+	}
+	// createDescriptors creates the descriptors of the metric types missing from the cached ones, their kind and
+	// unit not being the ones of their series
+	createDescriptors := func(api *fakeMonitoringAPI) {
+		for _, metricType := range []string{requests.Metric.Type, latency.Metric.Type} {
+			api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Name: metricType, Type: metricType, ValueType: "DOUBLE"})
+		}
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		api := newAPI()
+		c := newCollector(api, true)
+		collect(c)
+		createDescriptors(api)
+		metrics := collect(c)
+
+		require.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_requests"], 1)
+		gauge := metrics["stackdriver_gce_instance_custom_googleapis_com_requests"][0]
+		assert.Equal(t, float64(1), gauge.GetGauge().GetValue())
+		assert.Equal(t, "1", labelsOf(gauge)["unit"], "the unit should be inferred from the series")
+		require.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_latency"], 1)
+		assert.Equal(t, float64(2), metrics["stackdriver_gce_instance_custom_googleapis_com_latency"][0].GetCounter().GetValue(), "the kind should be inferred from the series")
+		assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_described"], 1)
+
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.descriptorInferredTotal.WithLabelValues("custom.googleapis.com/requests")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.descriptorInferredTotal.WithLabelValues("custom.googleapis.com/latency")))
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.descriptorInferredTotal.WithLabelValues("custom.googleapis.com/described")))
+		assert.Equal(t, 2, api.descriptorRequestCount(), "the metric types missing from the cached descriptors should be discovered once per prefix")
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com")))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		api := newAPI()
+		c := newCollector(api, false)
+		collect(c)
+		createDescriptors(api)
+		metrics := collect(c)
+
+		assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_described"], 1)
+		assert.NotContains(t, metrics, "stackdriver_gce_instance_custom_googleapis_com_requests")
+		assert.NotContains(t, metrics, "stackdriver_gce_instance_custom_googleapis_com_latency")
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.descriptorInferredTotal.WithLabelValues("custom.googleapis.com/requests")))
+		assert.Equal(t, 1, api.descriptorRequestCount(), "the cached descriptors should be used alone")
+		assert.Equal(t, 2, api.timeSeriesRequestCount(), "only the series of the described metric type should be listed")
+	})
+
+	t.Run("failed discovery", func(t *testing.T) {
+		api := newAPI()
+		c := newCollector(api, true)
+		collect(c)
+		createDescriptors(api)
+		api.descriptorHook = func(*http.Request) int { return http.StatusInternalServerError }
+		metrics := collect(c)
+
+		assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_described"], 1, "the cached descriptors should still be reported")
+		assert.NotContains(t, metrics, "stackdriver_gce_instance_custom_googleapis_com_requests")
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.descriptorDiscoveryErrorsTotal.WithLabelValues("custom.googleapis.com")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com")), "a failed discovery should not fail the prefix")
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeErrorsTotalMetric))
+	})
+}
+
+func TestMonitoringCollector_DropEmptyLabelValues(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"code": "200", "method": "", "path": " "})
+	series.Resource.Labels["zone"] = ""
+	series.Metadata = &monitoring.MonitoredResourceMetadata{
+		SystemLabels: googleapi.RawMessage(`{"machine_type": "", "state": "  ", "spot": "false"}`),
+		UserLabels:   map[string]string{"team": "", "env": "prod"},
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true, DropEmptyLabelValues: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{
+			"unit":       "",
+			"code":       "200",
+			"path":       " ",
+			"project_id": "test-project",
+			"state":      "  ",
+			"spot":       "false",
+			"env":        "prod",
+		}, labelsOf(metrics[fqName][0]), "empty values should be dropped from every label source, whitespace-only ones kept")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		for _, key := range []string{"method", "zone", "machine_type", "team"} {
+			assert.Contains(t, labels, key)
+		}
+	})
+}
+
+func TestMonitoringCollector_MetricPrefix(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "compute.googleapis.com/instance/cpu/usage_time"}
+
+	for _, tc := range []struct {
+		prefix string
+		fqName string
+	}{
+		{"", "stackdriver_gce_instance_compute_googleapis_com_instance_cpu_usage_time"},
+		{"gcp", "gcp_gce_instance_compute_googleapis_com_instance_cpu_usage_time"},
+	} {
+		t.Run(tc.fqName, func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{MetricPrefix: tc.prefix})
+			series := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"instance_name": "a"})
+			metrics := reportPage(t, c, descriptor, series)
+
+			assert.Len(t, metrics, 1)
+			require.Len(t, metrics[tc.fqName], 1)
+
+			// The deduplicator tracks the series under its exported name
+			labelKeys := []string{"unit", "instance_name", "project_id"}
+			labelValues := []string{"", "a", "test-project"}
+			assert.True(t, duplicate(c.deduplicator.CheckAndMark("", tc.fqName, labelKeys, labelValues, time.Now())))
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{MetricPrefix: "my-exporter"}, slog.New(slog.NewTextHandler(os.Stdout, nil)), &testCounterStore{}, &testHistogramStore{})
+		assert.Error(t, err)
+	})
+}
+
+func TestMonitoringCollector_DescriptorCache(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	ttl := 200 * time.Millisecond
+
+	newCollector := func(api *fakeMonitoringAPI) *MonitoringCollector {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes: []string{"custom.googleapis.com"},
+			RequestInterval:    time.Minute,
+			DescriptorCacheTTL: ttl,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("ttl", func(t *testing.T) {
+		api := newFakeAPIWithDescriptors(2)
+		c := newCollector(api)
+
+		assert.Len(t, collectSeries(t, c), 4)
+		assert.Len(t, collectSeries(t, c), 4, "cached descriptors should be reported")
+		assert.Equal(t, 1, api.descriptorRequestCount(), "descriptors should not be listed again within the TTL")
+
+		time.Sleep(ttl)
+		assert.Len(t, collectSeries(t, c), 4)
+		assert.Equal(t, 2, api.descriptorRequestCount(), "descriptors should be listed again once the TTL expired")
+	})
+
+	t.Run("forced refresh", func(t *testing.T) {
+		api := newFakeAPIWithDescriptors(2)
+		c := newCollector(api)
+
+		collectSeries(t, c)
+		c.RefreshDescriptorCache()
+		collectSeries(t, c)
+		assert.Equal(t, 2, api.descriptorRequestCount(), "a forced refresh should bypass the cache")
+
+		collectSeries(t, c)
+		assert.Equal(t, 2, api.descriptorRequestCount(), "the refreshed descriptors should be cached")
+	})
+
+	t.Run("failed listing is not cached", func(t *testing.T) {
+		api := newFakeAPIWithDescriptors(2)
+		var fail atomic.Bool
+		fail.Store(true)
+		api.descriptorHook = func(*http.Request) int {
+			if fail.Load() {
+				return http.StatusInternalServerError
+			}
+			return 0
+		}
+		c := newCollector(api)
+
+		assert.Empty(t, collectSeries(t, c))
+		fail.Store(false)
+		assert.Len(t, collectSeries(t, c), 4)
+		assert.Equal(t, 2, api.descriptorRequestCount())
+	})
+
+	t.Run("expired descriptors served on a failed listing", func(t *testing.T) {
+		api := newFakeAPIWithDescriptors(2)
+		var fail atomic.Bool
+		api.descriptorHook = func(*http.Request) int {
+			if fail.Load() {
+				return http.StatusInternalServerError
+			}
+			return 0
+		}
+		c := newCollector(api)
+
+		assert.Len(t, collectSeries(t, c), 4)
+		fail.Store(true)
+		time.Sleep(ttl)
+		assert.Len(t, collectSeries(t, c), 4, "the expired descriptors should be reported")
+		assert.Equal(t, 2, api.descriptorRequestCount())
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.apiCallsSavedTotal.WithLabelValues("stale_served")))
+	})
+}
+
+func TestMonitoringCollector_APICallsSaved(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := newFakeAPIWithDescriptors(2)
+	// The same descriptor listed from an attached project is fetched once
+	duplicate := *api.descriptors[0]
+	duplicate.Name = "projects/attached-project/metricDescriptors/" + duplicate.Type
+	api.descriptors = append(api.descriptors, &duplicate)
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com"},
+		RequestInterval:    time.Minute,
+		DescriptorCacheTTL: time.Hour,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	saved := func() map[string]float64 {
+		return map[string]float64{
+			"descriptor_cache": testutil.ToFloat64(c.apiCallsSavedTotal.WithLabelValues("descriptor_cache")),
+			"coalesced":        testutil.ToFloat64(c.apiCallsSavedTotal.WithLabelValues("coalesced")),
+			"stale_served":     testutil.ToFloat64(c.apiCallsSavedTotal.WithLabelValues("stale_served")),
+		}
+	}
+
+	collectSeries(t, c)
+	assert.Equal(t, map[string]float64{"descriptor_cache": 0, "coalesced": 1, "stale_served": 0}, saved())
+	assert.Equal(t, 2, api.timeSeriesRequestCount())
+
+	collectSeries(t, c)
+	assert.Equal(t, map[string]float64{"descriptor_cache": 1, "coalesced": 2, "stale_served": 0}, saved())
+	assert.Equal(t, 1, api.descriptorRequestCount())
+}
+
+// newDistributionPointTimeSeries returns a GAUGE DISTRIBUTION time series with a single point of explicit buckets.
+func newDistributionPointTimeSeries(metricType string, bounds []float64, bucketCounts []int64, endTime time.Time) *monitoring.TimeSeries {
+	var count int64
+	for _, c := range bucketCounts {
+		count += c
+	}
+	return &monitoring.TimeSeries{
+		Metric:     &monitoring.Metric{Type: metricType},
+		Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "test-project"}},
+		MetricKind: "GAUGE",
+		ValueType:  "DISTRIBUTION",
+		Points: []*monitoring.Point{{
+			Interval: &monitoring.TimeInterval{EndTime: endTime.Format(time.RFC3339Nano)},
+			Value: &monitoring.TypedValue{DistributionValue: &monitoring.Distribution{
+				Count:         count,
+				Mean:          1,
+				BucketCounts:  bucketCounts,
+				BucketOptions: &monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{Bounds: bounds}},
+			}},
+		}},
+	}
+}
+
+func TestMonitoringCollector_HistogramPrecision(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	huge := int64(1<<54 + 3)
+
+	t.Run("warning", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, newDistributionPointTimeSeries(descriptor.Type, []float64{1}, []int64{huge, 1}, time.Now()))
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Empty(t, metrics[fqName+"_count_high"], "counts should not be split by default")
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.histogramPrecisionLossTotal.WithLabelValues(descriptor.Type)))
+	})
+
+	t.Run("safe counts", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{SplitLargeHistogramCounts: true})
+		metrics := reportPage(t, c, descriptor, newDistributionPointTimeSeries(descriptor.Type, []float64{1}, []int64{10, 1}, time.Now()))
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Empty(t, metrics[fqName+"_count_high"])
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.histogramPrecisionLossTotal.WithLabelValues(descriptor.Type)))
+	})
+
+	t.Run("split", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{SplitLargeHistogramCounts: true})
+		metrics := reportPage(t, c, descriptor, newDistributionPointTimeSeries(descriptor.Type, []float64{1}, []int64{huge, 1}, time.Now()))
+
+		require.Len(t, metrics[fqName+"_count_high"], 1)
+		require.Len(t, metrics[fqName+"_count_low"], 1)
+		high := uint64(metrics[fqName+"_count_high"][0].GetGauge().GetValue())
+		low := uint64(metrics[fqName+"_count_low"][0].GetGauge().GetValue())
+		assert.Equal(t, uint64(huge+1), high<<32+low)
+	})
+}
+
+func TestMonitoringCollector_ScrapeSuccess(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}}
+	for _, metricType := range []string{"custom.googleapis.com/healthy/metric", "custom.googleapis.com/failing/metric"} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Name: metricType, Type: metricType})
+		api.series[metricType] = []*monitoring.TimeSeries{newDoubleTimeSeries(metricType, 1, time.Now(), nil)}
+	}
+	api.timeSeriesHook = func(r *http.Request) int {
+		if strings.Contains(r.URL.Query().Get("filter"), "failing") {
+			return http.StatusBadRequest
+		}
+		return 0
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/healthy", "custom.googleapis.com/failing"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	series := collectSeries(t, c)
+	assert.Len(t, series, 1, "the series of the healthy prefix should still be reported")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/healthy")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/failing")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.apiErrorsTotalMetric))
+
+	api.mu.Lock()
+	api.timeSeriesHook = nil
+	api.mu.Unlock()
+	collectSeries(t, c)
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/failing")), "the prefix should recover")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.apiErrorsTotalMetric))
+}
+
+func TestMonitoringCollector_CircuitBreaker(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	metricType := "custom.googleapis.com/app/metric"
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{{Name: metricType, Type: metricType}},
+		series:      map[string][]*monitoring.TimeSeries{metricType: {newDoubleTimeSeries(metricType, 1, time.Now(), nil)}},
+	}
+	failing := true
+	api.descriptorHook = func(*http.Request) int {
+		if failing {
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+	descriptorRequests := func() int {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		return len(api.descriptorRequests)
+	}
+	setFailing := func(f bool) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		failing = f
+	}
+	// elapseCooldown moves the opening of the circuit back past the cooldown
+	elapseCooldown := func(c *MonitoringCollector) {
+		c.circuitBreaker.mu.Lock()
+		defer c.circuitBreaker.mu.Unlock()
+		c.circuitBreaker.openedAt = c.circuitBreaker.openedAt.Add(-time.Hour)
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes:     []string{"custom.googleapis.com/app"},
+		RequestInterval:        time.Minute,
+		CircuitBreakerFailures: 2,
+		CircuitBreakerCooldown: time.Hour,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	collectSeries(t, c)
+	assert.Equal(t, float64(circuitClosed), testutil.ToFloat64(c.circuitBreaker.stateMetric), "a single failure should not open the circuit")
+	collectSeries(t, c)
+	assert.Equal(t, float64(circuitOpen), testutil.ToFloat64(c.circuitBreaker.stateMetric), "the circuit should open after 2 failures")
+	require.Equal(t, 2, descriptorRequests())
+
+	// Open, the scrapes fail without calling the API
+	setFailing(false)
+	assert.Empty(t, collectSeries(t, c))
+	assert.Equal(t, 2, descriptorRequests(), "the API should not be called while the circuit is open")
+	assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/app")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.lastScrapeErrorMetric))
+
+	// Half-open after the cooldown, a failed probe opens the circuit again right away
+	setFailing(true)
+	elapseCooldown(c)
+	collectSeries(t, c)
+	assert.Equal(t, 3, descriptorRequests(), "the API should be probed after the cooldown")
+	assert.Equal(t, float64(circuitOpen), testutil.ToFloat64(c.circuitBreaker.stateMetric), "a failed probe should open the circuit")
+	collectSeries(t, c)
+	assert.Equal(t, 3, descriptorRequests())
+
+	// A successful probe closes the circuit
+	setFailing(false)
+	elapseCooldown(c)
+	assert.Len(t, collectSeries(t, c), 1)
+	assert.Equal(t, float64(circuitClosed), testutil.ToFloat64(c.circuitBreaker.stateMetric), "a successful probe should close the circuit")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/app")))
+}
+
+func TestMonitoringCollector_CircuitBreakerOptions(t *testing.T) {
+	for _, opts := range []MonitoringCollectorOptions{
+		{CircuitBreakerFailures: -1},
+		{CircuitBreakerFailures: 3},
+	} {
+		_, err := NewMonitoringCollector("test-project", nil, opts, slog.New(slog.DiscardHandler), &testCounterStore{}, &testHistogramStore{})
+		assert.Error(t, err, "%+v should be invalid", opts)
+	}
+}
+
+func TestMonitoringCollector_CircuitBreakerSingleProbe(t *testing.T) {
+	b := newCircuitBreaker("test-project", 1, time.Hour)
+	now := time.Now()
+	b.record(now, true)
+	require.False(t, b.allow(now), "the circuit should be open")
+
+	later := now.Add(time.Hour)
+	assert.True(t, b.allow(later), "a scrape should probe the API after the cooldown")
+	assert.False(t, b.allow(later), "a single scrape should probe the API at once")
+
+	b.release()
+	assert.True(t, b.allow(later), "a scrape should probe the API once the cancelled probe is released")
+	b.record(later, false)
+	assert.True(t, b.allow(later))
+	assert.True(t, b.allow(later), "every scrape should call the API once closed")
+}
+
+func TestMonitoringCollector_SharedCircuitBreaker(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := newFakeAPIWithDescriptors(1)
+	api.descriptorHook = func(*http.Request) int {
+		return http.StatusInternalServerError
+	}
+
+	breakers := NewCircuitBreakers()
+	newCollector := func(prefix string) *MonitoringCollector {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes:     []string{prefix},
+			RequestInterval:        time.Minute,
+			CircuitBreakerFailures: 1,
+			CircuitBreakerCooldown: time.Hour,
+			CircuitBreakers:        breakers,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		return c
+	}
+	c := newCollector("custom.googleapis.com/metric_00")
+	other := newCollector("custom.googleapis.com")
+	require.Same(t, c.circuitBreaker, other.circuitBreaker)
+
+	collectSeries(t, c)
+	require.Equal(t, 1, api.descriptorRequestCount())
+	collectSeries(t, other)
+	assert.Equal(t, 1, api.descriptorRequestCount(), "the circuit opened by a collector should skip the scrapes of the other collectors of the project")
+	assert.Equal(t, float64(1), testutil.ToFloat64(other.lastScrapeErrorMetric))
+}
+
+func TestMonitoringCollector_DedupHistoryDepth(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}}
+	for _, metricType := range []string{"custom.googleapis.com/a/metric", "custom.googleapis.com/b/metric"} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Name: metricType, Type: metricType})
+		api.series[metricType] = []*monitoring.TimeSeries{newDoubleTimeSeries(metricType, 1, now, nil)}
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/a", "custom.googleapis.com/b"},
+		RequestInterval:    time.Minute,
+		DedupByTimestamp:   true,
+		DedupHistoryDepth:  2,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	assert.Len(t, collectSeries(t, c), 2)
+	assert.Empty(t, collectSeries(t, c), "the points of every prefix reported by the previous scrape should not be reported again")
+
+	_, err = NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{DedupHistoryDepth: 2}, logger, &testCounterStore{}, &testHistogramStore{})
+	assert.ErrorContains(t, err, "invalid dedup history depth 2 without dedup by timestamp")
+}
+
+func TestMonitoringCollector_PhaseTimeouts(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}, latency: 150 * time.Millisecond}
+	for _, metricType := range []string{"custom.googleapis.com/fast/metric", "custom.googleapis.com/slow/metric"} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Name: metricType, Type: metricType})
+		api.series[metricType] = []*monitoring.TimeSeries{newDoubleTimeSeries(metricType, 1, time.Now(), nil)}
+	}
+	api.descriptorHook = func(r *http.Request) int {
+		if strings.Contains(r.URL.Query().Get("filter"), "slow") {
+			time.Sleep(300 * time.Millisecond)
+		}
+		return 0
+	}
+
+	newCollector := func(timeSeriesPhaseTimeout time.Duration) *MonitoringCollector {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes:     []string{"custom.googleapis.com/fast", "custom.googleapis.com/slow"},
+			RequestInterval:        time.Minute,
+			DescriptorPhaseTimeout: 100 * time.Millisecond,
+			TimeSeriesPhaseTimeout: timeSeriesPhaseTimeout,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("descriptor phase timeout", func(t *testing.T) {
+		c := newCollector(time.Second)
+		series := collectSeries(t, c)
+		assert.Len(t, series, 1, "the time series fetch should outlive the descriptor phase deadline")
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/fast")))
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/slow")))
+	})
+
+	t.Run("time series phase timeout", func(t *testing.T) {
+		c := newCollector(50 * time.Millisecond)
+		series := collectSeries(t, c)
+		assert.Empty(t, series)
+		assert.Equal(t, float64(0), testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/fast")))
+	})
+}
+
+func TestMonitoringCollector_MetricLastPointAge(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{
+		"custom.googleapis.com/fresh": {
+			newDoubleTimeSeries("custom.googleapis.com/fresh", 1, now.Add(-time.Minute), map[string]string{"instance": "a"}),
+			newDoubleTimeSeries("custom.googleapis.com/fresh", 1, now.Add(-5*time.Minute), map[string]string{"instance": "b"}),
+		},
+		"custom.googleapis.com/stale": {
+			newDoubleTimeSeries("custom.googleapis.com/stale", 1, now.Add(-10*time.Minute), nil),
+		},
+	}}
+	for metricType := range api.series {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Name: metricType, Type: metricType})
+	}
+
+	newCollector := func(enabled bool) *MonitoringCollector {
+		c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+			MetricTypePrefixes:     []string{"custom.googleapis.com"},
+			RequestInterval:        time.Hour,
+			EmitMetricLastPointAge: enabled,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		return c
+	}
+
+	ch := make(chan prometheus.Metric, 100)
+	newCollector(true).Collect(ch)
+	ages := map[string]float64{}
+	for _, m := range readMetrics(t, ch)["stackdriver_collector_metric_last_point_age_seconds"] {
+		ages[labelsOf(m)["metric_type"]] = m.GetGauge().GetValue()
+	}
+	require.Len(t, ages, 2)
+	assert.InDelta(t, time.Minute.Seconds(), ages["custom.googleapis.com/fresh"], 5, "the age should be the one of the newest point")
+	assert.InDelta(t, (10 * time.Minute).Seconds(), ages["custom.googleapis.com/stale"], 5)
+
+	ch = make(chan prometheus.Metric, 100)
+	newCollector(false).Collect(ch)
+	assert.Empty(t, readMetrics(t, ch)["stackdriver_collector_metric_last_point_age_seconds"])
+}
+
+func TestMonitoringCollector_TimeSeriesFilter(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Type: "compute.googleapis.com/instance/cpu/usage_time"}
+
+	for _, tc := range []struct {
+		name         string
+		filters      []MetricFilter
+		dropDelegate bool
+		expected     string
+	}{
+		{
+			name:     "no filters",
+			expected: `metric.type="compute.googleapis.com/instance/cpu/usage_time"`,
+		},
+		{
+			name:         "no filters with delegated projects dropped",
+			dropDelegate: true,
+			expected:     `project="test-project" AND metric.type="compute.googleapis.com/instance/cpu/usage_time"`,
+		},
+		{
+			name: "targeted and global filters",
+			filters: []MetricFilter{
+				{TargetedMetricPrefix: "compute.googleapis.com/instance", FilterQuery: `resource.labels.zone = "us-central1-a"`},
+				{TargetedMetricPrefix: "pubsub.googleapis.com", FilterQuery: `resource.labels.subscription_id = "other"`},
+				{FilterQuery: `metadata.user_labels.team = "infra" OR metadata.user_labels.team = "sre"`},
+			},
+			expected: `metric.type="compute.googleapis.com/instance/cpu/usage_time" AND (resource.labels.zone = "us-central1-a") AND (metadata.user_labels.team = "infra" OR metadata.user_labels.team = "sre")`,
+		},
+		{
+			name:     "empty filter query",
+			filters:  []MetricFilter{{TargetedMetricPrefix: "compute.googleapis.com"}},
+			expected: `metric.type="compute.googleapis.com/instance/cpu/usage_time"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{ExtraFilters: tc.filters, DropDelegatedProjects: tc.dropDelegate})
+			assert.Equal(t, tc.expected, c.timeSeriesFilter(descriptor))
+		})
+	}
+}
+
+func TestMonitoringCollector_DropDelegatedProjects(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	c := newTestCollector(t, MonitoringCollectorOptions{DropDelegatedProjects: true})
+
+	// A metric label named project_id takes the label, the resource still telling the project of the series
+	delegated := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"project_id": "test-project"})
+	delegated.Resource.Labels["project_id"] = "delegated-project"
+	own := newDoubleTimeSeries(descriptor.Type, 2, time.Now(), map[string]string{"project_id": "delegated-project"})
+	metrics := reportPage(t, c, descriptor, delegated, own)
+
+	require.Len(t, metrics[fqName], 1)
+	assert.Equal(t, float64(2), metrics[fqName][0].GetGauge().GetValue(), "only the series of the resources of the collected project should be kept")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("delegated_project", descriptor.Type, "gce_instance", "GAUGE", "DOUBLE")))
+}
+
+func TestMonitoringCollector_RequestWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	withDelay := &monitoring.MetricDescriptor{
+		Type:     "loadbalancing.googleapis.com/https/request_count",
+		Metadata: &monitoring.MetricDescriptorMetadata{IngestDelay: "210s"},
+	}
+	withoutDelay := &monitoring.MetricDescriptor{Type: "custom.googleapis.com/metric"}
+	emptyMetadata := &monitoring.MetricDescriptor{Type: "custom.googleapis.com/other", Metadata: &monitoring.MetricDescriptorMetadata{}}
+
+	tests := []struct {
+		name        string
+		ingestDelay bool
+		descriptor  *monitoring.MetricDescriptor
+		expectedEnd time.Time
+	}{
+		{"ingest delay from metadata", true, withDelay, now.Add(-210 * time.Second)},
+		{"offset fallback without metadata", true, withoutDelay, now.Add(-time.Minute)},
+		{"offset fallback without ingest delay", true, emptyMetadata, now.Add(-time.Minute)},
+		{"ingest delay disabled", false, withDelay, now.Add(-time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{
+				RequestInterval: 5 * time.Minute,
+				RequestOffset:   time.Minute,
+				IngestDelay:     tt.ingestDelay,
+			})
+			startTime, endTime, err := c.requestWindow(tt.descriptor, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedEnd, endTime)
+			assert.Equal(t, tt.expectedEnd.Add(-5*time.Minute), startTime)
+		})
+	}
+
+	t.Run("invalid ingest delay", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{IngestDelay: true})
+		_, _, err := c.requestWindow(&monitoring.MetricDescriptor{
+			Type:     "custom.googleapis.com/metric",
+			Metadata: &monitoring.MetricDescriptorMetadata{IngestDelay: "soon"},
+		}, now)
+		assert.Error(t, err)
+	})
+}
+
+func TestMonitoringCollector_RequestWindowMaxLookback(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	descriptor := &monitoring.MetricDescriptor{Type: "custom.googleapis.com/metric"}
+
+	t.Run("clamped", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{RequestInterval: 8 * 24 * time.Hour, MaxLookback: 6 * 24 * time.Hour})
+		startTime, endTime, err := c.requestWindow(descriptor, now)
+		require.NoError(t, err)
+		assert.Equal(t, now, endTime)
+		assert.Equal(t, now.Add(-6*24*time.Hour), startTime)
+	})
+
+	t.Run("within lookback", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{RequestInterval: time.Hour, MaxLookback: 24 * time.Hour})
+		startTime, _, err := c.requestWindow(descriptor, now)
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(-time.Hour), startTime)
+	})
+
+	t.Run("end beyond lookback", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{RequestInterval: time.Hour, RequestOffset: 2 * time.Hour, MaxLookback: time.Hour})
+		startTime, endTime, err := c.requestWindow(descriptor, now)
+		require.NoError(t, err)
+		assert.Equal(t, endTime, startTime, "the interval should not start after its end")
+	})
+}
+
+func TestMonitoringCollector_RequestWindowInitialLookback(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	descriptor := &monitoring.MetricDescriptor{Type: "custom.googleapis.com/metric"}
+	other := &monitoring.MetricDescriptor{Type: "custom.googleapis.com/other"}
+	c := newTestCollector(t, MonitoringCollectorOptions{RequestInterval: time.Minute, InitialLookback: 10 * time.Minute})
+
+	startTime, endTime, err := c.requestWindow(descriptor, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, endTime)
+	assert.Equal(t, now.Add(-10*time.Minute), startTime, "the first window should start the initial lookback before its end")
+
+	startTime, _, err = c.requestWindow(descriptor, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, now, startTime, "the later windows should start the request interval before their end")
+
+	startTime, _, err = c.requestWindow(other, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-10*time.Minute), startTime, "the first window of another metric type should use the initial lookback")
+}
+
+func TestMonitoringCollector_IngestDelayRequestedEndTime(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{
+			{Name: "custom.googleapis.com/delayed", Type: "custom.googleapis.com/delayed", Metadata: &monitoring.MetricDescriptorMetadata{IngestDelay: "300s"}},
+			{Name: "custom.googleapis.com/prompt", Type: "custom.googleapis.com/prompt"},
+		},
+		series: map[string][]*monitoring.TimeSeries{},
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/"},
+		RequestInterval:    time.Minute,
+		RequestOffset:      30 * time.Second,
+		IngestDelay:        true,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	before := time.Now().UTC()
+	collectSeries(t, c)
+	after := time.Now().UTC()
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	require.Len(t, api.timeSeriesRequests, 2)
+	for _, r := range api.timeSeriesRequests {
+		offset := 30 * time.Second
+		if strings.Contains(r.URL.Query().Get("filter"), "delayed") {
+			offset = 300 * time.Second
+		}
+		endTime, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("interval.endTime"))
+		require.NoError(t, err)
+		assert.False(t, endTime.Before(before.Add(-offset)), "end time %s should be %s before the scrape", endTime, offset)
+		assert.False(t, endTime.After(after.Add(-offset)), "end time %s should be %s before the scrape", endTime, offset)
+	}
+}
+
+func TestMonitoringCollector_MultipleProjectsRegistry(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	registry := prometheus.NewRegistry()
+	for _, projectID := range []string{"first-project", "second-project"} {
+		c, err := NewMonitoringCollector(projectID, nil, MonitoringCollectorOptions{}, logger, &testCounterStore{}, &testHistogramStore{})
+		require.NoError(t, err)
+		require.NoError(t, registry.Register(c), "collectors of different projects should be registrable together")
+	}
+}
+
+func TestMonitoringCollector_ScrapeDuration(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	api := newFakeAPIWithDescriptors(2)
+	api.latency = 20 * time.Millisecond
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	descs := make(chan *prometheus.Desc, 100)
+	c.Describe(descs)
+	close(descs)
+	var described bool
+	for desc := range descs {
+		described = described || fqNameOf(desc) == "stackdriver_monitoring_scrape_duration_seconds"
+	}
+	assert.True(t, described, "the scrape duration histogram should be described")
+
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	durations := readMetrics(t, ch)["stackdriver_monitoring_scrape_duration_seconds"]
+	require.Len(t, durations, 1)
+	assert.Equal(t, map[string]string{"project_id": "test-project"}, labelsOf(durations[0]))
+	assert.Equal(t, uint64(1), durations[0].GetHistogram().GetSampleCount())
+	assert.GreaterOrEqual(t, durations[0].GetHistogram().GetSampleSum(), api.latency.Seconds(), "the duration should cover the API calls")
+}
+
+// evictingCounterStore is a testCounterStore evicting its entries by report time.
+type evictingCounterStore struct {
+	testCounterStore
+	evictedBefore []time.Time
+}
+
+func (s *evictingCounterStore) EvictBefore(t time.Time) int {
+	s.evictedBefore = append(s.evictedBefore, t)
+	evicted := 0
+	for name, metrics := range s.metrics {
+		kept := metrics[:0]
+		for _, metric := range metrics {
+			if t.After(metric.CollectionTime) {
+				evicted++
+				continue
+			}
+			kept = append(kept, metric)
+		}
+		s.metrics[name] = kept
+	}
+	return evicted
+}
+
+func (s *evictingCounterStore) Len() int {
+	entries := 0
+	for _, metrics := range s.metrics {
+		entries += len(metrics)
+	}
+	return entries
+}
+
+func TestMonitoringCollector_DeltaAggregationTTL(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
+	now := time.Now()
+	store := &evictingCounterStore{}
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor"}
+	store.Increment(descriptor, &ConstMetric{FqName: "stale", CollectionTime: now.Add(-time.Hour)})
+	store.Increment(descriptor, &ConstMetric{FqName: "fresh", CollectionTime: now})
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, &fakeMonitoringAPI{}), MonitoringCollectorOptions{
+		MetricTypePrefixes:  []string{"custom.googleapis.com"},
+		RequestInterval:     time.Minute,
+		DeltaAggregationTTL: 30 * time.Minute,
+	}, logger, store, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	require.Len(t, store.evictedBefore, 1)
+	assert.WithinDuration(t, now.Add(-30*time.Minute), store.evictedBefore[0], time.Minute)
+	assert.Equal(t, []*ConstMetric{{FqName: "fresh", CollectionTime: now}}, store.ListMetrics(descriptor.Name))
+
+	require.Len(t, metrics["stackdriver_monitoring_delta_entries"], 1, "only the evicting stores should be reported")
+	assert.Equal(t, map[string]string{"project_id": "test-project", "store": "counter"}, labelsOf(metrics["stackdriver_monitoring_delta_entries"][0]))
+	assert.Equal(t, float64(1), metrics["stackdriver_monitoring_delta_entries"][0].GetGauge().GetValue())
+	require.Len(t, metrics["stackdriver_monitoring_delta_evictions_total"], 1)
+	assert.Equal(t, float64(1), metrics["stackdriver_monitoring_delta_evictions_total"][0].GetCounter().GetValue())
+}
+
+func TestMonitoringCollector_MaxConcurrency(t *testing.T) {
+	for _, maxConcurrentRequests := range []int{0, 8} {
+		c := newTestCollector(t, MonitoringCollectorOptions{MaxConcurrentRequests: maxConcurrentRequests})
+		expected := fmt.Sprintf(`
+# HELP stackdriver_collector_max_concurrency Max number of time series requests in flight for the project, 0 means unlimited.
+# TYPE stackdriver_collector_max_concurrency gauge
+stackdriver_collector_max_concurrency{project_id="test-project"} %d
+`, maxConcurrentRequests)
+		assert.NoError(t, testutil.CollectAndCompare(c.maxConcurrencyMetric, strings.NewReader(expected)))
+	}
+}
+
+func TestMonitoringCollector_MetricsScopeProject(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	metricType := "custom.googleapis.com/requests"
+	source := newDoubleTimeSeries(metricType, 1, time.Now(), nil)
+	source.Resource.Labels["project_id"] = "source-project"
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{{Name: metricType, Type: metricType}},
+		series:      map[string][]*monitoring.TimeSeries{metricType: {source}},
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes:  []string{"custom.googleapis.com/"},
+		RequestInterval:     time.Minute,
+		MetricsScopeProject: "scoping-project",
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+	series := collectSeries(t, c)
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	require.Len(t, api.timeSeriesRequests, 1)
+	assert.Equal(t, "/v3/projects/scoping-project/timeSeries", api.timeSeriesRequests[0].URL.Path, "the time series should be listed from the metrics scope")
+	require.Len(t, api.descriptorRequests, 1)
+	assert.Equal(t, "/v3/projects/test-project/metricDescriptors", api.descriptorRequests[0].URL.Path, "the descriptors should be listed from the collected project")
+
+	assert.Equal(t, map[string]float64{
+		"stackdriver_gce_instance_custom_googleapis_com_requests" + fmt.Sprintf("%v", map[string]string{
+			"project_id":        "source-project",
+			"scoped_project_id": "scoping-project",
+			"unit":              "",
+		}): 1,
+	}, series)
+}
+
+func TestMonitoringCollector_APIRequestMetrics(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{
+			{Name: "custom.googleapis.com/requests", Type: "custom.googleapis.com/requests"},
+			{Name: "custom.googleapis.com/forbidden", Type: "custom.googleapis.com/forbidden"},
+		},
+		series: map[string][]*monitoring.TimeSeries{},
+		timeSeriesHook: func(r *http.Request) int {
+			if strings.Contains(r.URL.Query().Get("filter"), "forbidden") {
+				return http.StatusForbidden
+			}
+			return 0
+		},
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+	collectSeries(t, c)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiRequestsTotal.WithLabelValues(apiMethodListMetricDescriptors, "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiRequestsTotal.WithLabelValues(apiMethodListTimeSeries, "200")), "the successful request should record its status code")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiRequestsTotal.WithLabelValues(apiMethodListTimeSeries, "403")), "the failed request should record its status code")
+	assert.Equal(t, 2, testutil.CollectAndCount(c.apiRequestDurationSeconds), "the duration should be observed per method")
+}
+
+func TestAPIRequestCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"success", nil, "200"},
+		{"api error", &googleapi.Error{Code: http.StatusTooManyRequests}, "429"},
+		{"wrapped api error", fmt.Errorf("listing: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), "503"},
+		{"deadline", context.DeadlineExceeded, "deadline_exceeded"},
+		{"canceled", context.Canceled, "canceled"},
+		{"transport error", fmt.Errorf("connection refused"), "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, apiRequestCode(tt.err))
+		})
+	}
+}
+
+func TestMonitoringCollector_LabelRenames(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	renames := map[string]string{"project_id": "gcp_project", "instance_id": "gce_instance", "team": "owner"}
+
+	t.Run("rename", func(t *testing.T) {
+		series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"code": "200"})
+		series.Resource.Labels["instance_id"] = "1234"
+		series.Resource.Labels["zone"] = "us-central1-a"
+		c := newTestCollector(t, MonitoringCollectorOptions{LabelRenames: renames})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{
+			"unit":         "",
+			"code":         "200",
+			"gcp_project":  "test-project",
+			"gce_instance": "1234",
+			"zone":         "us-central1-a",
+		}, labelsOf(metrics[fqName][0]), "only the listed labels should be renamed")
+	})
+
+	t.Run("collision", func(t *testing.T) {
+		series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"gcp_project": "metric-project"})
+		c := newTestCollector(t, MonitoringCollectorOptions{LabelRenames: renames})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, "metric-project", labelsOf(metrics[fqName][0])["gcp_project"], "the first label added should win")
+	})
+
+	t.Run("user label override", func(t *testing.T) {
+		series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"owner": "metric-owner"})
+		series.Metadata = &monitoring.MonitoredResourceMetadata{UserLabels: map[string]string{"team": "user-team"}}
+		for override, expected := range map[bool]string{false: "metric-owner", true: "user-team"} {
+			c := newTestCollector(t, MonitoringCollectorOptions{LabelRenames: renames, UserLabelsOverride: override})
+			metrics := reportPage(t, c, descriptor, series)
+
+			require.Len(t, metrics[fqName], 1)
+			assert.Equal(t, expected, labelsOf(metrics[fqName][0])["owner"], "override %v", override)
+		}
+	})
+}
+
+func TestMonitoringCollector_LabelSourcePrefixes(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"region": "us-west1"})
+	series.Resource.Labels["region"] = "us-east1"
+	series.Metadata = &monitoring.MonitoredResourceMetadata{
+		UserLabels:   map[string]string{"region": "eu-west1"},
+		SystemLabels: []byte(`{"region": "asia-east1"}`),
+	}
+
+	t.Run("collision without prefixes", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{"unit": "", "region": "us-west1", "project_id": "test-project"}, labelsOf(metrics[fqName][0]))
+	})
+
+	t.Run("prefixed sources coexist", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{
+			EnableSystemLabels:  true,
+			LabelSourcePrefixes: map[string]string{"resource": "resource_", "system": "system_", "user": "user_"},
+			LabelRenames:        map[string]string{"project_id": "gcp_project"},
+		})
+		metrics := reportPage(t, c, descriptor, series)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{
+			"unit":            "",
+			"region":          "us-west1",
+			"resource_region": "us-east1",
+			"system_region":   "asia-east1",
+			"user_region":     "eu-west1",
+			"gcp_project":     "test-project",
+		}, labelsOf(metrics[fqName][0]), "renamed labels should not be prefixed")
+	})
+}
+
+func TestNewMonitoringCollector_InvalidLabelSourcePrefixes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	for expected, prefixes := range map[string]map[string]string{
+		`unknown label source "labels"`:              {"labels": "labels_"},
+		`invalid prefix "resource-" of the resource`: {"resource": "resource-"},
+	} {
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+			LabelSourcePrefixes: prefixes,
+		}, logger, &testCounterStore{}, &testHistogramStore{})
+		assert.ErrorContains(t, err, expected)
+	}
+}
+
+func TestNewMonitoringCollector_InvalidLabelRename(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		LabelRenames: map[string]string{"project_id": "gcp-project"},
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	assert.ErrorContains(t, err, `invalid label name "gcp-project"`)
+}
+
+func TestMonitoringCollector_ResourceTypeAllowlist(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	instance := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), nil)
+	disk := newDoubleTimeSeries("custom.googleapis.com/requests", 2, time.Now(), nil)
+	disk.Resource.Type = "gce_disk"
+
+	t.Run("allowlisted", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{ResourceTypeAllowlist: []string{"gce_instance"}})
+		metrics := reportPage(t, c, descriptor, instance, disk)
+
+		assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_requests"], 1)
+		assert.NotContains(t, metrics, "stackdriver_gce_disk_custom_googleapis_com_requests", "a disallowed resource type should be dropped")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.deduplicator.checksTotal), "only the allowed series should reach the deduplicator")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("resource_type_not_allowed", "custom.googleapis.com/requests", "gce_disk", "GAUGE", "DOUBLE")))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, instance, disk)
+
+		assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_requests"], 1)
+		assert.Len(t, metrics["stackdriver_gce_disk_custom_googleapis_com_requests"], 1, "an empty allowlist should allow every resource type")
+		assert.Equal(t, 2.0, testutil.ToFloat64(c.deduplicator.checksTotal))
+	})
+}
+
+func TestMonitoringCollector_ValueTypeAllowlist(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}}
+	for metricType, valueType := range map[string]string{
+		"custom.googleapis.com/app/requests": "DOUBLE",
+		"custom.googleapis.com/app/latency":  "DOUBLE",
+		"custom.googleapis.com/app/up":       "BOOL",
+	} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{
+			Name:       "projects/test-project/metricDescriptors/" + metricType,
+			Type:       metricType,
+			MetricKind: "GAUGE",
+			ValueType:  valueType,
+		})
+		series := newDoubleTimeSeries(metricType, 1, now, nil)
+		series.ValueType = valueType
+		api.series[metricType] = []*monitoring.TimeSeries{series}
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/app"},
+		RequestInterval:    time.Minute,
+		ValueTypeAllowlist: []string{"INT64", "DOUBLE", "DISTRIBUTION"},
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1000)
+	c.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	var filters []string
+	for _, r := range api.timeSeriesRequests {
+		filters = append(filters, r.URL.Query().Get("filter"))
+	}
+	sort.Strings(filters)
+	assert.Equal(t, []string{
+		`metric.type="custom.googleapis.com/app/latency"`,
+		`metric.type="custom.googleapis.com/app/requests"`,
+	}, filters, "the time series of a disallowed value type should never be requested")
+	assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_app_requests"], 1)
+	assert.NotContains(t, metrics, "stackdriver_gce_instance_custom_googleapis_com_app_up")
+
+	_, err = NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		ValueTypeAllowlist: []string{"FLOAT"},
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	assert.ErrorContains(t, err, `invalid value type "FLOAT"`)
+}
+
+func TestMonitoringCollector_ProjectIDFilter(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	newSeries := func(projectID string) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"source": projectID})
+		if projectID == "" {
+			delete(series.Resource.Labels, "project_id")
+		} else {
+			series.Resource.Labels["project_id"] = projectID
+		}
+		return series
+	}
+	series := []*monitoring.TimeSeries{newSeries("test-project"), newSeries("team-a"), newSeries("team-b"), newSeries("")}
+	reportedProjects := func(t *testing.T, opts MonitoringCollectorOptions) ([]string, *MonitoringCollector) {
+		c := newTestCollector(t, opts)
+		var projects []string
+		for _, m := range reportPage(t, c, descriptor, series...)[fqName] {
+			projects = append(projects, labelsOf(m)["source"])
+		}
+		sort.Strings(projects)
+		return projects, c
+	}
+	dropped := func(c *MonitoringCollector, reason string) float64 {
+		return testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues(reason, descriptor.Type, "gce_instance", "GAUGE", "DOUBLE"))
+	}
+
+	t.Run("allowlist", func(t *testing.T) {
+		projects, c := reportedProjects(t, MonitoringCollectorOptions{ProjectIDAllowlist: []string{"test-project", "team-a"}})
+
+		assert.Equal(t, []string{"", "team-a", "test-project"}, projects, "series without a project_id should be reported")
+		assert.Equal(t, 1.0, dropped(c, "project_id_not_allowed"))
+		assert.Equal(t, 3.0, testutil.ToFloat64(c.deduplicator.checksTotal), "only the allowed series should reach the deduplicator")
+	})
+
+	t.Run("denylist", func(t *testing.T) {
+		projects, c := reportedProjects(t, MonitoringCollectorOptions{ProjectIDDenylist: []string{"team-b"}})
+
+		assert.Equal(t, []string{"", "team-a", "test-project"}, projects)
+		assert.Equal(t, 1.0, dropped(c, "project_id_denied"))
+	})
+
+	t.Run("both", func(t *testing.T) {
+		projects, c := reportedProjects(t, MonitoringCollectorOptions{
+			ProjectIDAllowlist: []string{"test-project", "team-a"},
+			ProjectIDDenylist:  []string{"team-a"},
+		})
+
+		assert.Equal(t, []string{"", "test-project"}, projects, "the denylist should win over the allowlist")
+		assert.Equal(t, 1.0, dropped(c, "project_id_denied"))
+		assert.Equal(t, 1.0, dropped(c, "project_id_not_allowed"))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		projects, _ := reportedProjects(t, MonitoringCollectorOptions{})
+
+		assert.Equal(t, []string{"", "team-a", "team-b", "test-project"}, projects, "every project should be reported")
+	})
+}
+
+func TestMonitoringCollector_PerRequestTimeout(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	metricType := "custom.googleapis.com/requests"
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{
+			{Name: metricType, Type: metricType},
+			{Name: "custom.googleapis.com/hung", Type: "custom.googleapis.com/hung"},
+		},
+		series: map[string][]*monitoring.TimeSeries{metricType: {newDoubleTimeSeries(metricType, 1, time.Now(), nil)}},
+		timeSeriesHook: func(r *http.Request) int {
+			if strings.Contains(r.URL.Query().Get("filter"), "hung") {
+				<-r.Context().Done() // Blocks until the client gives up
+			}
+			return 0
+		},
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/"},
+		RequestInterval:    time.Minute,
+		PerRequestTimeout:  50 * time.Millisecond,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	started := time.Now()
+	series := collectSeries(t, c)
+
+	assert.Less(t, time.Since(started), 5*time.Second, "the hung request should fail after the timeout")
+	assert.Equal(t, map[string]float64{
+		"stackdriver_gce_instance_custom_googleapis_com_requests" + fmt.Sprintf("%v", map[string]string{"project_id": "test-project", "unit": ""}): 1,
+	}, series, "the other descriptors should still be collected")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiErrorsTotalMetric))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiRequestsTotal.WithLabelValues(apiMethodListTimeSeries, "deadline_exceeded")))
+}
+
+func TestMonitoringCollector_BoolAndStringMetrics(t *testing.T) {
+	newPointSeries := func(metricType, valueType string, value *monitoring.TypedValue) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(metricType, 0, time.Now(), nil)
+		series.ValueType = valueType
+		series.Points[0].Value = value
+		return series
+	}
+	up, down := true, false
+	version := "1.2.3"
+	boolDescriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/up", MetricKind: "GAUGE", ValueType: "BOOL"}
+	stringDescriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/version", MetricKind: "GAUGE", ValueType: "STRING"}
+	stringSeries := newPointSeries("custom.googleapis.com/version", "STRING", &monitoring.TypedValue{StringValue: &version})
+
+	t.Run("bool", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		upSeries := newPointSeries("custom.googleapis.com/up", "BOOL", &monitoring.TypedValue{BoolValue: &up})
+		downSeries := newPointSeries("custom.googleapis.com/up", "BOOL", &monitoring.TypedValue{BoolValue: &down})
+		downSeries.Resource.Labels["zone"] = "us-central1-b"
+		metrics := reportPage(t, c, boolDescriptor, upSeries, downSeries)
+
+		values := map[string]float64{}
+		for _, m := range metrics["stackdriver_gce_instance_custom_googleapis_com_up"] {
+			values[labelsOf(m)["zone"]] = m.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"": 1, "us-central1-b": 0}, values)
+	})
+
+	t.Run("string enabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EmitStringMetrics: true})
+		metrics := reportPage(t, c, stringDescriptor, stringSeries)
+
+		fqName := "stackdriver_gce_instance_custom_googleapis_com_version"
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, 1.0, metrics[fqName][0].GetGauge().GetValue())
+		assert.Equal(t, "1.2.3", labelsOf(metrics[fqName][0])[stringValueLabel])
+	})
+
+	t.Run("string disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, stringDescriptor, stringSeries)
+
+		assert.Empty(t, metrics["stackdriver_gce_instance_custom_googleapis_com_version"])
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("unknown_value_type", "custom.googleapis.com/version", "gce_instance", "GAUGE", "STRING")))
+	})
+}
+
+func TestMonitoringCollector_Readiness(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	var failDescriptors atomic.Bool
+	failDescriptors.Store(true)
+	api := newFakeAPIWithDescriptors(1)
+	api.descriptorHook = func(*http.Request) int {
+		if failDescriptors.Load() {
+			return http.StatusForbidden
+		}
+		return 0
+	}
+	readiness := NewReadiness([]string{"test-project", "other-project"})
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/"},
+		RequestInterval:    time.Minute,
+		Readiness:          readiness,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	collectSeries(t, c)
+	assert.Equal(t, []string{"other-project", "test-project"}, readiness.NotReady(), "a failed descriptor list should not mark the project ready")
+
+	failDescriptors.Store(false)
+	collectSeries(t, c)
+	assert.Equal(t, []string{"other-project"}, readiness.NotReady())
+}
+
+func TestMonitoringCollector_DropLabels(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	newSeries := func(instanceID string) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries("custom.googleapis.com/requests", 1, time.Now(), map[string]string{"code": "200", "pod_uid": "uid-" + instanceID})
+		series.Resource.Labels["instance_id"] = instanceID
+		series.Resource.Labels["zone"] = "us-central1-a"
+		return series
+	}
+	dropLabels := []string{"instance_id", "pod_*"}
+
+	t.Run("dropped", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DropLabels: dropLabels})
+		metrics := reportPage(t, c, descriptor, newSeries("1"))
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{"unit": "", "code": "200", "project_id": "test-project", "zone": "us-central1-a"}, labelsOf(metrics[fqName][0]))
+	})
+
+	t.Run("dedup on dropped labels", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DropLabels: dropLabels})
+		metrics := reportPage(t, c, descriptor, newSeries("1"), newSeries("2"))
+
+		assert.Len(t, metrics[fqName], 1, "series only differing by dropped labels should be duplicates")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.deduplicator.duplicatesTotal))
+	})
+
+	t.Run("dedup on full labels", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DropLabels: dropLabels, DedupOnFullLabels: true})
+		metrics := reportPage(t, c, descriptor, newSeries("1"), newSeries("1"), newSeries("2"))
+
+		require.Len(t, metrics[fqName], 1, "series colliding once their labels dropped should not be emitted twice")
+		assert.NotContains(t, labelsOf(metrics[fqName][0]), "instance_id")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.deduplicator.duplicatesTotal), "only identical series should be duplicates")
+		assert.Equal(t, 1.0, testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("dropped_labels_collision", descriptor.Type, "gce_instance", "GAUGE", "DOUBLE")))
+	})
+}
+
+func TestMonitoringCollector_NativeHistograms(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/latencies"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_latencies"
+
+	exponential := newDistributionPointTimeSeries(descriptor.Type, nil, []int64{1, 2, 3}, time.Now())
+	exponential.Metric.Labels = map[string]string{"buckets": "exponential"}
+	exponential.Points[0].Value.DistributionValue.BucketOptions = &monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+		NumFiniteBuckets: 4, GrowthFactor: 2, Scale: 1,
+	}}
+	explicit := newDistributionPointTimeSeries(descriptor.Type, []float64{1, 5, 10}, []int64{1, 2, 3}, time.Now())
+	explicit.Metric.Labels = map[string]string{"buckets": "explicit"}
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fill missing labels %t", fillMissingLabels), func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{NativeHistograms: true, FillMissingLabels: fillMissingLabels})
+			metrics := reportPage(t, c, descriptor, exponential, explicit)
+
+			require.Len(t, metrics[fqName], 2)
+			for _, m := range metrics[fqName] {
+				h := m.GetHistogram()
+				assert.Equal(t, uint64(6), h.GetSampleCount())
+				switch labelsOf(m)["buckets"] {
+				case "exponential":
+					assert.Equal(t, int32(0), h.GetSchema())
+					assert.Equal(t, uint64(1), h.GetZeroCount())
+					assert.Equal(t, float64(1), h.GetZeroThreshold())
+					assert.Empty(t, h.GetBucket(), "native histograms should have no classic buckets")
+					assert.NotEmpty(t, h.GetPositiveSpan())
+				case "explicit":
+					assert.Len(t, h.GetBucket(), 4, "explicit buckets should fall back to a classic histogram")
+					assert.Empty(t, h.GetPositiveSpan())
+				}
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		metrics := reportPage(t, c, descriptor, exponential)
+
+		require.Len(t, metrics[fqName], 1)
+		assert.Len(t, metrics[fqName][0].GetHistogram().GetBucket(), 6)
+		assert.Empty(t, metrics[fqName][0].GetHistogram().GetPositiveSpan())
+	})
+}
+
+func TestMonitoringCollector_PartialPages(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}, pageSize: 2}
+	for metricType, count := range map[string]int{"custom.googleapis.com/app/requests": 5, "custom.googleapis.com/app/latency": 1} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{
+			Name:       "projects/test-project/metricDescriptors/" + metricType,
+			Type:       metricType,
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+		})
+		for i := 0; i < count; i++ {
+			api.series[metricType] = append(api.series[metricType], newDoubleTimeSeries(metricType, float64(i), now, map[string]string{"instance": strconv.Itoa(i)}))
+		}
+	}
+	// The third and last page of the requests fails
+	api.timeSeriesHook = func(r *http.Request) int {
+		if strings.Contains(r.URL.Query().Get("filter"), "app/requests") && r.URL.Query().Get("pageToken") == "4" {
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/app"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1000)
+	c.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	var instances []string
+	for _, m := range metrics["stackdriver_gce_instance_custom_googleapis_com_app_requests"] {
+		instances = append(instances, labelsOf(m)["instance"])
+	}
+	sort.Strings(instances)
+	assert.Equal(t, []string{"0", "1", "2", "3"}, instances, "the series of the pages before the failing one should be reported")
+	assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_app_latency"], 1, "the other descriptors should be reported")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.apiErrorsTotalMetric))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/app")), "the failing page should fail the scrape of its prefix")
+}
+
+func TestMonitoringCollector_StreamsPages(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+
+	metricType := "custom.googleapis.com/app/requests"
+	api := &fakeMonitoringAPI{
+		descriptors: []*monitoring.MetricDescriptor{{
+			Name:       "projects/test-project/metricDescriptors/" + metricType,
+			Type:       metricType,
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+		}},
+		series:   map[string][]*monitoring.TimeSeries{},
+		pageSize: 1,
+	}
+	for i := 0; i < 3; i++ {
+		api.series[metricType] = append(api.series[metricType], newDoubleTimeSeries(metricType, float64(i), now, map[string]string{"instance": strconv.Itoa(i)}))
+	}
+
+	ch := make(chan prometheus.Metric, 1000)
+	var reported []int
+	api.timeSeriesHook = func(r *http.Request) int {
+		reported = append(reported, len(ch))
+		return 0
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com/app"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	c.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	require.Len(t, reported, 3)
+	assert.Equal(t, []int{reported[0], reported[0] + 1, reported[0] + 2}, reported, "each page should be reported before the next one is requested")
+	assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_app_requests"], 3)
+}
+
+func TestMonitoringCollector_MaxSeriesPerMetricType(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	now := time.Now()
+
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}, pageSize: 2}
+	for metricType, count := range map[string]int{"custom.googleapis.com/app/requests": 7, "custom.googleapis.com/app/latency": 3} {
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{
+			Name:       "projects/test-project/metricDescriptors/" + metricType,
+			Type:       metricType,
+			MetricKind: "GAUGE",
+			ValueType:  "DOUBLE",
+		})
+		for i := 0; i < count; i++ {
+			api.series[metricType] = append(api.series[metricType], newDoubleTimeSeries(metricType, float64(i), now, map[string]string{"instance": strconv.Itoa(i)}))
+		}
+	}
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes:     []string{"custom.googleapis.com/app"},
+		RequestInterval:        time.Minute,
+		MaxSeriesPerMetricType: 3,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1000)
+	c.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	var instances []string
+	for _, m := range metrics["stackdriver_gce_instance_custom_googleapis_com_app_requests"] {
+		instances = append(instances, labelsOf(m)["instance"])
+	}
+	sort.Strings(instances)
+	assert.Equal(t, []string{"0", "1", "2"}, instances, "the series within the limit should be reported")
+	assert.Len(t, metrics["stackdriver_gce_instance_custom_googleapis_com_app_latency"], 3, "a metric type within the limit should be reported whole")
+
+	var requestsPages int
+	for _, r := range api.timeSeriesRequests {
+		if strings.Contains(r.URL.Query().Get("filter"), "app/requests") {
+			requestsPages++
+		}
+	}
+	assert.Equal(t, 2, requestsPages, "the pagination should stop once the limit is exceeded")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.seriesLimitHitTotal.WithLabelValues("custom.googleapis.com/app/requests")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(c.seriesLimitHitTotal.WithLabelValues("custom.googleapis.com/app/latency")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.scrapeSuccessMetric.WithLabelValues("custom.googleapis.com/app")), "a truncated metric type should not fail the scrape")
+}
+
+func TestMonitoringCollector_ResourceInfoMetric(t *testing.T) {
+	c := newTestCollector(t, MonitoringCollectorOptions{ResourceInfoMetric: true})
+	now := time.Now()
+
+	newSeries := func(metricType, instanceID string) *monitoring.TimeSeries {
+		ts := newDoubleTimeSeries(metricType, 1, now, map[string]string{"device": "a"})
+		ts.Resource.Labels = map[string]string{"project_id": "test-project", "zone": "us-east1-a", "instance_id": instanceID}
+		ts.Metadata = &monitoring.MonitoredResourceMetadata{UserLabels: map[string]string{"team": "storage"}}
+		return ts
+	}
+	cpu := &monitoring.MetricDescriptor{Name: "cpu", Type: "compute.googleapis.com/instance/cpu/utilization"}
+	disk := &monitoring.MetricDescriptor{Name: "disk", Type: "compute.googleapis.com/instance/disk/read_ops"}
+
+	cpuMetrics := reportPage(t, c, cpu, newSeries(cpu.Type, "1"), newSeries(cpu.Type, "2"))
+	diskMetrics := reportPage(t, c, disk, newSeries(disk.Type, "1"))
+
+	cpuSeries := cpuMetrics["stackdriver_gce_instance_compute_googleapis_com_instance_cpu_utilization"]
+	diskSeries := diskMetrics["stackdriver_gce_instance_compute_googleapis_com_instance_disk_read_ops"]
+	require.Len(t, cpuSeries, 2)
+	require.Len(t, diskSeries, 1)
+	for _, m := range append(cpuSeries, diskSeries...) {
+		labels := labelsOf(m)
+		assert.Equal(t, "test-project", labels["project_id"])
+		assert.NotEmpty(t, labels[resourceIDLabel])
+		assert.NotContains(t, labels, "zone", "the resource labels should only be on the info metric")
+		assert.NotContains(t, labels, "team", "the user labels should only be on the info metric")
+	}
+
+	ids := map[string]string{}
+	for _, m := range cpuSeries {
+		ids[labelsOf(m)[resourceIDLabel]] = ""
+	}
+	assert.Len(t, ids, 2, "distinct resources should have distinct join keys")
+
+	// The resource shared by both metric types is reported once, with the join key of its series
+	infos := append(cpuMetrics["stackdriver_resource_info"], diskMetrics["stackdriver_resource_info"]...)
+	require.Len(t, infos, 2)
+	for _, m := range infos {
+		labels := labelsOf(m)
+		assert.Equal(t, float64(1), m.GetGauge().GetValue())
+		assert.Equal(t, "gce_instance", labels[resourceTypeLabel])
+		assert.Equal(t, "us-east1-a", labels["zone"])
+		assert.Equal(t, "storage", labels["team"])
+		ids[labels[resourceIDLabel]] = labels["instance_id"]
+	}
+	assert.Len(t, ids, 2, "the info metrics should be joined on the data metrics resource_id")
+	assert.Equal(t, ids[labelsOf(diskSeries[0])[resourceIDLabel]], "1")
+
+	// The join keys are stable across scrapes, and the info metrics are reported again
+	c.resourceInfos.Reset()
+	again := reportPage(t, c, disk, newSeries(disk.Type, "1"))
+	require.Len(t, again["stackdriver_resource_info"], 1)
+	assert.Equal(t, labelsOf(diskSeries[0])[resourceIDLabel], labelsOf(again["stackdriver_resource_info"][0])[resourceIDLabel])
+
+	// An invalid info metric is dropped instead of panicking, the data metrics of its resource still being reported
+	invalid := newSeries(disk.Type, "3")
+	invalid.Metadata.UserLabels["team"] = "\xff"
+	invalidMetrics := reportPage(t, c, disk, invalid)
+	assert.Empty(t, invalidMetrics["stackdriver_resource_info"])
+	assert.Len(t, invalidMetrics["stackdriver_gce_instance_compute_googleapis_com_instance_disk_read_ops"], 1)
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("invalid_resource_info", disk.Type, "gce_instance", invalid.MetricKind, invalid.ValueType)))
+}
+
+func TestMonitoringCollector_PointSelection(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "compute.googleapis.com/instance/cpu/utilization"}
+
+	newSeries := func(metricKind string) *monitoring.TimeSeries {
+		ts := newDoubleTimeSeries(descriptor.Type, 0, now, nil)
+		ts.MetricKind = metricKind
+		// The points are not ordered by end time
+		ts.Points = nil
+		for _, p := range []struct {
+			value float64
+			age   time.Duration
+		}{{2, time.Minute}, {1, 2 * time.Minute}, {6, 0}} {
+			value := p.value
+			ts.Points = append(ts.Points, &monitoring.Point{
+				Interval: &monitoring.TimeInterval{EndTime: now.Add(-p.age).Format(time.RFC3339Nano)},
+				Value:    &monitoring.TypedValue{DoubleValue: &value},
+			})
+		}
+		return ts
+	}
+
+	for _, tc := range []struct {
+		selection PointSelection
+		value     float64
+		endTime   time.Time
+	}{
+		{selection: "", value: 6, endTime: now},
+		{selection: PointSelectionLatest, value: 6, endTime: now},
+		{selection: PointSelectionOldest, value: 1, endTime: now.Add(-2 * time.Minute)},
+		{selection: PointSelectionSum, value: 9, endTime: now},
+		{selection: PointSelectionMean, value: 3, endTime: now},
+	} {
+		t.Run(string(tc.selection), func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{PointSelection: tc.selection})
+			metrics := reportPage(t, c, descriptor, newSeries("GAUGE"))
+			series := metrics["stackdriver_gce_instance_compute_googleapis_com_instance_cpu_utilization"]
+			require.Len(t, series, 1)
+			assert.Equal(t, tc.value, series[0].GetGauge().GetValue())
+			assert.Equal(t, tc.endTime.UnixMilli(), series[0].GetTimestampMs())
+		})
+	}
+
+	t.Run("non gauge", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{PointSelection: PointSelectionSum})
+		metrics := reportPage(t, c, descriptor, newSeries("CUMULATIVE"))
+		series := metrics["stackdriver_gce_instance_compute_googleapis_com_instance_cpu_utilization"]
+		require.Len(t, series, 1)
+		assert.Equal(t, float64(6), series[0].GetCounter().GetValue(), "only the GAUGE series should be aggregated")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{PointSelection: "median"}, slog.Default(), &testCounterStore{}, &testHistogramStore{})
+		assert.ErrorContains(t, err, `invalid point selection "median"`)
+	})
+}
+
+func TestMonitoringCollector_MaxLabelValueLength(t *testing.T) {
+	c := newTestCollector(t, MonitoringCollectorOptions{MaxLabelValueLength: 32})
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/app/requests"}
+
+	long := strings.Repeat("x", 1000)
+	ts := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), map[string]string{"path": long, "code": "200"})
+	ts.Resource.Labels["instance_id"] = long
+	ts.Metadata = &monitoring.MonitoredResourceMetadata{UserLabels: map[string]string{"team": long}}
+
+	metrics := reportPage(t, c, descriptor, ts)
+	series := metrics["stackdriver_gce_instance_custom_googleapis_com_app_requests"]
+	require.Len(t, series, 1)
+	labels := labelsOf(series[0])
+	assert.Equal(t, "200", labels["code"])
+	assert.Equal(t, "test-project", labels["project_id"])
+	for _, key := range []string{"path", "instance_id", "team"} {
+		assert.Equal(t, truncateLabelValue(long, 32), labels[key], "label %s should be truncated", key)
+	}
+
+	_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{MaxLabelValueLength: labelValueHashSuffixLength}, slog.Default(), &testCounterStore{}, &testHistogramStore{})
+	assert.ErrorContains(t, err, "invalid max label value length")
+}
+
+func TestMonitoringCollector_RawDeltaPrefixes(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	counterStore := &testCounterStore{}
+	c, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		AggregateDeltas:  true,
+		RawDeltaPrefixes: []string{"custom.googleapis.com/raw"},
+	}, logger, counterStore, &testHistogramStore{})
+	require.NoError(t, err)
+
+	raw := &monitoring.MetricDescriptor{Name: "raw", Type: "custom.googleapis.com/raw/requests", MetricKind: "DELTA"}
+	aggregated := &monitoring.MetricDescriptor{Name: "aggregated", Type: "custom.googleapis.com/aggregated/requests", MetricKind: "DELTA"}
+	newDelta := func(metricType string, value float64, endTime time.Time) *monitoring.TimeSeries {
+		ts := newDoubleTimeSeries(metricType, value, endTime, nil)
+		ts.MetricKind = "DELTA"
+		return ts
+	}
+
+	endTime := time.Now().Truncate(time.Second)
+	for i := 0; i < 2; i++ {
+		endTime = endTime.Add(time.Minute)
+		c.deduplicator.Reset()
+		metrics := reportPage(t, c, raw, newDelta(raw.Type, 3, endTime))
+		series := metrics["stackdriver_gce_instance_custom_googleapis_com_raw_requests"]
+		require.Len(t, series, 1)
+		require.NotNil(t, series[0].GetGauge(), "raw deltas should be reported as gauges")
+		assert.Equal(t, float64(3), series[0].GetGauge().GetValue(), "raw deltas should not accumulate")
+		assert.Equal(t, endTime.UnixMilli(), series[0].GetTimestampMs())
+	}
+	assert.Empty(t, counterStore.ListMetrics(raw.Name), "raw deltas should bypass the delta store")
+
+	reportPage(t, c, aggregated, newDelta(aggregated.Type, 3, endTime))
+	assert.Len(t, counterStore.ListMetrics(aggregated.Name), 1, "the other deltas should still be aggregated")
+}
+
+func TestMonitoringCollector_DeriveLabels(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	region := LabelDerivation{Source: "zone", Target: "region", Regex: "(.+)-[a-z]", Replace: "$1"}
+	newSeries := func(zone string, value float64) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, value, time.Now(), nil)
+		series.Resource.Labels["zone"] = zone
+		return series
+	}
+
+	t.Run("zone to region", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DeriveLabels: []LabelDerivation{region}})
+		metrics := reportPage(t, c, descriptor, newSeries("us-central1-a", 1), newSeries("us-central1-b", 2))
+
+		require.Len(t, metrics[fqName], 2)
+		for _, metric := range metrics[fqName] {
+			assert.Equal(t, "us-central1", labelsOf(metric)["region"])
+		}
+	})
+
+	t.Run("collapsed zones", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DeriveLabels: []LabelDerivation{region}, DropLabels: []string{"zone"}})
+		metrics := reportPage(t, c, descriptor, newSeries("us-central1-a", 1), newSeries("us-central1-b", 2), newSeries("europe-west1-b", 3))
+
+		require.Len(t, metrics[fqName], 2, "the zones of a region should be deduplicated once dropped")
+		regions := map[string]float64{}
+		for _, metric := range metrics[fqName] {
+			assert.NotContains(t, labelsOf(metric), "zone")
+			regions[labelsOf(metric)["region"]] = metric.GetGauge().GetValue()
+		}
+		assert.Equal(t, map[string]float64{"us-central1": 1, "europe-west1": 3}, regions)
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{DeriveLabels: []LabelDerivation{
+			region,
+			{Source: "missing", Target: "derived", Regex: ".*", Replace: "value"},
+		}})
+		metrics := reportPage(t, c, descriptor, newSeries("global", 1))
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		assert.Equal(t, "global", labels["zone"])
+		assert.NotContains(t, labels, "region", "a value not matching should not derive a label")
+		assert.NotContains(t, labels, "derived", "a missing source should not derive a label")
+	})
+
+	for _, invalid := range []LabelDerivation{
+		{Source: "zone", Target: "invalid-name", Regex: ".*"},
+		{Target: "region", Regex: ".*"},
+		{Source: "zone", Target: "region", Regex: "(unclosed"},
+	} {
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{DeriveLabels: []LabelDerivation{invalid}}, slog.Default(), &testCounterStore{}, &testHistogramStore{})
+		assert.Error(t, err, "derivation %+v should be rejected", invalid)
+	}
+}
+
+func TestMonitoringCollector_EphemeralDeltaPrefixes(t *testing.T) {
+	counterStore := &testCounterStore{}
+	c, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		AggregateDeltas:        true,
+		EphemeralDeltaPrefixes: []string{"kubernetes.io/container"},
+	}, slog.Default(), counterStore, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ephemeral := &monitoring.MetricDescriptor{Name: "ephemeral", Type: "kubernetes.io/container/requests", MetricKind: "DELTA"}
+	regular := &monitoring.MetricDescriptor{Name: "regular", Type: "custom.googleapis.com/requests", MetricKind: "DELTA"}
+	for _, descriptor := range []*monitoring.MetricDescriptor{ephemeral, regular} {
+		ts := newDoubleTimeSeries(descriptor.Type, 3, time.Now(), nil)
+		ts.MetricKind = "DELTA"
+		reportPage(t, c, descriptor, ts)
+	}
+
+	require.Len(t, counterStore.ListMetrics(ephemeral.Name), 1)
+	assert.True(t, counterStore.ListMetrics(ephemeral.Name)[0].StartsAtZero, "the ephemeral deltas should start from zero")
+	require.Len(t, counterStore.ListMetrics(regular.Name), 1)
+	assert.False(t, counterStore.ListMetrics(regular.Name)[0].StartsAtZero, "the other deltas should start from their value")
+
+	c, err = NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{
+		EphemeralDeltaPrefixes: []string{"kubernetes.io/container"},
+	}, slog.Default(), &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+	assert.False(t, c.ephemeralDeltas(ephemeral.Type), "the ephemeral deltas should only apply when aggregating deltas")
+}
+
+func TestMonitoringCollector_OmitPointTimestamps(t *testing.T) {
+	gauge := &monitoring.MetricDescriptor{Name: "gauge", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	distribution := &monitoring.MetricDescriptor{Name: "distribution", Type: "custom.googleapis.com/latencies", MetricKind: "GAUGE", ValueType: "DISTRIBUTION"}
+	endTime := time.Now().Add(-3 * time.Minute).Truncate(time.Second)
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		for _, omitPointTimestamps := range []bool{false, true} {
+			c := newTestCollector(t, MonitoringCollectorOptions{FillMissingLabels: fillMissingLabels, OmitPointTimestamps: omitPointTimestamps})
+			metrics := reportPage(t, c, gauge, newDoubleTimeSeries(gauge.Type, 1, endTime, nil))
+			for fqName, series := range reportPage(t, c, distribution, newDistributionPointTimeSeries(distribution.Type, []float64{1, 5}, []int64{1, 2, 3}, endTime)) {
+				metrics[fqName] = series
+			}
+
+			for _, fqName := range []string{"stackdriver_gce_instance_custom_googleapis_com_requests", "stackdriver_gce_instance_custom_googleapis_com_latencies"} {
+				require.Len(t, metrics[fqName], 1)
+				if omitPointTimestamps {
+					assert.Nil(t, metrics[fqName][0].TimestampMs, "%s should be stamped with the scrape time", fqName)
+				} else {
+					assert.Equal(t, endTime.UnixMilli(), metrics[fqName][0].GetTimestampMs(), "%s should carry the end time of its point", fqName)
+				}
+			}
+		}
+	}
+}
+
+func TestMonitoringCollector_ProjectIDLabelSource(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	endTime := time.Now()
+	newSeries := func(projectID string) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, 1, endTime, nil)
+		series.Resource.Labels["project_id"] = projectID
+		return series
+	}
+
+	for _, tc := range []struct {
+		source         ProjectIDLabelSource
+		expectedLabels []map[string]string
+		dedupProjectID string
+		duplicates     float64
+	}{
+		{
+			source: "",
+			expectedLabels: []map[string]string{
+				{"project_id": "project-a", "scoped_project_id": "scope-project", "unit": ""},
+				{"project_id": "project-b", "scoped_project_id": "scope-project", "unit": ""},
+			},
+			dedupProjectID: "test-project",
+		},
+		{
+			source: ProjectIDLabelResource,
+			expectedLabels: []map[string]string{
+				{"project_id": "project-a", "unit": ""},
+				{"project_id": "project-b", "unit": ""},
+			},
+			dedupProjectID: "test-project",
+		},
+		{
+			// The series only differing by their project deduplicate together under the scoping project
+			source:         ProjectIDLabelScope,
+			expectedLabels: []map[string]string{{"project_id": "scope-project", "unit": ""}},
+			dedupProjectID: "scope-project",
+			duplicates:     1,
+		},
+	} {
+		t.Run(string(tc.source), func(t *testing.T) {
+			c := newTestCollector(t, MonitoringCollectorOptions{MetricsScopeProject: "scope-project", ProjectIDLabelSource: tc.source})
+			metrics := reportPage(t, c, descriptor, newSeries("project-a"), newSeries("project-b"))
+
+			var labels []map[string]string
+			for _, m := range metrics["stackdriver_gce_instance_custom_googleapis_com_requests"] {
+				labels = append(labels, labelsOf(m))
+			}
+			assert.ElementsMatch(t, tc.expectedLabels, labels)
+
+			registry := prometheus.NewRegistry()
+			require.NoError(t, registry.Register(c.deduplicator))
+			expected := fmt.Sprintf(`
+# HELP stackdriver_deduplicator_checks_total Total number of deduplication checks performed.
+# TYPE stackdriver_deduplicator_checks_total counter
+stackdriver_deduplicator_checks_total{project_id=%[1]q} 2
+# HELP stackdriver_deduplicator_duplicates_total Total number of duplicate metrics detected and dropped.
+# TYPE stackdriver_deduplicator_duplicates_total counter
+stackdriver_deduplicator_duplicates_total{project_id=%[1]q} %[2]v
+`, tc.dedupProjectID, tc.duplicates)
+			require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "stackdriver_deduplicator_checks_total", "stackdriver_deduplicator_duplicates_total"))
+		})
+	}
+
+	_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{ProjectIDLabelSource: "folder"}, slog.New(slog.DiscardHandler), &testCounterStore{}, &testHistogramStore{})
+	assert.Error(t, err)
+}
+
+func TestMonitoringCollector_CollectContextCancel(t *testing.T) {
+	api := &fakeMonitoringAPI{series: map[string][]*monitoring.TimeSeries{}}
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		metricType := fmt.Sprintf("custom.googleapis.com/app/metric_%d", i)
+		api.descriptors = append(api.descriptors, &monitoring.MetricDescriptor{Type: metricType, MetricKind: "GAUGE", ValueType: "DOUBLE"})
+		api.series[metricType] = []*monitoring.TimeSeries{newDoubleTimeSeries(metricType, 1, now, nil)}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The scrape is cancelled while the first time series request is in flight
+	api.timeSeriesHook = func(*http.Request) int {
+		cancel()
+		return 0
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes:    []string{"custom.googleapis.com/app"},
+		RequestInterval:       time.Minute,
+		MaxConcurrentRequests: 1,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	ch := make(chan prometheus.Metric, 1000)
+	c.CollectContext(ctx, ch)
+	readMetrics(t, ch)
+
+	assert.Equal(t, 1, api.timeSeriesRequestCount(), "no more time series should be requested once the scrape is cancelled")
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.lastScrapeErrorMetric))
+}
+
+func TestMonitoringCollector_DescriptorHelp(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	api := newFakeAPIWithDescriptors(3)
+	api.descriptors[0].Description = "Number of requests served."
+	api.descriptors[1].Description = "Latency of the requests,\nfrom reception\tto response.\x00"
+
+	c, err := NewMonitoringCollector("test-project", newFakeMonitoringService(t, api), MonitoringCollectorOptions{
+		MetricTypePrefixes: []string{"custom.googleapis.com"},
+		RequestInterval:    time.Minute,
+	}, logger, &testCounterStore{}, &testHistogramStore{})
+	require.NoError(t, err)
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	var exposition strings.Builder
+	for _, family := range families {
+		_, err := expfmt.MetricFamilyToText(&exposition, family)
+		require.NoError(t, err)
+	}
+
+	text := exposition.String()
+	assert.Contains(t, text, "# HELP stackdriver_gce_instance_custom_googleapis_com_metric_00 Number of requests served.\n")
+	assert.Contains(t, text, "# HELP stackdriver_gce_instance_custom_googleapis_com_metric_01 Latency of the requests, from reception to response.\n")
+	assert.Contains(t, text, "# HELP stackdriver_gce_instance_custom_googleapis_com_metric_02 Google Stackdriver Monitoring metric custom.googleapis.com/metric_02\n",
+		"a descriptor without description should fall back to its metric type")
+}
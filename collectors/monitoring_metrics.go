@@ -14,7 +14,10 @@
 package collectors
 
 import (
+	"math"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/api/monitoring/v3"
@@ -25,56 +28,111 @@ import (
 	"github.com/prometheus-community/stackdriver_exporter/utils"
 )
 
-func buildFQName(timeSeries *monitoring.TimeSeries) string {
+func buildFQName(metricPrefix string, timeSeries *monitoring.TimeSeries, unitSuffix string) string {
 	// The metric name to report is composed by the 3 parts:
-	// 1. namespace is a constant prefix (stackdriver)
+	// 1. namespace is the configured metric prefix (stackdriver by default)
 	// 2. subsystem is the monitored resource type (ie gce_instance)
 	// 3. name is the metric type (ie compute.googleapis.com/instance/cpu/usage_time)
-	return prometheus.BuildFQName(namespace, utils.NormalizeMetricName(timeSeries.Resource.Type), utils.NormalizeMetricName(timeSeries.Metric.Type))
+	fqName := prometheus.BuildFQName(metricPrefix, utils.NormalizeMetricName(timeSeries.Resource.Type), utils.NormalizeMetricName(timeSeries.Metric.Type))
+	// An optional unit suffix (ie bytes), unless the metric type already ends with it
+	if unitSuffix != "" && !strings.HasSuffix(fqName, "_"+unitSuffix) {
+		fqName += "_" + unitSuffix
+	}
+	return fqName
 }
 
-type timeSeriesMetrics struct {
-	metricDescriptor *monitoring.MetricDescriptor
-
-	ch chan<- prometheus.Metric
+// timeSeriesMetricsOptions are the options of the metrics reported for the time series of a metric descriptor.
+type timeSeriesMetricsOptions struct {
+	metricPrefix string
 
 	fillMissingLabels bool
-	constMetrics      map[string][]*ConstMetric
-	histogramMetrics  map[string][]*HistogramMetric
 
 	counterStore    DeltaCounterStore
 	histogramStore  DeltaHistogramStore
 	aggregateDeltas bool
+	ephemeralDeltas bool
+
+	emitDistributionRange bool
+	splitLargeCounts      bool
+
+	histogramToSummaryThreshold int
+	nativeHistograms            bool
+	exemplars                   bool
+	omitPointTimestamps         bool
+	createdTimestamps           bool
+
+	unitSuffix string
+
+	// malformedSeriesTotal counts the series skipped for having more label keys than values or vice versa
+	malformedSeriesTotal prometheus.Counter
 }
 
-func newTimeSeriesMetrics(descriptor *monitoring.MetricDescriptor,
-	ch chan<- prometheus.Metric,
-	fillMissingLabels bool,
-	counterStore DeltaCounterStore,
-	histogramStore DeltaHistogramStore,
-	aggregateDeltas bool) (*timeSeriesMetrics, error) {
+type timeSeriesMetrics struct {
+	timeSeriesMetricsOptions
+
+	metricDescriptor *monitoring.MetricDescriptor
+	help             string
+
+	ch chan<- prometheus.Metric
+
+	constMetrics     map[string][]*ConstMetric
+	histogramMetrics map[string][]*HistogramMetric
+}
 
+func newTimeSeriesMetrics(descriptor *monitoring.MetricDescriptor, ch chan<- prometheus.Metric, opts timeSeriesMetricsOptions) *timeSeriesMetrics {
 	return &timeSeriesMetrics{
-		metricDescriptor:  descriptor,
-		ch:                ch,
-		fillMissingLabels: fillMissingLabels,
-		constMetrics:      make(map[string][]*ConstMetric),
-		histogramMetrics:  make(map[string][]*HistogramMetric),
-		counterStore:      counterStore,
-		histogramStore:    histogramStore,
-		aggregateDeltas:   aggregateDeltas,
-	}, nil
+		timeSeriesMetricsOptions: opts,
+		metricDescriptor:         descriptor,
+		help:                     metricHelp(descriptor),
+		ch:                       ch,
+		constMetrics:             make(map[string][]*ConstMetric),
+		histogramMetrics:         make(map[string][]*HistogramMetric),
+	}
 }
 
 func (t *timeSeriesMetrics) newMetricDesc(fqName string, labelKeys []string) *prometheus.Desc {
 	return prometheus.NewDesc(
 		fqName,
-		t.metricDescriptor.Description,
+		t.help,
 		labelKeys,
 		prometheus.Labels{},
 	)
 }
 
+// withTimestamp returns the metric with the end time of its point as timestamp, or without a timestamp when the
+// point timestamps are omitted, Prometheus then using the scrape time.
+func (t *timeSeriesMetrics) withTimestamp(reportTime time.Time, metric prometheus.Metric) prometheus.Metric {
+	if t.omitPointTimestamps {
+		return metric
+	}
+	return prometheus.NewMetricWithTimestamp(reportTime, metric)
+}
+
+// labelsMatch reports whether a series has as many label values as label keys, counting it as malformed otherwise.
+// The malformed series are skipped, their metric could not be built.
+func (t *timeSeriesMetrics) labelsMatch(labelKeys []string, labelValues []string) bool {
+	if len(labelKeys) == len(labelValues) {
+		return true
+	}
+	if t.malformedSeriesTotal != nil {
+		t.malformedSeriesTotal.Inc()
+	}
+	return false
+}
+
+// metricHelp returns the HELP text of the metrics of a descriptor, its description on a single line or the metric
+// type when it has none. Line breaks and other control characters are folded into spaces, keeping the HELP line of
+// the exposition formats readable.
+func metricHelp(descriptor *monitoring.MetricDescriptor) string {
+	help := strings.Join(strings.FieldsFunc(descriptor.Description, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+	if help == "" {
+		return strings.TrimSpace("Google Stackdriver Monitoring metric " + descriptor.Type)
+	}
+	return help
+}
+
 type ConstMetric struct {
 	FqName         string
 	LabelKeys      []string
@@ -83,16 +141,26 @@ type ConstMetric struct {
 	LabelValues    []string
 	ReportTime     time.Time
 	CollectionTime time.Time
+	// StartsAtZero, if true, tells the delta store to track a new series from 0 rather than from its first value, a
+	// reset for rate() when a short-lived series appears.
+	StartsAtZero bool
+	// CreatedTime is the start time of the interval of the first point the delta store accumulated into the counter,
+	// its end time for the counters starting at zero, and zero for the metrics not accumulated.
+	CreatedTime time.Time
 
 	KeysHash uint64
 }
 
 type HistogramMetric struct {
-	FqName         string
-	LabelKeys      []string
-	Sum            float64
-	Count          uint64
-	Buckets        map[float64]uint64
+	FqName    string
+	LabelKeys []string
+	Sum       float64
+	Count     uint64
+	Buckets   map[float64]uint64
+	// Native is the native histogram representation of the buckets, nil when reported as a classic histogram
+	Native *NativeHistogram
+	// Exemplars are the exemplars of the buckets of a classic histogram
+	Exemplars      []prometheus.Exemplar
 	LabelValues    []string
 	ReportTime     time.Time
 	CollectionTime time.Time
@@ -111,9 +179,32 @@ func (h *HistogramMetric) MergeHistogram(other *HistogramMetric) {
 	}
 }
 
-func (t *timeSeriesMetrics) CollectNewConstHistogram(timeSeries *monitoring.TimeSeries, reportTime time.Time, labelKeys []string, dist *monitoring.Distribution, buckets map[float64]uint64, labelValues []string, metricKind string) {
-	fqName := buildFQName(timeSeries)
+// CollectNewConstHistogram reports a distribution as a histogram, a native one when enabled and the buckets are
+// representable. startTime is the start of the interval of the distribution.
+func (t *timeSeriesMetrics) CollectNewConstHistogram(timeSeries *monitoring.TimeSeries, reportTime, startTime time.Time, labelKeys []string, dist *monitoring.Distribution, buckets map[float64]uint64, labelValues []string, metricKind string) {
+	if !t.labelsMatch(labelKeys, labelValues) {
+		return
+	}
+	fqName := buildFQName(t.metricPrefix, timeSeries, t.unitSuffix)
+	if t.emitDistributionRange && dist.Range != nil {
+		t.collectDistributionRange(fqName, reportTime, labelKeys, dist.Range, labelValues)
+	}
+	if t.splitLargeCounts && exceedsSafeInteger(uint64(dist.Count)) {
+		t.collectSplitCount(fqName, reportTime, labelKeys, uint64(dist.Count), labelValues)
+	}
+
 	histogramSum := dist.Mean * float64(dist.Count)
+	var native *NativeHistogram
+	// The delta stores merge the classic buckets, aggregated deltas are always reported as classic histograms
+	if t.nativeHistograms && !(metricKind == "DELTA" && t.aggregateDeltas) {
+		native, _ = newNativeHistogram(dist, startTime)
+	}
+	var exemplars []prometheus.Exemplar
+	// The delta stores merge the buckets of successive points, their exemplars are left out
+	if t.exemplars && native == nil && !(metricKind == "DELTA" && t.aggregateDeltas) {
+		exemplars = distributionExemplars(dist, buckets)
+	}
+
 	var v HistogramMetric
 	if t.fillMissingLabels || (metricKind == "DELTA" && t.aggregateDeltas) {
 		v = HistogramMetric{
@@ -122,6 +213,8 @@ func (t *timeSeriesMetrics) CollectNewConstHistogram(timeSeries *monitoring.Time
 			Sum:            histogramSum,
 			Count:          uint64(dist.Count),
 			Buckets:        buckets,
+			Native:         native,
+			Exemplars:      exemplars,
 			LabelValues:    labelValues,
 			ReportTime:     reportTime,
 			CollectionTime: time.Now(),
@@ -144,24 +237,180 @@ func (t *timeSeriesMetrics) CollectNewConstHistogram(timeSeries *monitoring.Time
 		return
 	}
 
-	t.ch <- t.newConstHistogram(fqName, reportTime, labelKeys, histogramSum, uint64(dist.Count), buckets, labelValues)
+	if native != nil {
+		t.ch <- t.newConstNativeHistogram(fqName, reportTime, labelKeys, histogramSum, uint64(dist.Count), native, labelValues)
+		return
+	}
+
+	t.ch <- t.newConstHistogram(fqName, reportTime, labelKeys, histogramSum, uint64(dist.Count), buckets, exemplars, labelValues)
+}
+
+// collectDistributionRange reports the range of a distribution as <fqName>_min and <fqName>_max gauges
+// sharing the labels of the histogram.
+func (t *timeSeriesMetrics) collectDistributionRange(fqName string, reportTime time.Time, labelKeys []string, distRange *monitoring.Range, labelValues []string) {
+	t.collectHistogramGauges(fqName, reportTime, labelKeys, labelValues, []histogramGauge{
+		{"_min", distRange.Min},
+		{"_max", distRange.Max},
+	})
+}
+
+// collectSplitCount reports a histogram count too large to be exactly represented as a float as
+// <fqName>_count_high and <fqName>_count_low gauges, the count being high * 2^32 + low.
+func (t *timeSeriesMetrics) collectSplitCount(fqName string, reportTime time.Time, labelKeys []string, count uint64, labelValues []string) {
+	t.collectHistogramGauges(fqName, reportTime, labelKeys, labelValues, []histogramGauge{
+		{"_count_high", float64(count >> 32)},
+		{"_count_low", float64(count & math.MaxUint32)},
+	})
+}
+
+// histogramGauge is a gauge derived from a histogram, named after the histogram with a suffix.
+type histogramGauge struct {
+	suffix string
+	value  float64
+}
+
+// collectHistogramGauges reports gauges derived from a histogram, sharing the labels of the histogram.
+func (t *timeSeriesMetrics) collectHistogramGauges(fqName string, reportTime time.Time, labelKeys []string, labelValues []string, gauges []histogramGauge) {
+	for _, g := range gauges {
+		// Copy labels as filling missing labels appends to them and they are shared with the histogram
+		keys := append([]string{}, labelKeys...)
+		values := append([]string{}, labelValues...)
+
+		if t.fillMissingLabels {
+			t.constMetrics[fqName+g.suffix] = append(t.constMetrics[fqName+g.suffix], &ConstMetric{
+				FqName:         fqName + g.suffix,
+				LabelKeys:      keys,
+				ValueType:      prometheus.GaugeValue,
+				Value:          g.value,
+				LabelValues:    values,
+				ReportTime:     reportTime,
+				CollectionTime: time.Now(),
+
+				KeysHash: hashLabelKeys(keys),
+			})
+			continue
+		}
+
+		t.ch <- t.newConstMetric(fqName+g.suffix, reportTime, keys, prometheus.GaugeValue, g.value, values)
+	}
+}
+
+// maxSafeInteger is the largest integer exactly representable as a float64, 2^53.
+const maxSafeInteger = 1 << 53
+
+// exceedsSafeInteger reports whether a count loses precision once converted to a float64.
+func exceedsSafeInteger(count uint64) bool {
+	return count > maxSafeInteger
 }
 
-func (t *timeSeriesMetrics) newConstHistogram(fqName string, reportTime time.Time, labelKeys []string, sum float64, count uint64, buckets map[float64]uint64, labelValues []string) prometheus.Metric {
-	return prometheus.NewMetricWithTimestamp(
+// summaryQuantiles are the quantiles estimated when a distribution is reported as a summary.
+var summaryQuantiles = []float64{0.5, 0.9, 0.99}
+
+// newConstHistogram reports a classic histogram, the exemplars being attached to their buckets. Exemplars failing
+// validation, e.g. of labels too long, are left out.
+func (t *timeSeriesMetrics) newConstHistogram(fqName string, reportTime time.Time, labelKeys []string, sum float64, count uint64, buckets map[float64]uint64, exemplars []prometheus.Exemplar, labelValues []string) prometheus.Metric {
+	if t.histogramToSummaryThreshold > 0 && len(buckets) > t.histogramToSummaryThreshold {
+		return t.newConstSummary(fqName, reportTime, labelKeys, sum, count, buckets, labelValues)
+	}
+
+	histogram := prometheus.MustNewConstHistogram(
+		t.newMetricDesc(fqName, labelKeys),
+		count,
+		sum,
+		buckets,
+		labelValues...,
+	)
+	if len(exemplars) > 0 {
+		if withExemplars, err := prometheus.NewMetricWithExemplars(histogram, exemplars...); err == nil {
+			histogram = withExemplars
+		}
+	}
+	return t.withTimestamp(reportTime, histogram)
+}
+
+// newConstNativeHistogram reports a distribution as a native histogram. Native histograms are only exposed in the
+// protobuf exposition format, the text format having their count and sum only.
+func (t *timeSeriesMetrics) newConstNativeHistogram(fqName string, reportTime time.Time, labelKeys []string, sum float64, count uint64, native *NativeHistogram, labelValues []string) prometheus.Metric {
+	return t.withTimestamp(
+		reportTime,
+		prometheus.MustNewConstNativeHistogram(
+			t.newMetricDesc(fqName, labelKeys),
+			count,
+			sum,
+			native.PositiveBuckets,
+			nil,
+			native.ZeroCount,
+			native.Schema,
+			native.ZeroThreshold,
+			native.StartTime,
+			labelValues...,
+		),
+	)
+}
+
+// newConstSummary reports a distribution as a summary, its quantiles being estimated from the buckets.
+func (t *timeSeriesMetrics) newConstSummary(fqName string, reportTime time.Time, labelKeys []string, sum float64, count uint64, buckets map[float64]uint64, labelValues []string) prometheus.Metric {
+	quantiles := make(map[float64]float64, len(summaryQuantiles))
+	for _, q := range summaryQuantiles {
+		quantiles[q] = bucketQuantile(q, count, buckets)
+	}
+
+	return t.withTimestamp(
 		reportTime,
-		prometheus.MustNewConstHistogram(
+		prometheus.MustNewConstSummary(
 			t.newMetricDesc(fqName, labelKeys),
 			count,
 			sum,
-			buckets,
+			quantiles,
 			labelValues...,
 		),
 	)
 }
 
-func (t *timeSeriesMetrics) CollectNewConstMetric(timeSeries *monitoring.TimeSeries, reportTime time.Time, labelKeys []string, metricValueType prometheus.ValueType, metricValue float64, labelValues []string, metricKind string) {
-	fqName := buildFQName(timeSeries)
+// bucketQuantile estimates a quantile from cumulative buckets keyed by their upper bound, interpolating linearly
+// within the bucket holding the quantile like the histogram_quantile PromQL function.
+func bucketQuantile(q float64, count uint64, buckets map[float64]uint64) float64 {
+	if count == 0 || len(buckets) == 0 {
+		return math.NaN()
+	}
+
+	bounds := make([]float64, 0, len(buckets))
+	for bound := range buckets {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	rank := q * float64(count)
+	lowerBound, lowerCount := 0.0, 0.0
+	for i, bound := range bounds {
+		cumulative := float64(buckets[bound])
+		if cumulative < rank {
+			lowerBound, lowerCount = bound, cumulative
+			continue
+		}
+		if math.IsInf(bound, 1) {
+			// The upper bound of the last bucket is unknown, return the highest finite one
+			if i == 0 {
+				return math.NaN()
+			}
+			return bounds[i-1]
+		}
+		if i == 0 && bound <= 0 {
+			return bound
+		}
+		if cumulative == lowerCount {
+			return bound
+		}
+		return lowerBound + (bound-lowerBound)*(rank-lowerCount)/(cumulative-lowerCount)
+	}
+	return bounds[len(bounds)-1]
+}
+
+func (t *timeSeriesMetrics) CollectNewConstMetric(timeSeries *monitoring.TimeSeries, reportTime, startTime time.Time, labelKeys []string, metricValueType prometheus.ValueType, metricValue float64, labelValues []string, metricKind string) {
+	if !t.labelsMatch(labelKeys, labelValues) {
+		return
+	}
+	fqName := buildFQName(t.metricPrefix, timeSeries, t.unitSuffix)
 
 	var v ConstMetric
 	if t.fillMissingLabels || (metricKind == "DELTA" && t.aggregateDeltas) {
@@ -173,12 +422,15 @@ func (t *timeSeriesMetrics) CollectNewConstMetric(timeSeries *monitoring.TimeSer
 			LabelValues:    labelValues,
 			ReportTime:     reportTime,
 			CollectionTime: time.Now(),
+			StartsAtZero:   metricKind == "DELTA" && t.ephemeralDeltas,
 
 			KeysHash: hashLabelKeys(labelKeys),
 		}
 	}
 
 	if metricKind == "DELTA" && t.aggregateDeltas {
+		// The counter store keeps the created time of the first point accumulated
+		v.CreatedTime = startTime
 		t.counterStore.Increment(t.metricDescriptor, &v)
 		return
 	}
@@ -196,7 +448,7 @@ func (t *timeSeriesMetrics) CollectNewConstMetric(timeSeries *monitoring.TimeSer
 }
 
 func (t *timeSeriesMetrics) newConstMetric(fqName string, reportTime time.Time, labelKeys []string, metricValueType prometheus.ValueType, metricValue float64, labelValues []string) prometheus.Metric {
-	return prometheus.NewMetricWithTimestamp(
+	return t.withTimestamp(
 		reportTime,
 		prometheus.MustNewConstMetric(
 			t.newMetricDesc(fqName, labelKeys),
@@ -207,6 +459,24 @@ func (t *timeSeriesMetrics) newConstMetric(fqName string, reportTime time.Time,
 	)
 }
 
+// newCollectedConstMetric returns the metric of a collected ConstMetric, along with its created timestamp when enabled
+// and known.
+func (t *timeSeriesMetrics) newCollectedConstMetric(v *ConstMetric) prometheus.Metric {
+	if !t.createdTimestamps || v.CreatedTime.IsZero() || v.ValueType != prometheus.CounterValue {
+		return t.newConstMetric(v.FqName, v.ReportTime, v.LabelKeys, v.ValueType, v.Value, v.LabelValues)
+	}
+	return t.withTimestamp(
+		v.ReportTime,
+		prometheus.MustNewConstMetricWithCreatedTimestamp(
+			t.newMetricDesc(v.FqName, v.LabelKeys),
+			v.ValueType,
+			v.Value,
+			v.CreatedTime,
+			v.LabelValues...,
+		),
+	)
+}
+
 func hashLabelKeys(labelKeys []string) uint64 {
 	dh := hash.New()
 	sortedKeys := make([]string, len(labelKeys))
@@ -228,6 +498,8 @@ func (t *timeSeriesMetrics) Complete(reportingStartTime time.Time) {
 
 func (t *timeSeriesMetrics) completeConstMetrics(constMetrics map[string][]*ConstMetric) {
 	for _, vs := range constMetrics {
+		// The malformed metrics are dropped before their keys are merged into the others
+		vs = t.wellFormedConstMetrics(vs)
 		if len(vs) > 1 {
 			var needFill bool
 			for i := 1; i < len(vs); i++ {
@@ -241,13 +513,14 @@ func (t *timeSeriesMetrics) completeConstMetrics(constMetrics map[string][]*Cons
 		}
 
 		for _, v := range vs {
-			t.ch <- t.newConstMetric(v.FqName, v.ReportTime, v.LabelKeys, v.ValueType, v.Value, v.LabelValues)
+			t.ch <- t.newCollectedConstMetric(v)
 		}
 	}
 }
 
 func (t *timeSeriesMetrics) completeHistogramMetrics(histograms map[string][]*HistogramMetric) {
 	for _, vs := range histograms {
+		vs = t.wellFormedHistogramMetrics(vs)
 		if len(vs) > 1 {
 			var needFill bool
 			for i := 1; i < len(vs); i++ {
@@ -260,7 +533,11 @@ func (t *timeSeriesMetrics) completeHistogramMetrics(histograms map[string][]*Hi
 			}
 		}
 		for _, v := range vs {
-			t.ch <- t.newConstHistogram(v.FqName, v.ReportTime, v.LabelKeys, v.Sum, v.Count, v.Buckets, v.LabelValues)
+			if v.Native != nil {
+				t.ch <- t.newConstNativeHistogram(v.FqName, v.ReportTime, v.LabelKeys, v.Sum, v.Count, v.Native, v.LabelValues)
+				continue
+			}
+			t.ch <- t.newConstHistogram(v.FqName, v.ReportTime, v.LabelKeys, v.Sum, v.Count, v.Buckets, v.Exemplars, v.LabelValues)
 		}
 	}
 }
@@ -279,20 +556,16 @@ func (t *timeSeriesMetrics) completeDeltaConstMetrics(reportingStartTime time.Ti
 			reportingLag := collected.CollectionTime.Sub(collected.ReportTime).Truncate(time.Minute)
 			collected.ReportTime = now.Add(-reportingLag)
 		}
+		if !t.labelsMatch(collected.LabelKeys, collected.LabelValues) {
+			continue
+		}
 		if t.fillMissingLabels {
 			if _, exists := constMetrics[collected.FqName]; !exists {
 				constMetrics[collected.FqName] = []*ConstMetric{}
 			}
 			constMetrics[collected.FqName] = append(constMetrics[collected.FqName], collected)
 		} else {
-			t.ch <- t.newConstMetric(
-				collected.FqName,
-				collected.ReportTime,
-				collected.LabelKeys,
-				collected.ValueType,
-				collected.Value,
-				collected.LabelValues,
-			)
+			t.ch <- t.newCollectedConstMetric(collected)
 		}
 	}
 
@@ -315,6 +588,9 @@ func (t *timeSeriesMetrics) completeDeltaHistogramMetrics(reportingStartTime tim
 			reportingLag := collected.CollectionTime.Sub(collected.ReportTime).Truncate(time.Minute)
 			collected.ReportTime = now.Add(-reportingLag)
 		}
+		if !t.labelsMatch(collected.LabelKeys, collected.LabelValues) {
+			continue
+		}
 		if t.fillMissingLabels {
 			if _, exists := histograms[collected.FqName]; !exists {
 				histograms[collected.FqName] = []*HistogramMetric{}
@@ -328,6 +604,7 @@ func (t *timeSeriesMetrics) completeDeltaHistogramMetrics(reportingStartTime tim
 				collected.Sum,
 				collected.Count,
 				collected.Buckets,
+				nil,
 				collected.LabelValues,
 			)
 		}
@@ -338,6 +615,28 @@ func (t *timeSeriesMetrics) completeDeltaHistogramMetrics(reportingStartTime tim
 	}
 }
 
+// wellFormedConstMetrics returns the metrics having as many label values as label keys.
+func (t *timeSeriesMetrics) wellFormedConstMetrics(metrics []*ConstMetric) []*ConstMetric {
+	wellFormed := make([]*ConstMetric, 0, len(metrics))
+	for _, metric := range metrics {
+		if t.labelsMatch(metric.LabelKeys, metric.LabelValues) {
+			wellFormed = append(wellFormed, metric)
+		}
+	}
+	return wellFormed
+}
+
+// wellFormedHistogramMetrics returns the histograms having as many label values as label keys.
+func (t *timeSeriesMetrics) wellFormedHistogramMetrics(metrics []*HistogramMetric) []*HistogramMetric {
+	wellFormed := make([]*HistogramMetric, 0, len(metrics))
+	for _, metric := range metrics {
+		if t.labelsMatch(metric.LabelKeys, metric.LabelValues) {
+			wellFormed = append(wellFormed, metric)
+		}
+	}
+	return wellFormed
+}
+
 func fillConstMetricsLabels(metrics []*ConstMetric) []*ConstMetric {
 	allKeys := make(map[string]struct{})
 	for _, metric := range metrics {
@@ -0,0 +1,280 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+var fqNameRE = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// fqNameOf extracts the fully-qualified name of a metric descriptor.
+func fqNameOf(desc *prometheus.Desc) string {
+	if m := fqNameRE.FindStringSubmatch(desc.String()); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// readMetrics drains the channel and returns the written metrics keyed by their fully-qualified name.
+func readMetrics(t *testing.T, ch chan prometheus.Metric) map[string][]*dto.Metric {
+	t.Helper()
+	close(ch)
+
+	metrics := map[string][]*dto.Metric{}
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		metrics[fqNameOf(m.Desc())] = append(metrics[fqNameOf(m.Desc())], &out)
+	}
+	return metrics
+}
+
+// labelsOf returns the labels of a metric as a map.
+func labelsOf(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// testCounterStore and testHistogramStore are in-memory DELTA stores keyed by descriptor name.
+type testCounterStore struct {
+	metrics map[string][]*ConstMetric
+}
+
+func (s *testCounterStore) Increment(metricDescriptor *monitoring.MetricDescriptor, currentValue *ConstMetric) {
+	if s.metrics == nil {
+		s.metrics = map[string][]*ConstMetric{}
+	}
+	s.metrics[metricDescriptor.Name] = append(s.metrics[metricDescriptor.Name], currentValue)
+}
+
+func (s *testCounterStore) ListMetrics(metricDescriptorName string) []*ConstMetric {
+	return s.metrics[metricDescriptorName]
+}
+
+type testHistogramStore struct {
+	metrics map[string][]*HistogramMetric
+}
+
+func (s *testHistogramStore) Increment(metricDescriptor *monitoring.MetricDescriptor, currentValue *HistogramMetric) {
+	if s.metrics == nil {
+		s.metrics = map[string][]*HistogramMetric{}
+	}
+	s.metrics[metricDescriptor.Name] = append(s.metrics[metricDescriptor.Name], currentValue)
+}
+
+func (s *testHistogramStore) ListMetrics(metricDescriptorName string) []*HistogramMetric {
+	return s.metrics[metricDescriptorName]
+}
+
+func newDistributionTimeSeries() *monitoring.TimeSeries {
+	return &monitoring.TimeSeries{
+		Metric:     &monitoring.Metric{Type: "loadbalancing.googleapis.com/https/backend_latencies"},
+		Resource:   &monitoring.MonitoredResource{Type: "https_lb_rule"},
+		MetricKind: "GAUGE",
+		ValueType:  "DISTRIBUTION",
+	}
+}
+
+func TestTimeSeriesMetrics_DistributionRange(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Description: "Backend latencies"}
+	dist := &monitoring.Distribution{
+		Count: 3,
+		Mean:  2,
+		Range: &monitoring.Range{Min: 0.5, Max: 4.5},
+	}
+	buckets := map[float64]uint64{1: 1, 5: 3}
+	reportTime := time.Now()
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		ch := make(chan prometheus.Metric, 10)
+		tsm := newTimeSeriesMetrics(descriptor, ch, timeSeriesMetricsOptions{metricPrefix: namespace, fillMissingLabels: fillMissingLabels, counterStore: &testCounterStore{}, histogramStore: &testHistogramStore{}, emitDistributionRange: true})
+
+		tsm.CollectNewConstHistogram(newDistributionTimeSeries(), reportTime, reportTime, []string{"unit", "zone"}, dist, buckets, []string{"ms", "us-east1-b"}, "GAUGE")
+		tsm.Complete(reportTime)
+
+		metrics := readMetrics(t, ch)
+		fqName := "stackdriver_https_lb_rule_loadbalancing_googleapis_com_https_backend_latencies"
+		require.Len(t, metrics[fqName], 1)
+		require.Len(t, metrics[fqName+"_min"], 1)
+		require.Len(t, metrics[fqName+"_max"], 1)
+
+		assert.Equal(t, 0.5, metrics[fqName+"_min"][0].GetGauge().GetValue())
+		assert.Equal(t, 4.5, metrics[fqName+"_max"][0].GetGauge().GetValue())
+		assert.Equal(t, labelsOf(metrics[fqName][0]), labelsOf(metrics[fqName+"_min"][0]), "min should share the histogram labels")
+		assert.Equal(t, labelsOf(metrics[fqName][0]), labelsOf(metrics[fqName+"_max"][0]), "max should share the histogram labels")
+	}
+}
+
+func TestTimeSeriesMetrics_DistributionRangeAbsent(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor"}
+	dist := &monitoring.Distribution{Count: 3, Mean: 2}
+
+	ch := make(chan prometheus.Metric, 10)
+	tsm := newTimeSeriesMetrics(descriptor, ch, timeSeriesMetricsOptions{metricPrefix: namespace, counterStore: &testCounterStore{}, histogramStore: &testHistogramStore{}, emitDistributionRange: true})
+
+	tsm.CollectNewConstHistogram(newDistributionTimeSeries(), time.Now(), time.Now(), []string{"unit"}, dist, map[float64]uint64{1: 3}, []string{"ms"}, "GAUGE")
+
+	metrics := readMetrics(t, ch)
+	assert.Len(t, metrics, 1, "only the histogram should be reported without a range")
+}
+
+func TestTimeSeriesMetrics_HistogramToSummaryThreshold(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor"}
+	dist := &monitoring.Distribution{Count: 8, Mean: 2}
+	buckets := map[float64]uint64{1: 2, 2: 6, 4: 8, math.Inf(1): 8}
+	fqName := "stackdriver_https_lb_rule_loadbalancing_googleapis_com_https_backend_latencies"
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		collect := func(threshold int) *dto.Metric {
+			ch := make(chan prometheus.Metric, 10)
+			tsm := newTimeSeriesMetrics(descriptor, ch, timeSeriesMetricsOptions{metricPrefix: namespace, fillMissingLabels: fillMissingLabels, counterStore: &testCounterStore{}, histogramStore: &testHistogramStore{}, histogramToSummaryThreshold: threshold})
+
+			tsm.CollectNewConstHistogram(newDistributionTimeSeries(), time.Now(), time.Now(), []string{"unit"}, dist, buckets, []string{"ms"}, "GAUGE")
+			tsm.Complete(time.Now())
+
+			metrics := readMetrics(t, ch)
+			require.Len(t, metrics[fqName], 1)
+			return metrics[fqName][0]
+		}
+
+		for _, threshold := range []int{0, 4, 10} {
+			histogram := collect(threshold)
+			assert.NotNil(t, histogram.GetHistogram(), "%d buckets should be reported as a histogram with threshold %d", len(buckets), threshold)
+			assert.Nil(t, histogram.GetSummary())
+		}
+
+		summary := collect(3).GetSummary()
+		require.NotNil(t, summary, "%d buckets should be reported as a summary with threshold 3", len(buckets))
+		assert.Equal(t, uint64(8), summary.GetSampleCount())
+		assert.Equal(t, 16.0, summary.GetSampleSum())
+
+		quantiles := map[float64]float64{}
+		for _, q := range summary.GetQuantile() {
+			quantiles[q.GetQuantile()] = q.GetValue()
+		}
+		assert.InDeltaMapValues(t, map[float64]float64{0.5: 1.5, 0.9: 3.2, 0.99: 3.92}, quantiles, 1e-9)
+	}
+}
+
+func TestTimeSeriesMetrics_MalformedSeries(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor"}
+	timeSeries := &monitoring.TimeSeries{
+		Metric:     &monitoring.Metric{Type: "custom.googleapis.com/requests"},
+		Resource:   &monitoring.MonitoredResource{Type: "gce_instance"},
+		MetricKind: "GAUGE",
+		ValueType:  "DOUBLE",
+	}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	dist := &monitoring.Distribution{Count: 3, Mean: 2}
+	reportTime := time.Now()
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		ch := make(chan prometheus.Metric, 10)
+		malformedSeriesTotal := prometheus.NewCounter(prometheus.CounterOpts{Name: "malformed_series_total"})
+		// A DELTA entry of the store, merged with the collected ones, with a missing value
+		counterStore := &testCounterStore{metrics: map[string][]*ConstMetric{descriptor.Name: {{
+			FqName:      fqName,
+			LabelKeys:   []string{"unit", "zone"},
+			ValueType:   prometheus.CounterValue,
+			Value:       5,
+			LabelValues: []string{"1"},
+			ReportTime:  reportTime,
+		}}}}
+		tsm := newTimeSeriesMetrics(descriptor, ch, timeSeriesMetricsOptions{metricPrefix: namespace, fillMissingLabels: fillMissingLabels, counterStore: counterStore, histogramStore: &testHistogramStore{}, malformedSeriesTotal: malformedSeriesTotal})
+
+		tsm.CollectNewConstMetric(timeSeries, reportTime, reportTime, []string{"unit", "instance_id"}, prometheus.GaugeValue, 1, []string{"1", "a"}, "GAUGE")
+		tsm.CollectNewConstMetric(timeSeries, reportTime, reportTime, []string{"unit", "instance_id", "extra"}, prometheus.GaugeValue, 2, []string{"1", "b"}, "GAUGE")
+		tsm.CollectNewConstMetric(timeSeries, reportTime, reportTime, []string{"unit"}, prometheus.GaugeValue, 3, []string{"1", "c"}, "GAUGE")
+		tsm.CollectNewConstHistogram(newDistributionTimeSeries(), reportTime, reportTime, []string{"unit", "zone"}, dist, map[float64]uint64{1: 3}, []string{"ms"}, "GAUGE")
+		require.NotPanics(t, func() { tsm.Complete(reportTime) })
+
+		metrics := readMetrics(t, ch)
+		assert.Equal(t, 4.0, testutil.ToFloat64(malformedSeriesTotal), "every mismatched series should be counted")
+		require.Len(t, metrics, 1, "only the well formed series should be reported")
+		require.Len(t, metrics[fqName], 1)
+		assert.Equal(t, map[string]string{"unit": "1", "instance_id": "a"}, labelsOf(metrics[fqName][0]), "the keys of the malformed series should not be merged into the others")
+	}
+}
+
+func TestTimeSeriesMetrics_DeltaCreatedTimestamps(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "DELTA"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	createdTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	reportTime := time.Now().Truncate(time.Second)
+
+	for _, fillMissingLabels := range []bool{false, true} {
+		for _, createdTimestamps := range []bool{false, true} {
+			ch := make(chan prometheus.Metric, 10)
+			counterStore := &testCounterStore{metrics: map[string][]*ConstMetric{descriptor.Name: {{
+				FqName:         fqName,
+				LabelKeys:      []string{"unit"},
+				ValueType:      prometheus.CounterValue,
+				Value:          5,
+				LabelValues:    []string{"1"},
+				ReportTime:     reportTime,
+				CollectionTime: time.Now(),
+				CreatedTime:    createdTime,
+			}}}}
+			tsm := newTimeSeriesMetrics(descriptor, ch, timeSeriesMetricsOptions{metricPrefix: namespace, fillMissingLabels: fillMissingLabels, counterStore: counterStore, histogramStore: &testHistogramStore{}, aggregateDeltas: true, createdTimestamps: createdTimestamps})
+			tsm.Complete(reportTime.Add(-time.Minute))
+
+			metrics := readMetrics(t, ch)
+			require.Len(t, metrics[fqName], 1)
+			counter := metrics[fqName][0].GetCounter()
+			require.NotNil(t, counter)
+			assert.Equal(t, float64(5), counter.GetValue())
+			if createdTimestamps {
+				assert.Equal(t, createdTime.UnixMilli(), counter.GetCreatedTimestamp().AsTime().UnixMilli(), "the counter should carry the time of its first accumulation")
+			} else {
+				assert.Nil(t, counter.CreatedTimestamp, "the created timestamp should only be reported when enabled")
+			}
+		}
+	}
+
+	t.Run("interval start", func(t *testing.T) {
+		counterStore := &testCounterStore{}
+		tsm := newTimeSeriesMetrics(descriptor, make(chan prometheus.Metric, 10), timeSeriesMetricsOptions{metricPrefix: namespace, fillMissingLabels: true, counterStore: counterStore, histogramStore: &testHistogramStore{}, aggregateDeltas: true, createdTimestamps: true})
+		timeSeries := &monitoring.TimeSeries{Metric: &monitoring.Metric{Type: descriptor.Type}, Resource: &monitoring.MonitoredResource{Type: "gce_instance"}}
+		tsm.CollectNewConstMetric(timeSeries, reportTime, createdTime, []string{"unit"}, prometheus.CounterValue, 5, []string{"1"}, "DELTA")
+		tsm.CollectNewConstMetric(timeSeries, reportTime, createdTime, []string{"unit"}, prometheus.CounterValue, 5, []string{"2"}, "CUMULATIVE")
+
+		require.Len(t, counterStore.metrics[descriptor.Name], 1)
+		assert.Equal(t, createdTime, counterStore.metrics[descriptor.Name][0].CreatedTime, "the DELTA point should carry the start of its interval to the counter store")
+		require.Len(t, tsm.constMetrics[fqName], 1)
+		assert.True(t, tsm.constMetrics[fqName][0].CreatedTime.IsZero(), "the points not accumulated should have no created time")
+	})
+}
+
+func TestBucketQuantile(t *testing.T) {
+	buckets := map[float64]uint64{1: 2, 2: 6, 4: 8, math.Inf(1): 10}
+
+	assert.InDelta(t, 0.5, bucketQuantile(0.1, 10, buckets), 1e-9, "the first bucket should be interpolated from 0")
+	assert.InDelta(t, 1.75, bucketQuantile(0.5, 10, buckets), 1e-9)
+	assert.Equal(t, 4.0, bucketQuantile(0.95, 10, buckets), "the +Inf bucket should return the highest finite bound")
+	assert.True(t, math.IsNaN(bucketQuantile(0.5, 0, buckets)), "an empty distribution has no quantile")
+}
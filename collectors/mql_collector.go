@@ -0,0 +1,247 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/utils"
+)
+
+// metricNameRE matches the valid Prometheus metric names.
+var metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// MQLQuery is a Monitoring Query Language query, its result being reported as the metric Name.
+type MQLQuery struct {
+	Name  string
+	Query string
+}
+
+// ParseMQLQueries returns the MQL queries of a name to query map, sorted by name.
+func ParseMQLQueries(queries map[string]string) ([]MQLQuery, error) {
+	parsed := make([]MQLQuery, 0, len(queries))
+	for name, query := range queries {
+		if !metricNameRE.MatchString(name) {
+			return nil, fmt.Errorf("invalid metric name %q of the MQL query %q, it must match %s", name, query, metricNameRE)
+		}
+		if strings.TrimSpace(query) == "" {
+			return nil, fmt.Errorf("empty MQL query for the metric %q", name)
+		}
+		parsed = append(parsed, MQLQuery{Name: name, Query: query})
+	}
+	sort.Slice(parsed, func(i, j int) bool {
+		return parsed[i].Name < parsed[j].Name
+	})
+	return parsed, nil
+}
+
+// MQLCollector reports the tables returned by MQL queries, each row being a metric named after its query and
+// labelled with the label columns of the row.
+// @see https://cloud.google.com/monitoring/api/ref_v3/rest/v3/projects.timeSeries/query
+type MQLCollector struct {
+	projectID         string
+	monitoringService *monitoring.Service
+	queries           []MQLQuery
+	logger            *slog.Logger
+
+	scrapeErrorsTotalMetric   *prometheus.CounterVec
+	invalidMetricsTotalMetric *prometheus.CounterVec
+}
+
+// NewMQLCollector creates an MQLCollector running the queries against the project.
+func NewMQLCollector(projectID string, monitoringService *monitoring.Service, queries []MQLQuery, logger *slog.Logger) *MQLCollector {
+	scrapeErrorsTotalMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   namespace,
+		Subsystem:   "mql",
+		Name:        "scrape_errors_total",
+		Help:        "Total number of Google Stackdriver Monitoring MQL query errors.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	}, []string{"query"})
+	invalidMetricsTotalMetric := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace:   namespace,
+		Subsystem:   "mql",
+		Name:        "invalid_metrics_total",
+		Help:        "Total number of Google Stackdriver Monitoring MQL values not reported as their metric is invalid.",
+		ConstLabels: prometheus.Labels{"project_id": projectID},
+	}, []string{"query"})
+	for _, query := range queries {
+		scrapeErrorsTotalMetric.WithLabelValues(query.Name)
+		invalidMetricsTotalMetric.WithLabelValues(query.Name)
+	}
+
+	return &MQLCollector{
+		projectID:                 projectID,
+		monitoringService:         monitoringService,
+		queries:                   queries,
+		logger:                    logger.With("project_id", projectID, "component", "mql"),
+		scrapeErrorsTotalMetric:   scrapeErrorsTotalMetric,
+		invalidMetricsTotalMetric: invalidMetricsTotalMetric,
+	}
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *MQLCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.scrapeErrorsTotalMetric.Describe(ch)
+	c.invalidMetricsTotalMetric.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *MQLCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(context.Background(), ch)
+}
+
+// CollectContext collects the results of the queries like Collect, the Monitoring API calls being cancelled once
+// ctx is done.
+func (c *MQLCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	for _, query := range c.queries {
+		if err := c.reportQuery(ctx, query, ch); err != nil {
+			c.scrapeErrorsTotalMetric.WithLabelValues(query.Name).Inc()
+			c.logger.Error("Error while running Google Stackdriver Monitoring MQL query", "query", query.Name, "err", err)
+		}
+	}
+	c.scrapeErrorsTotalMetric.Collect(ch)
+	c.invalidMetricsTotalMetric.Collect(ch)
+}
+
+func (c *MQLCollector) reportQuery(ctx context.Context, query MQLQuery, ch chan<- prometheus.Metric) error {
+	request := newMQLQueryRequest(query)
+	return c.monitoringService.Projects.TimeSeries.Query(utils.ProjectResource(c.projectID), request).
+		Pages(ctx, func(page *monitoring.QueryTimeSeriesResponse) error {
+			for _, partialError := range page.PartialErrors {
+				c.logger.Warn("MQL query returned a partial error", "query", query.Name, "code", partialError.Code, "message", partialError.Message)
+			}
+			if page.TimeSeriesDescriptor == nil {
+				return nil
+			}
+			c.reportTable(query, page.TimeSeriesDescriptor, page.TimeSeriesData, ch)
+			return nil
+		})
+}
+
+// newMQLQueryRequest returns the request running an MQL query.
+func newMQLQueryRequest(query MQLQuery) *monitoring.QueryTimeSeriesRequest {
+	return &monitoring.QueryTimeSeriesRequest{Query: query.Query}
+}
+
+// reportTable reports the newest point of every row of an MQL table. A table of a single value column is reported
+// as the metric of the query, each value column being reported as <name>_<column> otherwise. The values of an
+// invalid metric, e.g. of label columns colliding once sanitized, are counted and left out.
+func (c *MQLCollector) reportTable(query MQLQuery, descriptor *monitoring.TimeSeriesDescriptor, rows []*monitoring.TimeSeriesData, ch chan<- prometheus.Metric) {
+	labelKeys := make([]string, len(descriptor.LabelDescriptors))
+	for i, label := range descriptor.LabelDescriptors {
+		labelKeys[i] = utils.SanitizeLabelName(label.Key)
+	}
+
+	descs := make([]*prometheus.Desc, len(descriptor.PointDescriptors))
+	for i, value := range descriptor.PointDescriptors {
+		name := query.Name
+		if len(descriptor.PointDescriptors) > 1 {
+			name += "_" + utils.SanitizeLabelName(strings.TrimPrefix(value.Key, "value."))
+		}
+		descs[i] = prometheus.NewDesc(name, fmt.Sprintf("MQL query %s", query.Name), labelKeys, prometheus.Labels{"project_id": c.projectID})
+	}
+
+	for _, row := range rows {
+		point, endTime := newestPointData(row.PointData)
+		if point == nil {
+			continue
+		}
+		labelValues := make([]string, len(labelKeys))
+		for i, value := range row.LabelValues {
+			if i < len(labelValues) {
+				labelValues[i] = mqlLabelValue(descriptor.LabelDescriptors[i], value)
+			}
+		}
+
+		for i, value := range point.Values {
+			if i >= len(descs) {
+				break
+			}
+			metricValue, ok := mqlPointValue(value)
+			if !ok {
+				c.logger.Debug("dropping MQL value of unsupported type", "query", query.Name, "column", descriptor.PointDescriptors[i].Key, "value_type", descriptor.PointDescriptors[i].ValueType)
+				continue
+			}
+			valueType := prometheus.GaugeValue
+			if descriptor.PointDescriptors[i].MetricKind == "CUMULATIVE" {
+				valueType = prometheus.CounterValue
+			}
+			metric, err := prometheus.NewConstMetric(descs[i], valueType, metricValue, labelValues...)
+			if err != nil {
+				c.invalidMetricsTotalMetric.WithLabelValues(query.Name).Inc()
+				c.logger.Error("error creating MQL metric", "query", query.Name, "column", descriptor.PointDescriptors[i].Key, "err", err)
+				continue
+			}
+			ch <- prometheus.NewMetricWithTimestamp(endTime, metric)
+		}
+	}
+}
+
+// newestPointData returns the point with the latest interval end time, along with that time.
+func newestPointData(points []*monitoring.PointData) (*monitoring.PointData, time.Time) {
+	var newest *monitoring.PointData
+	var newestEndTime time.Time
+	for _, point := range points {
+		if point.TimeInterval == nil {
+			continue
+		}
+		endTime, err := time.Parse(time.RFC3339Nano, point.TimeInterval.EndTime)
+		if err != nil {
+			continue
+		}
+		if newest == nil || endTime.After(newestEndTime) {
+			newest, newestEndTime = point, endTime
+		}
+	}
+	return newest, newestEndTime
+}
+
+// mqlLabelValue returns the string value of a label column.
+func mqlLabelValue(descriptor *monitoring.LabelDescriptor, value *monitoring.LabelValue) string {
+	switch descriptor.ValueType {
+	case "BOOL":
+		return strconv.FormatBool(value.BoolValue)
+	case "INT64":
+		return strconv.FormatInt(value.Int64Value, 10)
+	default:
+		return value.StringValue
+	}
+}
+
+// mqlPointValue returns the value of a value column, and false for the types not reported, e.g. distributions.
+func mqlPointValue(value *monitoring.TypedValue) (float64, bool) {
+	switch {
+	case value.DoubleValue != nil:
+		return *value.DoubleValue, true
+	case value.Int64Value != nil:
+		return float64(*value.Int64Value), true
+	case value.BoolValue != nil:
+		if *value.BoolValue {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+const (
+	cpuRatioQuery = "fetch gce_instance | metric compute.googleapis.com/instance/cpu/utilization | within 5m"
+	diskIOQuery   = "fetch gce_instance | { read_bytes_count ; write_bytes_count } | join | within 5m"
+	projectQuery  = "fetch gce_instance | metric compute.googleapis.com/instance/uptime | map [project_id: resource.project_id]"
+)
+
+func newMQLPoint(endTime time.Time, values ...*monitoring.TypedValue) *monitoring.PointData {
+	return &monitoring.PointData{
+		TimeInterval: &monitoring.TimeInterval{EndTime: endTime.Format(time.RFC3339Nano)},
+		Values:       values,
+	}
+}
+
+func doubleValue(v float64) *monitoring.TypedValue {
+	return &monitoring.TypedValue{DoubleValue: &v}
+}
+
+func int64Value(v int64) *monitoring.TypedValue {
+	return &monitoring.TypedValue{Int64Value: &v}
+}
+
+func TestParseMQLQueries(t *testing.T) {
+	queries, err := ParseMQLQueries(map[string]string{"b_ratio": "fetch b", "a_ratio": "fetch a"})
+	require.NoError(t, err)
+	assert.Equal(t, []MQLQuery{{Name: "a_ratio", Query: "fetch a"}, {Name: "b_ratio", Query: "fetch b"}}, queries)
+
+	_, err = ParseMQLQueries(map[string]string{"cpu-ratio": "fetch a"})
+	assert.Error(t, err, "invalid metric names should be rejected")
+
+	_, err = ParseMQLQueries(map[string]string{"cpu_ratio": " "})
+	assert.Error(t, err, "empty queries should be rejected")
+}
+
+func TestNewMQLQueryRequest(t *testing.T) {
+	request := newMQLQueryRequest(MQLQuery{Name: "cpu_ratio", Query: cpuRatioQuery})
+	assert.Equal(t, &monitoring.QueryTimeSeriesRequest{Query: cpuRatioQuery}, request)
+}
+
+func TestMQLCollector(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	api := &fakeMonitoringAPI{
+		mqlTables: map[string]*monitoring.QueryTimeSeriesResponse{
+			cpuRatioQuery: {
+				TimeSeriesDescriptor: &monitoring.TimeSeriesDescriptor{
+					LabelDescriptors: []*monitoring.LabelDescriptor{
+						{Key: "resource.zone"},
+						{Key: "metric.instance_name"},
+						{Key: "metric.preemptible", ValueType: "BOOL"},
+					},
+					PointDescriptors: []*monitoring.ValueDescriptor{{Key: "value.utilization", MetricKind: "GAUGE", ValueType: "DOUBLE"}},
+				},
+				TimeSeriesData: []*monitoring.TimeSeriesData{
+					{
+						LabelValues: []*monitoring.LabelValue{{StringValue: "us-east1-b"}, {StringValue: "web-1"}, {BoolValue: true}},
+						// The newest point is reported
+						PointData: []*monitoring.PointData{newMQLPoint(now, doubleValue(0.5)), newMQLPoint(now.Add(-time.Minute), doubleValue(0.9))},
+					},
+					{
+						LabelValues: []*monitoring.LabelValue{{StringValue: "us-east1-c"}, {StringValue: "web-2"}, {}},
+						PointData:   []*monitoring.PointData{newMQLPoint(now, doubleValue(0.25))},
+					},
+				},
+			},
+			diskIOQuery: {
+				TimeSeriesDescriptor: &monitoring.TimeSeriesDescriptor{
+					LabelDescriptors: []*monitoring.LabelDescriptor{{Key: "resource.instance_id", ValueType: "INT64"}},
+					PointDescriptors: []*monitoring.ValueDescriptor{
+						{Key: "value.read_bytes_count", MetricKind: "CUMULATIVE", ValueType: "INT64"},
+						{Key: "value.write_bytes_count", MetricKind: "CUMULATIVE", ValueType: "INT64"},
+					},
+				},
+				TimeSeriesData: []*monitoring.TimeSeriesData{{
+					LabelValues: []*monitoring.LabelValue{{Int64Value: 1234}},
+					PointData:   []*monitoring.PointData{newMQLPoint(now, int64Value(10), int64Value(20))},
+				}},
+			},
+			// The project_id column collides with the project_id label of the metrics
+			projectQuery: {
+				TimeSeriesDescriptor: &monitoring.TimeSeriesDescriptor{
+					LabelDescriptors: []*monitoring.LabelDescriptor{{Key: "project_id"}},
+					PointDescriptors: []*monitoring.ValueDescriptor{{Key: "value.uptime", MetricKind: "GAUGE", ValueType: "DOUBLE"}},
+				},
+				TimeSeriesData: []*monitoring.TimeSeriesData{{
+					LabelValues: []*monitoring.LabelValue{{StringValue: "other-project"}},
+					PointData:   []*monitoring.PointData{newMQLPoint(now, doubleValue(60))},
+				}},
+			},
+		},
+	}
+	queries, err := ParseMQLQueries(map[string]string{
+		"instance_cpu_ratio": cpuRatioQuery,
+		"instance_disk":      diskIOQuery,
+		"instance_uptime":    projectQuery,
+		"invalid":            "fetch nothing",
+	})
+	require.NoError(t, err)
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	collector := NewMQLCollector("test-project", newFakeMonitoringService(t, api), queries, logger)
+
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	cpu := map[string]float64{}
+	for _, m := range metrics["instance_cpu_ratio"] {
+		labels := labelsOf(m)
+		assert.Equal(t, "test-project", labels["project_id"])
+		assert.Equal(t, now.UnixMilli(), m.GetTimestampMs())
+		assert.NotNil(t, m.GetGauge())
+		cpu[labels["resource_zone"]+"/"+labels["metric_instance_name"]+"/"+labels["metric_preemptible"]] = m.GetGauge().GetValue()
+	}
+	assert.Equal(t, map[string]float64{
+		"us-east1-b/web-1/true":  0.5,
+		"us-east1-c/web-2/false": 0.25,
+	}, cpu)
+
+	require.Len(t, metrics["instance_disk_read_bytes_count"], 1)
+	require.Len(t, metrics["instance_disk_write_bytes_count"], 1)
+	read := metrics["instance_disk_read_bytes_count"][0]
+	assert.Equal(t, "1234", labelsOf(read)["resource_instance_id"])
+	assert.Equal(t, float64(10), read.GetCounter().GetValue(), "cumulative values should be counters")
+	assert.Equal(t, float64(20), metrics["instance_disk_write_bytes_count"][0].GetCounter().GetValue())
+
+	errors := map[string]float64{}
+	for _, m := range metrics["stackdriver_mql_scrape_errors_total"] {
+		errors[labelsOf(m)["query"]] = m.GetCounter().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"instance_cpu_ratio": 0, "instance_disk": 0, "instance_uptime": 0, "invalid": 1}, errors)
+
+	assert.Empty(t, metrics["instance_uptime"], "the invalid metrics should be left out")
+	invalid := map[string]float64{}
+	for _, m := range metrics["stackdriver_mql_invalid_metrics_total"] {
+		invalid[labelsOf(m)["query"]] = m.GetCounter().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"instance_cpu_ratio": 0, "instance_disk": 0, "instance_uptime": 1, "invalid": 0}, invalid)
+
+	require.Len(t, api.queryRequests, 4)
+	assert.Equal(t, cpuRatioQuery, api.queryRequests[0].Query)
+}
@@ -0,0 +1,136 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"math"
+	"time"
+
+	"google.golang.org/api/monitoring/v3"
+)
+
+const (
+	// nativeHistogramMinSchema and nativeHistogramMaxSchema are the schemas supported by the native histograms, the
+	// bucket boundaries of a schema being the powers of 2^(2^-schema).
+	nativeHistogramMinSchema = -4
+	nativeHistogramMaxSchema = 8
+
+	// nativeHistogramBoundTolerance is the relative error tolerated when matching a bucket bound to a native
+	// histogram bucket boundary, the bounds of exponential buckets being computed.
+	nativeHistogramBoundTolerance = 1e-9
+)
+
+// NativeHistogram is the sparse native histogram representation of the buckets of a distribution.
+type NativeHistogram struct {
+	Schema        int32
+	ZeroThreshold float64
+	ZeroCount     uint64
+	// PositiveBuckets are the counts of the buckets by native bucket index
+	PositiveBuckets map[int]int64
+	// StartTime is the start of the interval of the distribution, reported as the created timestamp
+	StartTime time.Time
+}
+
+// newNativeHistogram returns the native histogram representation of the buckets of a distribution, and false when
+// the buckets aren't representable. Representable buckets have positive finite bounds growing by a factor of
+// 2^(2^-schema), each bound being a native bucket boundary of that schema, and an empty overflow bucket. The
+// underflow bucket becomes the zero bucket, its threshold being the lowest bound.
+//
+// In practice exponential buckets with a power of two growth factor and scale are representable, while linear and
+// explicit buckets only are when their bounds happen to grow exponentially.
+func newNativeHistogram(dist *monitoring.Distribution, startTime time.Time) (*NativeHistogram, bool) {
+	opts := dist.BucketOptions
+	if opts == nil {
+		return nil, false
+	}
+
+	var bounds []float64
+	switch {
+	case opts.ExplicitBuckets != nil:
+		bounds = opts.ExplicitBuckets.Bounds
+	case opts.LinearBuckets != nil:
+		num := int(opts.LinearBuckets.NumFiniteBuckets)
+		bounds = make([]float64, num+1)
+		for i := range bounds {
+			bounds[i] = opts.LinearBuckets.Offset + (float64(i) * opts.LinearBuckets.Width)
+		}
+	case opts.ExponentialBuckets != nil:
+		num := int(opts.ExponentialBuckets.NumFiniteBuckets)
+		bounds = make([]float64, num+1)
+		for i := range bounds {
+			bounds[i] = opts.ExponentialBuckets.Scale * math.Pow(opts.ExponentialBuckets.GrowthFactor, float64(i))
+		}
+	}
+	if len(bounds) < 2 || bounds[0] <= 0 {
+		return nil, false
+	}
+
+	schema, ok := nativeHistogramSchema(bounds[1] / bounds[0])
+	if !ok {
+		return nil, false
+	}
+	// The lowest bound must be a bucket boundary, its index offsetting the indexes of the buckets
+	offset := int(math.Round(math.Log2(bounds[0]) * math.Exp2(float64(schema))))
+	for i, bound := range bounds {
+		if !nativeHistogramBoundMatches(bound, nativeHistogramBoundary(schema, offset+i)) {
+			return nil, false
+		}
+	}
+
+	h := &NativeHistogram{
+		Schema:          schema,
+		ZeroThreshold:   nativeHistogramBoundary(schema, offset),
+		PositiveBuckets: map[int]int64{},
+		StartTime:       startTime,
+	}
+	var count int64
+	for i, bucketCount := range dist.BucketCounts {
+		count += bucketCount
+		switch {
+		case bucketCount == 0:
+		case i == 0:
+			// The underflow bucket, [-inf, bounds[0])
+			h.ZeroCount = uint64(bucketCount)
+		case i == len(bounds):
+			// The overflow bucket, [bounds[len(bounds)-1], +inf), has no native counterpart
+			return nil, false
+		default:
+			// The bucket [bounds[i-1], bounds[i]) ends at the native bucket boundary offset+i
+			h.PositiveBuckets[offset+i] = bucketCount
+		}
+	}
+	if count != dist.Count {
+		return nil, false
+	}
+	return h, true
+}
+
+// nativeHistogramSchema returns the schema of the native histograms whose buckets grow by factor.
+func nativeHistogramSchema(factor float64) (int32, bool) {
+	for schema := int32(nativeHistogramMinSchema); schema <= nativeHistogramMaxSchema; schema++ {
+		if nativeHistogramBoundMatches(factor, math.Exp2(math.Exp2(-float64(schema)))) {
+			return schema, true
+		}
+	}
+	return 0, false
+}
+
+// nativeHistogramBoundary returns the upper boundary of the native histogram bucket of index i, 2^(i*2^-schema).
+func nativeHistogramBoundary(schema int32, i int) float64 {
+	return math.Exp2(float64(i) * math.Exp2(-float64(schema)))
+}
+
+func nativeHistogramBoundMatches(bound, boundary float64) bool {
+	return math.Abs(bound-boundary) <= nativeHistogramBoundTolerance*boundary
+}
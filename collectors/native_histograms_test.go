@@ -0,0 +1,137 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+func newBucketsDistribution(opts *monitoring.BucketOptions, bucketCounts ...int64) *monitoring.Distribution {
+	var count int64
+	for _, c := range bucketCounts {
+		count += c
+	}
+	return &monitoring.Distribution{Count: count, Mean: 1, BucketCounts: bucketCounts, BucketOptions: opts}
+}
+
+func TestNewNativeHistogram(t *testing.T) {
+	startTime := time.Unix(1700000000, 0)
+
+	for _, tc := range []struct {
+		name string
+		dist *monitoring.Distribution
+		want *NativeHistogram
+	}{
+		{
+			name: "exponential buckets of schema 0",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 3, GrowthFactor: 2, Scale: 1,
+			}}, 1, 2, 0, 4, 0),
+			// Bounds 1, 2, 4, 8: [1, 2) ends at 2^1, [4, 8) at 2^3
+			want: &NativeHistogram{Schema: 0, ZeroThreshold: 1, ZeroCount: 1, PositiveBuckets: map[int]int64{1: 2, 3: 4}},
+		},
+		{
+			name: "exponential buckets of schema 2 with an offset scale",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 2, GrowthFactor: math.Pow(2, 0.25), Scale: 0.5,
+			}}, 0, 3, 5),
+			// Bounds 2^-1, 2^-0.75, 2^-0.5 are the boundaries -4, -3 and -2 of schema 2
+			want: &NativeHistogram{Schema: 2, ZeroThreshold: 0.5, PositiveBuckets: map[int]int64{-3: 3, -2: 5}},
+		},
+		{
+			name: "exponential buckets of negative schema",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 1, GrowthFactor: 16, Scale: 16,
+			}}, 0, 7),
+			want: &NativeHistogram{Schema: -2, ZeroThreshold: 16, PositiveBuckets: map[int]int64{2: 7}},
+		},
+		{
+			name: "exponential buckets with a growth factor out of the schemas",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 3, GrowthFactor: 3, Scale: 1,
+			}}, 1, 2),
+		},
+		{
+			name: "exponential buckets with a scale off the boundaries",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 3, GrowthFactor: 2, Scale: 3,
+			}}, 1, 2),
+		},
+		{
+			name: "exponential buckets with an overflow count",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+				NumFiniteBuckets: 3, GrowthFactor: 2, Scale: 1,
+			}}, 1, 2, 0, 4, 1),
+		},
+		{
+			name: "linear buckets",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{LinearBuckets: &monitoring.Linear{
+				NumFiniteBuckets: 3, Width: 10, Offset: 0,
+			}}, 1, 2),
+		},
+		{
+			name: "linear buckets of a single bucket growing exponentially",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{LinearBuckets: &monitoring.Linear{
+				NumFiniteBuckets: 1, Width: 2, Offset: 2,
+			}}, 1, 2),
+			want: &NativeHistogram{Schema: 0, ZeroThreshold: 2, ZeroCount: 1, PositiveBuckets: map[int]int64{2: 2}},
+		},
+		{
+			name: "explicit buckets growing exponentially",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{
+				Bounds: []float64{0.25, 0.5, 1, 2},
+			}}, 0, 1, 1, 1),
+			want: &NativeHistogram{Schema: 0, ZeroThreshold: 0.25, PositiveBuckets: map[int]int64{-1: 1, 0: 1, 1: 1}},
+		},
+		{
+			name: "explicit buckets",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{
+				Bounds: []float64{1, 2, 5, 10},
+			}}, 1, 1, 1),
+		},
+		{
+			name: "explicit buckets from zero",
+			dist: newBucketsDistribution(&monitoring.BucketOptions{ExplicitBuckets: &monitoring.Explicit{
+				Bounds: []float64{0, 1, 2},
+			}}, 0, 1),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			native, ok := newNativeHistogram(tc.dist, startTime)
+			if tc.want == nil {
+				assert.False(t, ok, "buckets should not be representable")
+				return
+			}
+			require.True(t, ok, "buckets should be representable")
+			tc.want.StartTime = startTime
+			assert.Equal(t, tc.want, native)
+		})
+	}
+}
+
+func TestNewNativeHistogram_CountMismatch(t *testing.T) {
+	dist := newBucketsDistribution(&monitoring.BucketOptions{ExponentialBuckets: &monitoring.Exponential{
+		NumFiniteBuckets: 3, GrowthFactor: 2, Scale: 1,
+	}}, 1, 2)
+	dist.Count = 5
+
+	_, ok := newNativeHistogram(dist, time.Now())
+	assert.False(t, ok, "a count not matching the buckets should not be representable")
+}
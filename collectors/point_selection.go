@@ -0,0 +1,97 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/api/monitoring/v3"
+)
+
+// PointSelection decides which point of a GAUGE time series is reported when the request interval holds several.
+type PointSelection string
+
+const (
+	// PointSelectionLatest reports the point with the latest end time, the default.
+	PointSelectionLatest PointSelection = "latest"
+	// PointSelectionOldest reports the point with the earliest end time.
+	PointSelectionOldest PointSelection = "oldest"
+	// PointSelectionSum reports the sum of the INT64 and DOUBLE points, at the latest end time.
+	PointSelectionSum PointSelection = "sum"
+	// PointSelectionMean reports the mean of the INT64 and DOUBLE points, at the latest end time.
+	PointSelectionMean PointSelection = "mean"
+)
+
+// PointSelections are the supported point selections.
+var PointSelections = []PointSelection{PointSelectionLatest, PointSelectionOldest, PointSelectionSum, PointSelectionMean}
+
+// parsePointSelection returns the point selection, an empty one being the default latest.
+func parsePointSelection(selection PointSelection) (PointSelection, error) {
+	if selection == "" {
+		return PointSelectionLatest, nil
+	}
+	for _, supported := range PointSelections {
+		if selection == supported {
+			return selection, nil
+		}
+	}
+	return "", fmt.Errorf("invalid point selection %q, it must be one of %v", selection, PointSelections)
+}
+
+// selectPoint returns the point of a time series to report and its end time. The points of a GAUGE series are
+// selected by the point selection, the newest point being reported otherwise. Sum and mean report the newest point,
+// its value being replaced by aggregatePoints.
+func (c *MonitoringCollector) selectPoint(timeSeries *monitoring.TimeSeries) (*monitoring.Point, time.Time, error) {
+	oldest := c.pointSelection == PointSelectionOldest && timeSeries.MetricKind == "GAUGE"
+
+	var selected *monitoring.Point
+	var selectedEndTime time.Time
+	for _, point := range timeSeries.Points {
+		endTime, err := time.Parse(time.RFC3339Nano, point.Interval.EndTime)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("Error parsing TimeSeries Point interval end time `%s`: %s", point.Interval.EndTime, err)
+		}
+		if selected == nil || (oldest && endTime.Before(selectedEndTime)) || (!oldest && endTime.After(selectedEndTime)) {
+			selected, selectedEndTime = point, endTime
+		}
+	}
+	return selected, selectedEndTime, nil
+}
+
+// aggregatePoints returns the sum or mean of the points of a GAUGE INT64 or DOUBLE time series, and false when the
+// point selection or the series aren't aggregated.
+func (c *MonitoringCollector) aggregatePoints(timeSeries *monitoring.TimeSeries) (float64, bool) {
+	if (c.pointSelection != PointSelectionSum && c.pointSelection != PointSelectionMean) ||
+		timeSeries.MetricKind != "GAUGE" ||
+		len(timeSeries.Points) == 0 {
+		return 0, false
+	}
+
+	var sum float64
+	for _, point := range timeSeries.Points {
+		switch timeSeries.ValueType {
+		case "INT64":
+			sum += float64(*point.Value.Int64Value)
+		case "DOUBLE":
+			sum += *point.Value.DoubleValue
+		default:
+			return 0, false
+		}
+	}
+	if c.pointSelection == PointSelectionMean {
+		return sum / float64(len(timeSeries.Points)), true
+	}
+	return sum, true
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import "fmt"
+
+// ProjectIDLabelSource decides which project populates the project_id label of the metrics listed from a metrics
+// scope.
+type ProjectIDLabelSource string
+
+const (
+	// ProjectIDLabelBoth reports the project of the resource as project_id and the scoping project as
+	// scoped_project_id, the default.
+	ProjectIDLabelBoth ProjectIDLabelSource = "both"
+	// ProjectIDLabelResource reports the project of the resource as project_id only.
+	ProjectIDLabelResource ProjectIDLabelSource = "resource"
+	// ProjectIDLabelScope reports the scoping project, or the collector project without a metrics scope, as
+	// project_id.
+	ProjectIDLabelScope ProjectIDLabelSource = "scope"
+)
+
+// ProjectIDLabelSources are the supported project_id label sources.
+var ProjectIDLabelSources = []ProjectIDLabelSource{ProjectIDLabelBoth, ProjectIDLabelResource, ProjectIDLabelScope}
+
+// parseProjectIDLabelSource returns the project_id label source, an empty one being the default both.
+func parseProjectIDLabelSource(source ProjectIDLabelSource) (ProjectIDLabelSource, error) {
+	if source == "" {
+		return ProjectIDLabelBoth, nil
+	}
+	for _, supported := range ProjectIDLabelSources {
+		if source == supported {
+			return source, nil
+		}
+	}
+	return "", fmt.Errorf("invalid project_id label source %q, it must be one of %v", source, ProjectIDLabelSources)
+}
+
+// addScopedProjectID adds the scoping project as the scoped_project_id label when reported along with the project of
+// the resource.
+func (c *MonitoringCollector) addScopedProjectID(labels *labelSet) {
+	if c.metricsScopeProject != "" && c.projectIDLabelSource == ProjectIDLabelBoth {
+		labels.Add(scopedProjectIDLabel, c.metricsScopeProject)
+	}
+}
+
+// overrideProjectID replaces the project of the resource by the scoping project as project_id when the scope is the
+// project_id label source.
+func (c *MonitoringCollector) overrideProjectID(labels *labelSet) {
+	if c.projectIDLabelSource == ProjectIDLabelScope {
+		labels.Override(c.labelName(labels, LabelSourceResource, "project_id"), c.timeSeriesProject())
+	}
+}
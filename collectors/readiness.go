@@ -0,0 +1,88 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+)
+
+// errReadinessProbed stops the listing of the metric descriptors of a readiness probe after its first page.
+var errReadinessProbed = errors.New("readiness probed")
+
+// Readiness tracks the projects whose metric descriptors were listed successfully at least once, shared by the
+// collectors of these projects.
+type Readiness struct {
+	mu       sync.RWMutex // Protects projects
+	projects map[string]bool
+}
+
+// NewReadiness creates a Readiness waiting for the descriptors of every project to be listed.
+func NewReadiness(projectIDs []string) *Readiness {
+	projects := make(map[string]bool, len(projectIDs))
+	for _, projectID := range projectIDs {
+		projects[projectID] = false
+	}
+	return &Readiness{projects: projects}
+}
+
+// MarkReady records that the metric descriptors of the project were listed successfully.
+// This method is thread-safe.
+func (r *Readiness) MarkReady(projectID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.projects[projectID] = true
+}
+
+// NotReady returns the sorted projects whose metric descriptors were never listed successfully, none once ready.
+// This method is thread-safe.
+func (r *Readiness) NotReady() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var notReady []string
+	for projectID, ready := range r.projects {
+		if !ready {
+			notReady = append(notReady, projectID)
+		}
+	}
+	sort.Strings(notReady)
+	return notReady
+}
+
+// ProbeReadiness lists the first page of the metric descriptors of the first metric type prefix, marking the project
+// ready if it succeeds, e.g. at startup so that the project gets ready before its first scrape.
+func (c *MonitoringCollector) ProbeReadiness(ctx context.Context) error {
+	c.reloadMu.RLock()
+	defer c.reloadMu.RUnlock()
+	if len(c.metricsTypePrefixes) == 0 {
+		c.markReady()
+		return nil
+	}
+
+	filter := fmt.Sprintf("metric.type = starts_with(\"%s\")", c.metricsTypePrefixes[0])
+	err := c.listMetricDescriptors(ctx, filter, func(*monitoring.ListMetricDescriptorsResponse) error {
+		c.markReady()
+		return errReadinessProbed
+	})
+	if errors.Is(err, errReadinessProbed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"slices"
+	"time"
+)
+
+// ReloadableOptions are the options of a MonitoringCollector that can be changed while it runs, keeping its delta
+// stores and caches. They have the meaning of the MonitoringCollectorOptions of the same names.
+type ReloadableOptions struct {
+	MetricTypePrefixes []string
+	ExtraFilters       []MetricFilter
+	RequestInterval    time.Duration
+	RequestOffset      time.Duration
+}
+
+// Reload swaps the reloadable options of the collector, and reports whether they changed. A collection in flight
+// completes with the previous options, the swap waiting for it.
+func (c *MonitoringCollector) Reload(opts ReloadableOptions) bool {
+	c.reloadMu.RLock()
+	unchanged := slices.Equal(c.metricsTypePrefixes, opts.MetricTypePrefixes) &&
+		slices.Equal(c.metricsFilters, opts.ExtraFilters) &&
+		c.metricsInterval == opts.RequestInterval &&
+		c.metricsOffset == opts.RequestOffset
+	c.reloadMu.RUnlock()
+	if unchanged {
+		return false
+	}
+
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+	c.metricsTypePrefixes = opts.MetricTypePrefixes
+	c.metricsFilters = opts.ExtraFilters
+	c.metricsInterval = opts.RequestInterval
+	c.metricsOffset = opts.RequestOffset
+	// The prefixes no longer collected must not keep reporting their last scrape success
+	c.scrapeSuccessMetric.Reset()
+	c.logger.Info("Reloaded the collector options", "metric_prefixes", opts.MetricTypePrefixes, "interval", opts.RequestInterval, "offset", opts.RequestOffset)
+	return true
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+// resourceIDLabel and resourceTypeLabel are the labels joining the data metrics to the resource info metric.
+const (
+	resourceIDLabel   = "resource_id"
+	resourceTypeLabel = "resource_type"
+)
+
+// resourceInfos tracks the resources whose info metric was reported during a scrape, each resource being reported
+// once whatever the number of its time series.
+type resourceInfos struct {
+	mu       sync.Mutex
+	reported map[string]struct{}
+}
+
+func newResourceInfos() *resourceInfos {
+	return &resourceInfos{reported: map[string]struct{}{}}
+}
+
+// Reset starts a new scrape, every resource being reported again.
+func (r *resourceInfos) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reported = map[string]struct{}{}
+}
+
+// MarkReported marks the resource reported, and reports whether it was not already.
+func (r *resourceInfos) MarkReported(resourceID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reported[resourceID]; ok {
+		return false
+	}
+	r.reported[resourceID] = struct{}{}
+	return true
+}
+
+// resourceID returns the join key of a monitored resource, a hash of its type and labels. The resource metadata
+// is left out so that the key of a resource is stable when its metadata changes.
+func resourceID(resource *monitoring.MonitoredResource) string {
+	keys := make([]string, 0, len(resource.Labels))
+	for key := range resource.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := hash.New()
+	h = hash.Add(h, resource.Type)
+	h = hash.AddByte(h, hash.SeparatorByte)
+	for _, key := range keys {
+		h = hash.Add(h, key)
+		h = hash.AddByte(h, hash.SeparatorByte)
+		h = hash.Add(h, resource.Labels[key])
+		h = hash.AddByte(h, hash.SeparatorByte)
+	}
+	return fmt.Sprintf("%016x", h)
+}
@@ -0,0 +1,129 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// maxRetryDelay caps the delay between two attempts, the exponential backoff as well as the Retry-After delay.
+const maxRetryDelay = 30 * time.Second
+
+// retryPolicy retries Monitoring API calls failing with a transient error (429 RESOURCE_EXHAUSTED or
+// 503 UNAVAILABLE) using an exponential backoff with full jitter.
+type retryPolicy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	budget      *RetryBudget
+
+	// sleep waits for the given duration or until the context is done, it is replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newRetryPolicy(maxAttempts int, baseDelay time.Duration, budget *RetryBudget) *retryPolicy {
+	return &retryPolicy{
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		budget:      budget,
+		sleep:       sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// do calls fn until it succeeds, fails with a non-retryable error or the attempts are exhausted.
+// The error of the last attempt is returned.
+func (p *retryPolicy) do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retryable, retryAfter := isRetryableError(err)
+		if !retryable || attempt >= p.maxAttempts {
+			return err
+		}
+		if p.budget != nil && !p.budget.Allow() {
+			return err
+		}
+
+		delay := min(retryAfter, maxRetryDelay)
+		if delay == 0 {
+			delay = p.backoff(attempt)
+		}
+		if sleepErr := p.sleep(ctx, delay); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns a random delay between 0 and baseDelay * 2^(attempt-1), capped at maxRetryDelay.
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	top := math.Min(float64(p.baseDelay)*math.Pow(2, float64(attempt-1)), float64(maxRetryDelay))
+	if top < 1 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(top)))
+}
+
+// isRetryableError reports if err is a transient Monitoring API error worth retrying, along with the
+// delay requested by the server through the Retry-After header if any.
+func isRetryableError(err error) (bool, time.Duration) {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false, 0
+	}
+
+	switch apiErr.Code {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true, parseRetryAfter(apiErr.Header.Get("Retry-After"))
+	default:
+		return false, 0
+	}
+}
+
+// parseRetryAfter parses a Retry-After header value expressed either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
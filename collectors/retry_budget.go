@@ -0,0 +1,80 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RetryBudget caps the total number of retries performed during a single scrape,
+// shared across all the metric type prefixes and projects of that scrape.
+// Once the budget is exhausted the remaining failures are not retried.
+type RetryBudget struct {
+	mu    sync.Mutex // Protects all fields below
+	limit int
+	used  int
+
+	exhaustedTotal prometheus.Counter
+}
+
+// NewRetryBudget creates a new RetryBudget allowing limit retries per scrape, 0 means unlimited.
+func NewRetryBudget(limit int) *RetryBudget {
+	return &RetryBudget{
+		limit: limit,
+		exhaustedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "collector",
+			Name:      "retry_budget_exhausted_total",
+			Help:      "Total number of retries not attempted because the scrape retry budget was exhausted.",
+		}),
+	}
+}
+
+// Allow consumes one retry from the budget, returning false if the budget is exhausted.
+// This method is thread-safe.
+func (b *RetryBudget) Allow() bool {
+	if b.limit <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.used >= b.limit {
+		b.exhaustedTotal.Inc()
+		return false
+	}
+	b.used++
+	return true
+}
+
+// Reset refills the budget, it should be called at the beginning of each scrape.
+func (b *RetryBudget) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used = 0
+}
+
+// Describe implements prometheus.Collector interface.
+func (b *RetryBudget) Describe(ch chan<- *prometheus.Desc) {
+	b.exhaustedTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (b *RetryBudget) Collect(ch chan<- prometheus.Metric) {
+	b.exhaustedTotal.Collect(ch)
+}
@@ -0,0 +1,143 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/googleapi"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{"resource_exhausted", &googleapi.Error{Code: http.StatusTooManyRequests}, true, 0},
+		{"unavailable", &googleapi.Error{Code: http.StatusServiceUnavailable}, true, 0},
+		{"retry_after", &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"7"}}}, true, 7 * time.Second},
+		{"wrapped", fmt.Errorf("listing: %w", &googleapi.Error{Code: http.StatusServiceUnavailable}), true, 0},
+		{"permission_denied", &googleapi.Error{Code: http.StatusForbidden}, false, 0},
+		{"not_found", &googleapi.Error{Code: http.StatusNotFound}, false, 0},
+		{"not_an_api_error", errors.New("boom"), false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retryable, retryAfter := isRetryableError(tt.err)
+			assert.Equal(t, tt.retryable, retryable)
+			assert.Equal(t, tt.retryAfter, retryAfter)
+		})
+	}
+}
+
+func newTestRetryPolicy(maxAttempts int, budget *RetryBudget) (*retryPolicy, *[]time.Duration) {
+	var delays []time.Duration
+	p := newRetryPolicy(maxAttempts, 100*time.Millisecond, budget)
+	p.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return p, &delays
+}
+
+func TestRetryPolicy_RetriesTransientErrors(t *testing.T) {
+	p, delays := newTestRetryPolicy(3, nil)
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return &googleapi.Error{Code: http.StatusServiceUnavailable}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Len(t, *delays, 2)
+	for i, d := range *delays {
+		assert.Less(t, d, 100*time.Millisecond<<i, "delay should be bounded by the exponential backoff")
+	}
+}
+
+func TestRetryPolicy_StopsAtMaxAttempts(t *testing.T) {
+	p, _ := newTestRetryPolicy(3, nil)
+
+	calls := 0
+	err := p.do(context.Background(), func() error {
+		calls++
+		return &googleapi.Error{Code: http.StatusTooManyRequests}
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryPolicy_NonRetryableErrorsFailImmediately(t *testing.T) {
+	for _, code := range []int{http.StatusForbidden, http.StatusNotFound} {
+		p, delays := newTestRetryPolicy(5, nil)
+
+		calls := 0
+		err := p.do(context.Background(), func() error {
+			calls++
+			return &googleapi.Error{Code: code}
+		})
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls, "code %d should not be retried", code)
+		assert.Empty(t, *delays)
+	}
+}
+
+func TestRetryPolicy_RespectsRetryAfter(t *testing.T) {
+	p, delays := newTestRetryPolicy(2, nil)
+
+	_ = p.do(context.Background(), func() error {
+		return &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3"}}}
+	})
+
+	assert.Equal(t, []time.Duration{3 * time.Second}, *delays)
+}
+
+func TestRetryPolicy_CapsRetryAfter(t *testing.T) {
+	p, delays := newTestRetryPolicy(2, nil)
+
+	_ = p.do(context.Background(), func() error {
+		return &googleapi.Error{Code: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3600"}}}
+	})
+
+	assert.Equal(t, []time.Duration{maxRetryDelay}, *delays, "the server should not stall a scrape beyond the max delay")
+}
+
+func TestRetryPolicy_ConsumesRetryBudget(t *testing.T) {
+	budget := NewRetryBudget(1)
+	p, _ := newTestRetryPolicy(5, budget)
+
+	calls := 0
+	_ = p.do(context.Background(), func() error {
+		calls++
+		return &googleapi.Error{Code: http.StatusServiceUnavailable}
+	})
+
+	assert.Equal(t, 2, calls, "only one retry should be allowed by the budget")
+}
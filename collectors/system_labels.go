@@ -0,0 +1,112 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+	"google.golang.org/api/googleapi"
+)
+
+// systemLabelsLabel is the label holding the system labels when folded into a JSON object.
+const systemLabelsLabel = "system_labels"
+
+// SystemLabelsMode decides how the system labels of the metadata are added to the metrics.
+type SystemLabelsMode string
+
+const (
+	// SystemLabelsFlatten adds every system label as its own label, the default.
+	SystemLabelsFlatten SystemLabelsMode = "flatten"
+	// SystemLabelsJSON adds the system labels as a single system_labels label, a compact JSON object of sorted keys.
+	SystemLabelsJSON SystemLabelsMode = "json"
+	// SystemLabelsOff leaves the system labels out.
+	SystemLabelsOff SystemLabelsMode = "off"
+)
+
+// SystemLabelsModes are the supported system labels modes.
+var SystemLabelsModes = []SystemLabelsMode{SystemLabelsFlatten, SystemLabelsJSON, SystemLabelsOff}
+
+// parseSystemLabelsMode returns the system labels mode, an empty one being the default flatten.
+func parseSystemLabelsMode(mode SystemLabelsMode) (SystemLabelsMode, error) {
+	if mode == "" {
+		return SystemLabelsFlatten, nil
+	}
+	for _, supported := range SystemLabelsModes {
+		if mode == supported {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid system labels mode %q, it must be one of %v", mode, SystemLabelsModes)
+}
+
+// addSystemLabelsJSON adds the system labels as the system_labels label, the schema version excepted when reported
+// by addSystemLabelsSchema and the promoted ones. Anything but a non-empty JSON object is ignored.
+func (c *MonitoringCollector) addSystemLabelsJSON(raw googleapi.RawMessage, labels *labelSet) {
+	if len(raw) == 0 {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return
+	}
+	if c.emitSystemLabelsSchema {
+		delete(fields, c.systemLabelsSchemaKey)
+	}
+	for key := range fields {
+		if c.isPromotedSystemLabel(key) {
+			delete(fields, key)
+		}
+	}
+	if len(fields) == 0 {
+		return
+	}
+
+	// Marshalling a map sorts its keys, a same set of system labels always having the same value
+	folded, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	labels.Add(systemLabelsLabel, string(folded))
+}
+
+// isPromotedSystemLabel reports whether a system label key starts with one of the promoted prefixes.
+func (c *MonitoringCollector) isPromotedSystemLabel(key string) bool {
+	for _, prefix := range c.promotedSystemLabels {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// promoteSystemLabels adds the promoted system labels as labels of their own, e.g. the check_id of the uptime check
+// metrics, overriding the labels of the same name.
+func (c *MonitoringCollector) promoteSystemLabels(raw googleapi.RawMessage, labels *labelSet) {
+	if len(c.promotedSystemLabels) == 0 || len(raw) == 0 {
+		return
+	}
+	result := gjson.ParseBytes(raw)
+	if !result.IsObject() {
+		return
+	}
+	result.ForEach(func(key, value gjson.Result) bool {
+		if c.isPromotedSystemLabel(key.String()) {
+			c.addOrOverrideLabels(labels, c.labelName(labels, LabelSourceSystem, key.String()), value.String(), true)
+		}
+		return true
+	})
+}
@@ -18,9 +18,12 @@ import (
 	"os"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/api/googleapi"
+	"google.golang.org/api/monitoring/v3"
 )
 
 func TestMonitoringCollector_AddSystemLabels(t *testing.T) {
@@ -125,7 +128,7 @@ func TestMonitoringCollector_AddSystemLabels(t *testing.T) {
 			rawMessage := googleapi.RawMessage(tt.systemLabelsJSON)
 
 			// Call the method under test
-			collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+			withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 
 			// Verify the results
 			assert.Equal(t, tt.expectedLabelKeys, labelKeys, "Label keys should match expected")
@@ -193,7 +196,7 @@ func TestMonitoringCollector_AddSystemLabels_InvalidJSON(t *testing.T) {
 
 			// Should not panic
 			assert.NotPanics(t, func() {
-				collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+				withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 			})
 
 			if tt.expectChange {
@@ -217,18 +220,17 @@ func TestMonitoringCollector_AddSystemLabels_EdgeCases(t *testing.T) {
 		logger: logger,
 	}
 
-	t.Run("nil_slices", func(t *testing.T) {
-		var labelKeys *[]string
-		var labelValues *[]string
+	t.Run("nil_label_set", func(t *testing.T) {
+		var labels *labelSet
 
 		rawMessage := googleapi.RawMessage(`{"key": "value"}`)
 
 		// This will panic because the current implementation doesn't handle nil pointers
 		// This test documents the current behavior - in practice, this should never happen
-		// as the calling code always passes valid slice pointers
+		// as the calling code always passes a valid label set
 		assert.Panics(t, func() {
-			collector.addSystemLabels(rawMessage, labelKeys, labelValues)
-		}, "addSystemLabels should panic with nil slice pointers")
+			collector.addSystemLabels(rawMessage, labels)
+		}, "addSystemLabels should panic with a nil label set")
 	})
 
 	t.Run("empty_values", func(t *testing.T) {
@@ -237,7 +239,7 @@ func TestMonitoringCollector_AddSystemLabels_EdgeCases(t *testing.T) {
 
 		rawMessage := googleapi.RawMessage(`{"empty": "", "whitespace": "   ", "zero": "0"}`)
 
-		collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 
 		expectedKeys := []string{"existing", "empty", "whitespace", "zero"}
 		expectedValues := []string{"value", "", "   ", "0"}
@@ -252,7 +254,7 @@ func TestMonitoringCollector_AddSystemLabels_EdgeCases(t *testing.T) {
 
 		rawMessage := googleapi.RawMessage(`{"emoji": "🚀", "chinese": "你好", "arabic": "مرحبا"}`)
 
-		collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 
 		expectedKeys := []string{"emoji", "chinese", "arabic"}
 		expectedValues := []string{"🚀", "你好", "مرحبا"}
@@ -272,7 +274,7 @@ func TestMonitoringCollector_AddSystemLabels_EdgeCases(t *testing.T) {
 
 		rawMessage := googleapi.RawMessage(`{"long_key": "` + longValue + `"}`)
 
-		collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 
 		expectedKeys := []string{"long_key"}
 		expectedValues := []string{longValue}
@@ -283,12 +285,6 @@ func TestMonitoringCollector_AddSystemLabels_EdgeCases(t *testing.T) {
 }
 
 func TestMonitoringCollector_KeyExists(t *testing.T) {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
-
-	collector := &MonitoringCollector{
-		logger: logger,
-	}
-
 	tests := []struct {
 		name      string
 		labelKeys []string
@@ -335,7 +331,7 @@ func TestMonitoringCollector_KeyExists(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := collector.keyExists(tt.labelKeys, tt.searchKey)
+			result := (&labelSet{keys: tt.labelKeys}).Has(tt.searchKey)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
@@ -503,7 +499,7 @@ func TestMonitoringCollector_AllLabelSources_Integration(t *testing.T) {
 
 			// Add metric labels
 			for key, value := range tt.metricLabels {
-				if !collector.keyExists(labelKeys, key) {
+				if !(&labelSet{keys: labelKeys}).Has(key) {
 					labelKeys = append(labelKeys, key)
 					labelValues = append(labelValues, value)
 				}
@@ -511,7 +507,7 @@ func TestMonitoringCollector_AllLabelSources_Integration(t *testing.T) {
 
 			// Add resource labels
 			for key, value := range tt.resourceLabels {
-				if !collector.keyExists(labelKeys, key) {
+				if !(&labelSet{keys: labelKeys}).Has(key) {
 					labelKeys = append(labelKeys, key)
 					labelValues = append(labelValues, value)
 				}
@@ -519,7 +515,7 @@ func TestMonitoringCollector_AllLabelSources_Integration(t *testing.T) {
 
 			// Add user labels
 			for key, value := range tt.userLabels {
-				if !collector.keyExists(labelKeys, key) {
+				if !(&labelSet{keys: labelKeys}).Has(key) {
 					labelKeys = append(labelKeys, key)
 					labelValues = append(labelValues, value)
 				}
@@ -527,7 +523,7 @@ func TestMonitoringCollector_AllLabelSources_Integration(t *testing.T) {
 
 			// Add system labels
 			rawMessage := googleapi.RawMessage(tt.systemLabelsJSON)
-			collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+			withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 
 			sort.Strings(tt.expectedLabelValues)
 			sort.Strings(labelValues)
@@ -548,6 +544,190 @@ func TestMonitoringCollector_AllLabelSources_Integration(t *testing.T) {
 	}
 }
 
+func TestMonitoringCollector_SystemLabelsSchema(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/my_metric"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_my_metric"
+	newSeries := func(systemLabels string) *monitoring.TimeSeries {
+		series := newDoubleTimeSeries(descriptor.Type, 1, time.Now(), nil)
+		series.Metadata = &monitoring.MonitoredResourceMetadata{SystemLabels: googleapi.RawMessage(systemLabels)}
+		return series
+	}
+
+	t.Run("default key", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true, EmitSystemLabelsSchema: true})
+		metrics := reportPage(t, c, descriptor, newSeries(`{"__schema__": "v2", "zone": "us-central1-a"}`))
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		assert.Equal(t, "v2", labels["system_labels_schema"])
+		assert.Equal(t, "us-central1-a", labels["zone"])
+		assert.NotContains(t, labels, "__schema__", "the schema key should be removed from the system labels")
+	})
+
+	t.Run("custom key without system labels", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EmitSystemLabelsSchema: true, SystemLabelsSchemaKey: "schema_version"})
+		metrics := reportPage(t, c, descriptor, newSeries(`{"schema_version": "3", "zone": "us-central1-a"}`))
+
+		require.Len(t, metrics[fqName], 1)
+		labels := labelsOf(metrics[fqName][0])
+		assert.Equal(t, "3", labels["system_labels_schema"])
+		assert.NotContains(t, labels, "zone", "system labels should only be added when enabled")
+	})
+
+	t.Run("absent key", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{EnableSystemLabels: true, EmitSystemLabelsSchema: true})
+		metrics := reportPage(t, c, descriptor, newSeries(`{"zone": "us-central1-a"}`))
+
+		require.Len(t, metrics[fqName], 1)
+		assert.NotContains(t, labelsOf(metrics[fqName][0]), "system_labels_schema")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		collector := &MonitoringCollector{logger: slog.New(slog.NewTextHandler(os.Stdout, nil)), systemLabelsSchemaKey: "schema_version"}
+		labelKeys, labelValues := []string{}, []string{}
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) {
+			collector.addSystemLabels(googleapi.RawMessage(`{"schema_version": "3"}`), labels)
+		})
+
+		assert.Equal(t, []string{"schema_version"}, labelKeys, "the schema key should be a regular system label")
+		assert.Equal(t, []string{"3"}, labelValues)
+	})
+}
+
+func TestMonitoringCollector_SystemLabelsMode(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	raw := googleapi.RawMessage(`{"zone": "us-central1-a", "__schema__": "v2", "machine_type": "e2-small", "tags": ["a", "b"]}`)
+
+	tests := []struct {
+		name      string
+		collector *MonitoringCollector
+		expected  map[string]string
+	}{
+		{
+			name:      "default flattens",
+			collector: &MonitoringCollector{logger: logger},
+			expected:  map[string]string{"existing": "value", "__schema__": "v2", "machine_type": "e2-small", "tags": `["a", "b"]`, "zone": "us-central1-a"},
+		},
+		{
+			name:      "flatten",
+			collector: &MonitoringCollector{logger: logger, systemLabelsMode: SystemLabelsFlatten},
+			expected:  map[string]string{"existing": "value", "__schema__": "v2", "machine_type": "e2-small", "tags": `["a", "b"]`, "zone": "us-central1-a"},
+		},
+		{
+			name:      "json",
+			collector: &MonitoringCollector{logger: logger, systemLabelsMode: SystemLabelsJSON},
+			expected:  map[string]string{"existing": "value", "system_labels": `{"__schema__":"v2","machine_type":"e2-small","tags":["a","b"],"zone":"us-central1-a"}`},
+		},
+		{
+			name:      "json without the schema",
+			collector: &MonitoringCollector{logger: logger, systemLabelsMode: SystemLabelsJSON, emitSystemLabelsSchema: true, systemLabelsSchemaKey: "__schema__"},
+			expected:  map[string]string{"existing": "value", "system_labels": `{"machine_type":"e2-small","tags":["a","b"],"zone":"us-central1-a"}`},
+		},
+		{
+			name:      "off",
+			collector: &MonitoringCollector{logger: logger, systemLabelsMode: SystemLabelsOff},
+			expected:  map[string]string{"existing": "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labelKeys, labelValues := []string{"existing"}, []string{"value"}
+			withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { tt.collector.addSystemLabels(raw, labels) })
+
+			require.Len(t, labelValues, len(labelKeys))
+			labels := map[string]string{}
+			for i, key := range labelKeys {
+				labels[key] = labelValues[i]
+			}
+			assert.Equal(t, tt.expected, labels)
+		})
+	}
+}
+
+func TestMonitoringCollector_PromotedSystemLabels(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	timeSeries := &monitoring.TimeSeries{
+		Resource: &monitoring.MonitoredResource{Type: "uptime_url", Labels: map[string]string{"host": "example.com", "check_id": "resource-value"}},
+		Metadata: &monitoring.MonitoredResourceMetadata{
+			SystemLabels: googleapi.RawMessage(`{"check_id": "homepage", "checked_resource_id": "example.com", "region": "usa", "checker_location": "us-east4"}`),
+		},
+	}
+
+	tests := []struct {
+		mode     SystemLabelsMode
+		expected map[string]string
+	}{
+		{
+			mode:     SystemLabelsFlatten,
+			expected: map[string]string{"host": "example.com", "check_id": "homepage", "checked_resource_id": "example.com", "region": "usa", "checker_location": "us-east4"},
+		},
+		{
+			mode:     SystemLabelsJSON,
+			expected: map[string]string{"host": "example.com", "check_id": "homepage", "checked_resource_id": "example.com", "system_labels": `{"checker_location":"us-east4","region":"usa"}`},
+		},
+		{
+			mode:     SystemLabelsOff,
+			expected: map[string]string{"host": "example.com", "check_id": "homepage", "checked_resource_id": "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			collector := &MonitoringCollector{
+				logger:               logger,
+				enableSystemLabels:   tt.mode != SystemLabelsOff,
+				systemLabelsMode:     tt.mode,
+				promotedSystemLabels: []string{"check_id", "checked_resource"},
+			}
+			labels := &labelSet{}
+			collector.addResourceLabels(timeSeries, labels)
+
+			require.Len(t, labels.values, len(labels.keys))
+			got := map[string]string{}
+			for i, key := range labels.keys {
+				got[key] = labels.values[i]
+			}
+			assert.Equal(t, tt.expected, got, "the promoted keys should be labels of their own, overriding the resource labels")
+		})
+	}
+}
+
+func TestMonitoringCollector_SystemLabelsMode_JSONEarlyExit(t *testing.T) {
+	collector := &MonitoringCollector{
+		logger:                 slog.New(slog.NewTextHandler(os.Stdout, nil)),
+		systemLabelsMode:       SystemLabelsJSON,
+		emitSystemLabelsSchema: true,
+		systemLabelsSchemaKey:  "__schema__",
+	}
+
+	for name, systemLabels := range map[string]string{
+		"invalid_json": `{invalid json}`,
+		"empty_string": ``,
+		"null_json":    `null`,
+		"array_json":   `["not", "an", "object"]`,
+		"empty_object": `{}`,
+		"schema_only":  `{"__schema__": "v2"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			labelKeys, labelValues := []string{"existing"}, []string{"value"}
+			assert.NotPanics(t, func() {
+				withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) {
+					collector.addSystemLabels(googleapi.RawMessage(systemLabels), labels)
+				})
+			})
+
+			assert.Equal(t, []string{"existing"}, labelKeys, "labels should remain unchanged with early exit")
+			assert.Equal(t, []string{"value"}, labelValues)
+		})
+	}
+}
+
+func TestNewMonitoringCollector_InvalidSystemLabelsMode(t *testing.T) {
+	_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{SystemLabelsMode: "yaml"}, slog.New(slog.NewTextHandler(os.Stdout, nil)), nil, nil)
+	assert.ErrorContains(t, err, `invalid system labels mode "yaml"`)
+}
+
 func BenchmarkMonitoringCollector_AddSystemLabels(b *testing.B) {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelError}))
 
@@ -573,6 +753,6 @@ func BenchmarkMonitoringCollector_AddSystemLabels(b *testing.B) {
 		labelKeys := []string{"metric_type", "unit"}
 		labelValues := []string{"cpu_usage", "percent"}
 
-		collector.addSystemLabels(rawMessage, &labelKeys, &labelValues)
+		withLabelSet(&labelKeys, &labelValues, func(labels *labelSet) { collector.addSystemLabels(rawMessage, labels) })
 	}
 }
@@ -0,0 +1,75 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+// unchangedSeries remembers the last point emitted for every series, by its deduplication signature regardless of
+// the timestamp, so that a series whose point did not change since the previous scrapes is not emitted again.
+type unchangedSeries struct {
+	// maxAge is how long an unchanged series is skipped for before being emitted again
+	maxAge time.Duration
+
+	mu sync.Mutex
+	// scrape is the number of the current scrape, the series not seen in the previous one being forgotten
+	scrape uint64
+	last   map[hash.Signature]emittedPoint
+}
+
+// emittedPoint is the last point emitted for a series.
+type emittedPoint struct {
+	value     float64
+	timestamp time.Time
+	emittedAt time.Time
+	// scrape is the last scrape the series was seen during
+	scrape uint64
+}
+
+func newUnchangedSeries(maxAge time.Duration) *unchangedSeries {
+	return &unchangedSeries{maxAge: maxAge, last: map[hash.Signature]emittedPoint{}}
+}
+
+// Reset starts a new scrape, forgetting the series not seen during the previous one. A series reported again after
+// missing a scrape is then emitted even when unchanged.
+func (u *unchangedSeries) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for signature, point := range u.last {
+		if point.scrape < u.scrape {
+			delete(u.last, signature)
+		}
+	}
+	u.scrape++
+}
+
+// Skip reports whether the point of a series is the one last emitted, at the same timestamp and of the same value,
+// and was emitted less than maxAge ago. The point is recorded as emitted otherwise.
+func (u *unchangedSeries) Skip(signature hash.Signature, value float64, timestamp, now time.Time) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	last, ok := u.last[signature]
+	if ok && last.value == value && last.timestamp.Equal(timestamp) && now.Sub(last.emittedAt) < u.maxAge {
+		last.scrape = u.scrape
+		u.last[signature] = last
+		return true
+	}
+	u.last[signature] = emittedPoint{value: value, timestamp: timestamp, emittedAt: now, scrape: u.scrape}
+	return false
+}
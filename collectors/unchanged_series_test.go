@@ -0,0 +1,95 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/hash"
+)
+
+func TestMonitoringCollector_SkipUnchangedSeries(t *testing.T) {
+	descriptor := &monitoring.MetricDescriptor{Name: "descriptor", Type: "custom.googleapis.com/requests", MetricKind: "GAUGE", ValueType: "DOUBLE"}
+	fqName := "stackdriver_gce_instance_custom_googleapis_com_requests"
+	endTime := time.Now().Truncate(time.Minute)
+	c := newTestCollector(t, MonitoringCollectorOptions{SkipUnchangedSeries: true, UnchangedSeriesMaxAge: time.Hour})
+
+	// scrape reports the series as a new scrape and returns the value of the emitted ones by instance
+	scrape := func(series ...*monitoring.TimeSeries) map[string]float64 {
+		c.deduplicator.Reset()
+		c.unchangedSeries.Reset()
+		emitted := map[string]float64{}
+		for _, m := range reportPage(t, c, descriptor, series...)[fqName] {
+			emitted[labelsOf(m)["instance"]] = m.GetGauge().GetValue()
+		}
+		return emitted
+	}
+
+	assert.Equal(t, map[string]float64{"a": 1, "b": 2}, scrape(
+		newDoubleTimeSeries(descriptor.Type, 1, endTime, map[string]string{"instance": "a"}),
+		newDoubleTimeSeries(descriptor.Type, 2, endTime, map[string]string{"instance": "b"}),
+	), "every series should be emitted by the first scrape")
+
+	assert.Equal(t, map[string]float64{"b": 3}, scrape(
+		newDoubleTimeSeries(descriptor.Type, 1, endTime, map[string]string{"instance": "a"}),
+		newDoubleTimeSeries(descriptor.Type, 3, endTime.Add(time.Minute), map[string]string{"instance": "b"}),
+	), "only the changed series should be emitted")
+	assert.Equal(t, 1.0, testutil.ToFloat64(c.droppedMetricsTotal.WithLabelValues("unchanged", descriptor.Type, "gce_instance", "GAUGE", "DOUBLE")))
+
+	assert.Equal(t, map[string]float64{"a": 4}, scrape(
+		newDoubleTimeSeries(descriptor.Type, 4, endTime, map[string]string{"instance": "a"}),
+	), "a point of the same timestamp but another value should be emitted")
+
+	scrape(newDoubleTimeSeries(descriptor.Type, 4, endTime, map[string]string{"instance": "a"}))
+	assert.Equal(t, map[string]float64{"b": 3}, scrape(
+		newDoubleTimeSeries(descriptor.Type, 3, endTime.Add(time.Minute), map[string]string{"instance": "b"}),
+	), "a series missing from a scrape should be emitted again")
+
+	t.Run("no max age", func(t *testing.T) {
+		logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		_, err := NewMonitoringCollector("test-project", nil, MonitoringCollectorOptions{SkipUnchangedSeries: true}, logger, &testCounterStore{}, &testHistogramStore{})
+		assert.ErrorContains(t, err, "invalid unchanged series max age 0s")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestCollector(t, MonitoringCollectorOptions{})
+		series := newDoubleTimeSeries(descriptor.Type, 1, endTime, nil)
+		for i := 0; i < 2; i++ {
+			c.deduplicator.Reset()
+			require.Len(t, reportPage(t, c, descriptor, series)[fqName], 1, "unchanged series should be emitted by every scrape")
+		}
+	})
+}
+
+func TestUnchangedSeries_MaxAge(t *testing.T) {
+	signature := hash.Signature{42}
+	timestamp := time.Now()
+	u := newUnchangedSeries(time.Minute)
+
+	assert.False(t, u.Skip(signature, 1, timestamp, timestamp))
+	u.Reset()
+	assert.True(t, u.Skip(signature, 1, timestamp, timestamp.Add(30*time.Second)))
+	u.Reset()
+	assert.False(t, u.Skip(signature, 1, timestamp, timestamp.Add(time.Minute)), "an unchanged series should be emitted again after the max age")
+	u.Reset()
+	assert.True(t, u.Skip(signature, 1, timestamp, timestamp.Add(90*time.Second)), "the max age should start over from the last emission")
+}
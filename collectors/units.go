@@ -0,0 +1,100 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"regexp"
+	"strings"
+)
+
+// prometheusUnits maps the UCUM units of the metric descriptors to the Prometheus unit names. The dimensionless unit
+// 1 has no name.
+// @see https://cloud.google.com/monitoring/api/ref_v3/rest/v3/projects.metricDescriptors#MetricDescriptor.FIELDS.unit
+// @see https://prometheus.io/docs/practices/naming/#base-units
+var prometheusUnits = map[string]string{
+	"1": "",
+	"%": "percent",
+
+	"ns":  "nanoseconds",
+	"us":  "microseconds",
+	"ms":  "milliseconds",
+	"s":   "seconds",
+	"min": "minutes",
+	"h":   "hours",
+	"d":   "days",
+
+	"bit":  "bits",
+	"By":   "bytes",
+	"kBy":  "kilobytes",
+	"MBy":  "megabytes",
+	"GBy":  "gigabytes",
+	"TBy":  "terabytes",
+	"KiBy": "kibibytes",
+	"MiBy": "mebibytes",
+	"GiBy": "gibibytes",
+	"TiBy": "tebibytes",
+
+	"Hz":  "hertz",
+	"Cel": "celsius",
+	"V":   "volts",
+	"A":   "amperes",
+	"W":   "watts",
+	"J":   "joules",
+	"m":   "meters",
+	"g":   "grams",
+}
+
+// prometheusPerUnits maps the UCUM units found as the denominator of a rate, e.g. By/s, to their Prometheus names.
+var prometheusPerUnits = map[string]string{
+	"s":   "second",
+	"min": "minute",
+	"h":   "hour",
+	"d":   "day",
+}
+
+// unitAnnotationRE matches the curly braces annotations of a unit, e.g. {requests}.
+var unitAnnotationRE = regexp.MustCompile(`\{[^}]*\}`)
+
+// normalizeUnit returns the Prometheus name of a UCUM unit, and false when the unit is not recognized. Annotations
+// are ignored, {requests}/s being named per_second and {requests} having no name.
+func normalizeUnit(unit string) (string, bool) {
+	numerator, denominator, isRate := strings.Cut(unit, "/")
+
+	name, ok := normalizeUnitTerm(numerator)
+	if !ok || !isRate {
+		return name, ok
+	}
+
+	per, ok := prometheusPerUnits[denominator]
+	if !ok {
+		return "", false
+	}
+	if name == "" {
+		return "per_" + per, true
+	}
+	return name + "_per_" + per, true
+}
+
+// normalizeUnitTerm returns the Prometheus name of a unit without denominator.
+func normalizeUnitTerm(unit string) (string, bool) {
+	if stripped := unitAnnotationRE.ReplaceAllString(unit, ""); stripped != unit {
+		// An annotation alone is dimensionless, e.g. {requests}
+		if stripped == "" || stripped == "1" {
+			return "", true
+		}
+		unit = stripped
+	}
+	name, ok := prometheusUnits[unit]
+	return name, ok
+}
@@ -0,0 +1,50 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeUnit(t *testing.T) {
+	tests := []struct {
+		unit     string
+		expected string
+		ok       bool
+	}{
+		{"By", "bytes", true},
+		{"s", "seconds", true},
+		{"ms", "milliseconds", true},
+		{"1", "", true},
+		{"%", "percent", true},
+		{"GiBy", "gibibytes", true},
+		{"By/s", "bytes_per_second", true},
+		{"{requests}/s", "per_second", true},
+		{"{requests}", "", true},
+		{"1/min", "per_minute", true},
+		{"", "", false},
+		{"furlong", "", false},
+		{"By/furlong", "", false},
+		{"{packets}furlong", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			name, ok := normalizeUnit(tt.unit)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+}
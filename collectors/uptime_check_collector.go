@@ -0,0 +1,180 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/utils"
+)
+
+// uptimeCheckPassedMetric is the metric type reporting the result of each uptime check execution.
+// @see https://cloud.google.com/monitoring/api/metrics_gcp#gcp-monitoring
+const uptimeCheckPassedMetric = "monitoring.googleapis.com/uptime_check/check_passed"
+
+// UptimeCheckCollector reports whether the uptime checks of a project are passing.
+type UptimeCheckCollector struct {
+	projectID         string
+	monitoringService *monitoring.Service
+	interval          time.Duration
+	logger            *slog.Logger
+
+	passingDesc             *prometheus.Desc
+	scrapeErrorsTotalMetric prometheus.Counter
+}
+
+// NewUptimeCheckCollector creates an UptimeCheckCollector looking for check results over the given interval.
+func NewUptimeCheckCollector(projectID string, monitoringService *monitoring.Service, interval time.Duration, logger *slog.Logger) *UptimeCheckCollector {
+	return &UptimeCheckCollector{
+		projectID:         projectID,
+		monitoringService: monitoringService,
+		interval:          interval,
+		logger:            logger.With("project_id", projectID, "component", "uptime_check"),
+		passingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "uptime_check", "passing"),
+			"Whether the latest result of the uptime check passed in every checker location (1 for passing, 0 for failing).",
+			[]string{"check", "resource"},
+			prometheus.Labels{"project_id": projectID},
+		),
+		scrapeErrorsTotalMetric: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "uptime_check",
+			Name:        "scrape_errors_total",
+			Help:        "Total number of Google Stackdriver Monitoring uptime checks scrape errors.",
+			ConstLabels: prometheus.Labels{"project_id": projectID},
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector interface.
+func (c *UptimeCheckCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.passingDesc
+	c.scrapeErrorsTotalMetric.Describe(ch)
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *UptimeCheckCollector) Collect(ch chan<- prometheus.Metric) {
+	c.CollectContext(context.Background(), ch)
+}
+
+// CollectContext collects the uptime checks like Collect, the Monitoring API calls being cancelled once ctx is done.
+func (c *UptimeCheckCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	if err := c.reportUptimeChecks(ctx, ch); err != nil {
+		c.scrapeErrorsTotalMetric.Inc()
+		c.logger.Error("Error while getting Google Stackdriver Monitoring uptime checks", "err", err)
+	}
+	c.scrapeErrorsTotalMetric.Collect(ch)
+}
+
+func (c *UptimeCheckCollector) reportUptimeChecks(ctx context.Context, ch chan<- prometheus.Metric) error {
+	var configs []*monitoring.UptimeCheckConfig
+	if err := c.monitoringService.Projects.UptimeCheckConfigs.List(utils.ProjectResource(c.projectID)).
+		Pages(ctx, func(page *monitoring.ListUptimeCheckConfigsResponse) error {
+			configs = append(configs, page.UptimeCheckConfigs...)
+			return nil
+		}); err != nil {
+		return fmt.Errorf("error listing uptime check configs: %w", err)
+	}
+	if len(configs) == 0 {
+		return nil
+	}
+
+	results, err := c.latestCheckResults(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		checkID := path.Base(config.Name)
+		passed, ok := results[checkID]
+		if !ok {
+			c.logger.Debug("no recent result for uptime check", "check", checkID)
+			continue
+		}
+
+		value := float64(0)
+		if passed {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.passingDesc, prometheus.GaugeValue, value, checkID, uptimeCheckResource(config))
+	}
+	return nil
+}
+
+// latestCheckResults returns, for each check ID, whether the newest result of every checker location passed.
+func (c *UptimeCheckCollector) latestCheckResults(ctx context.Context) (map[string]bool, error) {
+	endTime := time.Now().UTC()
+	startTime := endTime.Add(-c.interval)
+
+	results := map[string]bool{}
+	if err := c.monitoringService.Projects.TimeSeries.List(utils.ProjectResource(c.projectID)).
+		Filter(fmt.Sprintf("metric.type=\"%s\"", uptimeCheckPassedMetric)).
+		IntervalStartTime(startTime.Format(time.RFC3339Nano)).
+		IntervalEndTime(endTime.Format(time.RFC3339Nano)).
+		Pages(ctx, func(page *monitoring.ListTimeSeriesResponse) error {
+			for _, timeSeries := range page.TimeSeries {
+				checkID := timeSeries.Metric.Labels["check_id"]
+				newest := newestPoint(timeSeries.Points)
+				if checkID == "" || newest == nil || newest.Value.BoolValue == nil {
+					continue
+				}
+				passed, seen := results[checkID]
+				results[checkID] = *newest.Value.BoolValue && (passed || !seen)
+			}
+			return nil
+		}); err != nil {
+		return nil, fmt.Errorf("error retrieving uptime check results: %w", err)
+	}
+	return results, nil
+}
+
+// newestPoint returns the point with the latest interval end time.
+func newestPoint(points []*monitoring.Point) *monitoring.Point {
+	var newest *monitoring.Point
+	var newestEndTime time.Time
+	for _, point := range points {
+		endTime, err := time.Parse(time.RFC3339Nano, point.Interval.EndTime)
+		if err != nil {
+			continue
+		}
+		if newest == nil || endTime.After(newestEndTime) {
+			newest, newestEndTime = point, endTime
+		}
+	}
+	return newest
+}
+
+// uptimeCheckResource describes the target of an uptime check.
+func uptimeCheckResource(config *monitoring.UptimeCheckConfig) string {
+	switch {
+	case config.MonitoredResource != nil:
+		if host, ok := config.MonitoredResource.Labels["host"]; ok {
+			return host
+		}
+		return config.MonitoredResource.Type
+	case config.ResourceGroup != nil:
+		return config.ResourceGroup.GroupId
+	case config.SyntheticMonitor != nil && config.SyntheticMonitor.CloudFunctionV2 != nil:
+		return config.SyntheticMonitor.CloudFunctionV2.Name
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collectors
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/monitoring/v3"
+)
+
+// newCheckPassedTimeSeries returns a check_passed series of a checker location with one point per result,
+// ordered from the oldest to the newest.
+func newCheckPassedTimeSeries(checkID, checkerLocation string, now time.Time, results ...bool) *monitoring.TimeSeries {
+	var points []*monitoring.Point
+	for i, result := range results {
+		passed := result
+		points = append(points, &monitoring.Point{
+			Interval: &monitoring.TimeInterval{EndTime: now.Add(time.Duration(i-len(results)) * time.Minute).Format(time.RFC3339Nano)},
+			Value:    &monitoring.TypedValue{BoolValue: &passed},
+		})
+	}
+	// The API returns the points in reverse time order.
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+
+	return &monitoring.TimeSeries{
+		Metric: &monitoring.Metric{
+			Type:   uptimeCheckPassedMetric,
+			Labels: map[string]string{"check_id": checkID, "checker_location": checkerLocation},
+		},
+		Resource:   &monitoring.MonitoredResource{Type: "uptime_url", Labels: map[string]string{"project_id": "test-project"}},
+		MetricKind: "GAUGE",
+		ValueType:  "BOOL",
+		Points:     points,
+	}
+}
+
+func TestUptimeCheckCollector(t *testing.T) {
+	now := time.Now()
+	api := &fakeMonitoringAPI{
+		uptimeCheckConfigs: []*monitoring.UptimeCheckConfig{
+			{
+				Name:              "projects/test-project/uptimeCheckConfigs/homepage",
+				MonitoredResource: &monitoring.MonitoredResource{Type: "uptime_url", Labels: map[string]string{"host": "example.com"}},
+			},
+			{
+				Name:          "projects/test-project/uptimeCheckConfigs/backends",
+				ResourceGroup: &monitoring.ResourceGroup{GroupId: "backend-group", ResourceType: "INSTANCE"},
+			},
+			{
+				Name:              "projects/test-project/uptimeCheckConfigs/no-results",
+				MonitoredResource: &monitoring.MonitoredResource{Type: "uptime_url", Labels: map[string]string{"host": "example.org"}},
+			},
+		},
+		series: map[string][]*monitoring.TimeSeries{
+			uptimeCheckPassedMetric: {
+				// homepage recovered in every location
+				newCheckPassedTimeSeries("homepage", "usa-iowa", now, false, true),
+				newCheckPassedTimeSeries("homepage", "europe", now, true, true),
+				// backends is failing in one location
+				newCheckPassedTimeSeries("backends", "usa-iowa", now, true, true),
+				newCheckPassedTimeSeries("backends", "europe", now, true, false),
+			},
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	collector := NewUptimeCheckCollector("test-project", newFakeMonitoringService(t, api), 5*time.Minute, logger)
+
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	metrics := readMetrics(t, ch)
+
+	passing := map[string]float64{}
+	for _, m := range metrics["stackdriver_uptime_check_passing"] {
+		labels := labelsOf(m)
+		assert.Equal(t, "test-project", labels["project_id"])
+		passing[labels["check"]+"/"+labels["resource"]] = m.GetGauge().GetValue()
+	}
+	assert.Equal(t, map[string]float64{
+		"homepage/example.com":   1,
+		"backends/backend-group": 0,
+	}, passing)
+
+	require.Len(t, metrics["stackdriver_uptime_check_scrape_errors_total"], 1)
+	assert.Equal(t, float64(0), metrics["stackdriver_uptime_check_scrape_errors_total"][0].GetCounter().GetValue())
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+// configCheckProjectID is the project of the collector built to validate the collector options.
+const configCheckProjectID = "config-check"
+
+// filterRegexRE matches the monitoring.regex.full_match calls of a filter, capturing their quoted regular expression.
+var filterRegexRE = regexp.MustCompile(`monitoring\.regex\.full_match\(\s*"((?:[^"\\]|\\.)*)"\s*\)`)
+
+// checkConfig validates the flags and the config file, without calling the Monitoring API: the prefixes and their
+// profiles, the request interval and offset, the regular expressions of the extra filters, the aggregations, the label
+// derivations, the MQL queries, the credentials files and the options of the collectors. Every problem found is
+// returned.
+func checkConfig(logger *slog.Logger) error {
+	var errs []error
+
+	prefixes := flagMetricTypePrefixes()
+	profiles := flagMetricPrefixProfiles()
+	extraFilters := *monitoringMetricsExtraFilter
+	interval := *monitoringMetricsInterval
+	offset := *monitoringMetricsOffset
+	if *configFile != "" {
+		config, err := loadScrapeConfig(*configFile)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			if len(config.MetricsPrefixes) > 0 {
+				prefixes = config.MetricsPrefixes
+			}
+			if config.MetricsPrefixProfiles != nil {
+				profiles = config.MetricsPrefixProfiles
+			}
+			if config.ExtraFilters != nil {
+				extraFilters = config.ExtraFilters
+			}
+			if config.Interval != nil {
+				interval = *config.Interval
+			}
+			if config.Offset != nil {
+				offset = *config.Offset
+			}
+		}
+	}
+	if len(prefixes) == 0 {
+		errs = append(errs, errors.New("at least one GCP monitoring prefix is required"))
+	}
+	parsedProfiles := parseMetricPrefixProfiles(profiles)
+	for _, name := range slices.Sorted(maps.Keys(parsedProfiles)) {
+		if len(parsedProfiles[name]) == 0 {
+			errs = append(errs, fmt.Errorf("profile %q has no metric type prefix", name))
+		}
+	}
+	errs = append(errs, checkRequestWindow(interval, offset))
+	for _, filter := range extraFilters {
+		errs = append(errs, checkFilterRegexes(filter))
+	}
+	aggregations, err := parseMetricAggregations(*monitoringAggregations)
+	errs = append(errs, err)
+	labelDerivations, err := parseLabelDerivations(*monitoringDeriveLabels)
+	errs = append(errs, err)
+	_, err = collectors.ParseMQLQueries(*monitoringMQLQueries)
+	errs = append(errs, err)
+	errs = append(errs, checkCredentialsFiles())
+	// The projects of the projects filter are only known once listed, at startup
+	configuredProjectIDs := slices.Clone(*projectIDs)
+	if *projectID != "" {
+		configuredProjectIDs = append(configuredProjectIDs, strings.Split(*projectID, ",")...)
+	}
+	slices.Sort(configuredProjectIDs)
+	errs = append(errs, checkMetricsScopeProject(slices.Compact(configuredProjectIDs)))
+
+	// The collector options are validated by building a collector, which does not call the API until collected
+	h := newHandler(nil, parseMetricTypePrefixes(prefixes), parseMetricExtraFilters(extraFilters), aggregations, labelDerivations, nil, &monitoringServices{}, collectors.NewRetryBudget(0), logger, nil)
+	h.metricsInterval = interval
+	h.metricsOffset = offset
+	if _, err := h.getCollector(configCheckProjectID, "", nil); err != nil {
+		errs = append(errs, fmt.Errorf("invalid collector options: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// checkRequestWindow validates the request interval and offset: a positive interval and an offset between 0 and the
+// interval.
+func checkRequestWindow(interval, offset time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval %s, it must be positive", interval)
+	}
+	if offset < 0 {
+		return fmt.Errorf("invalid offset %s, it must not be negative", offset)
+	}
+	if offset >= interval {
+		return fmt.Errorf("invalid offset %s, it must be lower than the interval %s", offset, interval)
+	}
+	return nil
+}
+
+// checkMetricsScopeProject validates that a single project is collected when the time series are listed from a
+// metrics scope project, every collected project listing the same series from it otherwise.
+func checkMetricsScopeProject(projectIDs []string) error {
+	if *monitoringMetricsScopeProject != "" && len(projectIDs) > 1 {
+		return fmt.Errorf("invalid metrics scope project %q with %d projects, a single project must be collected", *monitoringMetricsScopeProject, len(projectIDs))
+	}
+	return nil
+}
+
+// checkFilterRegexes compiles the regular expressions of the monitoring.regex.full_match calls of an extra filter.
+// The Monitoring API uses the RE2 syntax of the regexp package.
+func checkFilterRegexes(filter string) error {
+	for _, m := range filterRegexRE.FindAllStringSubmatch(filter, -1) {
+		// The quotes and backslashes are escaped within the filter strings, the other escapes belong to the expression
+		expr := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(m[1])
+		if _, err := regexp.Compile(expr); err != nil {
+			return fmt.Errorf("invalid regular expression of extra filter %q: %w", filter, err)
+		}
+	}
+	return nil
+}
+
+// checkCredentialsFiles verifies that the credentials files of the projects, and the one of the
+// GOOGLE_APPLICATION_CREDENTIALS environment variable if set, exist.
+func checkCredentialsFiles() error {
+	var errs []error
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		if _, err := os.Stat(path); err != nil {
+			errs = append(errs, fmt.Errorf("invalid GOOGLE_APPLICATION_CREDENTIALS: %w", err))
+		}
+	}
+	projectIDs := make([]string, 0, len(*googleProjectCredentials))
+	for projectID := range *googleProjectCredentials {
+		projectIDs = append(projectIDs, projectID)
+	}
+	sort.Strings(projectIDs)
+	for _, projectID := range projectIDs {
+		if _, err := os.Stat((*googleProjectCredentials)[projectID]); err != nil {
+			errs = append(errs, fmt.Errorf("invalid credentials file of project %s: %w", projectID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
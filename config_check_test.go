@@ -0,0 +1,116 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestCheckConfig(t *testing.T) {
+	defer func(prefixes, filters, derivations, projects []string, scopeProject string, interval, offset time.Duration, credentials map[string]string) {
+		*monitoringMetricsPrefixes = prefixes
+		*monitoringMetricsExtraFilter = filters
+		*monitoringDeriveLabels = derivations
+		*projectIDs = projects
+		*monitoringMetricsScopeProject = scopeProject
+		*monitoringMetricsInterval = interval
+		*monitoringMetricsOffset = offset
+		*googleProjectCredentials = credentials
+	}(*monitoringMetricsPrefixes, *monitoringMetricsExtraFilter, *monitoringDeriveLabels, *projectIDs, *monitoringMetricsScopeProject, *monitoringMetricsInterval, *monitoringMetricsOffset, *googleProjectCredentials)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	for name, tc := range map[string]struct {
+		filters      []string
+		derivations  []string
+		projects     []string
+		scopeProject string
+		interval     time.Duration
+		offset       time.Duration
+		credentials  map[string]string
+		err          string
+	}{
+		"valid": {
+			filters:  []string{`pubsub.googleapis.com/subscription:resource.labels.subscription_id=monitoring.regex.full_match("us-west4.*my-subs\\.[0-9]+")`},
+			interval: 5 * time.Minute,
+			offset:   time.Minute,
+		},
+		"invalid regex": {
+			filters:  []string{`pubsub.googleapis.com/subscription:resource.labels.subscription_id=monitoring.regex.full_match("my-subs-(")`},
+			interval: 5 * time.Minute,
+			err:      "invalid regular expression",
+		},
+		"malformed label derivation": {
+			derivations: []string{"zone:region"},
+			interval:    5 * time.Minute,
+			err:         "invalid label derivation",
+		},
+		"invalid label derivation": {
+			derivations: []string{"zone:1region:(.+)-[a-z]:$1"},
+			interval:    5 * time.Minute,
+			err:         "invalid target label name",
+		},
+		"negative interval": {
+			interval: -time.Minute,
+			err:      "invalid interval",
+		},
+		"offset beyond interval": {
+			interval: time.Minute,
+			offset:   2 * time.Minute,
+			err:      "invalid offset",
+		},
+		"metrics scope project": {
+			projects:     []string{"my-project"},
+			scopeProject: "scoping-project",
+			interval:     5 * time.Minute,
+		},
+		"metrics scope project with several projects": {
+			projects:     []string{"my-project", "other-project"},
+			scopeProject: "scoping-project",
+			interval:     5 * time.Minute,
+			err:          "invalid metrics scope project",
+		},
+		"missing credentials file": {
+			interval:    5 * time.Minute,
+			credentials: map[string]string{"other-project": filepath.Join(t.TempDir(), "missing.json")},
+			err:         "invalid credentials file of project other-project",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			*monitoringMetricsPrefixes = []string{"compute.googleapis.com/instance/cpu"}
+			*monitoringMetricsExtraFilter = tc.filters
+			*monitoringDeriveLabels = tc.derivations
+			*projectIDs = tc.projects
+			*monitoringMetricsScopeProject = tc.scopeProject
+			*monitoringMetricsInterval = tc.interval
+			*monitoringMetricsOffset = tc.offset
+			*googleProjectCredentials = tc.credentials
+
+			err := checkConfig(promslog.NewNopLogger())
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("expected a valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected an error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// scrapeConfig is the config file of the options reloadable without a restart. Its unset fields keep the value of
+// their flags.
+type scrapeConfig struct {
+	MetricsPrefixes       []string            `yaml:"metrics_prefixes"`
+	MetricsPrefixProfiles map[string][]string `yaml:"metrics_prefix_profiles"`
+	ExtraFilters          []string            `yaml:"extra_filters"`
+	Interval              *time.Duration      `yaml:"interval"`
+	Offset                *time.Duration      `yaml:"offset"`
+}
+
+// loadScrapeConfig reads the config file at path, unknown fields being an error.
+func loadScrapeConfig(path string) (*scrapeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &scrapeConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("error parsing the config file %s: %w", path, err)
+	}
+	if config.Interval != nil && *config.Interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s of the config file %s, it must be positive", *config.Interval, path)
+	}
+	return config, nil
+}
+
+// reloadConfig re-reads the config file and swaps the metric type prefixes and profiles, extra filters, interval and
+// offset of the handler. The collectors pick the new options up on their next collection, the collections in flight
+// completing with the previous ones. The options are left untouched if the file is invalid.
+func (h *handler) reloadConfig(path string) error {
+	config, err := loadScrapeConfig(path)
+	if err != nil {
+		return err
+	}
+
+	prefixes := flagMetricTypePrefixes()
+	if len(config.MetricsPrefixes) > 0 {
+		prefixes = config.MetricsPrefixes
+	}
+	if len(prefixes) == 0 {
+		return errors.New("at least one GCP monitoring prefix is required")
+	}
+	profiles := flagMetricPrefixProfiles()
+	if config.MetricsPrefixProfiles != nil {
+		profiles = config.MetricsPrefixProfiles
+	}
+	extraFilters := *monitoringMetricsExtraFilter
+	if config.ExtraFilters != nil {
+		extraFilters = config.ExtraFilters
+	}
+	interval := *monitoringMetricsInterval
+	if config.Interval != nil {
+		interval = *config.Interval
+	}
+	offset := *monitoringMetricsOffset
+	if config.Offset != nil {
+		offset = *config.Offset
+	}
+
+	warnAmbiguousMetricTypePrefixes(h.logger, prefixes)
+	h.configMu.Lock()
+	defer h.configMu.Unlock()
+	h.metricsPrefixes = parseMetricTypePrefixes(prefixes)
+	h.metricsPrefixProfiles = parseMetricPrefixProfiles(profiles)
+	h.metricsExtraFilters = parseMetricExtraFilters(extraFilters)
+	h.metricsInterval = interval
+	h.metricsOffset = offset
+	h.logger.Info("Loaded the config file", "path", path, "metric_prefixes", fmt.Sprintf("%v", h.metricsPrefixes), "interval", interval, "offset", offset)
+	return nil
+}
+
+// reloadHandler reloads the config file on POST requests, answering with the error if the file is invalid.
+func reloadHandler(h *handler, path string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := h.reloadConfig(path); err != nil {
+			h.logger.Error("Error reloading the config file", "path", path, "err", err)
+			http.Error(w, fmt.Sprintf("failed to reload the config file: %s", err), http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintln(w, "OK")
+	}
+}
+
+// reloadOnSignal reloads the config file on every SIGHUP.
+func reloadOnSignal(h *handler, path string, logger *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		if err := h.reloadConfig(path); err != nil {
+			logger.Error("Error reloading the config file", "path", path, "err", err)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+func TestReloadConfig(t *testing.T) {
+	var mu sync.Mutex
+	var descriptorFilters []string
+	var interval time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			descriptorFilters = append(descriptorFilters, r.URL.Query().Get("filter"))
+			prefix := strings.TrimSuffix(strings.TrimPrefix(r.URL.Query().Get("filter"), `metric.type = starts_with("`), `")`)
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+				MetricDescriptors: []*monitoring.MetricDescriptor{{Type: prefix + "/utilization", MetricKind: "GAUGE", ValueType: "DOUBLE"}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			startTime, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("interval.startTime"))
+			endTime, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("interval.endTime"))
+			interval = endTime.Sub(startTime)
+			_ = json.NewEncoder(w).Encode(&monitoring.ListTimeSeriesResponse{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	// gather returns the descriptor filters requested by a collection and the interval of its time series requests
+	gather := func(collect func() error) ([]string, time.Duration) {
+		mu.Lock()
+		descriptorFilters = nil
+		mu.Unlock()
+		if err := collect(); err != nil {
+			t.Fatal(err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return descriptorFilters, interval
+	}
+	scrape := func() ([]string, time.Duration) {
+		return gather(func() error {
+			_, err := h.innerGatherer(context.Background(), "", nil).Gather()
+			return err
+		})
+	}
+	// The push gatherer is created once at startup, before any reload
+	pushGatherer := h.pushGatherer(context.Background())
+	reload := func(method string) int {
+		recorder := httptest.NewRecorder()
+		reloadHandler(h, filepath.Join(t.TempDir(), "missing.yml")).ServeHTTP(recorder, httptest.NewRequest(method, "/-/reload", nil))
+		return recorder.Code
+	}
+
+	if filters, got := scrape(); !reflect.DeepEqual(filters, []string{`metric.type = starts_with("compute.googleapis.com/instance/cpu")`}) || got != *monitoringMetricsInterval {
+		t.Fatalf("expected the flag prefix and interval before reloading, got %v and %s", filters, got)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("metrics_prefixes:\n  - compute.googleapis.com/instance/disk/\ninterval: 10m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	reloadHandler(h, path).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the reload to succeed, got %d: %s", recorder.Code, recorder.Body)
+	}
+	pushed, got := gather(func() error {
+		_, err := pushGatherer.Gather()
+		return err
+	})
+	if !reflect.DeepEqual(pushed, []string{`metric.type = starts_with("compute.googleapis.com/instance/disk")`}) || got != 10*time.Minute {
+		t.Errorf("expected the next push to use the reloaded prefix and interval, got %v and %s", pushed, got)
+	}
+	if filters, got := scrape(); !reflect.DeepEqual(filters, []string{`metric.type = starts_with("compute.googleapis.com/instance/disk")`}) || got != 10*time.Minute {
+		t.Errorf("expected the next collection to use the reloaded prefix and interval, got %v and %s", filters, got)
+	}
+	cached, got := gather(func() error {
+		_, err := h.collectStackdriverMetrics(context.Background())
+		return err
+	})
+	if !reflect.DeepEqual(cached, []string{`metric.type = starts_with("compute.googleapis.com/instance/disk")`}) || got != 10*time.Minute {
+		t.Errorf("expected the next cache refresh to use the reloaded prefix and interval, got %v and %s", cached, got)
+	}
+
+	if code := reload(http.MethodGet); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected GET requests to be rejected, got %d", code)
+	}
+	if code := reload(http.MethodPost); code != http.StatusInternalServerError {
+		t.Errorf("expected the reload of a missing file to fail, got %d", code)
+	}
+	if filters, _ := scrape(); !reflect.DeepEqual(filters, []string{`metric.type = starts_with("compute.googleapis.com/instance/disk")`}) {
+		t.Errorf("expected a failed reload to keep the options, got %v", filters)
+	}
+}
+
+func TestLoadScrapeConfig(t *testing.T) {
+	for name, tc := range map[string]struct {
+		content string
+		valid   bool
+	}{
+		"empty":         {content: "", valid: true},
+		"every field":   {content: "metrics_prefixes: [a]\nmetrics_prefix_profiles: {cheap: [a/b]}\nextra_filters: [\"a:b\"]\ninterval: 1m\noffset: 30s\n", valid: true},
+		"unknown field": {content: "metrics_prefix: [a]\n"},
+		"zero interval": {content: "interval: 0s\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := loadScrapeConfig(path); (err == nil) != tc.valid {
+				t.Errorf("expected valid %v, got error %v", tc.valid, err)
+			}
+		})
+	}
+}
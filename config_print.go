@@ -0,0 +1,99 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+// redactedValue replaces the value of the secret flags in the printed configuration.
+const redactedValue = "<redacted>"
+
+// secretFlags are the flags whose value is redacted from the printed configuration: the credentials file paths and
+// the proxy password.
+var secretFlags = map[string]bool{
+	"google.project-credentials": true,
+	"google.http-proxy-password": true,
+}
+
+// urlFlags are the flags whose value is a URL, its userinfo being redacted from the printed configuration.
+var urlFlags = map[string]bool{
+	"google.http-proxy": true,
+}
+
+// effectiveConfig is the configuration in effect once the flags are parsed, their environment variables and
+// defaults applied.
+type effectiveConfig struct {
+	Flags            map[string]string `json:"flags"`
+	CollectorOptions map[string]any    `json:"collector_options"`
+}
+
+// marshalEffectiveConfig returns the values of the flags of the application and the collector options as JSON, the
+// secret flags being redacted. The durations are reported as strings, e.g. 1m0s, and the compiled regular expressions,
+// as text marshalers, as their source strings.
+func marshalEffectiveConfig(app *kingpin.Application, opts collectors.MonitoringCollectorOptions) ([]byte, error) {
+	config := effectiveConfig{Flags: map[string]string{}, CollectorOptions: map[string]any{}}
+	for _, flag := range app.Model().Flags {
+		if flag.Hidden {
+			continue
+		}
+		value := flag.Value.String()
+		if secretFlags[flag.Name] {
+			value = redactedValue
+		} else if urlFlags[flag.Name] {
+			value = redactUserinfo(value)
+		}
+		config.Flags[flag.Name] = value
+	}
+
+	v := reflect.ValueOf(opts)
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch value := v.Field(i).Interface().(type) {
+		case *collectors.RetryBudget, *collectors.Readiness, *collectors.CircuitBreakers:
+			// State shared by the collectors, not configuration
+		case time.Duration:
+			config.CollectorOptions[name] = value.String()
+		default:
+			config.CollectorOptions[name] = value
+		}
+	}
+	return json.Marshal(config)
+}
+
+// redactUserinfo returns the URL with its userinfo, if any, replaced by the redacted value, e.g.
+// http://<redacted>@proxy:3128. A value which is not a URL with a host is redacted entirely, as it may hold
+// credentials too.
+func redactUserinfo(value string) string {
+	if value == "" {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	if u.User == nil {
+		return value
+	}
+	u.User = nil
+	return strings.Replace(u.String(), "//", "//"+redactedValue+"@", 1)
+}
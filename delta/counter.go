@@ -14,7 +14,9 @@
 package delta
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
 	"log/slog"
 	"sort"
 	"strings"
@@ -67,6 +69,16 @@ func (s *InMemoryCounterStore) Increment(metricDescriptor *monitoring.MetricDesc
 	existing := entry.Collected[key]
 
 	if existing == nil {
+		// The counter covers the interval of its first point, unless it starts at zero once the point is dropped
+		if currentValue.CreatedTime.IsZero() || currentValue.StartsAtZero {
+			currentValue.CreatedTime = currentValue.ReportTime
+		}
+		if currentValue.StartsAtZero {
+			s.logger.Debug("Tracking new counter from zero", "fqName", currentValue.FqName, "key", key, "dropped_value", currentValue.Value, "incoming_time", currentValue.ReportTime)
+			currentValue.Value = 0
+			entry.Collected[key] = currentValue
+			return
+		}
 		s.logger.Debug("Tracking new counter", "fqName", currentValue.FqName, "key", key, "current_value", currentValue.Value, "incoming_time", currentValue.ReportTime)
 		entry.Collected[key] = currentValue
 		return
@@ -75,6 +87,7 @@ func (s *InMemoryCounterStore) Increment(metricDescriptor *monitoring.MetricDesc
 	if existing.ReportTime.Before(currentValue.ReportTime) {
 		s.logger.Debug("Incrementing existing counter", "fqName", currentValue.FqName, "key", key, "current_value", existing.Value, "adding", currentValue.Value, "last_reported_time", existing.ReportTime, "incoming_time", currentValue.ReportTime)
 		currentValue.Value = currentValue.Value + existing.Value
+		currentValue.CreatedTime = existing.CreatedTime
 		entry.Collected[key] = currentValue
 		return
 	}
@@ -129,3 +142,78 @@ func (s *InMemoryCounterStore) ListMetrics(metricDescriptorName string) []*colle
 
 	return output
 }
+
+// EvictBefore removes the counter entries last collected before the given time, whatever their metric descriptor,
+// and returns how many were removed. A removed series starts a new accumulation if it reappears.
+func (s *InMemoryCounterStore) EvictBefore(t time.Time) int {
+	evicted := 0
+	s.store.Range(func(_, value any) bool {
+		entry := value.(*MetricEntry)
+		entry.mutex.Lock()
+		defer entry.mutex.Unlock()
+		for key, collected := range entry.Collected {
+			if t.After(collected.CollectionTime) {
+				s.logger.Debug("Evicting counter entry", "key", key, "fqName", collected.FqName, "collection_time", collected.CollectionTime)
+				delete(entry.Collected, key)
+				evicted++
+			}
+		}
+		return true
+	})
+	return evicted
+}
+
+// Len returns the number of counter entries in the store.
+func (s *InMemoryCounterStore) Len() int {
+	entries := 0
+	s.store.Range(func(_, value any) bool {
+		entry := value.(*MetricEntry)
+		entry.mutex.RLock()
+		defer entry.mutex.RUnlock()
+		entries += len(entry.Collected)
+		return true
+	})
+	return entries
+}
+
+// Snapshot writes the counter entries of the store to w, in a format read back by Restore.
+func (s *InMemoryCounterStore) Snapshot(w io.Writer) error {
+	snapshot := map[string]map[uint64]*collectors.ConstMetric{}
+	s.store.Range(func(name, value any) bool {
+		entry := value.(*MetricEntry)
+		entry.mutex.RLock()
+		defer entry.mutex.RUnlock()
+		collected := make(map[uint64]*collectors.ConstMetric, len(entry.Collected))
+		for key, metric := range entry.Collected {
+			collected[key] = metric
+		}
+		snapshot[name.(string)] = collected
+		return true
+	})
+	if err := gob.NewEncoder(w).Encode(snapshot); err != nil {
+		return fmt.Errorf("error writing the counter store snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore reads the counter entries of a snapshot written by Snapshot from r into the store, replacing the entries of
+// the same series. The entries are evicted as usual once outside the TTL.
+func (s *InMemoryCounterStore) Restore(r io.Reader) error {
+	var snapshot map[string]map[uint64]*collectors.ConstMetric
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("error reading the counter store snapshot: %w", err)
+	}
+	for name, collected := range snapshot {
+		tmp, _ := s.store.LoadOrStore(name, &MetricEntry{
+			Collected: map[uint64]*collectors.ConstMetric{},
+			mutex:     &sync.RWMutex{},
+		})
+		entry := tmp.(*MetricEntry)
+		entry.mutex.Lock()
+		for key, metric := range collected {
+			entry.Collected[key] = metric
+		}
+		entry.mutex.Unlock()
+	}
+	return nil
+}
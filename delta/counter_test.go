@@ -14,6 +14,8 @@
 package delta_test
 
 import (
+	"bytes"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -81,4 +83,133 @@ var _ = Describe("Counter", func() {
 		metrics := store.ListMetrics(descriptor.Name)
 		Expect(len(metrics)).To(Equal(0))
 	})
+
+	It("will evict counters not collected since the given time", func() {
+		stale := &collectors.ConstMetric{
+			FqName:         "other_counter_name",
+			LabelKeys:      []string{"labelKey"},
+			ValueType:      1,
+			Value:          5,
+			LabelValues:    []string{"labelValue"},
+			ReportTime:     metric.ReportTime,
+			CollectionTime: metric.CollectionTime.Add(-10 * time.Minute),
+		}
+		store.Increment(descriptor, metric)
+		store.Increment(&monitoring.MetricDescriptor{Name: "This is another metric"}, stale)
+		Expect(store.Len()).To(Equal(2))
+
+		Expect(store.EvictBefore(metric.CollectionTime.Add(-5 * time.Minute))).To(Equal(1))
+		Expect(store.Len()).To(Equal(1))
+		Expect(store.ListMetrics(descriptor.Name)).To(HaveLen(1))
+	})
+
+	It("will start evicted counters fresh when they reappear", func() {
+		store.Increment(descriptor, metric)
+		Expect(store.EvictBefore(metric.CollectionTime.Add(time.Second))).To(Equal(1))
+
+		reappeared := *metric
+		reappeared.Value = 3
+		reappeared.ReportTime = metric.ReportTime.Add(time.Second)
+		store.Increment(descriptor, &reappeared)
+
+		metrics := store.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Value).To(Equal(float64(3)))
+	})
+
+	It("starts new ephemeral counters from zero and accumulates existing ones", func() {
+		metric.StartsAtZero = true
+		store.Increment(descriptor, metric)
+		metrics := store.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Value).To(Equal(float64(0)))
+
+		next := *metric
+		next.Value = 20
+		next.ReportTime = metric.ReportTime.Add(time.Second)
+		store.Increment(descriptor, &next)
+		metrics = store.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Value).To(Equal(float64(20)))
+
+		pod := *metric
+		pod.LabelValues = []string{"otherValue"}
+		pod.Value = 7
+		pod.ReportTime = next.ReportTime
+		store.Increment(descriptor, &pod)
+		values := map[string]float64{}
+		for _, m := range store.ListMetrics(descriptor.Name) {
+			values[m.LabelValues[0]] = m.Value
+		}
+		Expect(values).To(Equal(map[string]float64{"labelValue": 20, "otherValue": 0}))
+	})
+
+	It("keeps the interval start of the first accumulation as created time until reset", func() {
+		createdTime := metric.ReportTime.Add(-time.Minute)
+		metric.CreatedTime = createdTime
+		store.Increment(descriptor, metric)
+
+		for i := 1; i <= 2; i++ {
+			next := *metric
+			next.ReportTime = metric.ReportTime.Add(time.Duration(i) * time.Second)
+			next.CreatedTime = next.ReportTime.Add(-time.Second)
+			store.Increment(descriptor, &next)
+
+			metrics := store.ListMetrics(descriptor.Name)
+			Expect(len(metrics)).To(Equal(1))
+			Expect(metrics[0].CreatedTime).To(BeTemporally("==", createdTime))
+		}
+
+		Expect(store.EvictBefore(metric.CollectionTime.Add(time.Second))).To(Equal(1))
+		reappeared := *metric
+		reappeared.ReportTime = metric.ReportTime.Add(time.Minute)
+		reappeared.CreatedTime = reappeared.ReportTime.Add(-time.Minute)
+		store.Increment(descriptor, &reappeared)
+		metrics := store.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].CreatedTime).To(BeTemporally("==", reappeared.ReportTime.Add(-time.Minute)))
+	})
+
+	It("falls back to the report time as created time", func() {
+		store.Increment(descriptor, metric)
+		Expect(store.ListMetrics(descriptor.Name)[0].CreatedTime).To(BeTemporally("==", metric.ReportTime))
+
+		ephemeral := *metric
+		ephemeral.LabelValues = []string{"otherValue"}
+		ephemeral.CreatedTime = metric.ReportTime.Add(-time.Minute)
+		ephemeral.StartsAtZero = true
+		store.Increment(descriptor, &ephemeral)
+		for _, m := range store.ListMetrics(descriptor.Name) {
+			Expect(m.CreatedTime).To(BeTemporally("==", metric.ReportTime), "a counter starting at zero leaves its first interval out")
+		}
+	})
+
+	It("can restore accumulated counters from a snapshot", func() {
+		store.Increment(descriptor, metric)
+		accumulated := *metric
+		accumulated.Value = 20
+		accumulated.ReportTime = metric.ReportTime.Add(time.Second)
+		store.Increment(descriptor, &accumulated)
+
+		var snapshot bytes.Buffer
+		Expect(store.Snapshot(&snapshot)).To(Succeed())
+
+		restored := delta.NewInMemoryCounterStore(promslog.New(&promslog.Config{}), time.Minute)
+		Expect(restored.Restore(&snapshot)).To(Succeed())
+		metrics := restored.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Value).To(Equal(float64(30)))
+		Expect(metrics[0].LabelValues).To(Equal(metric.LabelValues))
+		Expect(metrics[0].ReportTime).To(BeTemporally("==", accumulated.ReportTime))
+
+		next := *metric
+		next.Value = 5
+		next.ReportTime = accumulated.ReportTime.Add(time.Second)
+		restored.Increment(descriptor, &next)
+		Expect(restored.ListMetrics(descriptor.Name)[0].Value).To(Equal(float64(35)))
+	})
+
+	It("will fail to restore an invalid snapshot", func() {
+		Expect(store.Restore(strings.NewReader("not a snapshot"))).NotTo(Succeed())
+	})
 })
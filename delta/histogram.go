@@ -14,7 +14,9 @@
 package delta
 
 import (
+	"encoding/gob"
 	"fmt"
+	"io"
 	"log/slog"
 	"sort"
 	"strings"
@@ -73,6 +75,13 @@ func (s *InMemoryHistogramStore) Increment(metricDescriptor *monitoring.MetricDe
 	}
 
 	if existing.ReportTime.Before(currentValue.ReportTime) {
+		if !sameBucketBounds(existing.Buckets, currentValue.Buckets) {
+			// Buckets with different bounds can't be summed, restart the accumulation from the new distribution
+			s.logger.Debug("Resetting histogram with changed bucket bounds", "fqName", currentValue.FqName, "key", key, "last_reported_time", existing.ReportTime, "incoming_time", currentValue.ReportTime)
+			entry.Collected[key] = currentValue
+			return
+		}
+
 		s.logger.Debug("Incrementing existing histogram", "fqName", currentValue.FqName, "key", key, "last_reported_time", existing.ReportTime, "incoming_time", currentValue.ReportTime)
 		currentValue.MergeHistogram(existing)
 		// Replace the existing histogram by the new one after merging it.
@@ -83,6 +92,19 @@ func (s *InMemoryHistogramStore) Increment(metricDescriptor *monitoring.MetricDe
 	s.logger.Debug("Ignoring old sample for histogram", "fqName", currentValue.FqName, "key", key, "last_reported_time", existing.ReportTime, "incoming_time", currentValue.ReportTime)
 }
 
+// sameBucketBounds reports whether both histograms have buckets with the same upper bounds.
+func sameBucketBounds(a, b map[float64]uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for bound := range a {
+		if _, ok := b[bound]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func toHistogramKey(hist *collectors.HistogramMetric) uint64 {
 	labels := make(map[string]string)
 	keysCopy := append([]string{}, hist.LabelKeys...)
@@ -129,3 +151,78 @@ func (s *InMemoryHistogramStore) ListMetrics(metricDescriptorName string) []*col
 
 	return output
 }
+
+// EvictBefore removes the histogram entries last collected before the given time, whatever their metric descriptor,
+// and returns how many were removed. A removed series starts a new accumulation if it reappears.
+func (s *InMemoryHistogramStore) EvictBefore(t time.Time) int {
+	evicted := 0
+	s.store.Range(func(_, value any) bool {
+		entry := value.(*HistogramEntry)
+		entry.mutex.Lock()
+		defer entry.mutex.Unlock()
+		for key, collected := range entry.Collected {
+			if t.After(collected.CollectionTime) {
+				s.logger.Debug("Evicting histogram entry", "key", key, "fqName", collected.FqName, "collection_time", collected.CollectionTime)
+				delete(entry.Collected, key)
+				evicted++
+			}
+		}
+		return true
+	})
+	return evicted
+}
+
+// Len returns the number of histogram entries in the store.
+func (s *InMemoryHistogramStore) Len() int {
+	entries := 0
+	s.store.Range(func(_, value any) bool {
+		entry := value.(*HistogramEntry)
+		entry.mutex.RLock()
+		defer entry.mutex.RUnlock()
+		entries += len(entry.Collected)
+		return true
+	})
+	return entries
+}
+
+// Snapshot writes the histogram entries of the store to w, in a format read back by Restore.
+func (s *InMemoryHistogramStore) Snapshot(w io.Writer) error {
+	snapshot := map[string]map[uint64]*collectors.HistogramMetric{}
+	s.store.Range(func(name, value any) bool {
+		entry := value.(*HistogramEntry)
+		entry.mutex.RLock()
+		defer entry.mutex.RUnlock()
+		collected := make(map[uint64]*collectors.HistogramMetric, len(entry.Collected))
+		for key, metric := range entry.Collected {
+			collected[key] = metric
+		}
+		snapshot[name.(string)] = collected
+		return true
+	})
+	if err := gob.NewEncoder(w).Encode(snapshot); err != nil {
+		return fmt.Errorf("error writing the histogram store snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore reads the histogram entries of a snapshot written by Snapshot from r into the store, replacing the entries of
+// the same series. The entries are evicted as usual once outside the TTL.
+func (s *InMemoryHistogramStore) Restore(r io.Reader) error {
+	var snapshot map[string]map[uint64]*collectors.HistogramMetric
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return fmt.Errorf("error reading the histogram store snapshot: %w", err)
+	}
+	for name, collected := range snapshot {
+		tmp, _ := s.store.LoadOrStore(name, &HistogramEntry{
+			Collected: map[uint64]*collectors.HistogramMetric{},
+			mutex:     &sync.RWMutex{},
+		})
+		entry := tmp.(*HistogramEntry)
+		entry.mutex.Lock()
+		for key, metric := range collected {
+			entry.Collected[key] = metric
+		}
+		entry.mutex.Unlock()
+	}
+	return nil
+}
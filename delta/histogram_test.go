@@ -14,6 +14,7 @@
 package delta_test
 
 import (
+	"bytes"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -83,6 +84,57 @@ var _ = Describe("HistogramStore", func() {
 		Expect(histogram.Buckets[bucketKey]).To(Equal(bucketValue * 2))
 	})
 
+	It("can accumulate consecutive delta distributions into cumulative buckets", func() {
+		histogram.Count = 6
+		histogram.Sum = 30
+		histogram.Buckets = map[float64]uint64{1: 1, 10: 4, 100: 6}
+		store.Increment(descriptor, histogram)
+
+		nextValue := &collectors.HistogramMetric{
+			FqName:         "histogram_name",
+			LabelKeys:      []string{"labelKey"},
+			Sum:            50,
+			Count:          5,
+			Buckets:        map[float64]uint64{1: 0, 10: 2, 100: 5},
+			LabelValues:    []string{"labelValue"},
+			ReportTime:     histogram.ReportTime.Add(time.Minute),
+			CollectionTime: time.Now().Truncate(time.Second),
+			KeysHash:       8765,
+		}
+		store.Increment(descriptor, nextValue)
+
+		metrics := store.ListMetrics(descriptor.Name)
+
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Count).To(Equal(uint64(11)))
+		Expect(metrics[0].Sum).To(Equal(80.0))
+		Expect(metrics[0].Buckets).To(Equal(map[float64]uint64{1: 1, 10: 6, 100: 11}))
+	})
+
+	It("will reset histograms when bucket bounds change", func() {
+		store.Increment(descriptor, histogram)
+
+		nextValue := &collectors.HistogramMetric{
+			FqName:         "histogram_name",
+			LabelKeys:      []string{"labelKey"},
+			Sum:            5,
+			Count:          3,
+			Buckets:        map[float64]uint64{2: 1, 20: 3},
+			LabelValues:    []string{"labelValue"},
+			ReportTime:     histogram.ReportTime.Add(time.Minute),
+			CollectionTime: time.Now().Truncate(time.Second),
+			KeysHash:       8765,
+		}
+		store.Increment(descriptor, nextValue)
+
+		metrics := store.ListMetrics(descriptor.Name)
+
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Count).To(Equal(uint64(3)))
+		Expect(metrics[0].Sum).To(Equal(5.0))
+		Expect(metrics[0].Buckets).To(Equal(map[float64]uint64{2: 1, 20: 3}))
+	})
+
 	It("will remove histograms outside of TTL", func() {
 		histogram.CollectionTime = histogram.CollectionTime.Add(-time.Hour)
 
@@ -91,4 +143,50 @@ var _ = Describe("HistogramStore", func() {
 		metrics := store.ListMetrics(descriptor.Name)
 		Expect(len(metrics)).To(Equal(0))
 	})
+
+	It("will evict histograms not collected since the given time", func() {
+		store.Increment(descriptor, histogram)
+		Expect(store.Len()).To(Equal(1))
+
+		Expect(store.EvictBefore(histogram.CollectionTime)).To(Equal(0))
+		Expect(store.EvictBefore(histogram.CollectionTime.Add(time.Second))).To(Equal(1))
+		Expect(store.Len()).To(Equal(0))
+	})
+
+	It("will start evicted histograms fresh when they reappear", func() {
+		store.Increment(descriptor, histogram)
+		store.EvictBefore(histogram.CollectionTime.Add(time.Second))
+
+		reappeared := *histogram
+		reappeared.Count = 1
+		reappeared.Sum = 2
+		reappeared.Buckets = map[float64]uint64{bucketKey: 1}
+		reappeared.ReportTime = histogram.ReportTime.Add(time.Second)
+		store.Increment(descriptor, &reappeared)
+
+		metrics := store.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Count).To(Equal(uint64(1)))
+		Expect(metrics[0].Sum).To(Equal(2.0))
+	})
+
+	It("can restore accumulated histograms from a snapshot", func() {
+		store.Increment(descriptor, histogram)
+		accumulated := *histogram
+		accumulated.Buckets = map[float64]uint64{bucketKey: 1}
+		accumulated.Count = 1
+		accumulated.ReportTime = histogram.ReportTime.Add(time.Second)
+		store.Increment(descriptor, &accumulated)
+
+		var snapshot bytes.Buffer
+		Expect(store.Snapshot(&snapshot)).To(Succeed())
+
+		restored := delta.NewInMemoryHistogramStore(promslog.New(&promslog.Config{}), time.Minute)
+		Expect(restored.Restore(&snapshot)).To(Succeed())
+		metrics := restored.ListMetrics(descriptor.Name)
+		Expect(len(metrics)).To(Equal(1))
+		Expect(metrics[0].Count).To(Equal(uint64(101)))
+		Expect(metrics[0].Buckets).To(Equal(map[float64]uint64{bucketKey: bucketValue + 1}))
+		Expect(metrics[0].ReportTime).To(BeTemporally("==", accumulated.ReportTime))
+	})
 })
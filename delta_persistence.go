@@ -0,0 +1,164 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus-community/stackdriver_exporter/delta"
+)
+
+// deltaStores are the delta stores of a collector.
+type deltaStores struct {
+	counter   *delta.InMemoryCounterStore
+	histogram *delta.InMemoryHistogramStore
+}
+
+// deltaStoresSnapshot is the snapshot of the delta stores of a collector.
+type deltaStoresSnapshot struct {
+	Counter   []byte
+	Histogram []byte
+}
+
+// deltaPersistence keeps the delta stores of the collectors by collector key, to save them to a snapshot file on
+// shutdown and restore them from it on startup. The accumulated DELTA metrics then survive a restart instead of
+// being reported as counter resets.
+type deltaPersistence struct {
+	path   string
+	ttl    time.Duration
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	stores map[string]*deltaStores
+	// restored are the snapshots loaded on startup of the collectors not created yet
+	restored map[string]deltaStoresSnapshot
+}
+
+// newDeltaPersistence returns a deltaPersistence saving the stores to path, restoring the snapshot already there if
+// any. A snapshot that can't be read is logged and ignored, the stores starting empty.
+func newDeltaPersistence(path string, ttl time.Duration, logger *slog.Logger) *deltaPersistence {
+	p := &deltaPersistence{
+		path:     path,
+		ttl:      ttl,
+		logger:   logger.With("component", "delta_persistence", "path", path),
+		stores:   map[string]*deltaStores{},
+		restored: map[string]deltaStoresSnapshot{},
+	}
+	if err := p.load(); err != nil {
+		p.logger.Warn("Ignoring the delta stores snapshot", "err", err)
+	}
+	return p
+}
+
+func (p *deltaPersistence) load() error {
+	f, err := os.Open(p.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&p.restored); err != nil {
+		return fmt.Errorf("error reading the snapshot: %w", err)
+	}
+	p.logger.Info("Loaded the delta stores snapshot", "collectors", len(p.restored))
+	return nil
+}
+
+// deltaStores returns the delta stores of the collector, restored from the snapshot the first time they are
+// requested.
+func (p *deltaPersistence) deltaStores(collectorKey string) *deltaStores {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if stores, ok := p.stores[collectorKey]; ok {
+		return stores
+	}
+
+	stores := &deltaStores{
+		counter:   delta.NewInMemoryCounterStore(p.logger, p.ttl),
+		histogram: delta.NewInMemoryHistogramStore(p.logger, p.ttl),
+	}
+	if snapshot, ok := p.restored[collectorKey]; ok {
+		delete(p.restored, collectorKey)
+		if err := stores.counter.Restore(bytes.NewReader(snapshot.Counter)); err != nil {
+			p.logger.Warn("Ignoring the counter store snapshot", "collector", collectorKey, "err", err)
+		}
+		if err := stores.histogram.Restore(bytes.NewReader(snapshot.Histogram)); err != nil {
+			p.logger.Warn("Ignoring the histogram store snapshot", "collector", collectorKey, "err", err)
+		}
+	}
+	p.stores[collectorKey] = stores
+	return stores
+}
+
+// forget drops the delta stores of a collector evicted from the collector cache, which are no longer saved. A
+// collector created again for the key starts with empty stores, as without the persistence.
+func (p *deltaPersistence) forget(collectorKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.stores, collectorKey)
+}
+
+// save writes the snapshot of every delta store to the file, replacing it atomically. The snapshots restored on
+// startup of collectors not created since are kept.
+func (p *deltaPersistence) save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	snapshots := make(map[string]deltaStoresSnapshot, len(p.stores)+len(p.restored))
+	for collectorKey, snapshot := range p.restored {
+		snapshots[collectorKey] = snapshot
+	}
+	for collectorKey, stores := range p.stores {
+		var counter, histogram bytes.Buffer
+		if err := stores.counter.Snapshot(&counter); err != nil {
+			return err
+		}
+		if err := stores.histogram.Snapshot(&histogram); err != nil {
+			return err
+		}
+		snapshots[collectorKey] = deltaStoresSnapshot{Counter: counter.Bytes(), Histogram: histogram.Bytes()}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(snapshots); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing the snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return err
+	}
+	p.logger.Info("Saved the delta stores snapshot", "collectors", len(snapshots))
+	return nil
+}
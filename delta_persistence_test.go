@@ -0,0 +1,109 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+func TestDeltaPersistence(t *testing.T) {
+	logger := promslog.NewNopLogger()
+	path := filepath.Join(t.TempDir(), "deltas.snapshot")
+	descriptor := &monitoring.MetricDescriptor{Name: "projects/test-project/metricDescriptors/custom.googleapis.com/requests"}
+	now := time.Now().Truncate(time.Second)
+
+	persistence := newDeltaPersistence(path, time.Hour, logger)
+	stores := persistence.deltaStores("test-project-[custom.googleapis.com]")
+	for i, value := range []float64{10, 20} {
+		stores.counter.Increment(descriptor, &collectors.ConstMetric{
+			FqName:         "stackdriver_gce_instance_custom_googleapis_com_requests",
+			LabelKeys:      []string{"code"},
+			LabelValues:    []string{"200"},
+			Value:          value,
+			ReportTime:     now.Add(time.Duration(i) * time.Second),
+			CollectionTime: now,
+		})
+	}
+	stores.histogram.Increment(descriptor, &collectors.HistogramMetric{
+		FqName:         "stackdriver_gce_instance_custom_googleapis_com_latencies",
+		Count:          3,
+		Buckets:        map[float64]uint64{1: 1, 2: 3},
+		ReportTime:     now,
+		CollectionTime: now,
+	})
+	if persistence.deltaStores("test-project-[custom.googleapis.com]") != stores {
+		t.Fatal("expected the stores of a collector to be kept")
+	}
+	if err := persistence.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := newDeltaPersistence(path, time.Hour, logger)
+	restored := restarted.deltaStores("test-project-[custom.googleapis.com]")
+	counters := restored.counter.ListMetrics(descriptor.Name)
+	if len(counters) != 1 || counters[0].Value != 30 {
+		t.Fatalf("expected the accumulated counter to be restored, got %+v", counters)
+	}
+	histograms := restored.histogram.ListMetrics(descriptor.Name)
+	if len(histograms) != 1 || histograms[0].Count != 3 || histograms[0].Buckets[2] != 3 {
+		t.Fatalf("expected the accumulated histogram to be restored, got %+v", histograms)
+	}
+	if other := restarted.deltaStores("other-project-[custom.googleapis.com]"); other.counter.Len() != 0 {
+		t.Errorf("expected the stores of another collector to start empty, got %d entries", other.counter.Len())
+	}
+
+	// The snapshot of a collector not created since the restart is saved again
+	if err := newDeltaPersistence(path, time.Hour, logger).save(); err != nil {
+		t.Fatal(err)
+	}
+	if counters := newDeltaPersistence(path, time.Hour, logger).deltaStores("test-project-[custom.googleapis.com]").counter.ListMetrics(descriptor.Name); len(counters) != 1 {
+		t.Errorf("expected the restored snapshot to be kept, got %+v", counters)
+	}
+}
+
+func TestDeltaPersistenceInvalidSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deltas.snapshot")
+	if err := os.WriteFile(path, []byte("not a snapshot"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := newDeltaPersistence(path, time.Hour, promslog.NewNopLogger()).deltaStores("test-project")
+	if stores.counter.Len() != 0 || stores.histogram.Len() != 0 {
+		t.Error("expected an invalid snapshot to start with empty stores")
+	}
+}
+
+func TestDeltaPersistenceForget(t *testing.T) {
+	persistence := newDeltaPersistence(filepath.Join(t.TempDir(), "deltas.snapshot"), time.Hour, promslog.NewNopLogger())
+	cache := collectors.NewCollectorCache(time.Millisecond)
+	cache.OnEvict(persistence.forget)
+
+	stores := persistence.deltaStores("test-project")
+	cache.Store("test-project", nil)
+	time.Sleep(5 * time.Millisecond)
+	if _, found := cache.Get("test-project"); found {
+		t.Fatal("expected the collector to have expired")
+	}
+	if persistence.deltaStores("test-project") == stores {
+		t.Error("expected the stores of an evicted collector to be dropped")
+	}
+}
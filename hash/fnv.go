@@ -28,6 +28,15 @@ func New() uint64 {
 	return offset64
 }
 
+// NewWithSeed initializes a new fnv64a hash value diversified by a seed, hashes of a same input with different
+// seeds being unrelated. The zero seed gives the value of New.
+func NewWithSeed(seed uint64) uint64 {
+	if seed == 0 {
+		return offset64
+	}
+	return AddUint64(offset64, seed)
+}
+
 // Add adds a string to a fnv64a hash value, returning the updated hash.
 func Add(h uint64, s string) uint64 {
 	for i := 0; i < len(s); i++ {
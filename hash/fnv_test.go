@@ -83,6 +83,27 @@ func TestAddUint64VsOldImplementation(t *testing.T) {
 	t.Logf("Old (incorrect) implementation: %d", oldResult)
 }
 
+func TestNewWithSeed(t *testing.T) {
+	if NewWithSeed(0) != New() {
+		t.Errorf("NewWithSeed(0) = %d, expected the unseeded New() = %d", NewWithSeed(0), New())
+	}
+
+	input := "test_metric"
+	unseeded := Add(New(), input)
+	seeded := Add(NewWithSeed(42), input)
+	if seeded == unseeded {
+		t.Errorf("seeded and unseeded hashes should differ, both gave %d", seeded)
+	}
+	if other := Add(NewWithSeed(43), input); other == seeded {
+		t.Errorf("hashes of different seeds should differ, both gave %d", seeded)
+	}
+
+	// The seeded hash is deterministic
+	if again := Add(NewWithSeed(42), input); again != seeded {
+		t.Errorf("NewWithSeed is not deterministic: got %d and %d", seeded, again)
+	}
+}
+
 func BenchmarkAddUint64(b *testing.B) {
 	h := New()
 	testValue := uint64(1694174400000000000)
@@ -0,0 +1,111 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	stdhash "hash"
+)
+
+// Hash algorithms of the hashers.
+const (
+	// FNV is the 64-bit fnv64a hash of New and Add, the default.
+	FNV = "fnv"
+	// SHA256 is the SHA-256 hash truncated to 128 bits, slower but making collisions negligible.
+	SHA256 = "sha256"
+)
+
+// Algorithms are the hash algorithms of NewHasher.
+var Algorithms = []string{FNV, SHA256}
+
+// Signature is the hash computed by a Hasher, up to 128 bits. The 64-bit hashes leave the second half zero.
+type Signature [2]uint64
+
+// String returns the signature in hexadecimal.
+func (s Signature) String() string {
+	if s[1] == 0 {
+		return fmt.Sprintf("%016x", s[0])
+	}
+	return fmt.Sprintf("%016x%016x", s[0], s[1])
+}
+
+// Hasher computes a Signature incrementally. A Hasher is reused across signatures by resetting it, and is not safe
+// for concurrent use.
+type Hasher interface {
+	// Reset starts a new signature seeded with seed, the zero seed being the unseeded hash.
+	Reset(seed uint64)
+	AddString(s string)
+	AddByte(b byte)
+	AddUint64(val uint64)
+	// Sum returns the signature of what was added since the last reset.
+	Sum() Signature
+}
+
+// NewHasher returns a hasher of the algorithm, FNV when empty.
+func NewHasher(algorithm string) (Hasher, error) {
+	switch algorithm {
+	case "", FNV:
+		return &fnvHasher{h: offset64}, nil
+	case SHA256:
+		return &sha256Hasher{h: sha256.New()}, nil
+	default:
+		return nil, fmt.Errorf("unknown hash algorithm %q, it must be one of %v", algorithm, Algorithms)
+	}
+}
+
+// fnvHasher is the Hasher of the fnv64a functions of the package, its signatures being their values.
+type fnvHasher struct {
+	h uint64
+}
+
+func (f *fnvHasher) Reset(seed uint64)    { f.h = NewWithSeed(seed) }
+func (f *fnvHasher) AddString(s string)   { f.h = Add(f.h, s) }
+func (f *fnvHasher) AddByte(b byte)       { f.h = AddByte(f.h, b) }
+func (f *fnvHasher) AddUint64(val uint64) { f.h = AddUint64(f.h, val) }
+func (f *fnvHasher) Sum() Signature       { return Signature{f.h} }
+
+// sha256Hasher is the Hasher of the SHA-256 hash, its signatures being the first 128 bits of the digest.
+type sha256Hasher struct {
+	h   stdhash.Hash
+	buf [sha256.Size]byte
+}
+
+func (s *sha256Hasher) Reset(seed uint64) {
+	s.h.Reset()
+	if seed != 0 {
+		s.AddUint64(seed)
+	}
+}
+
+func (s *sha256Hasher) AddString(str string) {
+	// Writing to a hash never fails
+	_, _ = s.h.Write([]byte(str))
+}
+
+func (s *sha256Hasher) AddByte(b byte) {
+	s.buf[0] = b
+	_, _ = s.h.Write(s.buf[:1])
+}
+
+func (s *sha256Hasher) AddUint64(val uint64) {
+	binary.LittleEndian.PutUint64(s.buf[:8], val)
+	_, _ = s.h.Write(s.buf[:8])
+}
+
+func (s *sha256Hasher) Sum() Signature {
+	sum := s.h.Sum(s.buf[:0])
+	return Signature{binary.LittleEndian.Uint64(sum[:8]), binary.LittleEndian.Uint64(sum[8:16])}
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"testing"
+)
+
+func hashWith(h Hasher, seed uint64, s string, val uint64) Signature {
+	h.Reset(seed)
+	h.AddString(s)
+	h.AddByte(SeparatorByte)
+	h.AddUint64(val)
+	return h.Sum()
+}
+
+func TestHashers(t *testing.T) {
+	for _, algorithm := range Algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			h, err := NewHasher(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+			other, err := NewHasher(algorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signature := hashWith(h, 0, "metric", 42)
+			if got := hashWith(h, 0, "metric", 42); got != signature {
+				t.Errorf("expected a reset hasher to compute the same signature, got %s and %s", signature, got)
+			}
+			if got := hashWith(other, 0, "metric", 42); got != signature {
+				t.Errorf("expected distinct hashers to compute the same signature, got %s and %s", signature, got)
+			}
+			if got := hashWith(h, 0, "metric", 43); got == signature {
+				t.Errorf("expected distinct inputs to have distinct signatures, got %s", got)
+			}
+			if got := hashWith(h, 1, "metric", 42); got == signature {
+				t.Errorf("expected a seed to change the signature, got %s", got)
+			}
+		})
+	}
+}
+
+func TestFNVHasherMatchesFunctions(t *testing.T) {
+	h, err := NewHasher(FNV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := AddUint64(AddByte(Add(NewWithSeed(7), "metric"), SeparatorByte), 42)
+	if got := hashWith(h, 7, "metric", 42); got != (Signature{expected}) {
+		t.Errorf("expected the FNV hasher to match the package functions, got %s, expected %016x", got, expected)
+	}
+}
+
+func TestSHA256HasherIs128Bits(t *testing.T) {
+	h, err := NewHasher(SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature := hashWith(h, 0, "metric", 42); signature[0] == 0 || signature[1] == 0 {
+		t.Errorf("expected both halves of the signature to be set, got %s", signature)
+	}
+}
+
+func TestNewHasherUnknownAlgorithm(t *testing.T) {
+	if _, err := NewHasher("md5"); err == nil {
+		t.Error("expected an error for an unknown algorithm")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+// readinessProbeInterval is the interval between two readiness probes of the projects not ready yet.
+const readinessProbeInterval = 10 * time.Second
+
+// healthzHandler answers the liveness probes, healthy as soon as the HTTP server is up whatever the state of the
+// Monitoring API.
+func healthzHandler(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "OK")
+}
+
+// readyzHandler answers the readiness probes, ready once the metric descriptors of every project were listed
+// successfully at least once.
+func readyzHandler(readiness *collectors.Readiness) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if notReady := readiness.NotReady(); len(notReady) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "Waiting for the metric descriptors of projects: %s\n", strings.Join(notReady, ", "))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "OK")
+	}
+}
+
+// probeReadiness probes the projects not ready yet every interval until they all are or the context is done, so that
+// the exporter gets ready without waiting for a scrape, which a Kubernetes service does not route to a pod not ready.
+func (h *handler) probeReadiness(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, project := range h.readiness.NotReady() {
+			collector, err := h.getCollector(project, "", nil)
+			if err != nil {
+				h.logger.Error("error creating monitoring collector", "project_id", project, "err", err)
+				continue
+			}
+			if err := collector.ProbeReadiness(ctx); err != nil {
+				h.logger.Warn("Error probing the readiness of the project", "project_id", project, "err", err)
+			}
+		}
+		if len(h.readiness.NotReady()) == 0 {
+			h.logger.Info("Listed the metric descriptors of every project, ready")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+func TestHealthz(t *testing.T) {
+	rec := httptest.NewRecorder()
+	healthzHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	readiness := collectors.NewReadiness([]string{"project-a", "project-b"})
+	readyz := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		readyzHandler(readiness)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec
+	}
+
+	rec := readyz()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d before any descriptor list, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "project-a, project-b") {
+		t.Errorf("expected the projects not ready to be listed, got %q", rec.Body.String())
+	}
+
+	readiness.MarkReady("project-a")
+	if rec := readyz(); rec.Code != http.StatusServiceUnavailable || strings.Contains(rec.Body.String(), "project-a") {
+		t.Errorf("expected only project-b to be waited for, got status %d and %q", rec.Code, rec.Body.String())
+	}
+
+	readiness.MarkReady("project-b")
+	if rec := readyz(); rec.Code != http.StatusOK {
+		t.Errorf("expected status %d once every project listed its descriptors, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestProbeReadiness(t *testing.T) {
+	var descriptorRequests, timeSeriesRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			// The first probe fails, the project getting ready with the next one
+			if descriptorRequests.Add(1) == 1 {
+				http.Error(w, "{}", http.StatusForbidden)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{NextPageToken: "next"})
+		default:
+			timeSeriesRequests.Add(1)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	h.probeReadiness(ctx, time.Millisecond)
+
+	if notReady := h.readiness.NotReady(); len(notReady) != 0 {
+		t.Fatalf("expected the probes to mark the project ready, got %v not ready", notReady)
+	}
+	if got := descriptorRequests.Load(); got != 2 {
+		t.Errorf("expected a failed probe then a single page to be listed, got %d descriptor requests", got)
+	}
+	if got := timeSeriesRequests.Load(); got != 0 {
+		t.Errorf("expected the probes not to list time series, got %d requests", got)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+
+	"github.com/prometheus-community/stackdriver_exporter/utils"
+)
+
+// listedDescriptor is a metric descriptor as printed by the list-descriptors mode.
+type listedDescriptor struct {
+	ProjectID  string `json:"project_id"`
+	Type       string `json:"type"`
+	MetricKind string `json:"metric_kind"`
+	ValueType  string `json:"value_type"`
+	Unit       string `json:"unit"`
+}
+
+// listDescriptors lists the metric descriptors of the given projects matching the metric type prefixes.
+func listDescriptors(ctx context.Context, monitoringServices *monitoringServices, projectIDs []string, metricPrefixes []string) ([]listedDescriptor, error) {
+	var descriptors []listedDescriptor
+	for _, projectID := range projectIDs {
+		for _, prefix := range metricPrefixes {
+			filter := fmt.Sprintf("metric.type = starts_with(\"%s\")", prefix)
+			if err := monitoringServices.forProject(projectID).Projects.MetricDescriptors.List(utils.ProjectResource(projectID)).
+				Filter(filter).
+				Pages(ctx, func(page *monitoring.ListMetricDescriptorsResponse) error {
+					for _, d := range page.MetricDescriptors {
+						descriptors = append(descriptors, listedDescriptor{
+							ProjectID:  projectID,
+							Type:       d.Type,
+							MetricKind: d.MetricKind,
+							ValueType:  d.ValueType,
+							Unit:       d.Unit,
+						})
+					}
+					return nil
+				}); err != nil {
+				return nil, fmt.Errorf("error listing metric descriptors of project %s starting with %s: %w", projectID, prefix, err)
+			}
+		}
+	}
+	return descriptors, nil
+}
+
+// writeDescriptors prints the descriptors either as a JSON array or as an aligned table.
+func writeDescriptors(w io.Writer, format string, descriptors []listedDescriptor) error {
+	switch format {
+	case "json":
+		if descriptors == nil {
+			descriptors = []listedDescriptor{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(descriptors)
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROJECT\tTYPE\tKIND\tVALUE TYPE\tUNIT")
+		for _, d := range descriptors {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.ProjectID, d.Type, d.MetricKind, d.ValueType, d.Unit)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+func TestListDescriptorsJSON(t *testing.T) {
+	var filters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/projects/my-project/metricDescriptors") {
+			http.NotFound(w, r)
+			return
+		}
+		filters = append(filters, r.URL.Query().Get("filter"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+			MetricDescriptors: []*monitoring.MetricDescriptor{
+				{Type: "compute.googleapis.com/instance/cpu/usage_time", MetricKind: "DELTA", ValueType: "DOUBLE", Unit: "s{CPU}"},
+				{Type: "compute.googleapis.com/instance/uptime", MetricKind: "GAUGE", ValueType: "DOUBLE", Unit: "s"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	descriptors, err := listDescriptors(context.Background(), &monitoringServices{fallback: service}, []string{"my-project"}, []string{"compute.googleapis.com/instance"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{`metric.type = starts_with("compute.googleapis.com/instance")`}; !reflect.DeepEqual(filters, expected) {
+		t.Errorf("unexpected filters, expected %v, got %v", expected, filters)
+	}
+
+	var out bytes.Buffer
+	if err := writeDescriptors(&out, "json", descriptors); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output is not a JSON array of objects: %v\n%s", err, out.String())
+	}
+	expected := []map[string]string{
+		{"project_id": "my-project", "type": "compute.googleapis.com/instance/cpu/usage_time", "metric_kind": "DELTA", "value_type": "DOUBLE", "unit": "s{CPU}"},
+		{"project_id": "my-project", "type": "compute.googleapis.com/instance/uptime", "metric_kind": "GAUGE", "value_type": "DOUBLE", "unit": "s"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected output, expected %v, got %v", expected, got)
+	}
+}
+
+func TestWriteDescriptorsEmptyJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeDescriptors(&out, "json", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "[]" {
+		t.Errorf("expected an empty JSON array, got %s", got)
+	}
+}
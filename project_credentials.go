@@ -0,0 +1,56 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/api/monitoring/v3"
+)
+
+// monitoringServices are the Monitoring API clients of the projects. The projects with a credentials file have their
+// own client, the others share the client of the default credentials.
+type monitoringServices struct {
+	fallback *monitoring.Service
+	projects map[string]*monitoring.Service
+}
+
+// newMonitoringServices returns the clients of the projects of the credentials files, made by newService from the
+// file of each project, falling back to the fallback client for the other projects.
+func newMonitoringServices(fallback *monitoring.Service, credentialsFiles map[string]string, newService func(credentialsFile string) (*monitoring.Service, error)) (*monitoringServices, error) {
+	projectIDs := make([]string, 0, len(credentialsFiles))
+	for projectID := range credentialsFiles {
+		projectIDs = append(projectIDs, projectID)
+	}
+	sort.Strings(projectIDs)
+
+	services := &monitoringServices{fallback: fallback, projects: make(map[string]*monitoring.Service, len(projectIDs))}
+	for _, projectID := range projectIDs {
+		service, err := newService(credentialsFiles[projectID])
+		if err != nil {
+			return nil, fmt.Errorf("error creating the Monitoring service of project %s with %s: %w", projectID, credentialsFiles[projectID], err)
+		}
+		services.projects[projectID] = service
+	}
+	return services, nil
+}
+
+// forProject returns the client of the project.
+func (s *monitoringServices) forProject(projectID string) *monitoring.Service {
+	if service, ok := s.projects[projectID]; ok {
+		return service
+	}
+	return s.fallback
+}
@@ -0,0 +1,138 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/monitoring/v3"
+)
+
+func TestNewMonitoringServices(t *testing.T) {
+	fallback := &monitoring.Service{}
+	created := map[string]*monitoring.Service{}
+	newService := func(credentialsFile string) (*monitoring.Service, error) {
+		service := &monitoring.Service{}
+		created[credentialsFile] = service
+		return service, nil
+	}
+
+	services, err := newMonitoringServices(fallback, map[string]string{
+		"team-a": "/keys/team-a.json",
+		"team-b": "/keys/team-b.json",
+	}, newService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected a service per credentials file, got %d", len(created))
+	}
+	for project, file := range map[string]string{"team-a": "/keys/team-a.json", "team-b": "/keys/team-b.json"} {
+		if services.forProject(project) != created[file] {
+			t.Errorf("expected project %s to use the service of %s", project, file)
+		}
+	}
+	if services.forProject("team-c") != fallback {
+		t.Errorf("expected a project without credentials file to use the default service")
+	}
+
+	_, err = newMonitoringServices(fallback, map[string]string{"team-a": "/keys/missing.json"}, func(string) (*monitoring.Service, error) {
+		return nil, errors.New("no such file")
+	})
+	if err == nil || !strings.Contains(err.Error(), "project team-a") {
+		t.Errorf("expected an error naming the project, got %v", err)
+	}
+}
+
+func TestGoogleTokenSourceCredentialsFile(t *testing.T) {
+	// The token server returns a token naming the service account of the JWT assertion
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.FormValue("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "invalid assertion", http.StatusBadRequest)
+			return
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-of-" + claims.Iss, "token_type": "Bearer", "expires_in": 3600})
+	}))
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKeyFile := func(email string) string {
+		data, err := json.Marshal(map[string]string{
+			"type":         "service_account",
+			"client_email": email,
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"token_uri":    server.URL,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(t.TempDir(), email+".json")
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, email := range []string{"reader@team-a.iam.gserviceaccount.com", "reader@team-b.iam.gserviceaccount.com"} {
+		tokenSource, err := googleTokenSource(context.Background(), "", writeKeyFile(email))
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := tokenSource.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "token-of-" + email; token.AccessToken != expected {
+			t.Errorf("expected the token of the credentials file %q, got %q", expected, token.AccessToken)
+		}
+	}
+
+	if _, err := googleTokenSource(context.Background(), "", filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing credentials file")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+// pushSink periodically pushes the gathered metrics to a Pushgateway.
+type pushSink struct {
+	pusher   *push.Pusher
+	interval time.Duration
+	logger   *slog.Logger
+}
+
+// newPushSink creates a pushSink pushing the metrics of gatherer under job. The identity labels are set on every
+// pushed metric, overriding a label of the same name, and are never added to the metrics served for pulling.
+func newPushSink(url, job string, identityLabels map[string]string, interval time.Duration, gatherer prometheus.Gatherer, logger *slog.Logger) *pushSink {
+	return &pushSink{
+		pusher:   push.New(url, job).Gatherer(&identityGatherer{gatherer: gatherer, labels: identityLabels}),
+		interval: interval,
+		logger:   logger.With("component", "push_sink", "url", url, "job", job),
+	}
+}
+
+// push gathers the metrics and pushes them, replacing the metrics previously pushed under the job.
+func (s *pushSink) push(ctx context.Context) error {
+	return s.pusher.PushContext(ctx)
+}
+
+// run pushes the metrics every interval until the context is done.
+func (s *pushSink) run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.push(ctx); err != nil {
+			s.logger.Error("error pushing metrics", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushGatherer returns a gatherer of the collectors of every project for the push sink, along with the additional
+// gatherer. The collectors are dedicated to the pushes, so that they do not consume the DELTA points and
+// deduplication iterations of the pull scrapes, and are resolved at each push to apply the reloaded options. Each push
+// refills the retry budget, like a scrape.
+func (h *handler) pushGatherer(ctx context.Context) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		if h.retryBudget != nil {
+			h.retryBudget.Reset()
+		}
+		registry := h.registryOf(ctx, func(project string) (*collectors.MonitoringCollector, error) {
+			return h.getCollectorByKey(pushCollectorKey(project), project, "", nil)
+		})
+		return h.withAdditionalGatherer(registry).Gather()
+	})
+}
+
+// pushCollectorKey returns the key of the collector of a project dedicated to the pushes, which is never the key of a
+// pull scrape collector.
+func pushCollectorKey(project string) string {
+	return "push/" + collectorKey(project, "", nil)
+}
+
+// identityGatherer sets the identity labels on the metrics of a gatherer.
+type identityGatherer struct {
+	gatherer prometheus.Gatherer
+	labels   map[string]string
+}
+
+// Gather implements prometheus.Gatherer interface.
+func (g *identityGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.Metric {
+			metric.Label = withIdentityLabels(metric.Label, g.labels)
+		}
+	}
+	return families, err
+}
+
+// withIdentityLabels returns the sorted label pairs overridden by the identity labels. The label pairs are not
+// modified as the collectors can share them between the metrics they write.
+func withIdentityLabels(pairs []*dto.LabelPair, identityLabels map[string]string) []*dto.LabelPair {
+	labels := make([]*dto.LabelPair, 0, len(pairs)+len(identityLabels))
+	for _, pair := range pairs {
+		if _, ok := identityLabels[pair.GetName()]; !ok {
+			labels = append(labels, pair)
+		}
+	}
+	for name, value := range identityLabels {
+		labels = append(labels, &dto.LabelPair{Name: &name, Value: &value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	return labels
+}
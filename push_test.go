@@ -0,0 +1,148 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/promslog"
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+func TestPushSinkIdentityLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "stackdriver_test_metric", Help: "Test metric."}, []string{"instance"})
+	gauge.WithLabelValues("gce-instance").Set(1)
+	registry.MustRegister(gauge)
+
+	var pushedPath string
+	var pushed []byte
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pushedPath = r.URL.Path
+		pushed, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	identity := map[string]string{"instance": "exporter-1", "cluster": "europe"}
+	sink := newPushSink(gateway.URL, "stackdriver", identity, time.Minute, registry, promslog.NewNopLogger())
+	if err := sink.push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if pushedPath != "/metrics/job/stackdriver" {
+		t.Errorf("unexpected push path %s", pushedPath)
+	}
+
+	// the Pushgateway protocol defaults to the protobuf format
+	var family dto.MetricFamily
+	if err := expfmt.NewDecoder(bytes.NewReader(pushed), expfmt.NewFormat(expfmt.TypeProtoDelim)).Decode(&family); err != nil {
+		t.Fatalf("unable to decode pushed metrics: %v", err)
+	}
+	if family.GetName() != "stackdriver_test_metric" || len(family.GetMetric()) != 1 {
+		t.Fatalf("unexpected pushed metrics: %v", family.String())
+	}
+	labels := map[string]string{}
+	for _, label := range family.GetMetric()[0].GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	if expected := map[string]string{"instance": "exporter-1", "cluster": "europe"}; !reflect.DeepEqual(labels, expected) {
+		t.Errorf("unexpected pushed labels, expected %v, got %v", expected, labels)
+	}
+
+	// the pulled metrics stay clean
+	recorder := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	if !strings.Contains(body, `stackdriver_test_metric{instance="gce-instance"} 1`) {
+		t.Errorf("pulled metric should keep its own labels, got %s", body)
+	}
+	if strings.Contains(body, "exporter-1") || strings.Contains(body, "cluster") {
+		t.Errorf("identity labels should be absent from the pulled metrics, got %s", body)
+	}
+}
+
+func TestPushGatherer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{})
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	retryBudget := collectors.NewRetryBudget(1)
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, retryBudget, promslog.NewNopLogger(), nil)
+
+	// scrapes returns the number of scrapes of a collector
+	scrapes := func(c *collectors.MonitoringCollector) float64 {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(c.SelfMetrics())
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, family := range families {
+			if family.GetName() == "stackdriver_monitoring_scrapes_total" {
+				return family.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		return 0
+	}
+
+	retryBudget.Allow()
+	if _, err := h.pushGatherer(context.Background()).Gather(); err != nil {
+		t.Fatal(err)
+	}
+	if !retryBudget.Allow() {
+		t.Error("expected a push to refill the retry budget")
+	}
+
+	pulled, err := h.getCollector("my-project", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := h.getCollectorByKey(pushCollectorKey("my-project"), "my-project", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pulled == pushed {
+		t.Fatal("expected the pushes to have their own collector")
+	}
+	if got := scrapes(pulled); got != 0 {
+		t.Errorf("expected a push not to scrape the collector of the pull scrapes, got %v scrapes", got)
+	}
+	if got := scrapes(pushed); got != 1 {
+		t.Errorf("expected a push to scrape its own collector, got %v scrapes", got)
+	}
+}
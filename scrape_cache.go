@@ -0,0 +1,115 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context"
+)
+
+// scrapeCache collects the metrics every interval in the background and serves the last collected ones to every
+// scrape, so that the Monitoring API is called once per interval whatever the number of scrapers.
+type scrapeCache struct {
+	collect  func(ctx context.Context) ([]*dto.MetricFamily, error)
+	interval time.Duration
+	logger   *slog.Logger
+
+	// mu guards the last collected metric families and error
+	mu       sync.RWMutex
+	families []*dto.MetricFamily
+	err      error
+}
+
+func newScrapeCache(collect func(ctx context.Context) ([]*dto.MetricFamily, error), interval time.Duration, logger *slog.Logger) *scrapeCache {
+	return &scrapeCache{
+		collect:  collect,
+		interval: interval,
+		logger:   logger.With("component", "scrape_cache"),
+	}
+}
+
+// refresh collects the metrics and replaces the cached ones. The metrics collected along with an error are cached
+// too, and served with the error as a live scrape would.
+func (c *scrapeCache) refresh(ctx context.Context) {
+	start := time.Now()
+	families, err := c.collect(ctx)
+	if err != nil {
+		c.logger.Error("error collecting the cached metrics", "err", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.families = families
+	c.err = err
+	c.logger.Debug("refreshed the cached metrics", "duration", time.Since(start))
+}
+
+// run refreshes the cached metrics every interval until the context is done.
+func (c *scrapeCache) run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Gather implements prometheus.Gatherer interface. It returns no metric until the first collection completes. The
+// metric families are shared by the scrapes and must not be modified.
+func (c *scrapeCache) Gather() ([]*dto.MetricFamily, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.families, c.err
+}
+
+// collected reports whether the metrics were collected at least once, the scrapes being served from the cache since.
+func (c *scrapeCache) collected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.families != nil || c.err != nil
+}
+
+// servedStale counts a scrape served from the scrape cache in the API calls saved by the collector of every project.
+func (h *handler) servedStale() {
+	for _, project := range h.projectIDs {
+		monitoringCollector, err := h.getCollector(project, "", nil)
+		if err != nil {
+			h.logger.Error("error creating monitoring collector", "project_id", project, "err", err)
+			continue
+		}
+		monitoringCollector.ServedStale()
+	}
+}
+
+// collectStackdriverMetrics collects the Stackdriver metrics of every project for the scrape cache, without the
+// additional gatherer served live along with the cache.
+func (h *handler) collectStackdriverMetrics(ctx context.Context) ([]*dto.MetricFamily, error) {
+	if h.retryBudget != nil {
+		h.retryBudget.Reset()
+	}
+	if *stackdriverMaxScrapeDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *stackdriverMaxScrapeDuration)
+		defer cancel()
+	}
+	return h.stackdriverRegistry(ctx, "", nil).Gather()
+}
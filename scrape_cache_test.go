@@ -0,0 +1,160 @@
+// Copyright 2026 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
+
+// countingCollect returns a collect function of a gauge whose value is the number of collections, and its counter.
+func countingCollect() (func(ctx context.Context) ([]*dto.MetricFamily, error), *atomic.Int64) {
+	var collections atomic.Int64
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "stackdriver_test_collections", Help: "Test metric."})
+	registry.MustRegister(gauge)
+	return func(ctx context.Context) ([]*dto.MetricFamily, error) {
+		gauge.Set(float64(collections.Add(1)))
+		return registry.Gather()
+	}, &collections
+}
+
+func TestScrapeCacheConcurrentScrapes(t *testing.T) {
+	collect, collections := countingCollect()
+	cache := newScrapeCache(collect, time.Minute, promslog.NewNopLogger())
+	cache.refresh(context.Background())
+	h := &handler{logger: promslog.NewNopLogger(), scrapeCache: cache}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			bodies[i] = recorder.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if n := collections.Load(); n != 1 {
+		t.Errorf("the scrapes should be served from the cache, got %d collections", n)
+	}
+	for _, body := range bodies {
+		if !strings.Contains(body, "stackdriver_test_collections 1") {
+			t.Errorf("scrape should serve the cached metrics, got %s", body)
+		}
+	}
+}
+
+func TestScrapeCacheRefresh(t *testing.T) {
+	collect, _ := countingCollect()
+	cache := newScrapeCache(collect, 10*time.Millisecond, promslog.NewNopLogger())
+	if families, err := cache.Gather(); err != nil || len(families) != 0 {
+		t.Fatalf("nothing should be served before the first collection, got %v, %v", families, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cache.run(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		families, err := cache.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(families) == 1 && families[0].GetMetric()[0].GetGauge().GetValue() >= 3 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the background poller should refresh the cache, got %s", fmt.Sprint(families))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScrapeCacheStaleServed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{})
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+	h.scrapeCache = newScrapeCache(h.collectStackdriverMetrics, time.Minute, promslog.NewNopLogger())
+
+	scrape := func() {
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	}
+	staleServed := func() string {
+		monitoringCollector, err := h.getCollector("my-project", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(monitoringCollector.SelfMetrics())
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, family := range families {
+			if family.GetName() != "stackdriver_collector_api_calls_saved_total" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "reason" && label.GetValue() == "stale_served" {
+						return fmt.Sprint(metric.GetCounter().GetValue())
+					}
+				}
+			}
+		}
+		return ""
+	}
+
+	scrape()
+	if got := staleServed(); got != "0" {
+		t.Errorf("a scrape before the first collection should not be counted, got %s", got)
+	}
+	h.scrapeCache.refresh(context.Background())
+	scrape()
+	scrape()
+	if got := staleServed(); got != "2" {
+		t.Errorf("the scrapes served from the cache should be counted, got %s", got)
+	}
+}
@@ -14,12 +14,19 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/PuerkitoBio/rehttp"
@@ -33,16 +40,24 @@ import (
 	"github.com/prometheus/exporter-toolkit/web"
 	webflag "github.com/prometheus/exporter-toolkit/web/kingpinflag"
 	"golang.org/x/net/context"
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/time/rate"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/impersonate"
 	"google.golang.org/api/monitoring/v3"
 	"google.golang.org/api/option"
 
 	"github.com/prometheus-community/stackdriver_exporter/collectors"
 	"github.com/prometheus-community/stackdriver_exporter/delta"
+	"github.com/prometheus-community/stackdriver_exporter/hash"
 	"github.com/prometheus-community/stackdriver_exporter/utils"
 )
 
+// shutdownTimeout is how long the scrapes in flight are waited for on shutdown.
+const shutdownTimeout = 30 * time.Second
+
 var (
 	// General exporter flags
 
@@ -56,10 +71,34 @@ var (
 		"web.stackdriver-telemetry-path", "Path under which to expose Stackdriver metrics.",
 	).Default("/metrics").String()
 
+	internalMetricsPath = kingpin.Flag(
+		"web.internal-telemetry-path", "Path under which to expose only the metrics of the exporter itself, without scraping the Stackdriver metrics. Disabled when empty.",
+	).Default("/internal/metrics").String()
+
+	configFile = kingpin.Flag(
+		"config.file", "Path of a YAML file of the metric type prefixes, extra filters, interval and offset to use instead of their flags. It is reloaded on a POST to /-/reload or on SIGHUP.",
+	).Default("").String()
+
+	configCheck = kingpin.Flag(
+		"config.check", "Validate the flags and the config file, without calling the Monitoring API, then exit with a non-zero status if they are invalid.",
+	).Default("false").Bool()
+
+	configPrint = kingpin.Flag(
+		"config.print", "Log the effective flags and collector options as JSON at startup, the credentials files and the proxy password being redacted.",
+	).Default("false").Bool()
+
 	projectID = kingpin.Flag(
 		"google.project-id", "DEPRECATED - Comma seperated list of Google Project IDs. Use 'google.project-ids' instead.",
 	).String()
 
+	listDescriptorsMode = kingpin.Flag(
+		"list-descriptors", "List the metric descriptors matching the configured prefixes and exit without starting the server.",
+	).Default("false").Bool()
+
+	listDescriptorsFormat = kingpin.Flag(
+		"list-descriptors.format", "Output format of the listed metric descriptors.",
+	).Default("table").Enum("table", "json")
+
 	projectIDs = kingpin.Flag(
 		"google.project-ids", "Repeatable flag of Google Project IDs",
 	).Strings()
@@ -72,14 +111,62 @@ var (
 		"google.universe-domain", "The Cloud universe to use.",
 	).Default("googleapis.com").String()
 
+	googleUserAgent = kingpin.Flag(
+		"google.user-agent", "User-Agent product token sent with the Monitoring API calls, after the one of the Google API client.",
+	).Default("stackdriver_exporter/" + version.Version).String()
+
+	googleImpersonateServiceAccount = kingpin.Flag(
+		"google.impersonate-service-account", "Email of a service account to impersonate with the default credentials to read the metrics. Uses the default credentials directly when empty.",
+	).Default("").String()
+
+	googleProjectCredentials = kingpin.Flag(
+		"google.project-credentials", "Credentials file of a project, the project being read with its own credentials instead of the default credentials (repeatable, project_id=path).",
+	).StringMap()
+
+	googleHTTPProxy = kingpin.Flag(
+		"google.http-proxy", "URL of the HTTP proxy to send the Monitoring API requests through. Falls back to the HTTPS_PROXY and NO_PROXY environment variables when empty.",
+	).Default("").String()
+
+	googleHTTPProxyUsername = kingpin.Flag(
+		"google.http-proxy-username", "Username to authenticate to the HTTP proxy with.",
+	).Default("").String()
+
+	googleHTTPProxyPassword = kingpin.Flag(
+		"google.http-proxy-password", "Password to authenticate to the HTTP proxy with.",
+	).Envar("GOOGLE_HTTP_PROXY_PASSWORD").Default("").String()
+
 	stackdriverMaxRetries = kingpin.Flag(
-		"stackdriver.max-retries", "Max number of retries that should be attempted on 503 errors from stackdriver.",
+		"stackdriver.max-retries", "Max number of retries that should be attempted on 503 errors from stackdriver. Ignored when monitoring.retry-max-attempts enables the Monitoring API call retries.",
 	).Default("0").Int()
 
 	stackdriverHttpTimeout = kingpin.Flag(
 		"stackdriver.http-timeout", "How long should stackdriver_exporter wait for a result from the Stackdriver API.",
 	).Default("10s").Duration()
 
+	stackdriverMaxScrapeDuration = kingpin.Flag(
+		"stackdriver.max-scrape-duration", "Max duration of a scrape, the Monitoring API calls still in flight being cancelled after it. The scrape timeout sent by Prometheus also bounds the scrape. 0 means unlimited.",
+	).Default("0s").Duration()
+
+	monitoringCacheScrapeResults = kingpin.Flag(
+		"monitoring.cache-scrape-results", "If enabled will collect the Stackdriver metrics in the background every monitoring.cache-scrape-interval and serve the last collected ones to every scrape, instead of collecting them on each scrape.",
+	).Default("false").Bool()
+
+	monitoringCacheScrapeInterval = kingpin.Flag(
+		"monitoring.cache-scrape-interval", "Interval between two background collections of monitoring.cache-scrape-results.",
+	).Default("1m").Duration()
+
+	monitoringBackfillEndpoint = kingpin.Flag(
+		"monitoring.backfill-endpoint", "If enabled will serve one-off backfills of every point of a metric type prefix over a past window as OpenMetrics, on POST /-/backfill?prefix=...&start=...&end=...",
+	).Default("false").Bool()
+
+	monitoringBackfillMaxWindow = kingpin.Flag(
+		"monitoring.backfill-max-window", "Max window of a backfill of monitoring.backfill-endpoint, longer ones being rejected.",
+	).Default("24h").Duration()
+
+	monitoringBackfillTimeout = kingpin.Flag(
+		"monitoring.backfill-timeout", "Timeout of a backfill of monitoring.backfill-endpoint, including its Monitoring API calls.",
+	).Default("5m").Duration()
+
 	stackdriverMaxBackoffDuration = kingpin.Flag(
 		"stackdriver.max-backoff", "Max time between each request in an exp backoff scenario.",
 	).Default("5s").Duration()
@@ -92,15 +179,23 @@ var (
 		"stackdriver.retry-statuses", "The HTTP statuses that should trigger a retry.",
 	).Default("503").Ints()
 
+	stackdriverScrapeRetryBudget = kingpin.Flag(
+		"stackdriver.scrape-retry-budget", "Max number of retries shared by all the API calls of a single scrape, 0 means unlimited.",
+	).Default("0").Int()
+
 	// Monitoring collector flags
 	monitoringMetricsTypePrefixes = kingpin.Flag(
 		"monitoring.metrics-type-prefixes", "DEPRECATED - Comma separated Google Stackdriver Monitoring Metric Type prefixes. Use 'monitoring.metrics-prefixes' instead.",
 	).String()
 
 	monitoringMetricsPrefixes = kingpin.Flag(
-		"monitoring.metrics-prefixes", "Google Stackdriver Monitoring Metric Type prefixes. Repeat this flag to scrape multiple prefixes.",
+		"monitoring.metrics-prefixes", "Google Stackdriver Monitoring Metric Type prefixes. Repeat this flag to scrape multiple prefixes. The time series of each metric type are listed by its own request, the API requiring a single metric type per filter.",
 	).Strings()
 
+	monitoringMetricsPrefixProfiles = kingpin.Flag(
+		"monitoring.metrics-prefix-profile", "Named set of metric type prefixes collected instead of monitoring.metrics-prefixes by the scrapes with its name as profile URL param (repeatable, name=prefix,prefix).",
+	).StringMap()
+
 	monitoringMetricsInterval = kingpin.Flag(
 		"monitoring.metrics-interval", "Interval to request the Google Stackdriver Monitoring Metrics for. Only the most recent data point is used.",
 	).Default("5m").Duration()
@@ -110,7 +205,7 @@ var (
 	).Default("0s").Duration()
 
 	monitoringMetricsIngestDelay = kingpin.Flag(
-		"monitoring.metrics-ingest-delay", "Offset for the Google Stackdriver Monitoring Metrics interval into the past by the ingest delay from the metric's metadata.",
+		"monitoring.metrics-ingest-delay", "Offset for the Google Stackdriver Monitoring Metrics interval into the past by the ingest delay from the metric's metadata, in place of monitoring.metrics-offset when the metadata has one.",
 	).Default("false").Bool()
 
 	collectorFillMissingLabels = kingpin.Flag(
@@ -130,10 +225,30 @@ var (
 		"monitoring.aggregate-deltas", "If enabled will treat all DELTA metrics as an in-memory counter instead of a gauge",
 	).Default("false").Bool()
 
+	monitoringRawDeltaPrefixes = kingpin.Flag(
+		"monitoring.raw-delta-prefix", "Metric type prefix whose DELTA metrics are reported as gauges of their raw per interval value even when monitoring.aggregate-deltas is set (repeatable).",
+	).Strings()
+
+	monitoringEphemeralDeltaPrefixes = kingpin.Flag(
+		"monitoring.ephemeral-delta-prefix", "Metric type prefix whose DELTA metrics are aggregated as short-lived counters, a new series starting from 0, when monitoring.aggregate-deltas is set (repeatable).",
+	).Strings()
+
 	monitoringMetricsDeltasTTL = kingpin.Flag(
 		"monitoring.aggregate-deltas-ttl", "How long should a delta metric continue to be exported after GCP stops producing a metric",
 	).Default("30m").Duration()
 
+	monitoringClampCounterResets = kingpin.Flag(
+		"monitoring.clamp-counter-resets", "If enabled will report the CUMULATIVE counters going backwards without a later interval start time at their previous value.",
+	).Default("false").Bool()
+
+	deltaPersistencePath = kingpin.Flag(
+		"delta.persistence-path", "File the accumulated delta metrics are saved to on shutdown and restored from on startup, to keep their counters across restarts. The delta metrics are kept in memory only when empty.",
+	).Default("").String()
+
+	deltaWarmup = kingpin.Flag(
+		"delta.warmup", "If enabled will run a collection discarding its metrics before serving, so that the accumulated delta metrics of the first scrape already have a baseline.",
+	).Default("false").Bool()
+
 	monitoringDescriptorCacheTTL = kingpin.Flag(
 		"monitoring.descriptor-cache-ttl", "How long should the metric descriptors for a prefixed be cached for",
 	).Default("0s").Duration()
@@ -141,6 +256,298 @@ var (
 	monitoringDescriptorCacheOnlyGoogle = kingpin.Flag(
 		"monitoring.descriptor-cache-only-google", "Only cache descriptors for *.googleapis.com metrics",
 	).Default("true").Bool()
+
+	monitoringRetryMaxAttempts = kingpin.Flag(
+		"monitoring.retry-max-attempts", "Max number of attempts of a Monitoring API call failing with a 429 or 503 error, values lower than 2 disable retries. Replaces the stackdriver.max-retries retries when enabled.",
+	).Default("1").Int()
+
+	monitoringRetryBaseDelay = kingpin.Flag(
+		"monitoring.retry-base-delay", "Base delay of the exponential backoff between Monitoring API call retries.",
+	).Default("1s").Duration()
+
+	monitoringCircuitBreakerFailures = kingpin.Flag(
+		"monitoring.circuit-breaker-failures", "Number of consecutive failed scrapes of a project after which its Monitoring API calls are skipped for the cooldown, 0 disables the circuit breaker.",
+	).Default("0").Int()
+
+	monitoringCircuitBreakerCooldown = kingpin.Flag(
+		"monitoring.circuit-breaker-cooldown", "Time the Monitoring API calls of a project are skipped once its circuit breaker opens, before probing the API again.",
+	).Default("5m").Duration()
+
+	monitoringMaxConcurrentRequests = kingpin.Flag(
+		"monitoring.max-concurrent-requests", "Max number of time series requests in flight per project, 0 means unlimited.",
+	).Default("0").Int()
+
+	monitoringMaxConcurrentProjects = kingpin.Flag(
+		"monitoring.max-concurrent-projects", "Max number of projects collected concurrently during a scrape, 0 means unlimited.",
+	).Default("0").Int()
+
+	monitoringDistributionRange = kingpin.Flag(
+		"monitoring.distribution-range", "If enabled will report the min and max of distribution metrics as gauges when available",
+	).Default("false").Bool()
+
+	monitoringSanitizeLabelNames = kingpin.Flag(
+		"monitoring.sanitize-label-names", "If enabled will replace characters invalid in Prometheus label names with underscores.",
+	).Default("false").Bool()
+
+	monitoringDedupMaxSignatures = kingpin.Flag(
+		"monitoring.dedup-max-signatures", "Max number of metric signatures tracked for deduplication per scrape, 0 means unlimited.",
+	).Default("0").Int()
+
+	monitoringDedupByTimestamp = kingpin.Flag(
+		"monitoring.dedup-by-timestamp", "If enabled, series with the same labels but points at different timestamps are not deduplicated.",
+	).Default("false").Bool()
+
+	monitoringDedupIgnoreLabels = kingpin.Flag(
+		"monitoring.dedup-ignore-label", "Label key left out when looking for duplicate series (repeatable), * matching any characters, i.e: tmp_*",
+	).Strings()
+
+	monitoringDedupHistoryDepth = kingpin.Flag(
+		"monitoring.dedup-history-depth", "Number of scrapes the metric signatures are retained for deduplication, the current one included. Above 1 it requires monitoring.dedup-by-timestamp, points already reported by a previous scrape then not being reported again.",
+	).Default("1").Int()
+
+	monitoringDedupDebugCollisions = kingpin.Flag(
+		"monitoring.dedup-debug-collisions", "If enabled, the series colliding on a deduplication signature are logged at debug level. Costs memory, meant for debugging.",
+	).Default("false").Bool()
+
+	monitoringDedupHashSeed = kingpin.Flag(
+		"monitoring.dedup-hash-seed", "Seed of the hash of the deduplication signatures, to diversify them across exporter instances. 0 keeps the unseeded hash.",
+	).Default("0").Uint64()
+
+	monitoringDedupByResourceType = kingpin.Flag(
+		"monitoring.dedup-by-resource-type", "If enabled, the monitored resource type is part of the deduplication signatures, so series of distinct resource types are never duplicates. Requires monitoring.resource-type-label.",
+	).Default("false").Bool()
+
+	monitoringDedupDryRun = kingpin.Flag(
+		"monitoring.dedup-dry-run", "If enabled, the duplicates of the dedup options are counted by the deduplicator metrics but not dropped, the series with the same labels still being dropped.",
+	).Default("false").Bool()
+
+	monitoringDedupHashAlgorithm = kingpin.Flag(
+		"monitoring.dedup-hash-algorithm", "Hash algorithm of the deduplication signatures, the 64-bit fnv or the slower 128-bit sha256 making collisions negligible.",
+	).Default(hash.FNV).Enum(hash.Algorithms...)
+
+	monitoringSkipUnchangedSeries = kingpin.Flag(
+		"monitoring.skip-unchanged-series", "If enabled will not emit a series again while its point has the same timestamp and value as the one emitted by a previous scrape. Prometheus marks the skipped series stale.",
+	).Default("false").Bool()
+
+	monitoringUnchangedSeriesMaxAge = kingpin.Flag(
+		"monitoring.unchanged-series-max-age", "How long an unchanged series is skipped for by monitoring.skip-unchanged-series before being emitted again, bounding how long it stays stale. Must be positive.",
+	).Default("5m").Duration()
+
+	monitoringCaseInsensitiveMetricNames = kingpin.Flag(
+		"monitoring.case-insensitive-metric-names", "If enabled will lower-case the metric prefix so that exported metric names are entirely lower case.",
+	).Default("false").Bool()
+
+	monitoringSplitLargeHistogramCounts = kingpin.Flag(
+		"monitoring.split-large-histogram-counts", "If enabled will also report distribution counts above 2^53 exactly as _count_high and _count_low gauges.",
+	).Default("false").Bool()
+
+	monitoringSystemLabelsMode = kingpin.Flag(
+		"monitoring.system-labels-mode", "How the metadata system labels are added to the metrics, one of flatten, json or off.",
+	).Default(string(collectors.SystemLabelsOff)).Enum(string(collectors.SystemLabelsFlatten), string(collectors.SystemLabelsJSON), string(collectors.SystemLabelsOff))
+
+	monitoringSystemLabelsSchema = kingpin.Flag(
+		"monitoring.system-labels-schema", "If enabled will report the schema version found in the metadata system labels as the system_labels_schema label.",
+	).Default("false").Bool()
+
+	monitoringSystemLabelsSchemaKey = kingpin.Flag(
+		"monitoring.system-labels-schema-key", "System label holding the schema version reported by monitoring.system-labels-schema.",
+	).Default("__schema__").String()
+
+	monitoringPromotedSystemLabels = kingpin.Flag(
+		"monitoring.promote-system-label", "Key prefix of the metadata system labels to add as labels of their own, e.g. check_id of the uptime check metrics, whatever monitoring.system-labels-mode (repeatable).",
+	).Strings()
+
+	monitoringMetricPrefix = kingpin.Flag(
+		"monitoring.metric-prefix", "Prefix of the exported Stackdriver metric names.",
+	).Default("stackdriver").String()
+
+	monitoringHistogramToSummaryThreshold = kingpin.Flag(
+		"monitoring.histogram-to-summary-threshold", "Number of buckets above which distributions are reported as summaries instead of histograms, 0 means never.",
+	).Default("0").Int()
+
+	monitoringMetricLastPointAge = kingpin.Flag(
+		"monitoring.metric-last-point-age", "If enabled will report the age of the newest point of each metric type.",
+	).Default("false").Bool()
+
+	monitoringAbsentMetrics = kingpin.Flag(
+		"monitoring.absent-metrics", "If enabled will report a placeholder metric for the metric descriptors without time series, keeping their metric names exposed.",
+	).Default("false").Bool()
+
+	monitoringDescriptorPhaseTimeout = kingpin.Flag(
+		"monitoring.descriptor-phase-timeout", "Timeout for listing the metric descriptors during a scrape, 0 means none.",
+	).Default("0s").Duration()
+
+	monitoringTimeSeriesPhaseTimeout = kingpin.Flag(
+		"monitoring.time-series-phase-timeout", "Timeout for fetching the time series of the metric descriptors of each prefix once listed, 0 means none.",
+	).Default("0s").Duration()
+
+	monitoringPerRequestTimeout = kingpin.Flag(
+		"monitoring.request-timeout", "Timeout of each Monitoring API request, each retry included, 0 means none. A request timing out fails its metric type only.",
+	).Default("0s").Duration()
+
+	monitoringDeriveLabels = kingpin.Flag(
+		"monitoring.derive-label",
+		"Label derived from the value of another fully matching a regex (repeatable), i.e: zone:region:(.+)-[a-z]:$1",
+	).Strings()
+
+	monitoringAggregations = kingpin.Flag(
+		"monitoring.aggregation",
+		"Server-side aggregation of the time series of a metric prefix (repeatable), i.e: compute.googleapis.com/instance/cpu:60s:ALIGN_RATE:REDUCE_SUM:resource.labels.zone",
+	).Strings()
+
+	monitoringRawMetricTypeLabel = kingpin.Flag(
+		"monitoring.raw-metric-type-label", "If enabled will report the metric type each series was normalized from as the stackdriver_metric_type label.",
+	).Default("false").Bool()
+
+	monitoringMaxLookback = kingpin.Flag(
+		"monitoring.max-lookback", "Oldest the requested interval can start before the scrape, to avoid requesting data beyond the retention. 0 means no limit.",
+	).Default("0s").Duration()
+
+	monitoringInitialLookback = kingpin.Flag(
+		"monitoring.initial-lookback", "Interval requested by the first collection of every metric type, 0 meaning monitoring.metrics-interval.",
+	).Default("0s").Duration()
+
+	monitoringMetricKindLabel = kingpin.Flag(
+		"monitoring.metric-kind-label", "If enabled will report the metric kind and value type of each metric descriptor as the metric_kind and value_type labels.",
+	).Default("false").Bool()
+
+	monitoringResourceTypeLabel = kingpin.Flag(
+		"monitoring.resource-type-label", "If enabled will report the monitored resource type of each series as the resource_type label.",
+	).Default("false").Bool()
+
+	monitoringLaunchStageLabel = kingpin.Flag(
+		"monitoring.launch-stage-label", "If enabled will report the launch stage of each metric descriptor, e.g. GA or BETA, as the launch_stage label.",
+	).Default("false").Bool()
+
+	monitoringInferMissingDescriptors = kingpin.Flag(
+		"monitoring.infer-missing-descriptors", "If enabled will discover the metric types missing from the cached metric descriptors by listing the descriptors of every prefix again, and report their time series with a descriptor inferred from the series.",
+	).Default("false").Bool()
+
+	monitoringDropEmptyLabelValues = kingpin.Flag(
+		"monitoring.drop-empty-label-values", "If enabled will leave out the metric, resource, system and user labels with an empty value.",
+	).Default("false").Bool()
+
+	monitoringMetricsScopeProject = kingpin.Flag(
+		"monitoring.metrics-scope-project", "Scoping project of the metrics scope to list the time series from, reported as the scoped_project_id label. The project_id label keeps reporting the source project of each series. A single project must be collected.",
+	).Default("").String()
+
+	monitoringProjectIDLabel = kingpin.Flag(
+		"monitoring.project-id-label", "Project reported as the project_id label: both for the source project of each series along with the scoped_project_id label, resource for the source project only, scope for the scoping project, which also attributes the deduplicator metrics to it.",
+	).Default(string(collectors.ProjectIDLabelBoth)).Enum(string(collectors.ProjectIDLabelBoth), string(collectors.ProjectIDLabelResource), string(collectors.ProjectIDLabelScope))
+
+	monitoringNormalizeUnits = kingpin.Flag(
+		"monitoring.normalize-units", "Report the known UCUM units of the unit label by their Prometheus names, e.g. bytes for By and seconds for s. Unknown units are kept as is.",
+	).Default("false").Bool()
+
+	monitoringUnitSuffix = kingpin.Flag(
+		"monitoring.unit-suffix", "Suffix the metric names with the Prometheus name of their descriptor unit, e.g. _bytes, unless already present.",
+	).Default("false").Bool()
+
+	monitoringLabelRenames = kingpin.Flag(
+		"monitoring.label-rename", "Rename a metric, resource, system or user label (repeatable, key=name), e.g. project_id=gcp_project.",
+	).StringMap()
+
+	monitoringResourceTypeAllowlist = kingpin.Flag(
+		"monitoring.resource-type", "Monitored resource type to report the time series of, e.g. gce_instance (repeatable). Every resource type is reported when unset.",
+	).Strings()
+
+	monitoringValueTypeAllowlist = kingpin.Flag(
+		"monitoring.value-type", "Value type of the metric descriptors to collect, e.g. DOUBLE (repeatable). The time series of other value types are not requested. Every value type is collected when unset.",
+	).Enums(collectors.ValueTypes...)
+
+	monitoringProjectIDAllowlist = kingpin.Flag(
+		"monitoring.project-id-allowlist", "Project to report the time series of by their resource project_id label (repeatable). Every project is reported when unset.",
+	).Strings()
+
+	monitoringProjectIDDenylist = kingpin.Flag(
+		"monitoring.project-id-denylist", "Project to drop the time series of by their resource project_id label (repeatable), even when allowlisted.",
+	).Strings()
+
+	monitoringEmitStringMetrics = kingpin.Flag(
+		"monitoring.string-metrics", "Report the STRING metrics as gauges of constant 1 with their value as the string_value label. They are dropped otherwise.",
+	).Default("false").Bool()
+
+	monitoringDropLabels = kingpin.Flag(
+		"monitoring.drop-label", "Label key to leave out of the emitted metrics, * matching any characters (repeatable), e.g. instance_id or pod_*.",
+	).Strings()
+
+	monitoringDropLabelsDedupOnFullLabels = kingpin.Flag(
+		"monitoring.drop-label.dedup-on-full-labels", "Compute the deduplication signatures before dropping the monitoring.drop-label labels. The series only differing by dropped labels are then not duplicates, but only the first one of a scrape is emitted.",
+	).Default("false").Bool()
+
+	monitoringNativeHistograms = kingpin.Flag(
+		"monitoring.native-histograms", "If enabled will report the distributions as native histograms when their buckets are representable, falling back to classic histograms otherwise.",
+	).Default("false").Bool()
+
+	monitoringExemplars = kingpin.Flag(
+		"monitoring.exemplars", "If enabled will attach the trace exemplars of the distributions to the buckets of their classic histograms.",
+	).Default("false").Bool()
+
+	monitoringDeltaCreatedTimestamps = kingpin.Flag(
+		"monitoring.aggregate-deltas-created-timestamps", "If enabled will report the counters aggregated from DELTA metrics with the interval start time of their first point as created timestamp.",
+	).Default("false").Bool()
+
+	monitoringOmitPointTimestamps = kingpin.Flag(
+		"monitoring.omit-point-timestamps", "If enabled will report the metrics without the end time of their point as timestamp, Prometheus using the scrape time instead.",
+	).Default("false").Bool()
+
+	monitoringResourceInfoMetric = kingpin.Flag(
+		"monitoring.resource-info-metric", "Report the resource labels and metadata once per resource as a <prefix>_resource_info metric, the time series keeping only project_id and a resource_id join key.",
+	).Default("false").Bool()
+
+	monitoringPointSelection = kingpin.Flag(
+		"monitoring.point-selection", "Point of the GAUGE time series to report when the request interval holds several, the latest or oldest one, or the sum or mean of the INT64 and DOUBLE points.",
+	).Default("latest").Enum("latest", "oldest", "sum", "mean")
+
+	monitoringMaxLabelValueLength = kingpin.Flag(
+		"monitoring.max-label-value-length", "Max length in bytes of the label values, longer values being truncated and suffixed with a hash of the whole value. 0 means unlimited.",
+	).Default("0").Int()
+
+	monitoringMaxSeriesPerMetricType = kingpin.Flag(
+		"monitoring.max-series-per-metric-type", "Max number of series retrieved for a metric type per scrape, the pagination stopping once exceeded. 0 means unlimited.",
+	).Default("0").Int()
+
+	monitoringHistogramBuckets = kingpin.Flag(
+		"monitoring.histogram-bucket", "Upper bound of a bucket to re-bucket the distributions into instead of their own buckets (repeatable).",
+	).Float64List()
+
+	monitoringHistogramRebucketMode = kingpin.Flag(
+		"monitoring.histogram-rebucket-mode", "How the count of a distribution bucket is split across the monitoring.histogram-bucket buckets it overlaps, proportionally to the overlap or conservatively.",
+	).Default("proportional").Enum("proportional", "conservative")
+
+	monitoringLabelSourcePrefixes = kingpin.Flag(
+		"monitoring.label-source-prefix", "Prefix the names of the labels of a source, metric, resource, system or user (repeatable, source=prefix), e.g. resource=resource_.",
+	).StringMap()
+
+	monitoringMaxQPS = kingpin.Flag(
+		"monitoring.max-qps", "Max number of Monitoring API calls per second shared by all the collectors, 0 means unlimited.",
+	).Default("0").Float64()
+
+	monitoringMQLQueries = kingpin.Flag(
+		"monitoring.mql-query", "MQL query to report the result table of as the given metric (repeatable, name=query), e.g. instance_cpu_ratio='fetch gce_instance | metric compute.googleapis.com/instance/cpu/utilization | within 5m'.",
+	).StringMap()
+
+	monitoringUptimeChecks = kingpin.Flag(
+		"monitoring.uptime-checks", "If enabled will report whether the uptime checks of each project are passing.",
+	).Default("false").Bool()
+
+	// Push flags
+
+	pushGatewayURL = kingpin.Flag(
+		"push.gateway-url", "URL of a Pushgateway to push the Stackdriver metrics to, in addition to serving them. Disabled when empty.",
+	).Default("").String()
+
+	pushJob = kingpin.Flag(
+		"push.job", "Job name the Stackdriver metrics are pushed under.",
+	).Default("stackdriver_exporter").String()
+
+	pushInterval = kingpin.Flag(
+		"push.interval", "Interval between two pushes of the Stackdriver metrics.",
+	).Default("1m").Duration()
+
+	pushIdentityLabels = kingpin.Flag(
+		"push.identity-label", "Label identifying this exporter, set on the pushed metrics only (repeatable, name=value).",
+	).StringMap()
 )
 
 func init() {
@@ -158,57 +565,242 @@ func getDefaultGCPProject(ctx context.Context) (*string, error) {
 	return &credentials.ProjectID, nil
 }
 
-func createMonitoringService(ctx context.Context) (*monitoring.Service, error) {
-	googleClient, err := google.DefaultClient(ctx, monitoring.MonitoringReadScope)
+// googleTokenSource returns the token source of the monitoring client: the credentials of the credentials file, or
+// the application default credentials when empty, or, when a service account to impersonate is set, the tokens of
+// that service account generated with them.
+func googleTokenSource(ctx context.Context, impersonateServiceAccount string, credentialsFile string, opts ...option.ClientOption) (oauth2.TokenSource, error) {
+	if impersonateServiceAccount == "" {
+		if credentialsFile == "" {
+			return google.DefaultTokenSource(ctx, monitoring.MonitoringReadScope)
+		}
+		data, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		credentials, err := google.CredentialsFromJSON(ctx, data, monitoring.MonitoringReadScope)
+		if err != nil {
+			return nil, err
+		}
+		return credentials.TokenSource, nil
+	}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	return impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: impersonateServiceAccount,
+		Scopes:          []string{monitoring.MonitoringReadScope},
+	}, opts...)
+}
+
+func createMonitoringService(ctx context.Context, retryBudget *collectors.RetryBudget, limiter *rate.Limiter, credentialsFile string) (*monitoring.Service, error) {
+	transport, err := newProxyTransport(*googleHTTPProxy, *googleHTTPProxyUsername, *googleHTTPProxyPassword)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Google client: %v", err)
+	}
+	// The oauth2 client and its token refreshes use the HTTP client of the context
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+
+	tokenSource, err := googleTokenSource(ctx, *googleImpersonateServiceAccount, credentialsFile)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating Google client: %v", err)
 	}
+	googleClient := oauth2.NewClient(ctx, tokenSource)
 
 	googleClient.Timeout = *stackdriverHttpTimeout
-	googleClient.Transport = rehttp.NewTransport(
-		googleClient.Transport, // need to wrap DefaultClient transport
+	// The limiter is below the retries so every attempt waits for a token
+	googleClient.Transport = newRetryTransport(newRateLimitTransport(googleClient.Transport, limiter), retryBudget) // need to wrap DefaultClient transport
+
+	monitoringService, err := newMonitoringService(ctx, googleClient, *googleUserAgent, option.WithUniverseDomain(*googleUniverseDomain))
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Google Stackdriver Monitoring service: %v", err)
+	}
+
+	return monitoringService, nil
+}
+
+// newMonitoringService returns the Monitoring API service sending its calls with client and the userAgent product
+// token. The token is set on the service since option.WithUserAgent is ignored along with option.WithHTTPClient.
+func newMonitoringService(ctx context.Context, client *http.Client, userAgent string, opts ...option.ClientOption) (*monitoring.Service, error) {
+	monitoringService, err := monitoring.NewService(ctx, append([]option.ClientOption{option.WithHTTPClient(client)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	monitoringService.UserAgent = userAgent
+	return monitoringService, nil
+}
+
+// newProxyTransport returns the transport of the Monitoring API requests, sending them through the proxy or, when
+// empty, through the proxy of the HTTPS_PROXY and NO_PROXY environment variables. The username and password, if set,
+// authenticate to the proxy.
+func newProxyTransport(proxy, username, password string) (*http.Transport, error) {
+	proxyFunc := httpproxy.FromEnvironment().ProxyFunc()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy %q: %v", proxy, err)
+		}
+		if proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid HTTP proxy %q: expected a URL such as http://proxy:3128", proxy)
+		}
+		proxyFunc = func(*url.URL) (*url.URL, error) { return proxyURL, nil }
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(r *http.Request) (*url.URL, error) {
+		proxyURL, err := proxyFunc(r.URL)
+		if proxyURL == nil || err != nil || username == "" {
+			return proxyURL, err
+		}
+		authenticated := *proxyURL
+		authenticated.User = url.UserPassword(username, password)
+		return &authenticated, nil
+	}
+	return transport, nil
+}
+
+// newRetryTransport returns the transport retrying the failed requests up to stackdriver.max-retries times. The
+// retries of monitoring.retry-max-attempts replace it when enabled, the two would otherwise stack.
+func newRetryTransport(transport http.RoundTripper, retryBudget *collectors.RetryBudget) http.RoundTripper {
+	if *monitoringRetryMaxAttempts > 1 {
+		return transport
+	}
+	return rehttp.NewTransport(
+		transport,
 		rehttp.RetryAll(
 			rehttp.RetryMaxRetries(*stackdriverMaxRetries),
-			rehttp.RetryStatuses(*stackdriverRetryStatuses...)), // Cloud support suggests retrying on 503 errors
+			rehttp.RetryStatuses(*stackdriverRetryStatuses...),        // Cloud support suggests retrying on 503 errors
+			func(rehttp.Attempt) bool { return retryBudget.Allow() }), // Must be last so only actual retries consume the budget
 		rehttp.ExpJitterDelay(*stackdriverBackoffJitterBase, *stackdriverMaxBackoffDuration), // Set timeout to <10s as that is prom default timeout
 	)
+}
 
-	monitoringService, err := monitoring.NewService(ctx, option.WithHTTPClient(googleClient), option.WithUniverseDomain(*googleUniverseDomain))
-	if err != nil {
-		return nil, fmt.Errorf("Error creating Google Stackdriver Monitoring service: %v", err)
+// newRateLimiter returns the limiter of the Monitoring API calls allowing qps calls per second, or nil when qps is 0.
+// Its burst of a single call spaces the calls out evenly.
+func newRateLimiter(qps float64) *rate.Limiter {
+	if qps <= 0 {
+		return nil
 	}
+	return rate.NewLimiter(rate.Limit(qps), 1)
+}
 
-	return monitoringService, nil
+// newRateLimitTransport returns the transport sending every request once the limiter grants a token, or transport
+// itself when limiter is nil. The wait is given up once the context of the request is done.
+func newRateLimitTransport(transport http.RoundTripper, limiter *rate.Limiter) http.RoundTripper {
+	if limiter == nil {
+		return transport
+	}
+	return &rateLimitTransport{transport: transport, limiter: limiter}
 }
 
-type handler struct {
-	handler http.Handler
-	logger  *slog.Logger
+type rateLimitTransport struct {
+	transport http.RoundTripper
+	limiter   *rate.Limiter
+}
 
-	projectIDs          []string
-	metricsPrefixes     []string
-	metricsExtraFilters []collectors.MetricFilter
-	additionalGatherer  prometheus.Gatherer
-	m                   *monitoring.Service
-	collectors          *collectors.CollectorCache
+// RoundTrip implements http.RoundTripper interface.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.transport.RoundTrip(req)
+}
+
+type handler struct {
+	logger *slog.Logger
+
+	projectIDs      []string
+	metricsPrefixes []string
+	// metricsPrefixProfiles are the metric type prefixes of the profiles selectable by the profile URL param
+	metricsPrefixProfiles map[string][]string
+	metricsExtraFilters   []collectors.MetricFilter
+	metricsInterval       time.Duration
+	metricsOffset         time.Duration
+	metricsAggregations   []collectors.Aggregation
+	labelDerivations      []collectors.LabelDerivation
+	additionalGatherer    prometheus.Gatherer
+	m                     *monitoringServices
+	collectors            *collectors.CollectorCache
+	retryBudget           *collectors.RetryBudget
+	// projectSlots bounds the number of projects collected concurrently, nil when unbounded
+	projectSlots chan struct{}
+	// maxConcurrencyGlobal reports the effective limit of projects collected concurrently
+	maxConcurrencyGlobal prometheus.Gauge
+	// deltaPersistence saves the delta stores across restarts, nil when disabled
+	deltaPersistence *deltaPersistence
+	// readiness tracks the projects whose metric descriptors were listed
+	readiness *collectors.Readiness
+	// circuitBreakers holds the circuit breaker of every project, shared by its collectors
+	circuitBreakers *collectors.CircuitBreakers
+	// uptimeCheckCollectors and mqlCollectors are the uptime check and MQL collectors by project, if enabled
+	uptimeCheckCollectors map[string]*collectors.UptimeCheckCollector
+	mqlCollectors         map[string]*collectors.MQLCollector
+	// scrapeCache serves the metrics collected in the background to the scrapes, nil when disabled
+	scrapeCache *scrapeCache
+	// configMu guards the metric type prefixes and profiles, extra filters, interval and offset reloaded from the
+	// config file
+	configMu sync.RWMutex
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	profile := r.URL.Query().Get("profile")
+	if profile != "" && !h.hasProfile(profile) {
+		http.Error(w, fmt.Sprintf("unknown profile %q", profile), http.StatusBadRequest)
+		return
+	}
+
 	collectParams := r.URL.Query()["collect"]
 	filters := make(map[string]bool)
 	for _, param := range collectParams {
 		filters[param] = true
 	}
 
-	if len(filters) > 0 {
-		h.innerHandler(filters).ServeHTTP(w, r)
+	// The cache holds the metrics of the scrapes without a profile nor collect filters only
+	if h.scrapeCache != nil && profile == "" && len(filters) == 0 {
+		if h.scrapeCache.collected() {
+			h.servedStale()
+		}
+		h.handlerFor(h.withAdditionalGatherer(h.scrapeCache)).ServeHTTP(w, r)
 		return
 	}
 
-	h.handler.ServeHTTP(w, r)
+	if h.retryBudget != nil {
+		h.retryBudget.Reset()
+	}
+	ctx, cancel := scrapeContext(r)
+	defer cancel()
+	h.innerHandler(ctx, profile, filters).ServeHTTP(w, r)
+}
+
+// hasProfile reports whether the metric type prefix profile is configured.
+func (h *handler) hasProfile(profile string) bool {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	_, ok := h.metricsPrefixProfiles[profile]
+	return ok
 }
 
-func newHandler(projectIDs []string, metricPrefixes []string, metricExtraFilters []collectors.MetricFilter, m *monitoring.Service, logger *slog.Logger, additionalGatherer prometheus.Gatherer) *handler {
+// scrapeContext returns the context bounding the Monitoring API calls of a scrape request. It is done when the
+// client goes away or once the scrape timeout of the X-Prometheus-Scrape-Timeout-Seconds header, or the max scrape
+// duration if shorter, is elapsed.
+func scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	timeout := *stackdriverMaxScrapeDuration
+	if header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil && seconds > 0 {
+			if scrapeTimeout := time.Duration(seconds * float64(time.Second)); timeout <= 0 || scrapeTimeout < timeout {
+				timeout = scrapeTimeout
+			}
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+func newHandler(projectIDs []string, metricPrefixes []string, metricExtraFilters []collectors.MetricFilter, metricAggregations []collectors.Aggregation, labelDerivations []collectors.LabelDerivation, mqlQueries []collectors.MQLQuery, m *monitoringServices, retryBudget *collectors.RetryBudget, logger *slog.Logger, additionalGatherer prometheus.Gatherer) *handler {
 	var ttl time.Duration
 	// Add collector caching TTL as max of deltas aggregation or descriptor caching
 	if *monitoringMetricsAggregateDeltas || *monitoringDescriptorCacheTTL > 0 {
@@ -223,39 +815,74 @@ func newHandler(projectIDs []string, metricPrefixes []string, metricExtraFilters
 	logger.Info("Creating collector cache", "ttl", ttl)
 
 	h := &handler{
-		logger:              logger,
-		projectIDs:          projectIDs,
-		metricsPrefixes:     metricPrefixes,
-		metricsExtraFilters: metricExtraFilters,
-		additionalGatherer:  additionalGatherer,
-		m:                   m,
-		collectors:          collectors.NewCollectorCache(ttl),
+		logger:                logger,
+		projectIDs:            projectIDs,
+		metricsPrefixes:       metricPrefixes,
+		metricsPrefixProfiles: parseMetricPrefixProfiles(flagMetricPrefixProfiles()),
+		metricsExtraFilters:   metricExtraFilters,
+		metricsInterval:       *monitoringMetricsInterval,
+		metricsOffset:         *monitoringMetricsOffset,
+		metricsAggregations:   metricAggregations,
+		labelDerivations:      labelDerivations,
+		additionalGatherer:    additionalGatherer,
+		m:                     m,
+		collectors:            collectors.NewCollectorCache(ttl),
+		retryBudget:           retryBudget,
+		readiness:             collectors.NewReadiness(projectIDs),
+		circuitBreakers:       collectors.NewCircuitBreakers(),
+		maxConcurrencyGlobal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "stackdriver",
+			Subsystem: "collector",
+			Name:      "max_concurrency_global",
+			Help:      "Max number of projects collected concurrently during a scrape, 0 means unlimited.",
+		}),
+	}
+	if *deltaPersistencePath != "" {
+		h.deltaPersistence = newDeltaPersistence(*deltaPersistencePath, *monitoringMetricsDeltasTTL, logger)
+		h.collectors.OnEvict(h.deltaPersistence.forget)
+	}
+	if *monitoringMaxConcurrentProjects > 0 {
+		h.projectSlots = make(chan struct{}, *monitoringMaxConcurrentProjects)
+		h.maxConcurrencyGlobal.Set(float64(*monitoringMaxConcurrentProjects))
 	}
 
-	h.handler = h.innerHandler(nil)
+	h.uptimeCheckCollectors = map[string]*collectors.UptimeCheckCollector{}
+	h.mqlCollectors = map[string]*collectors.MQLCollector{}
+	for _, project := range projectIDs {
+		// Fail on startup rather than on the first scrape
+		if _, err := h.getCollector(project, "", nil); err != nil {
+			h.logger.Error("error creating monitoring collector", "err", err)
+			os.Exit(1)
+		}
+		if *monitoringUptimeChecks {
+			h.uptimeCheckCollectors[project] = collectors.NewUptimeCheckCollector(project, m.forProject(project), *monitoringMetricsInterval, logger)
+		}
+		if len(mqlQueries) > 0 {
+			h.mqlCollectors[project] = collectors.NewMQLCollector(project, m.forProject(project), mqlQueries, logger)
+		}
+	}
 	return h
 }
 
-func (h *handler) getCollector(project string, filters map[string]bool) (*collectors.MonitoringCollector, error) {
-	filterdPrefixes := h.filterMetricTypePrefixes(filters)
-	collectorKey := fmt.Sprintf("%s-%v", project, filterdPrefixes)
+// getCollector returns the collector of a project for a scrape selecting a metric type prefix profile, none when
+// empty, and collect filters.
+func (h *handler) getCollector(project, profile string, filters map[string]bool) (*collectors.MonitoringCollector, error) {
+	return h.getCollectorByKey(collectorKey(project, profile, filters), project, profile, filters)
+}
+
+// getCollectorByKey returns the collector of a project cached under collectorKey, created for a scrape selecting a
+// metric type prefix profile, none when empty, and collect filters.
+func (h *handler) getCollectorByKey(collectorKey, project, profile string, filters map[string]bool) (*collectors.MonitoringCollector, error) {
+	// The key does not depend on the reloadable options, so that a reload keeps the collectors and their delta stores
+	reloadable := h.reloadableOptions(profile, filters)
 
 	if collector, found := h.collectors.Get(collectorKey); found {
+		collector.Reload(reloadable)
 		return collector, nil
 	}
 
-	collector, err := collectors.NewMonitoringCollector(project, h.m, collectors.MonitoringCollectorOptions{
-		MetricTypePrefixes:        filterdPrefixes,
-		ExtraFilters:              h.metricsExtraFilters,
-		RequestInterval:           *monitoringMetricsInterval,
-		RequestOffset:             *monitoringMetricsOffset,
-		IngestDelay:               *monitoringMetricsIngestDelay,
-		FillMissingLabels:         *collectorFillMissingLabels,
-		DropDelegatedProjects:     *monitoringDropDelegatedProjects,
-		AggregateDeltas:           *monitoringMetricsAggregateDeltas,
-		DescriptorCacheTTL:        *monitoringDescriptorCacheTTL,
-		DescriptorCacheOnlyGoogle: *monitoringDescriptorCacheOnlyGoogle,
-	}, h.logger, delta.NewInMemoryCounterStore(h.logger, *monitoringMetricsDeltasTTL), delta.NewInMemoryHistogramStore(h.logger, *monitoringMetricsDeltasTTL))
+	stores := h.deltaStores(collectorKey)
+	collector, err := collectors.NewMonitoringCollector(project, h.m.forProject(project), h.collectorOptions(reloadable), h.logger, stores.counter, stores.histogram)
 	if err != nil {
 		return nil, err
 	}
@@ -263,36 +890,302 @@ func (h *handler) getCollector(project string, filters map[string]bool) (*collec
 	return collector, nil
 }
 
-func (h *handler) innerHandler(filters map[string]bool) http.Handler {
+// reloadableOptions returns the reloadable options of the collectors for a scrape selecting a metric type prefix
+// profile, none when empty, and collect filters.
+func (h *handler) reloadableOptions(profile string, filters map[string]bool) collectors.ReloadableOptions {
+	h.configMu.RLock()
+	defer h.configMu.RUnlock()
+	return collectors.ReloadableOptions{
+		MetricTypePrefixes: h.filterMetricTypePrefixes(profile, filters),
+		ExtraFilters:       h.metricsExtraFilters,
+		RequestInterval:    h.metricsInterval,
+		RequestOffset:      h.metricsOffset,
+	}
+}
+
+// collectorOptions returns the options of the collectors, the reloadable ones being those of the scrape.
+func (h *handler) collectorOptions(reloadable collectors.ReloadableOptions) collectors.MonitoringCollectorOptions {
+	return collectors.MonitoringCollectorOptions{
+		MetricTypePrefixes:          reloadable.MetricTypePrefixes,
+		ExtraFilters:                reloadable.ExtraFilters,
+		RequestInterval:             reloadable.RequestInterval,
+		RequestOffset:               reloadable.RequestOffset,
+		IngestDelay:                 *monitoringMetricsIngestDelay,
+		FillMissingLabels:           *collectorFillMissingLabels,
+		DropDelegatedProjects:       *monitoringDropDelegatedProjects,
+		AggregateDeltas:             *monitoringMetricsAggregateDeltas,
+		ClampCounterResets:          *monitoringClampCounterResets,
+		DescriptorCacheTTL:          *monitoringDescriptorCacheTTL,
+		DescriptorCacheOnlyGoogle:   *monitoringDescriptorCacheOnlyGoogle,
+		EmitDistributionRange:       *monitoringDistributionRange,
+		RetryMaxAttempts:            *monitoringRetryMaxAttempts,
+		RetryBaseDelay:              *monitoringRetryBaseDelay,
+		RetryBudget:                 h.retryBudget,
+		CircuitBreakerFailures:      *monitoringCircuitBreakerFailures,
+		CircuitBreakerCooldown:      *monitoringCircuitBreakerCooldown,
+		CircuitBreakers:             h.circuitBreakers,
+		MaxConcurrentRequests:       *monitoringMaxConcurrentRequests,
+		SanitizeLabelNames:          *monitoringSanitizeLabelNames,
+		DedupMaxSignatures:          *monitoringDedupMaxSignatures,
+		DedupByTimestamp:            *monitoringDedupByTimestamp,
+		DedupIgnoreLabels:           *monitoringDedupIgnoreLabels,
+		DedupHistoryDepth:           *monitoringDedupHistoryDepth,
+		DedupDebugCollisions:        *monitoringDedupDebugCollisions,
+		DedupHashSeed:               *monitoringDedupHashSeed,
+		DedupByResourceType:         *monitoringDedupByResourceType,
+		DedupDryRun:                 *monitoringDedupDryRun,
+		DedupHashAlgorithm:          *monitoringDedupHashAlgorithm,
+		SkipUnchangedSeries:         *monitoringSkipUnchangedSeries,
+		UnchangedSeriesMaxAge:       *monitoringUnchangedSeriesMaxAge,
+		CaseInsensitiveMetricNames:  *monitoringCaseInsensitiveMetricNames,
+		SplitLargeHistogramCounts:   *monitoringSplitLargeHistogramCounts,
+		EnableSystemLabels:          *monitoringSystemLabelsMode != string(collectors.SystemLabelsOff),
+		SystemLabelsMode:            collectors.SystemLabelsMode(*monitoringSystemLabelsMode),
+		EmitSystemLabelsSchema:      *monitoringSystemLabelsSchema,
+		SystemLabelsSchemaKey:       *monitoringSystemLabelsSchemaKey,
+		PromotedSystemLabels:        *monitoringPromotedSystemLabels,
+		MetricPrefix:                *monitoringMetricPrefix,
+		HistogramToSummaryThreshold: *monitoringHistogramToSummaryThreshold,
+		EmitMetricLastPointAge:      *monitoringMetricLastPointAge,
+		EmitAbsentMetrics:           *monitoringAbsentMetrics,
+		DescriptorPhaseTimeout:      *monitoringDescriptorPhaseTimeout,
+		TimeSeriesPhaseTimeout:      *monitoringTimeSeriesPhaseTimeout,
+		Aggregations:                h.metricsAggregations,
+		EmitRawMetricTypeLabel:      *monitoringRawMetricTypeLabel,
+		MaxLookback:                 *monitoringMaxLookback,
+		InitialLookback:             *monitoringInitialLookback,
+		AddMetricKindLabel:          *monitoringMetricKindLabel,
+		AddResourceTypeLabel:        *monitoringResourceTypeLabel,
+		AddLaunchStageLabel:         *monitoringLaunchStageLabel,
+		InferMissingDescriptors:     *monitoringInferMissingDescriptors,
+		DeltaAggregationTTL:         *monitoringMetricsDeltasTTL,
+		DropEmptyLabelValues:        *monitoringDropEmptyLabelValues,
+		MetricsScopeProject:         *monitoringMetricsScopeProject,
+		ProjectIDLabelSource:        collectors.ProjectIDLabelSource(*monitoringProjectIDLabel),
+		NormalizeUnits:              *monitoringNormalizeUnits,
+		AppendUnitSuffix:            *monitoringUnitSuffix,
+		LabelRenames:                *monitoringLabelRenames,
+		DeriveLabels:                h.labelDerivations,
+		ResourceTypeAllowlist:       *monitoringResourceTypeAllowlist,
+		ValueTypeAllowlist:          *monitoringValueTypeAllowlist,
+		ProjectIDAllowlist:          *monitoringProjectIDAllowlist,
+		ProjectIDDenylist:           *monitoringProjectIDDenylist,
+		PerRequestTimeout:           *monitoringPerRequestTimeout,
+		EmitStringMetrics:           *monitoringEmitStringMetrics,
+		Readiness:                   h.readiness,
+		DropLabels:                  *monitoringDropLabels,
+		DedupOnFullLabels:           *monitoringDropLabelsDedupOnFullLabels,
+		NativeHistograms:            *monitoringNativeHistograms,
+		Exemplars:                   *monitoringExemplars,
+		OmitPointTimestamps:         *monitoringOmitPointTimestamps,
+		DeltaCreatedTimestamps:      *monitoringDeltaCreatedTimestamps,
+		ResourceInfoMetric:          *monitoringResourceInfoMetric,
+		PointSelection:              collectors.PointSelection(*monitoringPointSelection),
+		MaxLabelValueLength:         *monitoringMaxLabelValueLength,
+		MaxSeriesPerMetricType:      *monitoringMaxSeriesPerMetricType,
+		RawDeltaPrefixes:            *monitoringRawDeltaPrefixes,
+		EphemeralDeltaPrefixes:      *monitoringEphemeralDeltaPrefixes,
+		HistogramBuckets:            *monitoringHistogramBuckets,
+		HistogramRebucketMode:       collectors.HistogramRebucketMode(*monitoringHistogramRebucketMode),
+		LabelSourcePrefixes:         *monitoringLabelSourcePrefixes,
+	}
+}
+
+// deltaStores returns the delta stores of a collector, kept by the delta persistence if enabled.
+func (h *handler) deltaStores(collectorKey string) *deltaStores {
+	if h.deltaPersistence != nil {
+		return h.deltaPersistence.deltaStores(collectorKey)
+	}
+	return &deltaStores{
+		counter:   delta.NewInMemoryCounterStore(h.logger, *monitoringMetricsDeltasTTL),
+		histogram: delta.NewInMemoryHistogramStore(h.logger, *monitoringMetricsDeltasTTL),
+	}
+}
+
+// warmup runs a collection of every project discarding its metrics, populating the delta stores so that the first
+// scrape already reports the DELTA metrics accumulated since. The collectors of the scrapes filtered by the collect
+// parameter have their own delta stores and aren't warmed up.
+func (h *handler) warmup(ctx context.Context) {
+	if h.retryBudget != nil {
+		h.retryBudget.Reset()
+	}
+	if *stackdriverMaxScrapeDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *stackdriverMaxScrapeDuration)
+		defer cancel()
+	}
+
+	start := time.Now()
+	if _, err := h.innerGatherer(ctx, "", nil).Gather(); err != nil {
+		h.logger.Warn("Error while warming up the delta stores", "err", err)
+	}
+	h.logger.Info("Warmed up the delta stores", "duration", time.Since(start))
+}
+
+// saveDeltaStores saves the delta stores to the snapshot file if the delta persistence is enabled.
+func (h *handler) saveDeltaStores() {
+	if h.deltaPersistence == nil {
+		return
+	}
+	if err := h.deltaPersistence.save(); err != nil {
+		h.logger.Error("Error saving the delta stores snapshot", "path", *deltaPersistencePath, "err", err)
+	}
+}
+
+func (h *handler) innerHandler(ctx context.Context, profile string, filters map[string]bool) http.Handler {
+	return h.handlerFor(h.innerGatherer(ctx, profile, filters))
+}
+
+// handlerFor returns the handler serving the Stackdriver metrics of a gatherer.
+func (h *handler) handlerFor(gatherer prometheus.Gatherer) http.Handler {
+	opts := promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(h.logger.Handler(), slog.LevelError),
+		// The text format has no exemplars nor created timestamps, OpenMetrics is negotiated with the scrapers
+		// accepting it
+		EnableOpenMetrics:                   *monitoringExemplars || *monitoringDeltaCreatedTimestamps,
+		EnableOpenMetricsTextCreatedSamples: *monitoringDeltaCreatedTimestamps,
+	}
+	// Delegate http serving to Prometheus client library, which will call collector.Collect.
+	return promhttp.HandlerFor(gatherer, opts)
+}
+
+// innerGatherer returns a gatherer of the collectors of every project for a profile and collect filters, along with
+// the additional gatherer. The collectors stop calling the Monitoring API once ctx is done.
+func (h *handler) innerGatherer(ctx context.Context, profile string, filters map[string]bool) prometheus.Gatherer {
+	return h.withAdditionalGatherer(h.stackdriverRegistry(ctx, profile, filters))
+}
+
+// stackdriverRegistry returns a registry of the collectors of every project for a profile and collect filters.
+func (h *handler) stackdriverRegistry(ctx context.Context, profile string, filters map[string]bool) *prometheus.Registry {
+	return h.registryOf(ctx, func(project string) (*collectors.MonitoringCollector, error) {
+		return h.getCollector(project, profile, filters)
+	})
+}
+
+// registryOf returns a registry of the collectors of every project returned by getCollector.
+func (h *handler) registryOf(ctx context.Context, getCollector func(project string) (*collectors.MonitoringCollector, error)) *prometheus.Registry {
 	registry := prometheus.NewRegistry()
+	registry.MustRegister(h.maxConcurrencyGlobal)
 
 	for _, project := range h.projectIDs {
-		monitoringCollector, err := h.getCollector(project, filters)
+		monitoringCollector, err := getCollector(project)
 		if err != nil {
 			h.logger.Error("error creating monitoring collector", "err", err)
 			os.Exit(1)
 		}
-		registry.MustRegister(monitoringCollector)
+		registry.MustRegister(h.limitProjectConcurrency(collectors.WithContext(ctx, monitoringCollector)))
+
+		if uptimeCheckCollector, ok := h.uptimeCheckCollectors[project]; ok {
+			registry.MustRegister(h.limitProjectConcurrency(collectors.WithContext(ctx, uptimeCheckCollector)))
+		}
+		if mqlCollector, ok := h.mqlCollectors[project]; ok {
+			registry.MustRegister(h.limitProjectConcurrency(collectors.WithContext(ctx, mqlCollector)))
+		}
 	}
-	var gatherers prometheus.Gatherer = registry
-	if h.additionalGatherer != nil {
-		gatherers = prometheus.Gatherers{
-			h.additionalGatherer,
-			registry,
+	return registry
+}
+
+// withAdditionalGatherer returns the gatherer along with the additional gatherer, if any.
+func (h *handler) withAdditionalGatherer(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	if h.additionalGatherer == nil {
+		return gatherer
+	}
+	return prometheus.Gatherers{
+		h.additionalGatherer,
+		gatherer,
+	}
+}
+
+// internalGatherer returns a gatherer of the metrics of the exporter itself, such as the API calls, deduplication
+// and scrape durations of the collectors of every project, without the Stackdriver metrics. Gathering it does not
+// call the Monitoring API, the metrics staying available when the API is down.
+func (h *handler) internalGatherer() prometheus.Gatherer {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(h.maxConcurrencyGlobal)
+	if h.retryBudget != nil {
+		registry.MustRegister(h.retryBudget)
+	}
+
+	for _, project := range h.projectIDs {
+		if _, err := h.getCollector(project, "", nil); err != nil {
+			h.logger.Error("error creating monitoring collector", "err", err)
+			os.Exit(1)
 		}
 	}
-	opts := promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(h.logger.Handler(), slog.LevelError)}
-	// Delegate http serving to Prometheus client library, which will call collector.Collect.
-	return promhttp.HandlerFor(gatherers, opts)
+	registry.MustRegister(&selfMetricsCollector{h: h})
+	return registry
 }
 
-// filterMetricTypePrefixes filters the initial list of metric type prefixes, with the ones coming from an individual
-// prometheus collect request.
-func (h *handler) filterMetricTypePrefixes(filters map[string]bool) []string {
-	filteredPrefixes := h.metricsPrefixes
+// selfMetricsCollector collects the metrics of the collector of every project itself. The collectors are resolved at
+// each collect, so that the metrics are those of the collectors created once the cached ones expired.
+type selfMetricsCollector struct {
+	h *handler
+}
+
+// Describe implements prometheus.Collector interface. It describes nothing, the collectors being resolved at each
+// collect.
+func (c *selfMetricsCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector interface.
+func (c *selfMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, project := range c.h.projectIDs {
+		monitoringCollector, err := c.h.getCollector(project, "", nil)
+		if err != nil {
+			c.h.logger.Error("error creating monitoring collector", "project_id", project, "err", err)
+			continue
+		}
+		monitoringCollector.SelfMetrics().Collect(ch)
+	}
+}
+
+// refreshDescriptorsHandler serves the POST requests forcing the cached collectors to list the metric descriptors
+// again on their next scrape, e.g. once new custom metrics were created, without waiting for the descriptor cache TTL.
+func refreshDescriptorsHandler(h *handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.collectors.RefreshDescriptorCaches()
+		h.logger.Info("Refreshing the metric descriptors on the next scrapes")
+		fmt.Fprintln(w, "OK")
+	}
+}
+
+// limitProjectConcurrency makes the collector of a project wait for a project slot before collecting. The registry
+// collects every registered collector concurrently, the slots bound how many projects are scraped at once.
+func (h *handler) limitProjectConcurrency(collector prometheus.Collector) prometheus.Collector {
+	if h.projectSlots == nil {
+		return collector
+	}
+	return &slotCollector{Collector: collector, slots: h.projectSlots}
+}
+
+// slotCollector is a collector holding a slot while collecting.
+type slotCollector struct {
+	prometheus.Collector
+	slots chan struct{}
+}
+
+// Collect implements prometheus.Collector interface.
+func (c *slotCollector) Collect(ch chan<- prometheus.Metric) {
+	c.slots <- struct{}{}
+	defer func() { <-c.slots }()
+	c.Collector.Collect(ch)
+}
+
+// filterMetricTypePrefixes filters the initial list of metric type prefixes, or the ones of the profile if any, with
+// the ones coming from an individual prometheus collect request.
+func (h *handler) filterMetricTypePrefixes(profile string, filters map[string]bool) []string {
+	prefixes := h.metricsPrefixes
+	if profile != "" {
+		prefixes = h.metricsPrefixProfiles[profile]
+	}
+	filteredPrefixes := prefixes
 	if len(filters) > 0 {
 		filteredPrefixes = nil
-		for _, prefix := range h.metricsPrefixes {
+		for _, prefix := range prefixes {
 			for filter := range filters {
 				if strings.HasPrefix(filter, prefix) {
 					filteredPrefixes = append(filteredPrefixes, filter)
@@ -303,6 +1196,33 @@ func (h *handler) filterMetricTypePrefixes(filters map[string]bool) []string {
 	return parseMetricTypePrefixes(filteredPrefixes)
 }
 
+// collectorKey returns the key of the collector of a project for a profile and collect filters. The profile is
+// separated by a slash, absent from the project IDs, so that the keys of distinct projects and profiles differ.
+func collectorKey(project, profile string, filters map[string]bool) string {
+	if profile != "" {
+		project += "/" + profile
+	}
+	return fmt.Sprintf("%s-%v", project, slices.Sorted(maps.Keys(filters)))
+}
+
+// flagMetricPrefixProfiles returns the metric type prefixes of the profiles of the flags by name.
+func flagMetricPrefixProfiles() map[string][]string {
+	profiles := make(map[string][]string, len(*monitoringMetricsPrefixProfiles))
+	for name, prefixes := range *monitoringMetricsPrefixProfiles {
+		profiles[name] = strings.Split(prefixes, ",")
+	}
+	return profiles
+}
+
+// parseMetricPrefixProfiles normalizes and deduplicates the metric type prefixes of the profiles.
+func parseMetricPrefixProfiles(profiles map[string][]string) map[string][]string {
+	parsed := make(map[string][]string, len(profiles))
+	for name, prefixes := range profiles {
+		parsed[name] = parseMetricTypePrefixes(prefixes)
+	}
+	return parsed
+}
+
 func main() {
 	promslogConfig := &promslog.Config{}
 	flag.AddFlags(kingpin.CommandLine, promslogConfig)
@@ -312,13 +1232,21 @@ func main() {
 	kingpin.Parse()
 
 	logger := promslog.New(promslogConfig)
+	if *configCheck {
+		if err := checkConfig(logger); err != nil {
+			logger.Error("Invalid configuration", "err", err)
+			os.Exit(1)
+		}
+		logger.Info("Configuration is valid")
+		return
+	}
 	if *projectID != "" {
 		logger.Warn("The google.project-id flag is deprecated and will be replaced by google.project-ids.")
 	}
 	if *monitoringMetricsTypePrefixes != "" {
 		logger.Warn("The monitoring.metrics-type-prefixes flag is deprecated and will be replaced by monitoring.metrics-prefix.")
 	}
-	if *monitoringMetricsTypePrefixes == "" && len(*monitoringMetricsPrefixes) == 0 {
+	if *monitoringMetricsTypePrefixes == "" && len(*monitoringMetricsPrefixes) == 0 && *configFile == "" {
 		logger.Error("At least one GCP monitoring prefix is required.")
 		os.Exit(1)
 	}
@@ -337,7 +1265,19 @@ func main() {
 		discoveredProjectIDs = append(discoveredProjectIDs, *defaultProject)
 	}
 
-	monitoringService, err := createMonitoringService(ctx)
+	retryBudget := collectors.NewRetryBudget(*stackdriverScrapeRetryBudget)
+	prometheus.MustRegister(retryBudget)
+
+	limiter := newRateLimiter(*monitoringMaxQPS)
+
+	monitoringService, err := createMonitoringService(ctx, retryBudget, limiter, "")
+	if err != nil {
+		logger.Error("failed to create monitoring service", "err", err)
+		os.Exit(1)
+	}
+	monitoringServices, err := newMonitoringServices(monitoringService, *googleProjectCredentials, func(credentialsFile string) (*monitoring.Service, error) {
+		return createMonitoringService(ctx, retryBudget, limiter, credentialsFile)
+	})
 	if err != nil {
 		logger.Error("failed to create monitoring service", "err", err)
 		os.Exit(1)
@@ -359,13 +1299,7 @@ func main() {
 		discoveredProjectIDs = append(discoveredProjectIDs, strings.Split(*projectID, ",")...)
 	}
 
-	var metricsPrefixes []string
-	if len(*monitoringMetricsPrefixes) > 0 {
-		metricsPrefixes = append(metricsPrefixes, *monitoringMetricsPrefixes...)
-	}
-	if *monitoringMetricsTypePrefixes != "" {
-		metricsPrefixes = append(metricsPrefixes, strings.Split(*monitoringMetricsTypePrefixes, ",")...)
-	}
+	metricsPrefixes := flagMetricTypePrefixes()
 
 	logger.Info(
 		"Starting stackdriver_exporter",
@@ -377,24 +1311,126 @@ func main() {
 		"projectsFilter", *projectsFilter,
 	)
 
+	warnAmbiguousMetricTypePrefixes(logger, metricsPrefixes)
 	parsedMetricsPrefixes := parseMetricTypePrefixes(metricsPrefixes)
-	metricExtraFilters := parseMetricExtraFilters()
+	metricExtraFilters := parseMetricExtraFilters(*monitoringMetricsExtraFilter)
+	metricAggregations, err := parseMetricAggregations(*monitoringAggregations)
+	if err != nil {
+		logger.Error("failed to parse monitoring aggregations", "err", err)
+		os.Exit(1)
+	}
+	labelDerivations, err := parseLabelDerivations(*monitoringDeriveLabels)
+	if err != nil {
+		logger.Error("failed to parse label derivations", "err", err)
+		os.Exit(1)
+	}
+	mqlQueries, err := collectors.ParseMQLQueries(*monitoringMQLQueries)
+	if err != nil {
+		logger.Error("failed to parse MQL queries", "err", err)
+		os.Exit(1)
+	}
 	// drop duplicate projects
 	slices.Sort(discoveredProjectIDs)
 	uniqueProjectIds := slices.Compact(discoveredProjectIDs)
+	if err := checkMetricsScopeProject(uniqueProjectIds); err != nil {
+		logger.Error("invalid metrics scope project", "err", err)
+		os.Exit(1)
+	}
 
+	if *listDescriptorsMode {
+		descriptors, err := listDescriptors(ctx, monitoringServices, uniqueProjectIds, parsedMetricsPrefixes)
+		if err != nil {
+			logger.Error("failed to list metric descriptors", "err", err)
+			os.Exit(1)
+		}
+		if err := writeDescriptors(os.Stdout, *listDescriptorsFormat, descriptors); err != nil {
+			logger.Error("failed to write metric descriptors", "err", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var handler *handler
 	if *metricsPath == *stackdriverMetricsPath {
-		handler := newHandler(
-			uniqueProjectIds, parsedMetricsPrefixes, metricExtraFilters, monitoringService, logger, prometheus.DefaultGatherer)
+		handler = newHandler(
+			uniqueProjectIds, parsedMetricsPrefixes, metricExtraFilters, metricAggregations, labelDerivations, mqlQueries, monitoringServices, retryBudget, logger, prometheus.DefaultGatherer)
 		http.Handle(*metricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler))
 	} else {
 		logger.Info("Serving Stackdriver metrics at separate path", "path", *stackdriverMetricsPath)
-		handler := newHandler(
-			uniqueProjectIds, parsedMetricsPrefixes, metricExtraFilters, monitoringService, logger, nil)
+		handler = newHandler(
+			uniqueProjectIds, parsedMetricsPrefixes, metricExtraFilters, metricAggregations, labelDerivations, mqlQueries, monitoringServices, retryBudget, logger, nil)
 		http.Handle(*stackdriverMetricsPath, promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler))
 		http.Handle(*metricsPath, promhttp.Handler())
 	}
 
+	if *configFile != "" {
+		if err := handler.reloadConfig(*configFile); err != nil {
+			logger.Error("failed to load the config file", "err", err)
+			os.Exit(1)
+		}
+		http.Handle("/-/reload", reloadHandler(handler, *configFile))
+		go reloadOnSignal(handler, *configFile, logger)
+	}
+
+	if *configPrint {
+		config, err := marshalEffectiveConfig(kingpin.CommandLine, handler.collectorOptions(handler.reloadableOptions("", nil)))
+		if err != nil {
+			logger.Error("failed to marshal the effective configuration", "err", err)
+			os.Exit(1)
+		}
+		logger.Info("Effective configuration", "config", string(config))
+	}
+
+	if *deltaWarmup {
+		handler.warmup(ctx)
+	}
+
+	// The background collections stop on shutdown, before the delta stores are saved
+	backgroundCtx, cancelBackground := context.WithCancel(ctx)
+	var background sync.WaitGroup
+
+	if *monitoringCacheScrapeResults {
+		logger.Info("Caching the scrape results", "interval", *monitoringCacheScrapeInterval)
+		handler.scrapeCache = newScrapeCache(handler.collectStackdriverMetrics, *monitoringCacheScrapeInterval, logger)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			handler.scrapeCache.run(backgroundCtx)
+		}()
+	}
+
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.probeReadiness(backgroundCtx, readinessProbeInterval)
+	}()
+
+	if *monitoringBackfillEndpoint {
+		http.Handle("/-/backfill", backfillHandler(handler, *monitoringBackfillMaxWindow, *monitoringBackfillTimeout))
+	}
+
+	if *monitoringDescriptorCacheTTL > 0 {
+		http.Handle("/-/refresh-descriptors", refreshDescriptorsHandler(handler))
+	}
+
+	if *internalMetricsPath != "" {
+		opts := promhttp.HandlerOpts{ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError)}
+		http.Handle(*internalMetricsPath, promhttp.HandlerFor(handler.internalGatherer(), opts))
+	}
+
+	http.HandleFunc("/healthz", healthzHandler)
+	http.Handle("/readyz", readyzHandler(handler.readiness))
+
+	if *pushGatewayURL != "" {
+		logger.Info("Pushing Stackdriver metrics", "url", *pushGatewayURL, "job", *pushJob, "interval", *pushInterval)
+		sink := newPushSink(*pushGatewayURL, *pushJob, *pushIdentityLabels, *pushInterval, handler.pushGatherer(backgroundCtx), logger)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			sink.run(backgroundCtx)
+		}()
+	}
+
 	if *metricsPath != "/" && *metricsPath != "" {
 		landingConfig := web.LandingConfig{
 			Name:        "Stackdriver Exporter",
@@ -415,6 +1451,14 @@ func main() {
 				},
 			)
 		}
+		if *internalMetricsPath != "" {
+			landingConfig.Links = append(landingConfig.Links,
+				web.LandingLinks{
+					Address: *internalMetricsPath,
+					Text:    "Internal Metrics",
+				},
+			)
+		}
 		landingPage, err := web.NewLandingPage(landingConfig)
 		if err != nil {
 			logger.Error("error creating landing page", "err", err)
@@ -424,18 +1468,83 @@ func main() {
 	}
 
 	srv := &http.Server{}
-	if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil {
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		shutdownOnSignal(srv, cancelBackground, logger)
+	}()
+	if err := web.ListenAndServe(srv, toolkitFlags, logger); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		logger.Error("Error starting server", "err", err)
 		os.Exit(1)
 	}
+	// ListenAndServe returns as soon as the shutdown begins, the scrapes in flight and the background collections
+	// still updating the delta stores
+	<-shutdown
+	background.Wait()
+	handler.saveDeltaStores()
+}
+
+// shutdownOnSignal gracefully shuts the server down on SIGTERM or SIGINT. The background collections are cancelled
+// first, then the scrapes in flight are let complete within shutdownTimeout.
+func shutdownOnSignal(srv *http.Server, cancelBackground context.CancelFunc, logger *slog.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	sig := <-signals
+	signal.Stop(signals)
+
+	logger.Info("Shutting down", "signal", sig.String())
+	cancelBackground()
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warn("Error shutting the server down", "err", err)
+	}
+}
+
+// flagMetricTypePrefixes returns the metric type prefixes of the monitoring.metrics-prefixes and deprecated
+// monitoring.metrics-type-prefixes flags.
+func flagMetricTypePrefixes() []string {
+	var metricsPrefixes []string
+	if len(*monitoringMetricsPrefixes) > 0 {
+		metricsPrefixes = append(metricsPrefixes, *monitoringMetricsPrefixes...)
+	}
+	if *monitoringMetricsTypePrefixes != "" {
+		metricsPrefixes = append(metricsPrefixes, strings.Split(*monitoringMetricsTypePrefixes, ",")...)
+	}
+	return metricsPrefixes
+}
+
+// normalizeMetricTypePrefix drops the leading and trailing slashes of a metric type prefix. Metric type prefixes
+// are matched as plain string prefixes, so with or without a trailing slash a prefix matches the same metric types:
+// compute.googleapis.com/instance/ matches compute.googleapis.com/instance_group as well.
+func normalizeMetricTypePrefix(prefix string) string {
+	return strings.Trim(prefix, "/")
+}
+
+// warnAmbiguousMetricTypePrefixes warns about the prefixes changed by normalizeMetricTypePrefix, as they match
+// more metric types than their configured form suggests.
+func warnAmbiguousMetricTypePrefixes(logger *slog.Logger, prefixes []string) {
+	for _, prefix := range prefixes {
+		if normalized := normalizeMetricTypePrefix(prefix); normalized != prefix {
+			logger.Warn("Metric type prefix has leading or trailing slashes, they are ignored and the prefix matches every metric type starting with the normalized prefix",
+				"prefix", prefix, "normalized", normalized)
+		}
+	}
 }
 
 func parseMetricTypePrefixes(inputPrefixes []string) []string {
 	metricTypePrefixes := []string{}
 
+	normalizedPrefixes := make([]string, 0, len(inputPrefixes))
+	for _, prefix := range inputPrefixes {
+		if normalized := normalizeMetricTypePrefix(prefix); normalized != "" {
+			normalizedPrefixes = append(normalizedPrefixes, normalized)
+		}
+	}
+
 	// Drop duplicate prefixes.
-	slices.Sort(inputPrefixes)
-	uniquePrefixes := slices.Compact(inputPrefixes)
+	slices.Sort(normalizedPrefixes)
+	uniquePrefixes := slices.Compact(normalizedPrefixes)
 
 	// Drop prefixes that start with another existing prefix to avoid error:
 	// "collected metric xxx was collected before with the same name and label values".
@@ -454,11 +1563,12 @@ func parseMetricTypePrefixes(inputPrefixes []string) []string {
 	return metricTypePrefixes
 }
 
-func parseMetricExtraFilters() []collectors.MetricFilter {
+func parseMetricExtraFilters(values []string) []collectors.MetricFilter {
 	var extraFilters []collectors.MetricFilter
-	for _, ef := range *monitoringMetricsExtraFilter {
+	for _, ef := range values {
 		targetedMetricPrefix, filterQuery := utils.SplitExtraFilter(ef, ":")
-		if targetedMetricPrefix != "" {
+		// An empty targeted prefix applies the filter to every metric prefix
+		if filterQuery != "" {
 			extraFilter := collectors.MetricFilter{
 				TargetedMetricPrefix: strings.ToLower(targetedMetricPrefix),
 				FilterQuery:          filterQuery,
@@ -468,3 +1578,55 @@ func parseMetricExtraFilters() []collectors.MetricFilter {
 	}
 	return extraFilters
 }
+
+// parseLabelDerivations parses label derivations formatted as <source>:<target>:<regex>:<replace>, the regex being
+// the text between the second and the last colons.
+func parseLabelDerivations(values []string) ([]collectors.LabelDerivation, error) {
+	var derivations []collectors.LabelDerivation
+	for _, value := range values {
+		parts := strings.SplitN(value, ":", 3)
+		last := strings.LastIndex(value, ":")
+		if len(parts) < 3 || last < len(parts[0])+len(parts[1])+2 {
+			return nil, fmt.Errorf("invalid label derivation %q, expected <source>:<target>:<regex>:<replace>", value)
+		}
+		derivations = append(derivations, collectors.LabelDerivation{
+			Source:  parts[0],
+			Target:  parts[1],
+			Regex:   value[len(parts[0])+len(parts[1])+2 : last],
+			Replace: value[last+1:],
+		})
+	}
+	return derivations, nil
+}
+
+// parseMetricAggregations parses aggregations formatted as
+// <targeted_metric_prefix>:<alignment_period>:<per_series_aligner>[:<cross_series_reducer>[:<group_by_field>,...]].
+func parseMetricAggregations(values []string) ([]collectors.Aggregation, error) {
+	var aggregations []collectors.Aggregation
+	for _, value := range values {
+		parts := strings.Split(value, ":")
+		if len(parts) < 3 || len(parts) > 5 {
+			return nil, fmt.Errorf("invalid aggregation %q, expected <prefix>:<alignment_period>:<aligner>[:<reducer>[:<group_by_fields>]]", value)
+		}
+		alignmentPeriod, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid alignment period of aggregation %q: %w", value, err)
+		}
+		aggregation := collectors.Aggregation{
+			TargetedMetricPrefix: strings.ToLower(parts[0]),
+			AlignmentPeriod:      alignmentPeriod,
+			PerSeriesAligner:     parts[2],
+		}
+		if len(parts) > 3 {
+			aggregation.CrossSeriesReducer = parts[3]
+		}
+		if len(parts) > 4 && parts[4] != "" {
+			aggregation.GroupByFields = strings.Split(parts[4], ",")
+		}
+		if err := aggregation.Validate(); err != nil {
+			return nil, err
+		}
+		aggregations = append(aggregations, aggregation)
+	}
+	return aggregations, nil
+}
@@ -13,8 +13,34 @@
 
 package main
 
-import "testing"
-import "reflect"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/promslog"
+	"github.com/prometheus/common/promslog/flag"
+	"golang.org/x/net/context"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"github.com/prometheus-community/stackdriver_exporter/collectors"
+)
 
 func TestParseMetricTypePrefixes(t *testing.T) {
 	inputPrefixes := []string{
@@ -36,6 +62,38 @@ func TestParseMetricTypePrefixes(t *testing.T) {
 	}
 }
 
+func TestParseMetricTypePrefixesSlashes(t *testing.T) {
+	metricTypes := []string{
+		"compute.googleapis.com/instance/cpu/usage_time",
+		"compute.googleapis.com/instance/uptime",
+		"compute.googleapis.com/instance_group/size",
+		"compute.googleapis.com/firewall/dropped_packets_count",
+	}
+	matching := func(prefixes []string) []string {
+		var matched []string
+		for _, metricType := range metricTypes {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(metricType, prefix) {
+					matched = append(matched, metricType)
+					break
+				}
+			}
+		}
+		return matched
+	}
+
+	expected := matching(parseMetricTypePrefixes([]string{"compute.googleapis.com/instance"}))
+	for _, prefix := range []string{"compute.googleapis.com/instance/", "/compute.googleapis.com/instance", "compute.googleapis.com/instance//"} {
+		if got := matching(parseMetricTypePrefixes([]string{prefix})); !reflect.DeepEqual(got, expected) {
+			t.Errorf("prefix %q should match %v, got %v", prefix, expected, got)
+		}
+	}
+
+	if got := parseMetricTypePrefixes([]string{"compute.googleapis.com/instance/", "compute.googleapis.com/instance", "/"}); !reflect.DeepEqual(got, []string{"compute.googleapis.com/instance"}) {
+		t.Errorf("both forms of a prefix should be deduplicated, got %v", got)
+	}
+}
+
 func TestFilterMetricTypePrefixes(t *testing.T) {
 	metricPrefixes := []string{
 		"redis.googleapis.com/stats/",
@@ -55,9 +113,826 @@ func TestFilterMetricTypePrefixes(t *testing.T) {
 		"redis.googleapis.com/stats/memory/usage",
 	}
 
-	outputPrefixes := h.filterMetricTypePrefixes(inputFilters)
+	outputPrefixes := h.filterMetricTypePrefixes("", inputFilters)
 
 	if !reflect.DeepEqual(outputPrefixes, expectedOutputPrefixes) {
 		t.Errorf("filterMetricTypePrefixes did not produce expected output. Expected:\n%s\nGot:\n%s", expectedOutputPrefixes, outputPrefixes)
 	}
 }
+
+func TestHandlerProfiles(t *testing.T) {
+	defer func(profiles map[string]string) { *monitoringMetricsPrefixProfiles = profiles }(*monitoringMetricsPrefixProfiles)
+	*monitoringMetricsPrefixProfiles = map[string]string{
+		"cheap":     "compute.googleapis.com/instance/cpu",
+		"expensive": "loadbalancing.googleapis.com,pubsub.googleapis.com",
+	}
+
+	var mu sync.Mutex
+	var descriptorFilters []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/metricDescriptors") {
+			mu.Lock()
+			descriptorFilters = append(descriptorFilters, r.URL.Query().Get("filter"))
+			mu.Unlock()
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	// scrape serves a scrape of the query and returns its status code and the sorted descriptor filters it requested
+	scrape := func(query string) (int, []string) {
+		mu.Lock()
+		descriptorFilters = nil
+		mu.Unlock()
+		recorder := httptest.NewRecorder()
+		h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics?"+query, nil))
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(descriptorFilters)
+		return recorder.Code, descriptorFilters
+	}
+
+	for _, tc := range []struct {
+		query   string
+		filters []string
+	}{
+		{"", []string{`metric.type = starts_with("compute.googleapis.com/instance")`}},
+		{"profile=cheap", []string{`metric.type = starts_with("compute.googleapis.com/instance/cpu")`}},
+		{"profile=expensive", []string{`metric.type = starts_with("loadbalancing.googleapis.com")`, `metric.type = starts_with("pubsub.googleapis.com")`}},
+		{"profile=expensive&collect=pubsub.googleapis.com/subscription", []string{`metric.type = starts_with("pubsub.googleapis.com/subscription")`}},
+	} {
+		if code, filters := scrape(tc.query); code != http.StatusOK || !reflect.DeepEqual(filters, tc.filters) {
+			t.Errorf("expected the scrape of %q to request %v, got %d and %v", tc.query, tc.filters, code, filters)
+		}
+	}
+	if code, filters := scrape("profile=unknown"); code != http.StatusBadRequest || len(filters) > 0 {
+		t.Errorf("expected the scrape of an unknown profile to be rejected, got %d and %v", code, filters)
+	}
+}
+
+func TestParseMetricExtraFilters(t *testing.T) {
+	defer func(filters []string) { *monitoringMetricsExtraFilter = filters }(*monitoringMetricsExtraFilter)
+	*monitoringMetricsExtraFilter = []string{
+		`Compute.googleapis.com/instance:resource.labels.zone="us-central1-a"`,
+		`:metadata.user_labels.team="infra"`,
+		"pubsub.googleapis.com/no/filter",
+		"pubsub.googleapis.com/empty:",
+	}
+
+	expected := []collectors.MetricFilter{
+		{TargetedMetricPrefix: "compute.googleapis.com/instance", FilterQuery: `resource.labels.zone="us-central1-a"`},
+		{TargetedMetricPrefix: "", FilterQuery: `metadata.user_labels.team="infra"`},
+	}
+	if got := parseMetricExtraFilters(*monitoringMetricsExtraFilter); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Extra filters parsing did not produce expected output. Expected:\n%v\nGot:\n%v", expected, got)
+	}
+}
+
+func TestParseMetricAggregations(t *testing.T) {
+	got, err := parseMetricAggregations([]string{
+		"Compute.googleapis.com/instance/cpu:60s:ALIGN_RATE",
+		"pubsub.googleapis.com/topic:5m:ALIGN_DELTA:REDUCE_SUM:resource.labels.topic_id,metric.labels.response_code",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []collectors.Aggregation{
+		{TargetedMetricPrefix: "compute.googleapis.com/instance/cpu", AlignmentPeriod: time.Minute, PerSeriesAligner: "ALIGN_RATE"},
+		{
+			TargetedMetricPrefix: "pubsub.googleapis.com/topic",
+			AlignmentPeriod:      5 * time.Minute,
+			PerSeriesAligner:     "ALIGN_DELTA",
+			CrossSeriesReducer:   "REDUCE_SUM",
+			GroupByFields:        []string{"resource.labels.topic_id", "metric.labels.response_code"},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Aggregations parsing did not produce expected output. Expected:\n%v\nGot:\n%v", expected, got)
+	}
+
+	for _, invalid := range []string{
+		"compute.googleapis.com/instance/cpu:ALIGN_RATE",
+		"compute.googleapis.com/instance/cpu:a minute:ALIGN_RATE",
+		"compute.googleapis.com/instance/cpu:60s:ALIGN_NONE:REDUCE_SUM",
+	} {
+		if _, err := parseMetricAggregations([]string{invalid}); err == nil {
+			t.Errorf("expected an error parsing aggregation %q", invalid)
+		}
+	}
+}
+
+func TestParseLabelDerivations(t *testing.T) {
+	got, err := parseLabelDerivations([]string{
+		"zone:region:(.+)-[a-z]:$1",
+		"instance_name:pool:(?:[a-z]+):([a-z]+)-[0-9]+:pool_$1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []collectors.LabelDerivation{
+		{Source: "zone", Target: "region", Regex: "(.+)-[a-z]", Replace: "$1"},
+		{Source: "instance_name", Target: "pool", Regex: "(?:[a-z]+):([a-z]+)-[0-9]+", Replace: "pool_$1"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Label derivations parsing did not produce expected output. Expected:\n%v\nGot:\n%v", expected, got)
+	}
+
+	for _, invalid := range []string{"zone:region", "zone:region:(.+)-[a-z]"} {
+		if _, err := parseLabelDerivations([]string{invalid}); err == nil {
+			t.Errorf("expected an error parsing label derivation %q", invalid)
+		}
+	}
+}
+
+func TestHandlerLabelDerivations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		value := 0.5
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+				MetricDescriptors: []*monitoring.MetricDescriptor{
+					{Type: "compute.googleapis.com/instance/cpu/utilization", MetricKind: "GAUGE", ValueType: "DOUBLE"},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			_ = json.NewEncoder(w).Encode(&monitoring.ListTimeSeriesResponse{
+				TimeSeries: []*monitoring.TimeSeries{{
+					Metric:     &monitoring.Metric{Type: "compute.googleapis.com/instance/cpu/utilization"},
+					Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "my-project", "zone": "us-central1-a"}},
+					MetricKind: "GAUGE",
+					ValueType:  "DOUBLE",
+					Points: []*monitoring.Point{{
+						Interval: &monitoring.TimeInterval{EndTime: time.Now().Format(time.RFC3339Nano)},
+						Value:    &monitoring.TypedValue{DoubleValue: &value},
+					}},
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	derivations, err := parseLabelDerivations([]string{"zone:region:(.+)-[a-z]:$1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, derivations, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the scrape to succeed, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, `region="us-central1"`) {
+		t.Errorf("expected the collectors built by the handler to derive the region label, got:\n%s", body)
+	}
+}
+
+func TestRetryTransportScrapeBudget(t *testing.T) {
+	*stackdriverMaxRetries = 3
+	*stackdriverRetryStatuses = []int{http.StatusServiceUnavailable}
+	*stackdriverBackoffJitterBase = time.Nanosecond
+	*stackdriverMaxBackoffDuration = time.Nanosecond
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	const budget = 5
+	const calls = 10
+	retryBudget := collectors.NewRetryBudget(budget)
+	client := &http.Client{Transport: newRetryTransport(http.DefaultTransport, retryBudget)}
+
+	doCalls := func() {
+		for i := 0; i < calls; i++ {
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+		}
+	}
+
+	doCalls()
+	if got := requests.Load(); got != calls+budget {
+		t.Errorf("expected %d requests (calls + retry budget), got %d", calls+budget, got)
+	}
+	// The first call consumes 3 retries, the second one the 2 remaining before being
+	// denied, every following call is denied its first retry.
+	if got := testutil.ToFloat64(retryBudget); got != calls-1 {
+		t.Errorf("expected %d exhausted retries, got %v", calls-1, got)
+	}
+
+	// A new scrape refills the budget
+	requests.Store(0)
+	retryBudget.Reset()
+	doCalls()
+	if got := requests.Load(); got != calls+budget {
+		t.Errorf("expected %d requests after reset, got %d", calls+budget, got)
+	}
+
+	// The Monitoring API call retries replace the transport ones
+	*monitoringRetryMaxAttempts = 2
+	defer func() { *monitoringRetryMaxAttempts = 0 }()
+	requests.Store(0)
+	retryBudget.Reset()
+	client = &http.Client{Transport: newRetryTransport(http.DefaultTransport, retryBudget)}
+	doCalls()
+	if got := requests.Load(); got != calls {
+		t.Errorf("expected %d requests without transport retries, got %d", calls, got)
+	}
+}
+
+// slowProjectCollector is a project collector recording how many projects are collected at once.
+type slowProjectCollector struct {
+	desc              *prometheus.Desc
+	inFlight, maxSeen *atomic.Int64
+}
+
+func (c *slowProjectCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *slowProjectCollector) Collect(ch chan<- prometheus.Metric) {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		seen := c.maxSeen.Load()
+		if n <= seen || c.maxSeen.CompareAndSwap(seen, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, 1)
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	var mu sync.Mutex
+	var dispatched []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		dispatched = append(dispatched, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	const qps = 20
+	const calls = 5
+	client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, newRateLimiter(qps))}
+
+	start := time.Now()
+	for i := 0; i < calls; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	// The first call is sent right away, the next ones once a token is granted every 1/qps
+	if elapsed, expected := time.Since(start), (calls-1)*time.Second/qps; elapsed < expected*9/10 {
+		t.Errorf("expected the %d calls to take at least %v, took %v", calls, expected, elapsed)
+	}
+	for i := 1; i < len(dispatched); i++ {
+		if gap := dispatched[i].Sub(dispatched[i-1]); gap < time.Second/qps/2 {
+			t.Errorf("expected the calls to be spaced out by %v, calls %d and %d were %v apart", time.Second/qps, i-1, i, gap)
+		}
+	}
+
+	t.Run("cancelled wait", func(t *testing.T) {
+		limiter := newRateLimiter(0.1)
+		limiter.Allow() // takes the only token, the next one being 10s away
+		client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, limiter)}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := time.Now()
+		if _, err := client.Do(req); err == nil {
+			t.Fatal("expected the call to fail once the context is done")
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("expected the wait to be given up with the context, took %v", elapsed)
+		}
+		if len(dispatched) != calls {
+			t.Errorf("expected the cancelled call not to be sent, got %d calls", len(dispatched))
+		}
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		if newRateLimiter(0) != nil {
+			t.Error("expected no limiter when the max QPS is 0")
+		}
+		if transport := newRateLimitTransport(http.DefaultTransport, nil); transport != http.DefaultTransport {
+			t.Error("expected the transport to be left as is without a limiter")
+		}
+	})
+}
+
+func TestLimitProjectConcurrency(t *testing.T) {
+	const projects = 6
+	gather := func(maxConcurrentProjects int) (string, int64) {
+		h := &handler{projectSlots: make(chan struct{}, maxConcurrentProjects)}
+		var inFlight, maxSeen atomic.Int64
+		registry := prometheus.NewRegistry()
+		for i := 0; i < projects; i++ {
+			registry.MustRegister(h.limitProjectConcurrency(&slowProjectCollector{
+				desc:     prometheus.NewDesc("stackdriver_test_metric", "Test metric.", nil, prometheus.Labels{"project_id": fmt.Sprintf("project-%d", i)}),
+				inFlight: &inFlight,
+				maxSeen:  &maxSeen,
+			}))
+		}
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		for _, family := range families {
+			if _, err := expfmt.MetricFamilyToText(&out, family); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return out.String(), maxSeen.Load()
+	}
+
+	sequential, maxSeen := gather(1)
+	if maxSeen != 1 {
+		t.Errorf("expected projects to be collected one at a time, got %d at once", maxSeen)
+	}
+	concurrent, maxSeen := gather(3)
+	if maxSeen != 3 {
+		t.Errorf("expected 3 projects to be collected at once, got %d", maxSeen)
+	}
+	if concurrent != sequential {
+		t.Errorf("concurrent collection output differs from sequential collection:\n%s\nvs\n%s", concurrent, sequential)
+	}
+	if got := strings.Count(concurrent, "stackdriver_test_metric{"); got != projects {
+		t.Errorf("expected %d series, got %d", projects, got)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestGoogleTokenSourceImpersonation(t *testing.T) {
+	var requestedURL string
+	iamCredentials := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requestedURL = r.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"accessToken":"impersonated-token","expireTime":"2099-01-01T00:00:00Z"}`)),
+		}, nil
+	})}
+
+	tokenSource, err := googleTokenSource(context.Background(), "reader@central-project.iam.gserviceaccount.com", "", option.WithHTTPClient(iamCredentials))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "impersonated-token" {
+		t.Errorf("expected the impersonated token, got %q", token.AccessToken)
+	}
+	if expected := "/v1/projects/-/serviceAccounts/reader@central-project.iam.gserviceaccount.com:generateAccessToken"; !strings.HasSuffix(requestedURL, expected) {
+		t.Errorf("expected a token generated for the impersonated service account, got request to %s", requestedURL)
+	}
+}
+
+func TestLogFormat(t *testing.T) {
+	for _, tt := range []struct {
+		format      string
+		handlerType slog.Handler
+	}{
+		{"logfmt", &slog.TextHandler{}},
+		{"json", &slog.JSONHandler{}},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			promslogConfig := &promslog.Config{}
+			app := kingpin.New("stackdriver_exporter", "")
+			flag.AddFlags(app, promslogConfig)
+			if _, err := app.Parse([]string{"--log.format=" + tt.format, "--log.level=debug"}); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			promslogConfig.Writer = &buf
+			logger := promslog.New(promslogConfig)
+			if reflect.TypeOf(logger.Handler()) != reflect.TypeOf(tt.handlerType) {
+				t.Fatalf("expected a %T handler, got %T", tt.handlerType, logger.Handler())
+			}
+
+			dedup := collectors.NewMetricDeduplicator(logger, "test-project", collectors.MetricDeduplicatorOptions{})
+			now := time.Now()
+			dedup.CheckAndMark("", "test_metric", nil, nil, now)
+			dedup.CheckAndMark("", "test_metric", nil, nil, now)
+
+			if tt.format != "json" {
+				if !strings.Contains(buf.String(), "component=deduplicator") {
+					t.Errorf("expected the component attribute in %s", buf.String())
+				}
+				return
+			}
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("expected a JSON record, got %s: %v", buf.String(), err)
+			}
+			if record["component"] != "deduplicator" {
+				t.Errorf("expected the component key in %v", record)
+			}
+		})
+	}
+}
+
+func TestMaxConcurrencyGlobal(t *testing.T) {
+	defer func(maxConcurrentProjects int) { *monitoringMaxConcurrentProjects = maxConcurrentProjects }(*monitoringMaxConcurrentProjects)
+
+	for _, maxConcurrentProjects := range []int{0, 4} {
+		*monitoringMaxConcurrentProjects = maxConcurrentProjects
+		h := newHandler(nil, nil, nil, nil, nil, nil, nil, nil, promslog.NewNopLogger(), nil)
+
+		expected := fmt.Sprintf(`
+# HELP stackdriver_collector_max_concurrency_global Max number of projects collected concurrently during a scrape, 0 means unlimited.
+# TYPE stackdriver_collector_max_concurrency_global gauge
+stackdriver_collector_max_concurrency_global %d
+`, maxConcurrentProjects)
+		if err := testutil.GatherAndCompare(h.innerGatherer(context.Background(), "", nil), strings.NewReader(expected), "stackdriver_collector_max_concurrency_global"); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestProxyTransport(t *testing.T) {
+	var proxiedURL, proxyAuthorization string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		proxyAuthorization = r.Header.Get("Proxy-Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	transport, err := newProxyTransport(proxy.URL, "exporter", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://monitoring.googleapis.test/v3/projects/test-project/timeSeries")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if expected := "http://monitoring.googleapis.test/v3/projects/test-project/timeSeries"; proxiedURL != expected {
+		t.Errorf("expected the request to %s to be routed through the proxy, got %q", expected, proxiedURL)
+	}
+	if expected := "Basic ZXhwb3J0ZXI6c2VjcmV0"; proxyAuthorization != expected {
+		t.Errorf("expected the proxy credentials %q, got %q", expected, proxyAuthorization)
+	}
+}
+
+func TestProxyTransportEnvironment(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://env-proxy.test:3128")
+	t.Setenv("NO_PROXY", "internal.test")
+
+	tests := []struct {
+		name     string
+		proxy    string
+		target   string
+		expected string
+	}{
+		{"environment fallback", "", "https://monitoring.googleapis.com/v3/projects", "http://env-proxy.test:3128"},
+		{"environment no proxy", "", "https://metrics.internal.test/v3/projects", ""},
+		{"flag over environment", "http://flag-proxy.test:8080", "https://monitoring.googleapis.com/v3/projects", "http://flag-proxy.test:8080"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := newProxyTransport(tt.proxy, "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, tt.target, nil)
+			proxyURL, err := transport.Proxy(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			if proxyURL != nil {
+				got = proxyURL.String()
+			}
+			if got != tt.expected {
+				t.Errorf("expected proxy %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	if _, err := newProxyTransport("proxy:3128", "", ""); err == nil {
+		t.Error("expected an error for a proxy without scheme")
+	}
+}
+
+func TestMonitoringServiceUserAgent(t *testing.T) {
+	userAgents := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{})
+	}))
+	defer server.Close()
+
+	service, err := newMonitoringService(context.Background(), server.Client(), "stackdriver_exporter/1.2.3", option.WithEndpoint(server.URL+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Projects.MetricDescriptors.List("projects/my-project").Do(); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent := <-userAgents; !strings.HasSuffix(userAgent, " stackdriver_exporter/1.2.3") {
+		t.Errorf("expected the User-Agent to end with the exporter product token, got %q", userAgent)
+	}
+}
+
+func TestInternalGatherer(t *testing.T) {
+	var timeSeriesRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		value := 0.5
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+				MetricDescriptors: []*monitoring.MetricDescriptor{
+					{Type: "compute.googleapis.com/instance/cpu/utilization", MetricKind: "GAUGE", ValueType: "DOUBLE"},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			timeSeriesRequests.Add(1)
+			_ = json.NewEncoder(w).Encode(&monitoring.ListTimeSeriesResponse{
+				TimeSeries: []*monitoring.TimeSeries{{
+					Metric:     &monitoring.Metric{Type: "compute.googleapis.com/instance/cpu/utilization"},
+					Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "my-project"}},
+					MetricKind: "GAUGE",
+					ValueType:  "DOUBLE",
+					Points: []*monitoring.Point{{
+						Interval: &monitoring.TimeInterval{EndTime: time.Now().Format(time.RFC3339Nano)},
+						Value:    &monitoring.TypedValue{DoubleValue: &value},
+					}},
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	names := func(g prometheus.Gatherer) map[string]bool {
+		families, err := g.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := map[string]bool{}
+		for _, family := range families {
+			names[family.GetName()] = true
+		}
+		return names
+	}
+
+	const scraped = "stackdriver_gce_instance_compute_googleapis_com_instance_cpu_utilization"
+	if !names(h.innerGatherer(context.Background(), "", nil))[scraped] {
+		t.Fatalf("expected the scrape to report %s", scraped)
+	}
+	requests := timeSeriesRequests.Load()
+
+	internal := names(h.internalGatherer())
+	if internal[scraped] {
+		t.Errorf("expected the internal metrics not to report the scraped %s", scraped)
+	}
+	for _, name := range []string{
+		"stackdriver_monitoring_api_calls_total",
+		"stackdriver_monitoring_api_request_duration_seconds",
+		"stackdriver_monitoring_scrape_duration_seconds",
+		"stackdriver_monitoring_scrapes_total",
+		"stackdriver_collector_max_concurrency_global",
+	} {
+		if !internal[name] {
+			t.Errorf("expected the internal metrics to report %s", name)
+		}
+	}
+	if got := timeSeriesRequests.Load(); got != requests {
+		t.Errorf("expected gathering the internal metrics not to call the Monitoring API, got %d more requests", got-requests)
+	}
+
+	// A new cache drops the collector, as on expiry, the next scrapes creating another one
+	gatherer := h.internalGatherer()
+	h.collectors = collectors.NewCollectorCache(time.Hour)
+	names(h.innerGatherer(context.Background(), "", nil))
+	names(h.innerGatherer(context.Background(), "", nil))
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() == "stackdriver_monitoring_scrapes_total" {
+			if got := family.GetMetric()[0].GetCounter().GetValue(); got != 2 {
+				t.Errorf("expected the internal metrics to be those of the current collector with 2 scrapes, got %v", got)
+			}
+		}
+	}
+}
+
+func TestRefreshDescriptorsHandler(t *testing.T) {
+	defer func(ttl time.Duration) { *monitoringDescriptorCacheTTL = ttl }(*monitoringDescriptorCacheTTL)
+	*monitoringDescriptorCacheTTL = time.Hour
+
+	var descriptorRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			descriptorRequests.Add(1)
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+				MetricDescriptors: []*monitoring.MetricDescriptor{
+					{Type: "compute.googleapis.com/instance/cpu/utilization", MetricKind: "GAUGE", ValueType: "DOUBLE"},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			_ = json.NewEncoder(w).Encode(&monitoring.ListTimeSeriesResponse{})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/cpu"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	scrape := func() {
+		if _, err := h.innerGatherer(context.Background(), "", nil).Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	refresh := func(method string) int {
+		recorder := httptest.NewRecorder()
+		refreshDescriptorsHandler(h).ServeHTTP(recorder, httptest.NewRequest(method, "/-/refresh-descriptors", nil))
+		return recorder.Code
+	}
+
+	scrape()
+	scrape()
+	if got := descriptorRequests.Load(); got != 1 {
+		t.Fatalf("expected the descriptors to be cached, got %d requests", got)
+	}
+	if code := refresh(http.MethodGet); code != http.StatusMethodNotAllowed {
+		t.Errorf("expected a GET to be rejected, got %d", code)
+	}
+	if code := refresh(http.MethodPost); code != http.StatusOK {
+		t.Fatalf("expected the refresh to succeed, got %d", code)
+	}
+	scrape()
+	if got := descriptorRequests.Load(); got != 2 {
+		t.Errorf("expected the descriptors to be listed again after the refresh, got %d requests", got)
+	}
+}
+
+func TestScrapeContext(t *testing.T) {
+	defer func(maxScrapeDuration time.Duration) { *stackdriverMaxScrapeDuration = maxScrapeDuration }(*stackdriverMaxScrapeDuration)
+
+	for _, tc := range []struct {
+		maxScrapeDuration time.Duration
+		header            string
+		timeout           time.Duration
+	}{
+		{},
+		{header: "invalid"},
+		{header: "10", timeout: 10 * time.Second},
+		{maxScrapeDuration: time.Minute, timeout: time.Minute},
+		{maxScrapeDuration: time.Minute, header: "9.5", timeout: 9500 * time.Millisecond},
+		{maxScrapeDuration: 5 * time.Second, header: "10", timeout: 5 * time.Second},
+	} {
+		*stackdriverMaxScrapeDuration = tc.maxScrapeDuration
+		r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tc.header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", tc.header)
+		}
+
+		ctx, cancel := scrapeContext(r)
+		deadline, ok := ctx.Deadline()
+		if tc.timeout == 0 {
+			if ok {
+				t.Errorf("expected no deadline for %+v, got %v", tc, deadline)
+			}
+		} else if remaining := time.Until(deadline); !ok || remaining > tc.timeout || remaining < tc.timeout-time.Second {
+			t.Errorf("expected a deadline in %v for %+v, got %v", tc.timeout, tc, remaining)
+		}
+		cancel()
+		if ctx.Err() == nil {
+			t.Errorf("expected the context to be done once cancelled")
+		}
+	}
+}
+
+func TestHandlerWarmup(t *testing.T) {
+	defer func(aggregateDeltas bool, ttl time.Duration, path string) {
+		*monitoringMetricsAggregateDeltas, *monitoringMetricsDeltasTTL, *deltaPersistencePath = aggregateDeltas, ttl, path
+	}(*monitoringMetricsAggregateDeltas, *monitoringMetricsDeltasTTL, *deltaPersistencePath)
+	*monitoringMetricsAggregateDeltas = true
+	*monitoringMetricsDeltasTTL = 30 * time.Minute
+	*deltaPersistencePath = t.TempDir() + "/deltas"
+
+	const metricType = "compute.googleapis.com/instance/network/received_bytes_count"
+	var timeSeriesRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/metricDescriptors"):
+			_ = json.NewEncoder(w).Encode(&monitoring.ListMetricDescriptorsResponse{
+				MetricDescriptors: []*monitoring.MetricDescriptor{
+					{Name: metricType, Type: metricType, MetricKind: "DELTA", ValueType: "INT64"},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/timeSeries"):
+			// Every request reports a delta of 10 over a newer minute
+			request := timeSeriesRequests.Add(1)
+			endTime := time.Now().Add(time.Duration(request-10) * time.Minute)
+			value := int64(10)
+			_ = json.NewEncoder(w).Encode(&monitoring.ListTimeSeriesResponse{
+				TimeSeries: []*monitoring.TimeSeries{{
+					Metric:     &monitoring.Metric{Type: metricType},
+					Resource:   &monitoring.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": "my-project"}},
+					MetricKind: "DELTA",
+					ValueType:  "INT64",
+					Points: []*monitoring.Point{{
+						Interval: &monitoring.TimeInterval{
+							StartTime: endTime.Add(-time.Minute).Format(time.RFC3339Nano),
+							EndTime:   endTime.Format(time.RFC3339Nano),
+						},
+						Value: &monitoring.TypedValue{Int64Value: &value},
+					}},
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	service, err := monitoring.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler([]string{"my-project"}, []string{"compute.googleapis.com/instance/network"}, nil, nil, nil, nil,
+		&monitoringServices{fallback: service}, collectors.NewRetryBudget(0), promslog.NewNopLogger(), nil)
+
+	h.warmup(context.Background())
+	if got := timeSeriesRequests.Load(); got != 1 {
+		t.Fatalf("expected the warmup to collect the time series once, got %d requests", got)
+	}
+	if got := h.deltaPersistence.deltaStores("my-project-[]").counter.Len(); got != 1 {
+		t.Fatalf("expected the warmup to populate the counter store, got %d series", got)
+	}
+
+	families, err := h.innerGatherer(context.Background(), "", nil).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name = "stackdriver_gce_instance_compute_googleapis_com_instance_network_received_bytes_count"
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		if got := family.GetMetric()[0].GetCounter().GetValue(); got != 20 {
+			t.Errorf("expected the first scrape to add its delta to the warmup baseline, got %v", got)
+		}
+		return
+	}
+	t.Fatalf("expected the first scrape to report %s", name)
+}
@@ -23,7 +23,8 @@ import (
 )
 
 var (
-	safeNameRE = regexp.MustCompile(`[^a-zA-Z0-9_]*$`)
+	safeNameRE     = regexp.MustCompile(`[^a-zA-Z0-9_]*$`)
+	invalidLabelRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 )
 
 func NormalizeMetricName(metricName string) string {
@@ -41,6 +42,16 @@ func NormalizeMetricName(metricName string) string {
 	return strings.Join(normalizedMetricName, "_")
 }
 
+// SanitizeLabelName converts a label key into a valid Prometheus label name by replacing any character
+// not matching [a-zA-Z0-9_] by an underscore and prefixing a leading digit with an underscore.
+func SanitizeLabelName(labelName string) string {
+	sanitized := invalidLabelRE.ReplaceAllLiteralString(labelName, "_")
+	if sanitized != "" && sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
+
 func SplitExtraFilter(extraFilter string, separator string) (string, string) {
 	mPrefix := strings.SplitN(extraFilter, separator, 2)
 	if len(mPrefix) != 2 {
@@ -26,6 +26,23 @@ var _ = Describe("NormalizeMetricName", func() {
 	})
 })
 
+var _ = Describe("SanitizeLabelName", func() {
+	It("replaces invalid characters with underscores", func() {
+		Expect(SanitizeLabelName("version.tag")).To(Equal("version_tag"))
+		Expect(SanitizeLabelName("k8s.pod.name")).To(Equal("k8s_pod_name"))
+		Expect(SanitizeLabelName("cost-center/team")).To(Equal("cost_center_team"))
+	})
+
+	It("prefixes a leading digit with an underscore", func() {
+		Expect(SanitizeLabelName("3rd.party")).To(Equal("_3rd_party"))
+	})
+
+	It("leaves valid label names untouched", func() {
+		Expect(SanitizeLabelName("instance_id")).To(Equal("instance_id"))
+		Expect(SanitizeLabelName("")).To(Equal(""))
+	})
+})
+
 var _ = Describe("ProjectResource", func() {
 	It("returns a project resource", func() {
 		Expect(ProjectResource("fake-project-1")).To(Equal("projects/fake-project-1"))